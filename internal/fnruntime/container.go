@@ -32,6 +32,7 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	"github.com/GoogleContainerTools/kpt/internal/util/porch"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"golang.org/x/mod/semver"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 )
@@ -101,6 +102,17 @@ type ContainerFn struct {
 	FnResult *fnresult.Result
 }
 
+var _ fn.ConfigurableFunctionRunner = &ContainerFn{}
+
+// SetRunnerOptions configures the container of the function. The network sandbox disables the
+// network of the container, regardless of the permission of the function.
+func (f *ContainerFn) SetRunnerOptions(opts fn.RunnerOptions) error {
+	if opts.NetworkSandbox {
+		f.Perm.AllowNetwork = false
+	}
+	return nil
+}
+
 // Run runs the container function using docker runtime.
 // It reads the input from the given reader and writes the output
 // to the provided writer.
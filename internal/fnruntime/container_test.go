@@ -24,6 +24,7 @@ import (
 
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -75,6 +76,19 @@ func TestContainerFn(t *testing.T) {
 	}
 }
 
+func TestContainerFnNetworkSandbox(t *testing.T) {
+	instance := ContainerFn{
+		Image: "gcr.io/google-containers/busybox",
+		Perm:  ContainerFnPermission{AllowNetwork: true, AllowMount: true},
+	}
+	require.NoError(t, instance.SetRunnerOptions(fn.RunnerOptions{}))
+	assert.True(t, instance.Perm.AllowNetwork)
+
+	require.NoError(t, instance.SetRunnerOptions(fn.RunnerOptions{NetworkSandbox: true}))
+	assert.False(t, instance.Perm.AllowNetwork)
+	assert.True(t, instance.Perm.AllowMount)
+}
+
 func TestIsSupportedDockerVersion(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -329,6 +329,36 @@ func (gur *GitUpstreamRepo) getRepoDir(uri string) string {
 	return strings.ToLower(base32.StdEncoding.EncodeToString(md5.New().Sum([]byte(uri))))
 }
 
+// deepenDepths are the depths of history of the upstream refs which deepenToCommit fetches in
+// turn, before fetching the complete history.
+var deepenDepths = []int{10, 100, 1000}
+
+// deepenToCommit fetches increasingly deep history of the upstream branches and tags until it
+// contains the commit, ending with the complete history. It reports whether the commit was found.
+func deepenToCommit(ctx context.Context, gitRunner *GitLocalRunner, commit string) bool {
+	hasCommit := func() bool {
+		_, err := gitRunner.Run(ctx, "cat-file", "-e", commit+"^{commit}")
+		return err == nil
+	}
+	for _, depth := range deepenDepths {
+		if _, err := gitRunner.Run(ctx, "fetch", "origin", "--tags", fmt.Sprintf("--depth=%d", depth)); err != nil {
+			return false
+		}
+		if hasCommit() {
+			return true
+		}
+	}
+
+	args := []string{"origin", "--tags"}
+	if rr, err := gitRunner.Run(ctx, "rev-parse", "--is-shallow-repository"); err == nil && strings.TrimSpace(rr.Stdout) == "true" {
+		args = append(args, "--unshallow")
+	}
+	if _, err := gitRunner.Run(ctx, "fetch", args...); err != nil {
+		return false
+	}
+	return hasCommit()
+}
+
 // getRepoCacheDir
 func (gur *GitUpstreamRepo) getRepoCacheDir() (string, error) {
 	const op errors.Op = "gitutil.getRepoCacheDir"
@@ -409,13 +439,18 @@ loop:
 			// If the ref references a branch or a tag, or is a valid commit
 			// sha and has not already been fetched, we can fetch just a single commit.
 			if _, err := gitRunner.RunVerbose(ctx, "fetch", "origin", "--depth=1", s); err != nil {
-				AmendGitExecError(err, func(e *GitExecError) {
-					e.Repo = uri
-					e.Command = "fetch"
-					e.Ref = s
-				})
-				return "", errors.E(op, errors.Git, fmt.Errorf(
-					"error running `git fetch` for ref %q: %w", s, err))
+				// Servers may not allow fetching a commit which no ref points to, such as
+				// the commit a package was previously fetched at. Fetch deeper history of
+				// the refs until it contains the commit instead.
+				if resolved || !validFullSha || !deepenToCommit(ctx, gitRunner, s) {
+					AmendGitExecError(err, func(e *GitExecError) {
+						e.Repo = uri
+						e.Command = "fetch"
+						e.Ref = s
+					})
+					return "", errors.E(op, errors.Git, fmt.Errorf(
+						"error running `git fetch` for ref %q: %w", s, err))
+				}
 			}
 			gur.fetchedRefs[s] = true
 		default:
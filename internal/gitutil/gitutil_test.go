@@ -411,6 +411,64 @@ func TestGitUpstreamRepo_GetRepo_multipleUpdates(t *testing.T) {
 	assert.Equal(t, firstRepoDir, secondRepoDir)
 }
 
+// Verify that a commit which no ref points to can be fetched from a server
+// which does not allow fetching unadvertised commits.
+func TestGitUpstreamRepo_GetRepo_unadvertisedCommit(t *testing.T) {
+	branchName := "kpt-test"
+	repoContent := map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithResource(pkgbuilder.DeploymentResource),
+				Branch: branchName,
+			},
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithResource(pkgbuilder.ConfigMapResource),
+				Branch: branchName,
+			},
+		},
+	}
+	g, _, clean := testutil.SetupReposAndWorkspace(t, repoContent)
+	defer clean()
+
+	upstream, err := NewLocalGitRunner(g[testutil.Upstream].RepoDirectory)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	rr, err := upstream.Run(fake.CtxWithDefaultPrinter(), "rev-parse", "HEAD")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	commit := strings.TrimSpace(rr.Stdout)
+	if !assert.NoError(t, testutil.UpdateRepos(t, g, repoContent)) {
+		t.FailNow()
+	}
+
+	// Git protocol version 0 servers only allow fetching advertised refs.
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.version")
+	t.Setenv("GIT_CONFIG_VALUE_0", "0")
+
+	gur, err := NewGitUpstreamRepo(fake.CtxWithDefaultPrinter(), g[testutil.Upstream].RepoDirectory)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	dir, err := gur.GetRepo(fake.CtxWithDefaultPrinter(), []string{commit})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	runner, err := NewLocalGitRunner(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = runner.Run(fake.CtxWithDefaultPrinter(), "reset", "--hard", commit)
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "deployment.yaml"))
+	assert.NoError(t, err)
+}
+
 func getRepoAndVerify(t *testing.T, repo, branchName string) string {
 	gur, err := NewGitUpstreamRepo(fake.CtxWithDefaultPrinter(), repo)
 	if !assert.NoError(t, err) {
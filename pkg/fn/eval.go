@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 )
 
@@ -28,6 +29,58 @@ type FunctionRunner interface {
 	Run(r io.Reader, w io.Writer) error
 }
 
+// IncrementalFunctionRunner is implemented by function runners which can restrict
+// processing to resources from a subset of the package files.
+type IncrementalFunctionRunner interface {
+	FunctionRunner
+	// RunIncremental behaves like Run, but is given the files which changed since
+	// the last render so that resources from unchanged files can be skipped.
+	RunIncremental(r io.Reader, w io.Writer, changedFiles []string) error
+}
+
+// ChangedFilesEnv is the environment variable in which runners of function processes pass the
+// changed files of an incremental run to the function, separated by commas. It is not set when
+// any file may have changed.
+const ChangedFilesEnv = "KPT_CHANGED_FILES"
+
+// LoggingFunctionRunner is implemented by function runners which can report the log
+// (the standard error output) of the function.
+type LoggingFunctionRunner interface {
+	FunctionRunner
+	// RunWithLog behaves like Run, and writes the function log to log.
+	RunWithLog(r io.Reader, w io.Writer, log io.Writer) error
+}
+
+// EnvFunctionRunner is implemented by function runners which can set additional environment
+// variables of the function process.
+type EnvFunctionRunner interface {
+	FunctionRunner
+	// SetEnv sets the additional environment variables for subsequent runs. It fails if the
+	// variables cannot be passed to the function.
+	SetEnv(env map[string]string) error
+}
+
+// ResultsCallback receives the results of a function as soon as the function completes.
+type ResultsCallback func(result *fnresult.Result)
+
+// RunnerOptions configures how a runner runs its function.
+type RunnerOptions struct {
+	// NetworkSandbox runs the function without network access.
+	NetworkSandbox bool
+	// OnResults, if set, is called with the results of each function run as soon as they are
+	// available, so that progress can be reported before all functions have run.
+	OnResults ResultsCallback
+}
+
+// ConfigurableFunctionRunner is implemented by function runners which can be configured
+// with RunnerOptions.
+type ConfigurableFunctionRunner interface {
+	FunctionRunner
+	// SetRunnerOptions configures subsequent runs. It fails if the runner cannot run the
+	// function as configured.
+	SetRunnerOptions(opts RunnerOptions) error
+}
+
 // FunctionRuntime provides a way to obtain a function runner to be used for a given function configuration.
 // If the function is not found, this should return an error that includes a NotFoundError in the chain.
 type FunctionRuntime interface {
@@ -23,6 +23,15 @@ import (
 type RenderOptions struct {
 	PkgPath string
 	Runtime FunctionRuntime
+
+	// ChangedFiles optionally lists the files (relative to the filesystem root) that
+	// changed since the package was last rendered. It is a hint only; nil means that
+	// any file may have changed.
+	ChangedFiles []string
+
+	// ResultsDirPath is the directory within the filesystem where the function results
+	// are written. If empty, the results are not saved.
+	ResultsDirPath string
 }
 
 type Renderer interface {
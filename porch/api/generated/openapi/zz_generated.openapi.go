@@ -29,6 +29,7 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
+		"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.DirectoryPackage":             schema_porch_api_porch_v1alpha1_DirectoryPackage(ref),
 		"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.Function":                     schema_porch_api_porch_v1alpha1_Function(ref),
 		"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.FunctionConfig":               schema_porch_api_porch_v1alpha1_FunctionConfig(ref),
 		"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.FunctionEvalTaskSpec":         schema_porch_api_porch_v1alpha1_FunctionEvalTaskSpec(ref),
@@ -112,6 +113,28 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 	}
 }
 
+func schema_porch_api_porch_v1alpha1_DirectoryPackage(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DirectoryPackage describes a package in a directory on the local file system of the Porch server.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"path": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Path of the package directory, relative to the local upstream root directory configured on the Porch server.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"path"},
+			},
+		},
+	}
+}
+
 func schema_porch_api_porch_v1alpha1_Function(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -271,6 +294,79 @@ func schema_porch_api_porch_v1alpha1_FunctionEvalTaskSpec(ref common.ReferenceCa
 							Ref:         ref("github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.Selector"),
 						},
 					},
+					"continueOnEmptyResult": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`ContinueOnEmptyResult` accepts a function output with no resources, for functions which legitimately remove all resources. By default, such an output fails the evaluation.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"when": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`When` makes the function evaluation conditional: the function runs only if at least one resource in the package matches the selector. If unspecified, the function always runs.",
+							Ref:         ref("github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.Selector"),
+						},
+					},
+					"mountedFiles": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`MountedFiles` are read-only supplementary files, such as CA bundles or policy sets, made available to the function alongside the package resources under the reserved `.porch/mounts/` directory. Keys are file paths relative to that directory. Mounted files are fed to the function like package files and are never part of the package output.",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"env": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`Env` sets additional environment variables of the function process. Variables reserved by the function runtime, such as `PATH` and `HOME`, and names prefixed with `KPT_` or `PORCH_` are rejected. Functions run in-process do not support environment variables.",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"mergedView": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`MergedView` runs the function as a validator over the merged resources of the package at `Subpackage` and all of its subpackages, so that it can detect conflicts across them. The output of the function is discarded and the package is left unchanged. Its results are tagged with the subpackage of the resource or file they refer to.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"skipped": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`Skipped` is set by Porch on the recorded task when the function was not run because no resource matched `When`.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"attempts": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`Attempts` is set by Porch on the recorded task to the number of times the function was run when a retry policy is configured.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"log": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`Log` is set by Porch on the recorded task to the log the function wrote when it succeeded, truncated to its last 4KiB.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 				},
 			},
 		},
@@ -430,12 +526,40 @@ func schema_porch_api_porch_v1alpha1_FunctionSpec(ref common.ReferenceCallback)
 							Format:      "",
 						},
 					},
+					"inputTypes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "InputTypes specifies to which input KRM types the function applies. Specified as Group Version Kind. For example:\n\n   inputTypes:\n   - kind: RoleBinding\n     # If version is unspecified, applies to all versions\n     apiVersion: rbac.authorization.k8s.io\n   - kind: ClusterRoleBinding\n     apiVersion: rbac.authorization.k8s.io/v1",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("k8s.io/apimachinery/pkg/apis/meta/v1.TypeMeta"),
+									},
+								},
+							},
+						},
+					},
+					"outputTypes": {
+						SchemaProps: spec.SchemaProps{
+							Description: "OutputTypes specifies types of any KRM resources the function creates For example:\n\n    outputTypes:\n    - kind: ConfigMap\n      apiVersion: v1",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("k8s.io/apimachinery/pkg/apis/meta/v1.TypeMeta"),
+									},
+								},
+							},
+						},
+					},
 				},
 				Required: []string{"image", "repositoryRef", "description"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.FunctionConfig", "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.RepositoryRef"},
+			"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.FunctionConfig", "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.RepositoryRef", "k8s.io/apimachinery/pkg/apis/meta/v1.TypeMeta"},
 	}
 }
 
@@ -467,7 +591,13 @@ func schema_porch_api_porch_v1alpha1_GitPackage(ref common.ReferenceCallback) co
 					"ref": {
 						SchemaProps: spec.SchemaProps{
 							Description: "`Ref` is the git ref containing the package. Ref can be a branch, tag, or commit SHA.",
-							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"branch": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`Branch` is the default branch of the repository, used as the ref when `Ref` is unspecified. If unspecified, defaults to \"main\".",
 							Type:        []string{"string"},
 							Format:      "",
 						},
@@ -480,6 +610,13 @@ func schema_porch_api_porch_v1alpha1_GitPackage(ref common.ReferenceCallback) co
 							Format:      "",
 						},
 					},
+					"commit": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`Commit` is the SHA of the upstream commit the package was cloned from. It is recorded by Porch and used as the common ancestor when the package is updated to a different `Ref`.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 					"secretRef": {
 						SchemaProps: spec.SchemaProps{
 							Description: "Reference to secret containing authentication credentials. Optional.",
@@ -487,8 +624,22 @@ func schema_porch_api_porch_v1alpha1_GitPackage(ref common.ReferenceCallback) co
 							Ref:         ref("github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.SecretRef"),
 						},
 					},
+					"verifiedSigner": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`VerifiedSigner` is the identity of the key which signed the cloned commit. It is recorded by Porch when upstream signature verification is enabled and should not be set by clients.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"pinnedCommit": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`PinnedCommit` is the full SHA of the commit `Ref` is expected to point at. If set, the clone fails if `Ref` resolves to a different commit, for example because the branch has moved.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 				},
-				Required: []string{"repo", "ref", "directory"},
+				Required: []string{"repo", "directory"},
 			},
 		},
 		Dependencies: []string{
@@ -538,6 +689,52 @@ func schema_porch_api_porch_v1alpha1_PackageCloneTaskSpec(ref common.ReferenceCa
 							Format:      "",
 						},
 					},
+					"extraFiles": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`ExtraFiles` are additional files, keyed by path relative to the package, which are added to the package after it is cloned and before it is rendered. They must not collide with files of the upstream package.",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"include": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`Include` are glob patterns, as of path.Match, selecting the files of the upstream package which are retained; a pattern which matches a directory selects all files under it. If empty, all files are retained. The Kptfile of the package is always retained.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"exclude": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`Exclude` are glob patterns, as `Include`, of files of the upstream package which are not retained, even if they match `Include`.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
 				},
 			},
 		},
@@ -753,6 +950,25 @@ func schema_porch_api_porch_v1alpha1_PackageRevisionRef(ref common.ReferenceCall
 							Format:      "",
 						},
 					},
+					"repository": {
+						SchemaProps: spec.SchemaProps{
+							Description: "`Repository`, `Package` and `Revision` are set by Porch, when it resolves the reference of a clone task, to the repository, package name and revision of the referenced package revision, for provenance.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"package": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"revision": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
 				},
 				Required: []string{"name"},
 			},
@@ -894,6 +1110,13 @@ func schema_porch_api_porch_v1alpha1_PackageRevisionResourcesSpec(ref common.Ref
 							},
 						},
 					},
+					"subpackage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Subpackage, on update, limits the update to the files under the subpackage directory. Resources then contain only files under the directory, and files outside of it are kept unchanged.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 				},
 			},
 		},
@@ -1187,11 +1410,17 @@ func schema_porch_api_porch_v1alpha1_UpstreamPackage(ref common.ReferenceCallbac
 							Ref:         ref("github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.PackageRevisionRef"),
 						},
 					},
+					"directory": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Directory upstream package specification, for development. Required if `type` is `directory`. Must be unspecified if `type` is not `directory`.",
+							Ref:         ref("github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.DirectoryPackage"),
+						},
+					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.GitPackage", "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.OciPackage", "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.PackageRevisionRef"},
+			"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.DirectoryPackage", "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.GitPackage", "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.OciPackage", "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1.PackageRevisionRef"},
 	}
 }
 
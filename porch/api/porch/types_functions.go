@@ -80,7 +80,7 @@ type FunctionSpec struct {
 	//      apiVersion: rbac.authorization.k8s.io
 	//    - kind: ClusterRoleBinding
 	//      apiVersion: rbac.authorization.k8s.io/v1
-	// InputTypes []metav1.TypeMeta
+	InputTypes []metav1.TypeMeta `json:"inputTypes,omitempty"`
 
 	// OutputTypes specifies types of any KRM resources the function creates
 	// For example:
@@ -88,8 +88,7 @@ type FunctionSpec struct {
 	//     outputTypes:
 	//     - kind: ConfigMap
 	//       apiVersion: v1
-	// OutputTypes []metav1.TypeMeta
-
+	OutputTypes []metav1.TypeMeta `json:"outputTypes,omitempty"`
 }
 
 // FunctionConfig specifies all the valid types of the function config for this function.
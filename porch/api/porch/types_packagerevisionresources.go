@@ -53,4 +53,9 @@ type PackageRevisionResourcesSpec struct {
 
 	// Resources are the content of the package.
 	Resources map[string]string `json:"resources,omitempty"`
+
+	// Subpackage, on update, limits the update to the files under the subpackage directory.
+	// Resources then contain only files under the directory, and files outside of it are kept
+	// unchanged.
+	Subpackage string `json:"subpackage,omitempty"`
 }
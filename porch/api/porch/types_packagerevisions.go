@@ -126,6 +126,20 @@ type PackageCloneTaskSpec struct {
 	//  * force-delete-replace: Wipe all the local changes to the package and replace
 	//    it with the remote version.
 	Strategy PackageMergeStrategy `json:"strategy,omitempty"`
+
+	// `ExtraFiles` are additional files, keyed by path relative to the package, which are added
+	// to the package after it is cloned and before it is rendered. They must not collide with
+	// files of the upstream package.
+	ExtraFiles map[string]string `json:"extraFiles,omitempty"`
+
+	// `Include` are glob patterns, as of path.Match, selecting the files of the upstream package
+	// which are retained; a pattern which matches a directory selects all files under it. If
+	// empty, all files are retained. The Kptfile of the package is always retained.
+	Include []string `json:"include,omitempty"`
+
+	// `Exclude` are glob patterns, as `Include`, of files of the upstream package which are not
+	// retained, even if they match `Include`.
+	Exclude []string `json:"exclude,omitempty"`
 }
 
 type PackageMergeStrategy string
@@ -164,6 +178,8 @@ type RepositoryType string
 const (
 	RepositoryTypeGit RepositoryType = "git"
 	RepositoryTypeOCI RepositoryType = "oci"
+	// RepositoryTypeDirectory is an upstream package in a directory on the local file system of the Porch server.
+	RepositoryTypeDirectory RepositoryType = "directory"
 )
 
 // UpstreamRepository repository may be specified directly or by referencing another Repository resource.
@@ -179,6 +195,9 @@ type UpstreamPackage struct {
 
 	// UpstreamRef is the reference to the package from a registered repository rather than external package.
 	UpstreamRef *PackageRevisionRef `json:"upstreamRef,omitempty"`
+
+	// Directory upstream package specification, for development. Required if `type` is `directory`. Must be unspecified if `type` is not `directory`.
+	Directory *DirectoryPackage `json:"directory,omitempty"`
 }
 
 type GitPackage struct {
@@ -187,13 +206,29 @@ type GitPackage struct {
 	Repo string `json:"repo"`
 
 	// `Ref` is the git ref containing the package. Ref can be a branch, tag, or commit SHA.
-	Ref string `json:"ref"`
+	Ref string `json:"ref,omitempty"`
+
+	// `Branch` is the default branch of the repository, used as the ref when `Ref` is unspecified.
+	// If unspecified, defaults to "main".
+	Branch string `json:"branch,omitempty"`
 
 	// Directory within the Git repository where the packages are stored. A subdirectory of this directory containing a Kptfile is considered a package.
 	Directory string `json:"directory"`
 
+	// `Commit` is the SHA of the upstream commit the package was cloned from. It is recorded by Porch
+	// and used as the common ancestor when the package is updated to a different `Ref`.
+	Commit string `json:"commit,omitempty"`
+
 	// Reference to secret containing authentication credentials. Optional.
 	SecretRef SecretRef `json:"secretRef,omitempty"`
+
+	// `VerifiedSigner` is the identity of the key which signed the cloned commit. It is recorded by Porch
+	// when upstream signature verification is enabled and should not be set by clients.
+	VerifiedSigner string `json:"verifiedSigner,omitempty"`
+
+	// `PinnedCommit` is the full SHA of the commit `Ref` is expected to point at. If set, the clone
+	// fails if `Ref` resolves to a different commit, for example because the branch has moved.
+	PinnedCommit string `json:"pinnedCommit,omitempty"`
 }
 
 type SecretRef struct {
@@ -207,10 +242,23 @@ type OciPackage struct {
 	Image string `json:"image"`
 }
 
+// DirectoryPackage describes a package in a directory on the local file system of the Porch server.
+type DirectoryPackage struct {
+	// Path of the package directory, relative to the local upstream root directory configured on the Porch server.
+	Path string `json:"path"`
+}
+
 // PackageRevisionRef is a reference to a package revision.
 type PackageRevisionRef struct {
 	// `Name` is the name of the referenced PackageRevision resource.
 	Name string `json:"name"`
+
+	// `Repository`, `Package` and `Revision` are set by Porch, when it resolves the reference of a
+	// clone task, to the repository, package name and revision of the referenced package
+	// revision, for provenance.
+	Repository string `json:"repository,omitempty"`
+	Package    string `json:"package,omitempty"`
+	Revision   string `json:"revision,omitempty"`
 }
 
 // RepositoryRef identifies a reference to a Repository resource.
@@ -239,6 +287,35 @@ type FunctionEvalTaskSpec struct {
 	EnableNetwork bool `json:"enableNetwork,omitempty"`
 	// Match specifies the selection criteria for the function evaluation.
 	Match Selector `json:"match,omitempty"`
+	// `ContinueOnEmptyResult` accepts a function output with no resources, for functions which
+	// legitimately remove all resources. By default, such an output fails the evaluation.
+	ContinueOnEmptyResult bool `json:"continueOnEmptyResult,omitempty"`
+	// `When` makes the function evaluation conditional: the function runs only if at least one
+	// resource in the package matches the selector. If unspecified, the function always runs.
+	When *Selector `json:"when,omitempty"`
+	// `MountedFiles` are read-only supplementary files, such as CA bundles or policy sets, made
+	// available to the function alongside the package resources under the reserved
+	// `.porch/mounts/` directory. Keys are file paths relative to that directory. Mounted files
+	// are fed to the function like package files and are never part of the package output.
+	MountedFiles map[string]string `json:"mountedFiles,omitempty"`
+	// `Env` sets additional environment variables of the function process. Variables reserved by
+	// the function runtime, such as `PATH` and `HOME`, and names prefixed with `KPT_` or `PORCH_`
+	// are rejected. Functions run in-process do not support environment variables.
+	Env map[string]string `json:"env,omitempty"`
+	// `MergedView` runs the function as a validator over the merged resources of the package at
+	// `Subpackage` and all of its subpackages, so that it can detect conflicts across them. The
+	// output of the function is discarded and the package is left unchanged. Its results are
+	// tagged with the subpackage of the resource or file they refer to.
+	MergedView bool `json:"mergedView,omitempty"`
+	// `Skipped` is set by Porch on the recorded task when the function was not run because no
+	// resource matched `When`.
+	Skipped bool `json:"skipped,omitempty"`
+	// `Attempts` is set by Porch on the recorded task to the number of times the function was run
+	// when a retry policy is configured.
+	Attempts int32 `json:"attempts,omitempty"`
+	// `Log` is set by Porch on the recorded task to the log the function wrote when it succeeded,
+	// truncated to its last 4KiB.
+	Log string `json:"log,omitempty"`
 }
 
 type Selector struct {
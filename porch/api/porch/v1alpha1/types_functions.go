@@ -47,6 +47,8 @@ type FunctionType string
 const (
 	FunctionTypeValidator FunctionType = "validator"
 	FunctionTypeMutator   FunctionType = "mutator"
+	// FunctionTypeUnspecified classifies functions whose types are not known.
+	FunctionTypeUnspecified FunctionType = "unspecified"
 )
 
 // FunctionSpec defines the desired state of a Function
@@ -80,7 +82,7 @@ type FunctionSpec struct {
 	//      apiVersion: rbac.authorization.k8s.io
 	//    - kind: ClusterRoleBinding
 	//      apiVersion: rbac.authorization.k8s.io/v1
-	// InputTypes []metav1.TypeMeta
+	InputTypes []metav1.TypeMeta `json:"inputTypes,omitempty"`
 
 	// OutputTypes specifies types of any KRM resources the function creates
 	// For example:
@@ -88,8 +90,7 @@ type FunctionSpec struct {
 	//     outputTypes:
 	//     - kind: ConfigMap
 	//       apiVersion: v1
-	// OutputTypes []metav1.TypeMeta
-
+	OutputTypes []metav1.TypeMeta `json:"outputTypes,omitempty"`
 }
 
 // FunctionConfig specifies all the valid types of the function config for this function.
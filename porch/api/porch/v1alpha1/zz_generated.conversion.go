@@ -23,6 +23,7 @@ import (
 	unsafe "unsafe"
 
 	porch "github.com/GoogleContainerTools/kpt/porch/api/porch"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	conversion "k8s.io/apimachinery/pkg/conversion"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -34,6 +35,16 @@ func init() {
 // RegisterConversions adds conversion functions to the given scheme.
 // Public to allow building arbitrary schemes.
 func RegisterConversions(s *runtime.Scheme) error {
+	if err := s.AddGeneratedConversionFunc((*DirectoryPackage)(nil), (*porch.DirectoryPackage)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_DirectoryPackage_To_porch_DirectoryPackage(a.(*DirectoryPackage), b.(*porch.DirectoryPackage), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*porch.DirectoryPackage)(nil), (*DirectoryPackage)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_porch_DirectoryPackage_To_v1alpha1_DirectoryPackage(a.(*porch.DirectoryPackage), b.(*DirectoryPackage), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddGeneratedConversionFunc((*Function)(nil), (*porch.Function)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha1_Function_To_porch_Function(a.(*Function), b.(*porch.Function), scope)
 	}); err != nil {
@@ -317,6 +328,26 @@ func RegisterConversions(s *runtime.Scheme) error {
 	return nil
 }
 
+func autoConvert_v1alpha1_DirectoryPackage_To_porch_DirectoryPackage(in *DirectoryPackage, out *porch.DirectoryPackage, s conversion.Scope) error {
+	out.Path = in.Path
+	return nil
+}
+
+// Convert_v1alpha1_DirectoryPackage_To_porch_DirectoryPackage is an autogenerated conversion function.
+func Convert_v1alpha1_DirectoryPackage_To_porch_DirectoryPackage(in *DirectoryPackage, out *porch.DirectoryPackage, s conversion.Scope) error {
+	return autoConvert_v1alpha1_DirectoryPackage_To_porch_DirectoryPackage(in, out, s)
+}
+
+func autoConvert_porch_DirectoryPackage_To_v1alpha1_DirectoryPackage(in *porch.DirectoryPackage, out *DirectoryPackage, s conversion.Scope) error {
+	out.Path = in.Path
+	return nil
+}
+
+// Convert_porch_DirectoryPackage_To_v1alpha1_DirectoryPackage is an autogenerated conversion function.
+func Convert_porch_DirectoryPackage_To_v1alpha1_DirectoryPackage(in *porch.DirectoryPackage, out *DirectoryPackage, s conversion.Scope) error {
+	return autoConvert_porch_DirectoryPackage_To_v1alpha1_DirectoryPackage(in, out, s)
+}
+
 func autoConvert_v1alpha1_Function_To_porch_Function(in *Function, out *porch.Function, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha1_FunctionSpec_To_porch_FunctionSpec(&in.Spec, &out.Spec, s); err != nil {
@@ -380,6 +411,14 @@ func autoConvert_v1alpha1_FunctionEvalTaskSpec_To_porch_FunctionEvalTaskSpec(in
 	if err := Convert_v1alpha1_Selector_To_porch_Selector(&in.Match, &out.Match, s); err != nil {
 		return err
 	}
+	out.ContinueOnEmptyResult = in.ContinueOnEmptyResult
+	out.When = (*porch.Selector)(unsafe.Pointer(in.When))
+	out.MountedFiles = *(*map[string]string)(unsafe.Pointer(&in.MountedFiles))
+	out.Env = *(*map[string]string)(unsafe.Pointer(&in.Env))
+	out.MergedView = in.MergedView
+	out.Skipped = in.Skipped
+	out.Attempts = in.Attempts
+	out.Log = in.Log
 	return nil
 }
 
@@ -399,6 +438,14 @@ func autoConvert_porch_FunctionEvalTaskSpec_To_v1alpha1_FunctionEvalTaskSpec(in
 	if err := Convert_porch_Selector_To_v1alpha1_Selector(&in.Match, &out.Match, s); err != nil {
 		return err
 	}
+	out.ContinueOnEmptyResult = in.ContinueOnEmptyResult
+	out.When = (*Selector)(unsafe.Pointer(in.When))
+	out.MountedFiles = *(*map[string]string)(unsafe.Pointer(&in.MountedFiles))
+	out.Env = *(*map[string]string)(unsafe.Pointer(&in.Env))
+	out.MergedView = in.MergedView
+	out.Skipped = in.Skipped
+	out.Attempts = in.Attempts
+	out.Log = in.Log
 	return nil
 }
 
@@ -459,6 +506,8 @@ func autoConvert_v1alpha1_FunctionSpec_To_porch_FunctionSpec(in *FunctionSpec, o
 	out.Keywords = *(*[]string)(unsafe.Pointer(&in.Keywords))
 	out.Description = in.Description
 	out.DocumentationUrl = in.DocumentationUrl
+	out.InputTypes = *(*[]v1.TypeMeta)(unsafe.Pointer(&in.InputTypes))
+	out.OutputTypes = *(*[]v1.TypeMeta)(unsafe.Pointer(&in.OutputTypes))
 	return nil
 }
 
@@ -477,6 +526,8 @@ func autoConvert_porch_FunctionSpec_To_v1alpha1_FunctionSpec(in *porch.FunctionS
 	out.Keywords = *(*[]string)(unsafe.Pointer(&in.Keywords))
 	out.Description = in.Description
 	out.DocumentationUrl = in.DocumentationUrl
+	out.InputTypes = *(*[]v1.TypeMeta)(unsafe.Pointer(&in.InputTypes))
+	out.OutputTypes = *(*[]v1.TypeMeta)(unsafe.Pointer(&in.OutputTypes))
 	return nil
 }
 
@@ -506,10 +557,14 @@ func Convert_porch_FunctionStatus_To_v1alpha1_FunctionStatus(in *porch.FunctionS
 func autoConvert_v1alpha1_GitPackage_To_porch_GitPackage(in *GitPackage, out *porch.GitPackage, s conversion.Scope) error {
 	out.Repo = in.Repo
 	out.Ref = in.Ref
+	out.Branch = in.Branch
 	out.Directory = in.Directory
+	out.Commit = in.Commit
 	if err := Convert_v1alpha1_SecretRef_To_porch_SecretRef(&in.SecretRef, &out.SecretRef, s); err != nil {
 		return err
 	}
+	out.VerifiedSigner = in.VerifiedSigner
+	out.PinnedCommit = in.PinnedCommit
 	return nil
 }
 
@@ -521,10 +576,14 @@ func Convert_v1alpha1_GitPackage_To_porch_GitPackage(in *GitPackage, out *porch.
 func autoConvert_porch_GitPackage_To_v1alpha1_GitPackage(in *porch.GitPackage, out *GitPackage, s conversion.Scope) error {
 	out.Repo = in.Repo
 	out.Ref = in.Ref
+	out.Branch = in.Branch
 	out.Directory = in.Directory
+	out.Commit = in.Commit
 	if err := Convert_porch_SecretRef_To_v1alpha1_SecretRef(&in.SecretRef, &out.SecretRef, s); err != nil {
 		return err
 	}
+	out.VerifiedSigner = in.VerifiedSigner
+	out.PinnedCommit = in.PinnedCommit
 	return nil
 }
 
@@ -558,6 +617,9 @@ func autoConvert_v1alpha1_PackageCloneTaskSpec_To_porch_PackageCloneTaskSpec(in
 		return err
 	}
 	out.Strategy = porch.PackageMergeStrategy(in.Strategy)
+	out.ExtraFiles = *(*map[string]string)(unsafe.Pointer(&in.ExtraFiles))
+	out.Include = *(*[]string)(unsafe.Pointer(&in.Include))
+	out.Exclude = *(*[]string)(unsafe.Pointer(&in.Exclude))
 	return nil
 }
 
@@ -571,6 +633,9 @@ func autoConvert_porch_PackageCloneTaskSpec_To_v1alpha1_PackageCloneTaskSpec(in
 		return err
 	}
 	out.Strategy = PackageMergeStrategy(in.Strategy)
+	out.ExtraFiles = *(*map[string]string)(unsafe.Pointer(&in.ExtraFiles))
+	out.Include = *(*[]string)(unsafe.Pointer(&in.Include))
+	out.Exclude = *(*[]string)(unsafe.Pointer(&in.Exclude))
 	return nil
 }
 
@@ -701,6 +766,9 @@ func Convert_porch_PackageRevisionList_To_v1alpha1_PackageRevisionList(in *porch
 
 func autoConvert_v1alpha1_PackageRevisionRef_To_porch_PackageRevisionRef(in *PackageRevisionRef, out *porch.PackageRevisionRef, s conversion.Scope) error {
 	out.Name = in.Name
+	out.Repository = in.Repository
+	out.Package = in.Package
+	out.Revision = in.Revision
 	return nil
 }
 
@@ -711,6 +779,9 @@ func Convert_v1alpha1_PackageRevisionRef_To_porch_PackageRevisionRef(in *Package
 
 func autoConvert_porch_PackageRevisionRef_To_v1alpha1_PackageRevisionRef(in *porch.PackageRevisionRef, out *PackageRevisionRef, s conversion.Scope) error {
 	out.Name = in.Name
+	out.Repository = in.Repository
+	out.Package = in.Package
+	out.Revision = in.Revision
 	return nil
 }
 
@@ -772,6 +843,7 @@ func autoConvert_v1alpha1_PackageRevisionResourcesSpec_To_porch_PackageRevisionR
 	out.Revision = in.Revision
 	out.RepositoryName = in.RepositoryName
 	out.Resources = *(*map[string]string)(unsafe.Pointer(&in.Resources))
+	out.Subpackage = in.Subpackage
 	return nil
 }
 
@@ -785,6 +857,7 @@ func autoConvert_porch_PackageRevisionResourcesSpec_To_v1alpha1_PackageRevisionR
 	out.Revision = in.Revision
 	out.RepositoryName = in.RepositoryName
 	out.Resources = *(*map[string]string)(unsafe.Pointer(&in.Resources))
+	out.Subpackage = in.Subpackage
 	return nil
 }
 
@@ -986,6 +1059,7 @@ func autoConvert_v1alpha1_UpstreamPackage_To_porch_UpstreamPackage(in *UpstreamP
 	out.Git = (*porch.GitPackage)(unsafe.Pointer(in.Git))
 	out.Oci = (*porch.OciPackage)(unsafe.Pointer(in.Oci))
 	out.UpstreamRef = (*porch.PackageRevisionRef)(unsafe.Pointer(in.UpstreamRef))
+	out.Directory = (*porch.DirectoryPackage)(unsafe.Pointer(in.Directory))
 	return nil
 }
 
@@ -999,6 +1073,7 @@ func autoConvert_porch_UpstreamPackage_To_v1alpha1_UpstreamPackage(in *porch.Ups
 	out.Git = (*GitPackage)(unsafe.Pointer(in.Git))
 	out.Oci = (*OciPackage)(unsafe.Pointer(in.Oci))
 	out.UpstreamRef = (*PackageRevisionRef)(unsafe.Pointer(in.UpstreamRef))
+	out.Directory = (*DirectoryPackage)(unsafe.Pointer(in.Directory))
 	return nil
 }
 
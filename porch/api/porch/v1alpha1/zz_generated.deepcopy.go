@@ -20,9 +20,26 @@
 package v1alpha1
 
 import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DirectoryPackage) DeepCopyInto(out *DirectoryPackage) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DirectoryPackage.
+func (in *DirectoryPackage) DeepCopy() *DirectoryPackage {
+	if in == nil {
+		return nil
+	}
+	out := new(DirectoryPackage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Function) DeepCopyInto(out *Function) {
 	*out = *in
@@ -90,6 +107,25 @@ func (in *FunctionEvalTaskSpec) DeepCopyInto(out *FunctionEvalTaskSpec) {
 	}
 	in.Config.DeepCopyInto(&out.Config)
 	out.Match = in.Match
+	if in.When != nil {
+		in, out := &in.When, &out.When
+		*out = new(Selector)
+		**out = **in
+	}
+	if in.MountedFiles != nil {
+		in, out := &in.MountedFiles, &out.MountedFiles
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -173,6 +209,16 @@ func (in *FunctionSpec) DeepCopyInto(out *FunctionSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.InputTypes != nil {
+		in, out := &in.InputTypes, &out.InputTypes
+		*out = make([]v1.TypeMeta, len(*in))
+		copy(*out, *in)
+	}
+	if in.OutputTypes != nil {
+		in, out := &in.OutputTypes, &out.OutputTypes
+		*out = make([]v1.TypeMeta, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -239,6 +285,23 @@ func (in *OciPackage) DeepCopy() *OciPackage {
 func (in *PackageCloneTaskSpec) DeepCopyInto(out *PackageCloneTaskSpec) {
 	*out = *in
 	in.Upstream.DeepCopyInto(&out.Upstream)
+	if in.ExtraFiles != nil {
+		in, out := &in.ExtraFiles, &out.ExtraFiles
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -658,6 +721,11 @@ func (in *UpstreamPackage) DeepCopyInto(out *UpstreamPackage) {
 		*out = new(PackageRevisionRef)
 		**out = **in
 	}
+	if in.Directory != nil {
+		in, out := &in.Directory, &out.Directory
+		*out = new(DirectoryPackage)
+		**out = **in
+	}
 	return
 }
 
@@ -84,6 +84,27 @@ type RepositorySpec struct {
 	// Based on the Kubernetest Admission Controllers (https://kubernetes.io/docs/reference/access-authn-authz/admission-controllers/). The functions will be evaluated
 	// in the order specified in the list.
 	Validators []FunctionEval `json:"validators,omitempty"`
+
+	// `Retention` limits how many published revisions of each package are retained.
+	// If unspecified, all published revisions are retained.
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+
+	// `DisableLatestRevision` disables computing the latest revision of each package. If true,
+	// package revisions in this repository are never labeled as latest.
+	DisableLatestRevision bool `json:"disableLatestRevision,omitempty"`
+
+	// `RevisionComparator` names the comparator which orders the revisions of packages in this repository.
+	// Comparators are registered with the Porch server. If unspecified, revisions are ordered as semantic versions.
+	RevisionComparator string `json:"revisionComparator,omitempty"`
+
+	// `MinimumLatestRevisions` maps package names to the lowest revision of the package which may be
+	// labeled as latest. Published revisions below the minimum are still listed, but are never selected
+	// as the latest revision of their package.
+	MinimumLatestRevisions map[string]string `json:"minimumLatestRevisions,omitempty"`
+
+	// `CacheDisabled` disables caching of the repository content, for debugging. If true, every read of
+	// package revisions and functions goes to the backend and the repository is not polled.
+	CacheDisabled bool `json:"cacheDisabled,omitempty"`
 }
 
 // GitRepository describes a Git repository.
@@ -122,6 +143,13 @@ type UpstreamRepository struct {
 	RepositoryRef *RepositoryRef `json:"repositoryRef,omitempty"`
 }
 
+// RetentionPolicy describes which published package revisions are retained.
+type RetentionPolicy struct {
+	// Maximum number of the most recent published revisions retained for each package. Older published
+	// revisions become candidates for deletion. If zero, all published revisions are retained.
+	MaxPublishedRevisions int `json:"maxPublishedRevisions,omitempty"`
+}
+
 // RepositoryRef identifies a reference to a Repository resource.
 type RepositoryRef struct {
 	// Name of the Repository resource referenced.
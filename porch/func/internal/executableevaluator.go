@@ -25,6 +25,7 @@ import (
 	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	pb "github.com/GoogleContainerTools/kpt/porch/func/evaluator"
+	"github.com/GoogleContainerTools/kpt/porch/func/internal/funcenv"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"gopkg.in/yaml.v2"
@@ -82,7 +83,9 @@ func NewExecutableEvaluator(functions string, config string) (Evaluator, error)
 
 func (e *executableEvaluator) EvaluateFunction(ctx context.Context, req *pb.EvaluateFunctionRequest) (*pb.EvaluateFunctionResponse, error) {
 	binary, cached := e.cache[req.Image]
-	if !cached {
+	// Executables run in the process of the evaluator, which has network access; sandboxed functions
+	// are left to the evaluators which can isolate them.
+	if !cached || req.NetworkSandbox {
 		return nil, &fn.NotFoundError{
 			Function: v1.Function{Image: req.Image},
 		}
@@ -94,6 +97,13 @@ func (e *executableEvaluator) EvaluateFunction(ctx context.Context, req *pb.Eval
 	cmd.Stdin = bytes.NewReader(req.ResourceList)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	if len(req.Env) > 0 {
+		env, err := funcenv.Environ(req.Env)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid environment of function %q: %s", req.Image, err)
+		}
+		cmd.Env = env
+	}
 
 	if err := cmd.Run(); err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to execute function %q: %s (%s)", req.Image, err, stderr.String())
@@ -0,0 +1,42 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package funcenv builds the environment of function processes run by the function evaluators.
+package funcenv
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Environ returns the environment of the function process: the environment of the evaluator
+// with the requested variables added, in a stable order. The requested variables cannot override
+// variables of the evaluator environment, since the function process relies on them.
+func Environ(env map[string]string) ([]string, error) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		if _, found := os.LookupEnv(name); found {
+			return nil, fmt.Errorf("environment variable %q is already set in the function environment", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := os.Environ()
+	for _, name := range names {
+		result = append(result, name+"="+env[name])
+	}
+	return result, nil
+}
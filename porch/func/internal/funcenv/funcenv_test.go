@@ -0,0 +1,47 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcenv
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnviron(t *testing.T) {
+	t.Setenv("FUNCENV_TEST_EXISTING", "evaluator")
+
+	got, err := Environ(map[string]string{"B_VAR": "b", "A_VAR": "a=1"})
+	if err != nil {
+		t.Fatalf("Environ failed: %v", err)
+	}
+	want := append(os.Environ(), "A_VAR=a=1", "B_VAR=b")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected environment (-want, +got): %s", diff)
+	}
+
+	if _, err := Environ(map[string]string{"FUNCENV_TEST_EXISTING": "function"}); err == nil {
+		t.Errorf("Environ overriding an evaluator variable succeeded unexpectedly")
+	}
+
+	got, err = Environ(nil)
+	if err != nil {
+		t.Fatalf("Environ failed: %v", err)
+	}
+	if diff := cmp.Diff(os.Environ(), got); diff != "" {
+		t.Errorf("Unexpected environment without variables (-want, +got): %s", diff)
+	}
+}
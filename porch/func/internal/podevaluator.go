@@ -33,9 +33,12 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -51,6 +54,10 @@ const (
 	krmFunctionLabel         = "fn.kpt.dev/image"
 	reclaimAfterAnnotation   = "fn.kpt.dev/reclaim-after"
 	fieldManagerName         = "krm-function-runner"
+	// networkSandboxLabel marks the pods of functions which run without network access.
+	networkSandboxLabel = "fn.kpt.dev/network-sandbox"
+	// networkSandboxPolicyName is the name of the NetworkPolicy isolating network sandbox pods.
+	networkSandboxPolicyName = "function-network-sandbox"
 
 	channelBufferSize = 128
 )
@@ -63,7 +70,60 @@ type podEvaluator struct {
 
 var _ Evaluator = &podEvaluator{}
 
-func NewPodEvaluator(namespace, wrapperServerImage string, interval, ttl time.Duration, podTTLConfig string) (Evaluator, error) {
+// PodResources are the compute resource requests and limits of function containers.
+// Empty values are not set. They are configured for the function runner as a whole and
+// apply to every function pod; they do not apply to the other function runtimes.
+type PodResources struct {
+	CPURequest    string
+	CPULimit      string
+	MemoryRequest string
+	MemoryLimit   string
+}
+
+// ResourceRequirements validates the resources and converts them to container resource requirements.
+func (r PodResources) ResourceRequirements() (corev1.ResourceRequirements, error) {
+	requirements := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
+	}
+	for _, v := range []struct {
+		list     corev1.ResourceList
+		resource corev1.ResourceName
+		value    string
+	}{
+		{requirements.Requests, corev1.ResourceCPU, r.CPURequest},
+		{requirements.Limits, corev1.ResourceCPU, r.CPULimit},
+		{requirements.Requests, corev1.ResourceMemory, r.MemoryRequest},
+		{requirements.Limits, corev1.ResourceMemory, r.MemoryLimit},
+	} {
+		if v.value == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(v.value)
+		if err != nil {
+			return corev1.ResourceRequirements{}, fmt.Errorf("invalid %s quantity %q: %w", v.resource, v.value, err)
+		}
+		if q.Sign() <= 0 {
+			return corev1.ResourceRequirements{}, fmt.Errorf("invalid %s quantity %q: must be positive", v.resource, v.value)
+		}
+		v.list[v.resource] = q
+	}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		request, hasRequest := requirements.Requests[name]
+		limit, hasLimit := requirements.Limits[name]
+		if hasRequest && hasLimit && request.Cmp(limit) > 0 {
+			return corev1.ResourceRequirements{}, fmt.Errorf("%s request %s exceeds limit %s", name, request.String(), limit.String())
+		}
+	}
+	return requirements, nil
+}
+
+func NewPodEvaluator(namespace, wrapperServerImage string, interval, ttl time.Duration, podTTLConfig string, resources PodResources) (Evaluator, error) {
+	requirements, err := resources.ResourceRequirements()
+	if err != nil {
+		return nil, fmt.Errorf("invalid function pod resources: %w", err)
+	}
+
 	restCfg, err := config.GetConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get rest config: %w", err)
@@ -97,6 +157,7 @@ func NewPodEvaluator(namespace, wrapperServerImage string, interval, ttl time.Du
 				namespace:          namespace,
 				wrapperServerImage: wrapperServerImage,
 				podReadyCh:         readyCh,
+				resources:          requirements,
 			},
 		},
 	}
@@ -120,8 +181,9 @@ func (pe *podEvaluator) EvaluateFunction(ctx context.Context, req *evaluator.Eva
 	ccChan := make(chan *clientConnAndError, 1)
 	// Send a request to request a grpc client.
 	pe.requestCh <- &clientConnRequest{
-		image:        req.Image,
-		grpcClientCh: ccChan,
+		image:          req.Image,
+		networkSandbox: req.NetworkSandbox,
+		grpcClientCh:   ccChan,
 	}
 
 	// Waiting for the client from the channel.
@@ -158,6 +220,8 @@ type podCacheManager struct {
 
 type clientConnRequest struct {
 	image string
+	// networkSandbox requests a pod without network access.
+	networkSandbox bool
 
 	unavavilable     bool
 	currClientTarget string
@@ -177,7 +241,8 @@ type podAndGRPCClient struct {
 }
 
 type imagePodAndGRPCClient struct {
-	image string
+	// key is the cache key of the pod; see podCacheKey.
+	key string
 	*podAndGRPCClient
 	err error
 }
@@ -212,7 +277,7 @@ func (pcm *podCacheManager) warmupCache(podTTLConfig string) error {
 			}
 			// We invoke the function with useGenerateName=false so that the pod name is fixed,
 			// since we want to ensure only one pod is created for each function.
-			pcm.podManager.getFuncEvalPodClient(ctx, img, ttl, false)
+			pcm.podManager.getFuncEvalPodClient(ctx, img, ttl, false, false)
 			klog.Infof("preloaded pod cache for function %v", img)
 		}(fnImage, ttlStr)
 	}
@@ -226,7 +291,8 @@ func (pcm *podCacheManager) podCacheManager() {
 	for {
 		select {
 		case req := <-pcm.requestCh:
-			podAndCl, found := pcm.cache[req.image]
+			key := podCacheKey(req.image, req.networkSandbox)
+			podAndCl, found := pcm.cache[key]
 			if found && podAndCl != nil {
 				// Ensure the pod still exists and is not being deleted before sending the gprc client back to the channel.
 				// We can't simply return grpc client from the cache and let evaluator try to connect to the pod.
@@ -249,26 +315,26 @@ func (pcm *podCacheManager) podCacheManager() {
 				}
 				// We delete the cache entry if the pod has been deleted or being deleted.
 				if deleteCacheEntry {
-					delete(pcm.cache, req.image)
+					delete(pcm.cache, key)
 				}
 			}
-			_, found = pcm.waitlists[req.image]
+			_, found = pcm.waitlists[key]
 			if !found {
-				pcm.waitlists[req.image] = []chan<- *clientConnAndError{}
+				pcm.waitlists[key] = []chan<- *clientConnAndError{}
 			}
-			list := pcm.waitlists[req.image]
-			pcm.waitlists[req.image] = append(list, req.grpcClientCh)
+			list := pcm.waitlists[key]
+			pcm.waitlists[key] = append(list, req.grpcClientCh)
 			// We invoke the function with useGenerateName=true to avoid potential name collision, since if pod foo is
 			// being deleted and we can't use the same name.
-			go pcm.podManager.getFuncEvalPodClient(context.Background(), req.image, pcm.podTTL, true)
+			go pcm.podManager.getFuncEvalPodClient(context.Background(), req.image, pcm.podTTL, true, req.networkSandbox)
 		case resp := <-pcm.podReadyCh:
 			if resp.err != nil {
 				klog.Warningf("received error from the pod manager: %v", resp.err)
 			} else {
-				pcm.cache[resp.image] = resp.podAndGRPCClient
+				pcm.cache[resp.key] = resp.podAndGRPCClient
 			}
-			channels := pcm.waitlists[resp.image]
-			delete(pcm.waitlists, resp.image)
+			channels := pcm.waitlists[resp.key]
+			delete(pcm.waitlists, resp.key)
 			for i := range channels {
 				cce := &clientConnAndError{err: resp.err}
 				if resp.podAndGRPCClient != nil {
@@ -325,8 +391,8 @@ func (pcm *podCacheManager) garbageCollector() {
 					}
 				}(podList.Items[i])
 
-				image := pod.Spec.Containers[0].Image
-				podAndCl, found := pcm.cache[image]
+				key := podCacheKey(pod.Spec.Containers[0].Image, pod.Labels[networkSandboxLabel] == "true")
+				podAndCl, found := pcm.cache[key]
 				if found {
 					host, _, err := net.SplitHostPort(podAndCl.grpcClient.Target())
 					// If the client target in the cache points to a different pod IP, it means the matching pod is not the current pod.
@@ -336,7 +402,7 @@ func (pcm *podCacheManager) garbageCollector() {
 					}
 					// We delete the cache entry when the IP of the old pod match the client target in the cache
 					// or we can't split the host and port in the client target.
-					delete(pcm.cache, image)
+					delete(pcm.cache, key)
 				}
 			}
 		}
@@ -350,6 +416,8 @@ type podManager struct {
 	namespace string
 	// wrapperServerImage is the image name of the wrapper server
 	wrapperServerImage string
+	// resources are the compute resources of function containers
+	resources corev1.ResourceRequirements
 
 	// podReadyCh is a channel to receive requests to get GRPC client from each function evaluation request handler.
 	podReadyCh chan<- *imagePodAndGRPCClient
@@ -367,9 +435,18 @@ type digestAndEntrypoint struct {
 	entrypoint []string
 }
 
-func (pm *podManager) getFuncEvalPodClient(ctx context.Context, image string, ttl time.Duration, useGenerateName bool) {
+// podCacheKey returns the key of the pods of the image in the pod cache. Functions in the network
+// sandbox run in other pods than the functions with network access.
+func podCacheKey(image string, networkSandbox bool) string {
+	if networkSandbox {
+		return image + " (network sandbox)"
+	}
+	return image
+}
+
+func (pm *podManager) getFuncEvalPodClient(ctx context.Context, image string, ttl time.Duration, useGenerateName, networkSandbox bool) {
 	c, err := func() (*podAndGRPCClient, error) {
-		podKey, err := pm.retrieveOrCreatePod(ctx, image, ttl, useGenerateName)
+		podKey, err := pm.retrieveOrCreatePod(ctx, image, ttl, useGenerateName, networkSandbox)
 		if err != nil {
 			return nil, err
 		}
@@ -391,7 +468,7 @@ func (pm *podManager) getFuncEvalPodClient(ctx context.Context, image string, tt
 		}, err
 	}()
 	pm.podReadyCh <- &imagePodAndGRPCClient{
-		image:            image,
+		key:              podCacheKey(image, networkSandbox),
 		podAndGRPCClient: c,
 		err:              err,
 	}
@@ -436,7 +513,7 @@ func (pm *podManager) imageDigestAndEntrypoint(image string) (*digestAndEntrypoi
 	return de, nil
 }
 
-func (pm *podManager) retrieveOrCreatePod(ctx context.Context, image string, ttl time.Duration, useGenerateName bool) (client.ObjectKey, error) {
+func (pm *podManager) retrieveOrCreatePod(ctx context.Context, image string, ttl time.Duration, useGenerateName, networkSandbox bool) (client.ObjectKey, error) {
 	var de *digestAndEntrypoint
 	var err error
 	val, found := pm.imageMetadataCache.Load(image)
@@ -453,6 +530,16 @@ func (pm *podManager) retrieveOrCreatePod(ctx context.Context, image string, ttl
 	if err != nil {
 		return client.ObjectKey{}, err
 	}
+	labels := map[string]string{}
+	if networkSandbox {
+		// Sandboxed pods are distinct from the pods of the same function with network access.
+		podId += "-sandboxed"
+		labels[networkSandboxLabel] = "true"
+		if err := pm.ensureNetworkSandboxPolicy(ctx); err != nil {
+			return client.ObjectKey{}, err
+		}
+	}
+	labels[krmFunctionLabel] = podId
 
 	// Try to retrieve the pod. Lookup the pod by label to see if there is a pod that can be reused.
 	// Looking it up locally may not work if there are more than one instance of the function runner,
@@ -495,9 +582,7 @@ func (pm *podManager) retrieveOrCreatePod(ctx context.Context, image string, ttl
 			// The function runner can use the label to retrieve the pod. Label is function name + part of its digest.
 			// If a function has more than one tags pointing to the same digest, we can reuse the same pod.
 			// TODO: controller-runtime provides field indexer, we can potentially use it to index spec.containers[*].image field.
-			Labels: map[string]string{
-				krmFunctionLabel: podId,
-			},
+			Labels: labels,
 		},
 		Spec: corev1.PodSpec{
 			InitContainers: []corev1.Container{
@@ -520,9 +605,10 @@ func (pm *podManager) retrieveOrCreatePod(ctx context.Context, image string, ttl
 			},
 			Containers: []corev1.Container{
 				{
-					Name:    "function",
-					Image:   image,
-					Command: cmd,
+					Name:      "function",
+					Image:     image,
+					Command:   cmd,
+					Resources: pm.resources,
 					ReadinessProbe: &corev1.Probe{
 						ProbeHandler: corev1.ProbeHandler{
 							Exec: &corev1.ExecAction{
@@ -569,6 +655,35 @@ func (pm *podManager) retrieveOrCreatePod(ctx context.Context, image string, ttl
 	return client.ObjectKeyFromObject(pod), nil
 }
 
+// ensureNetworkSandboxPolicy creates the NetworkPolicy which denies all egress of the pods in the
+// network sandbox, and all ingress except the connections of the function runner to the wrapper
+// server, if it does not exist. Network isolation requires a network plugin which enforces
+// NetworkPolicies.
+func (pm *podManager) ensureNetworkSandboxPolicy(ctx context.Context) error {
+	port := intstr.Parse(defaultWrapperServerPort)
+	protocol := corev1.ProtocolTCP
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pm.namespace,
+			Name:      networkSandboxPolicyName,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{networkSandboxLabel: "true"},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{Ports: []networkingv1.NetworkPolicyPort{{Protocol: &protocol, Port: &port}}},
+			},
+		},
+	}
+	err := pm.kubeClient.Create(ctx, policy, client.FieldOwner(fieldManagerName))
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to create the network sandbox policy: %w", err)
+	}
+	return nil
+}
+
 // podIpIfRunningAndReady waits for the pod to be running and ready and returns the pod IP and a potential error.
 func (pm *podManager) podIpIfRunningAndReady(ctx context.Context, podKey client.ObjectKey) (ip string, e error) {
 	var pod corev1.Pod
@@ -0,0 +1,198 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testImage = "gcr.io/kpt-fn/set-namespace:v0.4"
+
+func newTestPodManager() *podManager {
+	pm := &podManager{
+		kubeClient:         fake.NewClientBuilder().Build(),
+		namespace:          "porch-fn-system",
+		wrapperServerImage: "gcr.io/kpt-dev/porch-wrapper-server:latest",
+	}
+	pm.imageMetadataCache.Store(testImage, &digestAndEntrypoint{
+		digest:     "0123456789abcdef",
+		entrypoint: []string{"/usr/local/bin/function"},
+	})
+	return pm
+}
+
+func TestNetworkSandboxPod(t *testing.T) {
+	ctx := context.Background()
+	pm := newTestPodManager()
+
+	key, err := pm.retrieveOrCreatePod(ctx, testImage, time.Minute, true, true)
+	if err != nil {
+		t.Fatalf("retrieveOrCreatePod failed: %v", err)
+	}
+	var pod corev1.Pod
+	if err := pm.kubeClient.Get(ctx, key, &pod); err != nil {
+		t.Fatalf("Failed to get the function pod: %v", err)
+	}
+	if got := pod.Labels[networkSandboxLabel]; got != "true" {
+		t.Errorf("Sandboxed pod label %q: got %q, want %q", networkSandboxLabel, got, "true")
+	}
+
+	var policy networkingv1.NetworkPolicy
+	if err := pm.kubeClient.Get(ctx, client.ObjectKey{Namespace: pm.namespace, Name: networkSandboxPolicyName}, &policy); err != nil {
+		t.Fatalf("Failed to get the network sandbox policy: %v", err)
+	}
+	if got := policy.Spec.PodSelector.MatchLabels[networkSandboxLabel]; got != "true" {
+		t.Errorf("Network sandbox policy does not select sandboxed pods: %v", policy.Spec.PodSelector)
+	}
+	if len(policy.Spec.Egress) != 0 || len(policy.Spec.PolicyTypes) != 2 {
+		t.Errorf("Network sandbox policy allows egress: %+v", policy.Spec)
+	}
+
+	// Pods with network access are not reused for sandboxed functions, nor the other way around.
+	unsandboxed, err := pm.retrieveOrCreatePod(ctx, testImage, time.Minute, true, false)
+	if err != nil {
+		t.Fatalf("retrieveOrCreatePod failed: %v", err)
+	}
+	if unsandboxed == key {
+		t.Errorf("Function with network access reused the sandboxed pod %v", key)
+	}
+	if err := pm.kubeClient.Get(ctx, unsandboxed, &pod); err != nil {
+		t.Fatalf("Failed to get the function pod: %v", err)
+	}
+	if _, ok := pod.Labels[networkSandboxLabel]; ok {
+		t.Errorf("Pod with network access has the network sandbox label")
+	}
+	again, err := pm.retrieveOrCreatePod(ctx, testImage, time.Minute, true, true)
+	if err != nil {
+		t.Fatalf("retrieveOrCreatePod failed: %v", err)
+	}
+	if again != key {
+		t.Errorf("Sandboxed function did not reuse the sandboxed pod: got %v, want %v", again, key)
+	}
+
+	if podCacheKey(testImage, true) == podCacheKey(testImage, false) {
+		t.Errorf("Sandboxed and unsandboxed pods share the cache key %q", podCacheKey(testImage, true))
+	}
+}
+
+func TestPodResourceRequirements(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		resources    PodResources
+		wantRequests corev1.ResourceList
+		wantLimits   corev1.ResourceList
+		wantErr      string
+	}{
+		{
+			name:         "unset",
+			wantRequests: corev1.ResourceList{},
+			wantLimits:   corev1.ResourceList{},
+		},
+		{
+			name: "valid",
+			resources: PodResources{
+				CPURequest:    "100m",
+				CPULimit:      "1",
+				MemoryRequest: "64Mi",
+				MemoryLimit:   "256Mi",
+			},
+			wantRequests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("64Mi"),
+			},
+			wantLimits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			},
+		},
+		{
+			name:         "limits only",
+			resources:    PodResources{MemoryLimit: "1Gi"},
+			wantRequests: corev1.ResourceList{},
+			wantLimits: corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+		},
+		{
+			name:      "invalid quantity",
+			resources: PodResources{CPURequest: "lots"},
+			wantErr:   `invalid cpu quantity "lots"`,
+		},
+		{
+			name:      "zero quantity",
+			resources: PodResources{MemoryLimit: "0"},
+			wantErr:   `invalid memory quantity "0": must be positive`,
+		},
+		{
+			name:      "request exceeds limit",
+			resources: PodResources{CPURequest: "2", CPULimit: "500m"},
+			wantErr:   "cpu request 2 exceeds limit 500m",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.resources.ResourceRequirements()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("ResourceRequirements: got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResourceRequirements failed: %v", err)
+			}
+			if !equality.Semantic.DeepEqual(got.Requests, tc.wantRequests) {
+				t.Errorf("Requests: got %v, want %v", got.Requests, tc.wantRequests)
+			}
+			if !equality.Semantic.DeepEqual(got.Limits, tc.wantLimits) {
+				t.Errorf("Limits: got %v, want %v", got.Limits, tc.wantLimits)
+			}
+		})
+	}
+}
+
+func TestFunctionPodResources(t *testing.T) {
+	ctx := context.Background()
+	requirements, err := PodResources{CPURequest: "100m", MemoryLimit: "256Mi"}.ResourceRequirements()
+	if err != nil {
+		t.Fatalf("ResourceRequirements failed: %v", err)
+	}
+	pm := newTestPodManager()
+	pm.resources = requirements
+
+	key, err := pm.retrieveOrCreatePod(ctx, testImage, time.Minute, true, false)
+	if err != nil {
+		t.Fatalf("retrieveOrCreatePod failed: %v", err)
+	}
+	var pod corev1.Pod
+	if err := pm.kubeClient.Get(ctx, key, &pod); err != nil {
+		t.Fatalf("Failed to get the function pod: %v", err)
+	}
+	if len(pod.Spec.Containers) != 1 {
+		t.Fatalf("Function pod has %d containers, want 1", len(pod.Spec.Containers))
+	}
+	if got := pod.Spec.Containers[0].Resources; !equality.Semantic.DeepEqual(got, requirements) {
+		t.Errorf("Function container resources: got %v, want %v", got, requirements)
+	}
+}
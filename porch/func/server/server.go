@@ -44,6 +44,10 @@ var (
 	podCacheConfig  = flag.String("pod-cache-config", "/pod-cache-config/pod-cache-config.yaml", "Path to the pod cache config file. The file is map of function name to TTL.")
 	podNamespace    = flag.String("pod-namespace", "porch-fn-system", "Namespace to run KRM functions pods.")
 	podTTL          = flag.Duration("pod-ttl", 30*time.Minute, "TTL for pods before GC.")
+	podCPURequest   = flag.String("pod-cpu-request", "125m", "CPU request of KRM function containers. Not set if empty.")
+	podCPULimit     = flag.String("pod-cpu-limit", "", "CPU limit of KRM function containers. Not set if empty.")
+	podMemRequest   = flag.String("pod-memory-request", "64Mi", "Memory request of KRM function containers. Not set if empty.")
+	podMemLimit     = flag.String("pod-memory-limit", "", "Memory limit of KRM function containers. Not set if empty.")
 	scanInterval    = flag.Duration("scan-interval", time.Minute, "The interval of GC between scans.")
 	disableRuntimes = flag.String("disable-runtimes", "", fmt.Sprintf("The runtime(s) to disable. Multiple runtimes should separated by `,`. Available runtimes: `%v`, `%v`.", execRuntime, podRuntime))
 )
@@ -88,7 +92,12 @@ func run() error {
 			if wrapperServerImage == "" {
 				return fmt.Errorf("environment variable %v must be set to use pod function evaluator runtime", wrapperServerImageEnv)
 			}
-			podEval, err := internal.NewPodEvaluator(*podNamespace, wrapperServerImage, *scanInterval, *podTTL, *podCacheConfig)
+			podEval, err := internal.NewPodEvaluator(*podNamespace, wrapperServerImage, *scanInterval, *podTTL, *podCacheConfig, internal.PodResources{
+				CPURequest:    *podCPURequest,
+				CPULimit:      *podCPULimit,
+				MemoryRequest: *podMemRequest,
+				MemoryLimit:   *podMemLimit,
+			})
 			if err != nil {
 				return fmt.Errorf("failed to initialize pod evaluator: %w", err)
 			}
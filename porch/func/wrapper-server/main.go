@@ -26,6 +26,7 @@ import (
 	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
 	pb "github.com/GoogleContainerTools/kpt/porch/func/evaluator"
 	"github.com/GoogleContainerTools/kpt/porch/func/healthchecker"
+	"github.com/GoogleContainerTools/kpt/porch/func/internal/funcenv"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -96,6 +97,13 @@ func (e *singleFunctionEvaluator) EvaluateFunction(ctx context.Context, req *pb.
 	cmd.Stdin = bytes.NewReader(req.ResourceList)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	if len(req.Env) > 0 {
+		env, err := funcenv.Environ(req.Env)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid environment of function %q: %s", req.Image, err)
+		}
+		cmd.Env = env
+	}
 
 	err := cmd.Run()
 	var exitErr *exec.ExitError
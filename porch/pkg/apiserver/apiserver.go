@@ -17,6 +17,8 @@ package apiserver
 import (
 	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/GoogleContainerTools/kpt/porch/api/porch/install"
 	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
@@ -33,6 +35,7 @@ import (
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -62,11 +65,39 @@ func init() {
 	)
 }
 
+// Values of ExtraConfig.StartupLatestCheck.
+const (
+	// StartupLatestCheckNone disables the startup check of latest package revisions.
+	StartupLatestCheckNone = ""
+	// StartupLatestCheckWarn logs violations of the latest package revision invariants.
+	StartupLatestCheckWarn = "warn"
+	// StartupLatestCheckFail fails startup on violations of the latest package revision invariants.
+	StartupLatestCheckFail = "fail"
+)
+
 // ExtraConfig holds custom apiserver config
 type ExtraConfig struct {
 	CoreAPIKubeconfigPath string
 	CacheDirectory        string
 	FunctionRunnerAddress string
+	// StartupLatestCheck selects whether latest package revisions of all repositories
+	// are verified on startup, and whether violations are fatal.
+	StartupLatestCheck string
+	// LatestTiePolicy selects the latest package revision among published revisions whose versions compare equal.
+	LatestTiePolicy cache.LatestTiePolicy
+	// RevisionComparators are the comparators which repositories can select by name to order their package revisions.
+	RevisionComparators map[string]cache.RevisionComparator
+	// OrphanedDraftAge is the age beyond which drafts without a closed revision are reported as orphaned.
+	OrphanedDraftAge time.Duration
+	// LatestRevisionLabels are the labels applied to latest package revisions; defaults to the standard label.
+	LatestRevisionLabels map[string]string
+	// ResourcesCacheBytes is the memory budget of cached resources of published package revisions; zero disables it.
+	ResourcesCacheBytes int64
+	// FailureThreshold is the number of consecutive failed background refreshes after which a repository is unhealthy.
+	FailureThreshold int
+	// TrustedUpstreamKeysFile is the file of the armored PGP key ring trusted to sign cloned upstream
+	// commits. If set, only signed Git upstreams are cloned.
+	TrustedUpstreamKeysFile string
 }
 
 // Config defines the config for the apiserver
@@ -80,6 +111,8 @@ type PorchServer struct {
 	GenericAPIServer *genericapiserver.GenericAPIServer
 	coreClient       client.WithWatch
 	cache            *cache.Cache
+
+	startupLatestCheck string
 }
 
 type completedConfig struct {
@@ -169,10 +202,16 @@ func (c completedConfig) New() (*PorchServer, error) {
 	renderer := kpt.NewRenderer()
 
 	cache := cache.NewCache(c.ExtraConfig.CacheDirectory, cache.CacheOptions{
-		CredentialResolver: credentialResolver,
-		UserInfoProvider:   userInfoProvider,
+		CredentialResolver:   credentialResolver,
+		UserInfoProvider:     userInfoProvider,
+		LatestTiePolicy:      c.ExtraConfig.LatestTiePolicy,
+		RevisionComparators:  c.ExtraConfig.RevisionComparators,
+		OrphanedDraftAge:     c.ExtraConfig.OrphanedDraftAge,
+		LatestRevisionLabels: c.ExtraConfig.LatestRevisionLabels,
+		ResourcesCacheBytes:  c.ExtraConfig.ResourcesCacheBytes,
+		FailureThreshold:     c.ExtraConfig.FailureThreshold,
 	})
-	cad, err := engine.NewCaDEngine(
+	engineOptions := []engine.EngineOption{
 		engine.WithCache(cache),
 		// The order of registering the function runtimes matters here. When
 		// evaluating a function, the runtimes will be tried in the same
@@ -183,7 +222,15 @@ func (c completedConfig) New() (*PorchServer, error) {
 		engine.WithRenderer(renderer),
 		engine.WithReferenceResolver(referenceResolver),
 		engine.WithUserInfoProvider(userInfoProvider),
-	)
+	}
+	if c.ExtraConfig.TrustedUpstreamKeysFile != "" {
+		keys, err := os.ReadFile(c.ExtraConfig.TrustedUpstreamKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted upstream keys: %w", err)
+		}
+		engineOptions = append(engineOptions, engine.WithTrustedUpstreamKeys(string(keys)))
+	}
+	cad, err := engine.NewCaDEngine(engineOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -194,9 +241,10 @@ func (c completedConfig) New() (*PorchServer, error) {
 	}
 
 	s := &PorchServer{
-		GenericAPIServer: genericServer,
-		coreClient:       coreClient,
-		cache:            cache,
+		GenericAPIServer:   genericServer,
+		coreClient:         coreClient,
+		cache:              cache,
+		startupLatestCheck: c.ExtraConfig.StartupLatestCheck,
 	}
 
 	// Install the groups.
@@ -208,6 +256,36 @@ func (c completedConfig) New() (*PorchServer, error) {
 }
 
 func (s *PorchServer) Run(ctx context.Context) error {
+	if s.startupLatestCheck != StartupLatestCheckNone {
+		if err := s.verifyLatestRevisions(ctx); err != nil {
+			if s.startupLatestCheck == StartupLatestCheckFail {
+				return err
+			}
+			klog.Warningf("Startup check of latest package revisions failed: %v", err)
+		} else {
+			klog.Infof("Startup check of latest package revisions passed")
+		}
+	}
 	porch.RunBackground(ctx, s.coreClient, s.cache)
 	return s.GenericAPIServer.PrepareRun().Run(ctx.Done())
 }
+
+// verifyLatestRevisions opens all registered repositories and checks the latest package revision invariants.
+func (s *PorchServer) verifyLatestRevisions(ctx context.Context) error {
+	var repositories configapi.RepositoryList
+	if err := s.coreClient.List(ctx, &repositories); err != nil {
+		return fmt.Errorf("error listing repository objects: %w", err)
+	}
+
+	for i := range repositories.Items {
+		repo := &repositories.Items[i]
+		if _, err := s.cache.OpenRepository(ctx, repo); err != nil {
+			klog.Warningf("Cannot open repository %s:%s for startup check: %v", repo.Namespace, repo.Name, err)
+		}
+	}
+
+	if err := s.cache.VerifyLatestRevisions(ctx); err != nil {
+		return fmt.Errorf("latest package revisions are inconsistent: %w", err)
+	}
+	return nil
+}
@@ -18,14 +18,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/git"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/oci"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
 	"go.opentelemetry.io/otel/trace"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 // Cache allows us to keep state for repositories, rather than querying them every time.
@@ -44,19 +48,100 @@ type Cache struct {
 	cacheDir           string
 	credentialResolver repository.CredentialResolver
 	userInfoProvider   repository.UserInfoProvider
+	pollTimeout        time.Duration
+	pollJitter         float64
+	latestTiePolicy    LatestTiePolicy
+	comparators        map[string]RevisionComparator
+	orphanedDraftAge   time.Duration
+	latestLabels       map[string]string
+	// Resources of published package revisions cached across repositories; nil caches none.
+	resources *resourcesCache
+	// Number of consecutive failed refreshes after which a repository is reported unhealthy.
+	failureThreshold int
 }
 
+// LatestTiePolicy selects the latest package revision among published revisions whose versions compare equal.
+type LatestTiePolicy string
+
+const (
+	// LatestTieWarn keeps the first revision found and logs a warning.
+	LatestTieWarn LatestTiePolicy = ""
+	// LatestTieFail fails the repository refresh.
+	LatestTieFail LatestTiePolicy = "fail"
+	// LatestTieNewest selects the most recently created revision.
+	LatestTieNewest LatestTiePolicy = "newest"
+	// LatestTieMostTasks selects the revision with the most tasks.
+	LatestTieMostTasks LatestTiePolicy = "most-tasks"
+)
+
 type CacheOptions struct {
 	CredentialResolver repository.CredentialResolver
 	UserInfoProvider   repository.UserInfoProvider
+	// LatestTiePolicy selects the latest package revision among published revisions of a package
+	// whose versions compare equal. Defaults to keeping the first one found.
+	LatestTiePolicy LatestTiePolicy
+	// PollTimeout bounds the backend list calls of a single background poll, so that a stuck
+	// backend fails the poll and the next one can retry. Defaults to half of the poll interval.
+	PollTimeout time.Duration
+	// PollJitter is the fraction of the poll interval by which each background poll is randomly
+	// advanced or delayed, to spread the polls of repositories opened together. Defaults to 0.1;
+	// negative values disable jitter, and values above 1 are treated as 1.
+	PollJitter float64
+	// RevisionComparators are the comparators which repositories can select by name to order their
+	// package revisions. Repositories which select none use SemverRevisionComparator.
+	RevisionComparators map[string]RevisionComparator
+	// OrphanedDraftAge is the age beyond which drafts without a closed revision are reported as
+	// orphaned by the repository refresh. Zero disables detection of orphaned drafts.
+	OrphanedDraftAge time.Duration
+	// LatestRevisionLabels are the labels applied to the latest revision of each package.
+	// Defaults to the standard v1alpha1.LatestPackageRevisionKey label; installations which add
+	// their own labels should include the standard label to keep selecting latest revisions by it.
+	LatestRevisionLabels map[string]string
+	// ResourcesCacheBytes is the memory budget, in bytes of file contents, of the resources of
+	// published package revisions cached across all repositories. When it is exceeded, the least
+	// recently used resources are evicted and read from the backend again when next needed.
+	// Zero disables caching of resources.
+	ResourcesCacheBytes int64
+	// FailureThreshold is the number of consecutive failed background refreshes of a repository
+	// after which it is reported unhealthy, so that transient failures do not make it flap. A
+	// successful background refresh resets the count. Defaults to 1, reporting a repository
+	// unhealthy on any failure.
+	FailureThreshold int
 }
 
 func NewCache(cacheDir string, opts CacheOptions) *Cache {
+	pollTimeout := opts.PollTimeout
+	if pollTimeout <= 0 {
+		pollTimeout = pollInterval / 2
+	}
+	pollJitter := opts.PollJitter
+	switch {
+	case pollJitter == 0:
+		pollJitter = defaultPollJitter
+	case pollJitter > 1:
+		pollJitter = 1
+	}
+	var resources *resourcesCache
+	if opts.ResourcesCacheBytes > 0 {
+		resources = newResourcesCache(opts.ResourcesCacheBytes)
+	}
+	failureThreshold := opts.FailureThreshold
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
 	return &Cache{
 		repositories:       make(map[string]*cachedRepository),
 		cacheDir:           cacheDir,
 		credentialResolver: opts.CredentialResolver,
 		userInfoProvider:   opts.UserInfoProvider,
+		pollTimeout:        pollTimeout,
+		pollJitter:         pollJitter,
+		latestTiePolicy:    opts.LatestTiePolicy,
+		comparators:        opts.RevisionComparators,
+		orphanedDraftAge:   opts.OrphanedDraftAge,
+		latestLabels:       opts.LatestRevisionLabels,
+		resources:          resources,
+		failureThreshold:   failureThreshold,
 	}
 }
 
@@ -64,6 +149,18 @@ func (c *Cache) OpenRepository(ctx context.Context, repositorySpec *configapi.Re
 	ctx, span := tracer.Start(ctx, "Cache::OpenRepository", trace.WithAttributes())
 	defer span.End()
 
+	comparatorName := repositorySpec.Spec.RevisionComparator
+	comparator, err := c.revisionComparator(comparatorName)
+	if err != nil {
+		return nil, err
+	}
+	// A minimum the comparator cannot order would never exclude any revision.
+	for pkg, minimum := range repositorySpec.Spec.MinimumLatestRevisions {
+		if !comparator.IsValid(minimum) {
+			return nil, fmt.Errorf("invalid minimum latest revision %q of package %q", minimum, pkg)
+		}
+	}
+
 	switch repositoryType := repositorySpec.Spec.Type; repositoryType {
 	case configapi.RepositoryTypeOCI:
 		ociSpec := repositorySpec.Spec.Oci
@@ -81,9 +178,15 @@ func (c *Cache) OpenRepository(ctx context.Context, repositorySpec *configapi.Re
 			if err != nil {
 				return nil, err
 			}
-			cr = newRepository(key, r)
+			cr = newRepository(key, r, c.repositoryOptions())
+			cr.setBackend(configapi.RepositoryTypeOCI, ociSpec.Registry)
 			c.repositories[key] = cr
 		}
+		cr.setRetentionPolicy(repositorySpec.Spec.Retention)
+		cr.setLatestRevisionDisabled(repositorySpec.Spec.DisableLatestRevision)
+		cr.setRevisionComparator(comparatorName, comparator)
+		cr.setMinimumLatestRevisions(repositorySpec.Spec.MinimumLatestRevisions)
+		cr.setCacheDisabled(repositorySpec.Spec.CacheDisabled)
 		return cr, nil
 
 	case configapi.RepositoryTypeGit:
@@ -110,15 +213,22 @@ func (c *Cache) OpenRepository(ctx context.Context, repositorySpec *configapi.Re
 			}); err != nil {
 				return nil, err
 			} else {
-				cr = newRepository(key, r)
+				cr = newRepository(key, r, c.repositoryOptions())
+				cr.setBackend(configapi.RepositoryTypeGit, gitSpec.Repo)
 				c.repositories[key] = cr
 			}
 		} else {
-			// If there is an error from the background refresh goroutine, return it.
-			if err := cr.getRefreshError(); err != nil {
+			// If the background refresh goroutine has failed for as many consecutive refreshes as the
+			// failure threshold, return its error.
+			if err := cr.getUnhealthyError(); err != nil {
 				return nil, err
 			}
 		}
+		cr.setRetentionPolicy(repositorySpec.Spec.Retention)
+		cr.setLatestRevisionDisabled(repositorySpec.Spec.DisableLatestRevision)
+		cr.setRevisionComparator(comparatorName, comparator)
+		cr.setMinimumLatestRevisions(repositorySpec.Spec.MinimumLatestRevisions)
+		cr.setCacheDisabled(repositorySpec.Spec.CacheDisabled)
 		return cr, nil
 
 	default:
@@ -126,6 +236,31 @@ func (c *Cache) OpenRepository(ctx context.Context, repositorySpec *configapi.Re
 	}
 }
 
+func (c *Cache) repositoryOptions() cachedRepositoryOptions {
+	return cachedRepositoryOptions{
+		pollTimeout:      c.pollTimeout,
+		pollJitter:       c.pollJitter,
+		tiePolicy:        c.latestTiePolicy,
+		orphanedDraftAge: c.orphanedDraftAge,
+		latestLabels:     c.latestLabels,
+		resources:        c.resources,
+		failureThreshold: c.failureThreshold,
+	}
+}
+
+// revisionComparator returns the comparator registered under the name; the empty name selects
+// SemverRevisionComparator.
+func (c *Cache) revisionComparator(name string) (RevisionComparator, error) {
+	if name == "" {
+		return SemverRevisionComparator{}, nil
+	}
+	comparator, ok := c.comparators[name]
+	if !ok {
+		return nil, fmt.Errorf("revision comparator %q is not registered", name)
+	}
+	return comparator, nil
+}
+
 func isPackageContent(content configapi.RepositoryContent) bool {
 	return content == configapi.RepositoryContentPackage
 }
@@ -171,3 +306,169 @@ func (c *Cache) CloseRepository(repositorySpec *configapi.Repository) error {
 		return nil
 	}
 }
+
+// Prefetch loads the revisions of the named package of an open repository into the cache,
+// without refreshing the rest of the repository. The repository is identified by its cache
+// id, e.g. "git://<address>" or "oci://<registry>".
+func (c *Cache) Prefetch(ctx context.Context, repoId, packageName string) error {
+	ctx, span := tracer.Start(ctx, "Cache::Prefetch", trace.WithAttributes())
+	defer span.End()
+
+	r, err := c.openRepository(repoId)
+	if err != nil {
+		return err
+	}
+	return r.prefetchPackage(ctx, packageName)
+}
+
+// PausePolling suspends the background refreshes of an open repository, e.g. while its
+// backend is under maintenance, without closing it. The repository is identified by its
+// cache id, e.g. "git://<address>" or "oci://<registry>".
+func (c *Cache) PausePolling(repoId string) error {
+	r, err := c.openRepository(repoId)
+	if err != nil {
+		return err
+	}
+	r.PausePolling()
+	return nil
+}
+
+// ResumePolling resumes the background refreshes of an open repository paused by PausePolling.
+func (c *Cache) ResumePolling(repoId string) error {
+	r, err := c.openRepository(repoId)
+	if err != nil {
+		return err
+	}
+	r.ResumePolling()
+	return nil
+}
+
+// openRepository returns the open repository with the cache id.
+func (c *Cache) openRepository(repoId string) (*cachedRepository, error) {
+	c.mutex.Lock()
+	r := c.repositories[repoId]
+	c.mutex.Unlock()
+
+	if r == nil {
+		return nil, fmt.Errorf("repository %q is not open", repoId)
+	}
+	return r, nil
+}
+
+// WaitForInitialSync blocks until every open repository has completed its first fetch of
+// package revisions, or until the context is done. The returned error lists the repositories
+// which failed to sync or did not sync in time.
+func (c *Cache) WaitForInitialSync(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Cache::WaitForInitialSync", trace.WithAttributes())
+	defer span.End()
+
+	c.mutex.Lock()
+	repositories := make([]*cachedRepository, 0, len(c.repositories))
+	for _, r := range c.repositories {
+		repositories = append(repositories, r)
+	}
+	c.mutex.Unlock()
+
+	var errs []error
+	for _, r := range repositories {
+		if err := r.waitForInitialSync(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("repository %q failed to sync: %w", r.id, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// RepositoryStatus describes the cached state of an open repository.
+type RepositoryStatus struct {
+	// ID identifies the repository in the cache, e.g. "git://<address>" or "oci://<registry>".
+	ID string
+	// Type is the type of the backend of the repository; empty if unknown.
+	Type configapi.RepositoryType
+	// URL is the address of the git repository or OCI registry, without credentials.
+	URL string
+	// PackageRevisions is the number of cached package revisions.
+	PackageRevisions int
+	// Functions is the number of cached functions.
+	Functions int
+	// LastPoll is when the last background poll of the repository completed; zero if none has.
+	LastPoll time.Time
+	// LastError is the error of the last poll or, before the first poll, of the last refresh;
+	// nil if it succeeded.
+	LastError error
+	// ConsecutiveFailures is the number of background refreshes of the package revisions which
+	// failed in a row; zero if the last background refresh succeeded.
+	ConsecutiveFailures int
+	// Unhealthy is set once ConsecutiveFailures reaches the failure threshold of the cache.
+	Unhealthy bool
+	// PollingPaused is set if the background refreshes of the repository are paused.
+	PollingPaused bool
+}
+
+// sanitizeURL removes the user information, which may carry credentials, from a repository
+// address. Addresses which are not URLs, such as scp-like git addresses, are returned unchanged.
+func sanitizeURL(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.User == nil {
+		return address
+	}
+	u.User = nil
+	return u.String()
+}
+
+// ListRepositories returns the status of every open repository, ordered by id.
+func (c *Cache) ListRepositories() []RepositoryStatus {
+	c.mutex.Lock()
+	repositories := make([]*cachedRepository, 0, len(c.repositories))
+	for _, r := range c.repositories {
+		repositories = append(repositories, r)
+	}
+	c.mutex.Unlock()
+
+	result := make([]RepositoryStatus, 0, len(repositories))
+	for _, r := range repositories {
+		result = append(result, r.status())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// ListPackageRevisions lists the package revisions matching the filter in every open repository.
+func (c *Cache) ListPackageRevisions(ctx context.Context, filter repository.ListPackageRevisionFilter) ([]repository.PackageRevision, error) {
+	c.mutex.Lock()
+	repositories := make([]*cachedRepository, 0, len(c.repositories))
+	for _, r := range c.repositories {
+		repositories = append(repositories, r)
+	}
+	c.mutex.Unlock()
+
+	var result []repository.PackageRevision
+	for _, r := range repositories {
+		revisions, err := r.ListPackageRevisions(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, revisions...)
+	}
+	return result, nil
+}
+
+// VerifyLatestRevisions recomputes the latest package revisions of every open repository
+// and checks that each package with published revisions has exactly one latest revision.
+func (c *Cache) VerifyLatestRevisions(ctx context.Context) error {
+	c.mutex.Lock()
+	repositories := make([]*cachedRepository, 0, len(c.repositories))
+	for _, r := range c.repositories {
+		repositories = append(repositories, r)
+	}
+	c.mutex.Unlock()
+
+	var errs []error
+	for _, r := range repositories {
+		if err := r.verifyLatestRevisions(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// RevisionComparator orders the revisions of a package. The cache uses it to sort package
+// revisions, to compute the latest revision of each package and to apply retention policies.
+type RevisionComparator interface {
+	// IsValid reports whether the revision can be ordered by the comparator. Published revisions
+	// with invalid revisions are never labeled latest.
+	IsValid(revision string) bool
+	// Compare returns -1, 0 or +1 depending on whether revision a is older than, equal to, or
+	// more recent than revision b. Both revisions are valid.
+	Compare(a, b string) int
+}
+
+// SemverRevisionComparator is the default RevisionComparator; it orders revisions as semantic versions.
+type SemverRevisionComparator struct{}
+
+var _ RevisionComparator = SemverRevisionComparator{}
+
+func (SemverRevisionComparator) IsValid(revision string) bool {
+	return semver.IsValid(revision)
+}
+
+func (SemverRevisionComparator) Compare(a, b string) int {
+	return semver.Compare(a, b)
+}
+
+// compareRevisions totally orders any two revisions: invalid revisions sort before valid ones and
+// are compared as strings, valid revisions are compared by the comparator.
+func compareRevisions(comparator RevisionComparator, a, b string) int {
+	switch aValid, bValid := comparator.IsValid(a), comparator.IsValid(b); {
+	case aValid && bValid:
+		if res := comparator.Compare(a, b); res != 0 {
+			return res
+		}
+	case aValid:
+		return 1
+	case bValid:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
@@ -16,25 +16,54 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/mod/semver"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog/v2"
 )
 
 var tracer = otel.Tracer("cache")
 
+// pollInterval is the interval between background refreshes of a repository.
+const pollInterval = 1 * time.Minute
+
+// defaultPollJitter is the default fraction of the poll interval by which polls are randomly
+// advanced or delayed.
+const defaultPollJitter = 0.1
+
 type cachedRepository struct {
 	id     string
 	repo   repository.Repository
 	cancel context.CancelFunc
+	// repoType and url describe the backend of the repository; url has no credentials.
+	repoType configapi.RepositoryType
+	url      string
+	// pollTimeout bounds the backend calls of a single background poll.
+	pollTimeout time.Duration
+	// pollJitter is the fraction of the poll interval by which each poll is randomly advanced or delayed.
+	pollJitter float64
+	// tiePolicy selects the latest revision among published revisions whose versions compare equal.
+	tiePolicy LatestTiePolicy
+	// orphanedDraftAge is the age beyond which unclosed drafts are reported as orphaned; zero disables detection.
+	orphanedDraftAge time.Duration
+	// latestLabels are the labels applied to latest package revisions; nil applies the standard label.
+	latestLabels map[string]string
+	// resources caches the resources of published package revisions; nil caches nothing.
+	resources *resourcesCache
+	// failureThreshold is the number of consecutive failed refreshes after which the repository is unhealthy.
+	failureThreshold int
 
 	mutex          sync.Mutex
 	cachedPackages []*cachedPackageRevision
@@ -43,6 +72,37 @@ type cachedRepository struct {
 	// Error encountered on repository refresh by the refresh goroutine.
 	// This is returned back by the cache to the background goroutine when it calls periodicall to resync repositories.
 	refreshError error
+	// Number of background refreshes of the package revisions which failed in a row. Listings
+	// which fetch the package revisions in the foreground are not counted.
+	consecutiveFailures int
+	// Published package revisions whose cached content is never overwritten by a refresh, keyed by KubeObjectName.
+	frozen map[string]repository.PackageRevision
+	// Maximum number of published revisions retained per package; zero retains all revisions.
+	maxPublishedRevisions int
+	// Orphaned drafts identified by the last refresh.
+	orphanedDrafts []OrphanedDraft
+	// Revision aliases, keyed by package name and alias; values are revisions. Aliases are not
+	// affected by refreshes.
+	aliases map[string]map[string]string
+	// If set, latest package revisions are not computed and never labeled.
+	latestRevisionDisabled bool
+	// Lowest revision of each package which may be the latest, keyed by package name.
+	minimumLatestRevisions map[string]string
+	// If set, package revisions and functions are not cached; every read goes to the backend.
+	cacheDisabled bool
+	// If set, the background poller does not refresh the repository; reads are still served from the cache.
+	pollingPaused bool
+	// comparator orders the revisions of each package; comparatorName is its registered name.
+	comparator     RevisionComparator
+	comparatorName string
+
+	// Completion time and error of the last background poll.
+	lastPoll      time.Time
+	lastPollError error
+
+	// synced is closed once the first fetch of package revisions completes, successfully or not.
+	synced     chan struct{}
+	syncedOnce sync.Once
 }
 
 // We take advantage of the cache having a global view of all the packages
@@ -53,6 +113,17 @@ type cachedRepository struct {
 type cachedPackageRevision struct {
 	repository.PackageRevision
 	isLatestRevision bool
+	// latestLabels are the labels applied to the revision if it is the latest; nil applies
+	// the standard latest revision label.
+	latestLabels map[string]string
+	// resources caches the resources of the revision, if published, under resourcesKey.
+	resources    *resourcesCache
+	resourcesKey string
+}
+
+// defaultLatestLabels are the labels applied to latest package revisions unless configured otherwise.
+var defaultLatestLabels = map[string]string{
+	v1alpha1.LatestPackageRevisionKey: v1alpha1.LatestPackageRevisionValue,
 }
 
 func (c *cachedPackageRevision) GetPackageRevision() *v1alpha1.PackageRevision {
@@ -61,19 +132,63 @@ func (c *cachedPackageRevision) GetPackageRevision() *v1alpha1.PackageRevision {
 		if rev.Labels == nil {
 			rev.Labels = map[string]string{}
 		}
-		rev.Labels[v1alpha1.LatestPackageRevisionKey] = v1alpha1.LatestPackageRevisionValue
+		labels := c.latestLabels
+		if labels == nil {
+			labels = defaultLatestLabels
+		}
+		for k, v := range labels {
+			rev.Labels[k] = v
+		}
 	}
 	return rev
 }
 
 var _ repository.PackageRevision = &cachedPackageRevision{}
 
-func newRepository(id string, repo repository.Repository) *cachedRepository {
+// GetResources returns the resources of the package revision, from the resources cache if the
+// revision is published. Drafts change and are always read from the backend.
+func (c *cachedPackageRevision) GetResources(ctx context.Context) (*v1alpha1.PackageRevisionResources, error) {
+	if c.resources == nil || c.Lifecycle() != v1alpha1.PackageRevisionLifecyclePublished {
+		return c.PackageRevision.GetResources(ctx)
+	}
+	// The resource version changes if the revision is deleted and published again.
+	key := c.resourcesKey + "@" + c.PackageRevision.GetPackageRevision().ResourceVersion
+	if resources, ok := c.resources.get(key); ok {
+		return resources, nil
+	}
+	resources, err := c.PackageRevision.GetResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.resources.add(key, resources)
+	return resources, nil
+}
+
+type cachedRepositoryOptions struct {
+	pollTimeout      time.Duration
+	pollJitter       float64
+	tiePolicy        LatestTiePolicy
+	orphanedDraftAge time.Duration
+	latestLabels     map[string]string
+	resources        *resourcesCache
+	failureThreshold int
+}
+
+func newRepository(id string, repo repository.Repository, opts cachedRepositoryOptions) *cachedRepository {
 	ctx, cancel := context.WithCancel(context.Background())
 	r := &cachedRepository{
-		id:     id,
-		repo:   repo,
-		cancel: cancel,
+		id:               id,
+		repo:             repo,
+		cancel:           cancel,
+		pollTimeout:      opts.pollTimeout,
+		pollJitter:       opts.pollJitter,
+		tiePolicy:        opts.tiePolicy,
+		orphanedDraftAge: opts.orphanedDraftAge,
+		latestLabels:     opts.latestLabels,
+		resources:        opts.resources,
+		failureThreshold: opts.failureThreshold,
+		comparator:       SemverRevisionComparator{},
+		synced:           make(chan struct{}),
 	}
 
 	go r.pollForever(ctx)
@@ -107,45 +222,293 @@ func (r *cachedRepository) getRefreshError() error {
 	return r.refreshError
 }
 
+// getUnhealthyError returns the refresh error once the background refreshes of the repository have
+// failed failureThreshold consecutive times, so that occasional failures do not mark it as failed.
+func (r *cachedRepository) getUnhealthyError() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.consecutiveFailures < r.failureThreshold {
+		return nil
+	}
+	return r.refreshError
+}
+
+// waitForInitialSync blocks until the first fetch of package revisions has completed and
+// returns its error, if any.
+func (r *cachedRepository) waitForInitialSync(ctx context.Context) error {
+	select {
+	case <-r.synced:
+		return r.getRefreshError()
+	case <-ctx.Done():
+		return fmt.Errorf("initial sync not completed: %w", ctx.Err())
+	}
+}
+
 func (r *cachedRepository) getPackages(ctx context.Context, filter repository.ListPackageRevisionFilter, forceRefresh bool) ([]repository.PackageRevision, error) {
+	packages, err := r.loadPackages(ctx, forceRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	comparator := r.comparator
+	r.mutex.Unlock()
+
+	return toPackageRevisionSlice(packages, filter, comparator), nil
+}
+
+// loadPackages returns the cached package revisions, fetching them from the backend if they are
+// not cached, a refresh is forced, or the cache is disabled for the repository.
+func (r *cachedRepository) loadPackages(ctx context.Context, forceRefresh bool) ([]*cachedPackageRevision, error) {
 	r.mutex.Lock()
 	packages := r.cachedPackages
 	err := r.refreshError
+	disabled := r.cacheDisabled
 	r.mutex.Unlock()
 
-	if forceRefresh {
+	if forceRefresh || disabled {
 		packages = nil
 	}
 
 	if packages == nil {
 		// TODO: Avoid simultaneous fetches?
 		// TODO: Push-down partial refresh?
-		p, err := r.repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
-		if err == nil {
-			packages = toCachedPackageRevisionSlice(p)
-		}
+		var p []repository.PackageRevision
+		p, err = r.repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
 
 		r.mutex.Lock()
-		r.cachedPackages = packages
-		r.refreshError = err
+		if err == nil {
+			packages = r.restoreFrozen(r.toCachedPackageRevisionSlice(p))
+			if err = r.identifyLatestRevisions(packages); err != nil {
+				err = fmt.Errorf("repository %q: %w", r.id, err)
+				packages = nil
+			} else {
+				r.orphanedDrafts = identifyOrphanedDrafts(packages, r.orphanedDraftAge, time.Now())
+				if len(r.orphanedDrafts) > 0 {
+					klog.Infof("repository %q: found %d orphaned drafts", r.id, len(r.orphanedDrafts))
+				}
+			}
+		}
+		if !r.cacheDisabled {
+			r.cachedPackages = packages
+			r.refreshError = err
+		}
 		r.mutex.Unlock()
+
+		r.syncedOnce.Do(func() { close(r.synced) })
 	}
 
 	if err != nil {
 		return nil, err
 	}
+	return packages, nil
+}
+
+// prefetchPackage fetches the revisions of a single package, using a push-down filter, and
+// replaces the cached revisions of that package. If the repository is not cached yet, all
+// package revisions are fetched because a partial cache cannot be distinguished from a full one.
+func (r *cachedRepository) prefetchPackage(ctx context.Context, packageName string) error {
+	r.mutex.Lock()
+	loaded := r.cachedPackages != nil
+	disabled := r.cacheDisabled
+	r.mutex.Unlock()
 
-	return toPackageRevisionSlice(packages, filter), nil
+	if disabled {
+		// Nothing is cached; every read goes to the backend.
+		return nil
+	}
+	if !loaded {
+		_, err := r.getPackages(ctx, repository.ListPackageRevisionFilter{}, false)
+		return err
+	}
+
+	fetched, err := r.repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: packageName})
+	if err != nil {
+		return fmt.Errorf("cannot fetch revisions of package %q in repository %q: %w", packageName, r.id, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cachedPackages == nil {
+		// Flushed concurrently; the next read refreshes the whole repository.
+		return nil
+	}
+
+	packages := make([]*cachedPackageRevision, 0, len(r.cachedPackages)+len(fetched))
+	for _, current := range r.cachedPackages {
+		// Keep frozen revisions; drop the others of the prefetched package.
+		if current.Key().Package != packageName || r.frozen[current.KubeObjectName()] != nil {
+			packages = append(packages, current)
+		}
+	}
+	for _, pr := range fetched {
+		if pr.Key().Package != packageName || r.frozen[pr.KubeObjectName()] != nil {
+			continue
+		}
+		packages = append(packages, r.newCachedPackageRevision(pr))
+	}
+	if err := r.identifyLatestRevisions(packages); err != nil {
+		return fmt.Errorf("repository %q: %w", r.id, err)
+	}
+	r.cachedPackages = packages
+	return nil
+}
+
+// restoreFrozen replaces refreshed package revisions with their frozen counterparts.
+// Must be called with the mutex held.
+func (r *cachedRepository) restoreFrozen(packages []*cachedPackageRevision) []*cachedPackageRevision {
+	if len(r.frozen) == 0 {
+		return packages
+	}
+
+	seen := map[string]bool{}
+	for i, current := range packages {
+		name := current.KubeObjectName()
+		frozen, ok := r.frozen[name]
+		if !ok {
+			continue
+		}
+		seen[name] = true
+		if got, want := current.GetPackageRevision().ResourceVersion, frozen.GetPackageRevision().ResourceVersion; got != want {
+			klog.Warningf("repository %q reports different content for frozen package revision %q (got %q, frozen %q); keeping frozen content", r.id, name, got, want)
+		}
+		packages[i] = r.newCachedPackageRevision(frozen)
+	}
+	for name, frozen := range r.frozen {
+		if !seen[name] {
+			klog.Warningf("repository %q no longer reports frozen package revision %q; keeping frozen content", r.id, name)
+			packages = append(packages, r.newCachedPackageRevision(frozen))
+		}
+	}
+	return packages
+}
+
+// FreezePackageRevision pins the cached content of a published package revision so that
+// subsequent background refreshes never overwrite it.
+func (r *cachedRepository) FreezePackageRevision(pr repository.PackageRevision) error {
+	if got, want := pr.Lifecycle(), v1alpha1.PackageRevisionLifecyclePublished; got != want {
+		return fmt.Errorf("cannot freeze package revision %q in lifecycle %q; must be %q", pr.KubeObjectName(), got, want)
+	}
+	// Unwrap
+	if cached, ok := pr.(*cachedPackageRevision); ok {
+		pr = cached.PackageRevision
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.frozen == nil {
+		r.frozen = map[string]repository.PackageRevision{}
+	}
+	r.frozen[pr.KubeObjectName()] = pr
+	return nil
+}
+
+// SetAlias points an alias, such as "stable", of a package at a revision of the package.
+// The alias replaces any previous revision of the same alias.
+func (r *cachedRepository) SetAlias(packageName, alias, revision string) error {
+	if packageName == "" || alias == "" || revision == "" {
+		return fmt.Errorf("package, alias and revision are required; got %q, %q, %q", packageName, alias, revision)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.aliases == nil {
+		r.aliases = map[string]map[string]string{}
+	}
+	if r.aliases[packageName] == nil {
+		r.aliases[packageName] = map[string]string{}
+	}
+	r.aliases[packageName][alias] = revision
+	return nil
+}
+
+// RemoveAlias removes an alias of a package, if it exists.
+func (r *cachedRepository) RemoveAlias(packageName, alias string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.aliases[packageName], alias)
+	if len(r.aliases[packageName]) == 0 {
+		delete(r.aliases, packageName)
+	}
+}
+
+// ResolveAlias returns the package revision an alias of a package points at. If both a published
+// and an unpublished revision match, the published one is returned.
+func (r *cachedRepository) ResolveAlias(ctx context.Context, packageName, alias string) (repository.PackageRevision, error) {
+	r.mutex.Lock()
+	revision, ok := r.aliases[packageName][alias]
+	r.mutex.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("package %q has no alias %q", packageName, alias)
+	}
+
+	revisions, err := r.getPackages(ctx, repository.ListPackageRevisionFilter{Package: packageName, Revision: revision}, false)
+	if err != nil {
+		return nil, err
+	}
+	var resolved repository.PackageRevision
+	for _, pr := range revisions {
+		if resolved == nil || pr.Lifecycle() == v1alpha1.PackageRevisionLifecyclePublished {
+			resolved = pr
+		}
+	}
+	if resolved == nil {
+		return nil, fmt.Errorf("alias %q of package %q points at revision %q, which does not exist", alias, packageName, revision)
+	}
+	return resolved, nil
+}
+
+var _ repository.ResourcesBatchReader = &cachedRepository{}
+
+// GetResourcesBatch returns the resources of the package revisions of the repository, keyed by
+// KubeObjectName. Each package revision is read once, and together with the others if the
+// backend supports it.
+func (r *cachedRepository) GetResourcesBatch(ctx context.Context, revisions []repository.PackageRevision) (map[string]*v1alpha1.PackageRevisionResources, error) {
+	ctx, span := tracer.Start(ctx, "cachedRepository::GetResourcesBatch", trace.WithAttributes())
+	defer span.End()
+
+	unwrapped := make([]repository.PackageRevision, 0, len(revisions))
+	seen := map[string]bool{}
+	for _, pr := range revisions {
+		name := pr.KubeObjectName()
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if cached, ok := pr.(*cachedPackageRevision); ok {
+			pr = cached.PackageRevision
+		}
+		unwrapped = append(unwrapped, pr)
+	}
+
+	if batch, ok := r.repo.(repository.ResourcesBatchReader); ok {
+		return batch.GetResourcesBatch(ctx, unwrapped)
+	}
+
+	result := make(map[string]*v1alpha1.PackageRevisionResources, len(unwrapped))
+	for _, pr := range unwrapped {
+		resources, err := pr.GetResources(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read resources of package revision %q: %w", pr.KubeObjectName(), err)
+		}
+		result[pr.KubeObjectName()] = resources
+	}
+	return result, nil
 }
 
 func (r *cachedRepository) getFunctions(ctx context.Context, force bool) ([]repository.Function, error) {
 	var functions []repository.Function
 
-	if !force {
-		r.mutex.Lock()
+	r.mutex.Lock()
+	if !force && !r.cacheDisabled {
 		functions = r.cachedFunctions
-		r.mutex.Unlock()
 	}
+	r.mutex.Unlock()
 
 	if functions == nil {
 		fr, ok := (r.repo).(repository.FunctionRepository)
@@ -160,7 +523,9 @@ func (r *cachedRepository) getFunctions(ctx context.Context, force bool) ([]repo
 		}
 
 		r.mutex.Lock()
-		r.cachedFunctions = functions
+		if !r.cacheDisabled {
+			r.cachedFunctions = functions
+		}
 		r.mutex.Unlock()
 	}
 
@@ -197,10 +562,15 @@ func (r *cachedRepository) update(closed repository.PackageRevision) *cachedPack
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	cached := &cachedPackageRevision{PackageRevision: closed}
+	cached := r.newCachedPackageRevision(closed)
+	if r.cacheDisabled {
+		return cached
+	}
 	r.cachedPackages = updateOrAppend(r.cachedPackages, cached)
 	// Recompute latest package revisions.
-	identifyLatestRevisions(r.cachedPackages)
+	if err := r.identifyLatestRevisions(r.cachedPackages); err != nil {
+		klog.Warningf("repository %q: %v", r.id, err)
+	}
 	return cached
 }
 
@@ -223,6 +593,7 @@ func (r *cachedRepository) DeletePackageRevision(ctx context.Context, old reposi
 	}
 
 	r.mutex.Lock()
+	delete(r.frozen, old.KubeObjectName())
 	// TODO: Do something more efficient than a full cache flush
 	r.cachedPackages = nil
 	r.mutex.Unlock()
@@ -230,19 +601,71 @@ func (r *cachedRepository) DeletePackageRevision(ctx context.Context, old reposi
 	return nil
 }
 
+// DeletePackageRevisions deletes the package revisions in order, stopping at the first failure,
+// and flushes the cache once.
+func (r *cachedRepository) DeletePackageRevisions(ctx context.Context, revisions []repository.PackageRevision) error {
+	var deleted []string
+	var err error
+	for _, old := range revisions {
+		// Unwrap
+		unwrapped := old.(*cachedPackageRevision).PackageRevision
+		if err = r.repo.DeletePackageRevision(ctx, unwrapped); err != nil {
+			err = fmt.Errorf("cannot delete package revision %q: %w", old.KubeObjectName(), err)
+			break
+		}
+		deleted = append(deleted, old.KubeObjectName())
+	}
+
+	r.mutex.Lock()
+	for _, name := range deleted {
+		delete(r.frozen, name)
+	}
+	if len(deleted) > 0 {
+		r.cachedPackages = nil
+	}
+	r.mutex.Unlock()
+
+	return err
+}
+
 func (r *cachedRepository) Close() error {
 	r.cancel()
 	return nil
 }
 
+// PausePolling suspends the background refreshes of the repository until ResumePolling is
+// called. The cached package revisions and functions remain available for reads.
+func (r *cachedRepository) PausePolling() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pollingPaused = true
+}
+
+// ResumePolling resumes the background refreshes of the repository after PausePolling.
+func (r *cachedRepository) ResumePolling() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pollingPaused = false
+}
+
+// jitteredInterval returns the interval randomly shortened or lengthened by up to the jitter fraction.
+func jitteredInterval(interval time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	return interval + time.Duration((2*rand.Float64()-1)*jitter*float64(interval))
+}
+
 // pollForever will continue polling until signal channel is closed or ctx is done.
 func (r *cachedRepository) pollForever(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Minute)
+	timer := time.NewTimer(jitteredInterval(pollInterval, r.pollJitter))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			r.pollOnce(ctx)
+			timer.Reset(jitteredInterval(pollInterval, r.pollJitter))
 
 		case <-ctx.Done():
 			klog.V(2).Infof("exiting repository poller, because context is done: %v", ctx.Err())
@@ -252,35 +675,120 @@ func (r *cachedRepository) pollForever(ctx context.Context) {
 }
 
 func (r *cachedRepository) pollOnce(ctx context.Context) {
+	r.mutex.Lock()
+	disabled, paused := r.cacheDisabled, r.pollingPaused
+	r.mutex.Unlock()
+	if disabled {
+		klog.V(2).Infof("skipping background refresh of repo %q; cache is disabled", r.id)
+		return
+	}
+	if paused {
+		klog.V(2).Infof("skipping background refresh of repo %q; polling is paused", r.id)
+		return
+	}
+
 	klog.Infof("background-refreshing repo %q", r.id)
 	ctx, span := tracer.Start(ctx, "Repository::pollOnce", trace.WithAttributes())
 	defer span.End()
 
-	if _, err := r.getPackages(ctx, repository.ListPackageRevisionFilter{}, true); err != nil {
-		klog.Warningf("error polling repo packages %s: %v", r.id, err)
+	if r.pollTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.pollTimeout)
+		defer cancel()
+	}
+
+	var errs []error
+	_, packagesErr := r.getPackages(ctx, repository.ListPackageRevisionFilter{}, true)
+	if packagesErr != nil {
+		klog.Warningf("error polling repo packages %s: %v", r.id, packagesErr)
+		errs = append(errs, packagesErr)
 	}
 	if _, err := r.getFunctions(ctx, true); err != nil {
 		klog.Warningf("error polling repo functions %s: %v", r.id, err)
+		errs = append(errs, err)
+	}
+
+	r.mutex.Lock()
+	r.lastPoll = time.Now()
+	r.lastPollError = utilerrors.NewAggregate(errs)
+	if packagesErr != nil {
+		r.consecutiveFailures++
+	} else {
+		r.consecutiveFailures = 0
 	}
+	r.mutex.Unlock()
 }
 
-func toCachedPackageRevisionSlice(revisions []repository.PackageRevision) []*cachedPackageRevision {
+// status returns the cached state of the repository.
+func (r *cachedRepository) status() RepositoryStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	err := r.lastPollError
+	if r.lastPoll.IsZero() {
+		err = r.refreshError
+	}
+	return RepositoryStatus{
+		ID:               r.id,
+		Type:             r.repoType,
+		URL:              r.url,
+		PackageRevisions: len(r.cachedPackages),
+		Functions:        len(r.cachedFunctions),
+		LastPoll:         r.lastPoll,
+		LastError:        err,
+		PollingPaused:    r.pollingPaused,
+
+		ConsecutiveFailures: r.consecutiveFailures,
+		Unhealthy:           r.consecutiveFailures > 0 && r.consecutiveFailures >= r.failureThreshold,
+	}
+}
+
+func (r *cachedRepository) toCachedPackageRevisionSlice(revisions []repository.PackageRevision) []*cachedPackageRevision {
 	result := make([]*cachedPackageRevision, len(revisions))
 	for i := range revisions {
-		current := &cachedPackageRevision{
-			PackageRevision:  revisions[i],
-			isLatestRevision: false,
-		}
-		result[i] = current
+		result[i] = r.newCachedPackageRevision(revisions[i])
 	}
-	identifyLatestRevisions(result)
 	return result
 }
 
-func identifyLatestRevisions(result []*cachedPackageRevision) {
+// newCachedPackageRevision wraps a package revision of the backend.
+func (r *cachedRepository) newCachedPackageRevision(pr repository.PackageRevision) *cachedPackageRevision {
+	return &cachedPackageRevision{
+		PackageRevision: pr,
+		resources:       r.resources,
+		resourcesKey:    r.id + "/" + pr.KubeObjectName(),
+	}
+}
+
+// identifyLatestRevisions computes the latest package revisions, unless disabled for the repository.
+// Must be called with the mutex held.
+func (r *cachedRepository) identifyLatestRevisions(packages []*cachedPackageRevision) error {
+	if r.latestRevisionDisabled {
+		for _, current := range packages {
+			current.isLatestRevision = false
+		}
+		return nil
+	}
+	for _, current := range packages {
+		current.latestLabels = r.latestLabels
+	}
+	return identifyLatestRevisions(packages, r.tiePolicy, r.comparator, r.minimumLatestRevisions)
+}
+
+// belowMinimumLatestRevision returns true if the revision is below the minimum revision of its
+// package which may be the latest, if any.
+func belowMinimumLatestRevision(key repository.PackageRevisionKey, comparator RevisionComparator, minimums map[string]string) bool {
+	minimum, ok := minimums[key.Package]
+	return ok && comparator.Compare(key.Revision, minimum) < 0
+}
+
+// identifyLatestRevisions marks the latest published revision of each package. Revisions below
+// the minimum revision of their package, if any, are not candidates.
+func identifyLatestRevisions(result []*cachedPackageRevision, policy LatestTiePolicy, comparator RevisionComparator, minimums map[string]string) error {
 	// Compute the latest among the different revisions of the same package.
 	// The map is keyed by the package name; Values are the latest revision found so far.
 	latest := map[string]*cachedPackageRevision{}
+	var ties []string
 	for _, current := range result {
 		current.isLatestRevision = false // Clear all values
 
@@ -291,19 +799,28 @@ func identifyLatestRevisions(result []*cachedPackageRevision) {
 		}
 
 		currentKey := current.Key()
+		// Revisions the comparator cannot order are never latest, and are not compared.
+		if !comparator.IsValid(currentKey.Revision) || belowMinimumLatestRevision(currentKey, comparator, minimums) {
+			continue
+		}
 		if previous, ok := latest[currentKey.Package]; ok {
 			previousKey := previous.Key()
-			switch cmp := semver.Compare(currentKey.Revision, previousKey.Revision); {
+			switch cmp := comparator.Compare(currentKey.Revision, previousKey.Revision); {
 			case cmp == 0:
 				// Same revision.
-				klog.Warningf("Encountered package revisions whose versions compare equal: %q, %q", currentKey, previousKey)
+				winner, err := breakLatestTie(policy, previous, current)
+				if err != nil {
+					ties = append(ties, err.Error())
+					continue
+				}
+				latest[currentKey.Package] = winner
 			case cmp < 0:
 				// currentKey.Revision < previousKey.Revision; no change
 			case cmp > 0:
 				// currentKey.Revision > previousKey.Revision; update latest
 				latest[currentKey.Package] = current
 			}
-		} else if semver.IsValid(currentKey.Revision) {
+		} else {
 			// First revision of the specific package; candidate for the latest.
 			latest[currentKey.Package] = current
 		}
@@ -312,43 +829,350 @@ func identifyLatestRevisions(result []*cachedPackageRevision) {
 	for _, v := range latest {
 		v.isLatestRevision = true
 	}
+	if len(ties) > 0 {
+		return errors.New(strings.Join(ties, "; "))
+	}
+	return nil
+}
+
+// breakLatestTie selects one of two published package revisions whose versions compare equal.
+func breakLatestTie(policy LatestTiePolicy, previous, current *cachedPackageRevision) (*cachedPackageRevision, error) {
+	previousKey, currentKey := previous.Key(), current.Key()
+	winner := previous
+	switch policy {
+	case LatestTieFail:
+		return nil, fmt.Errorf("package revisions %q and %q have versions which compare equal", previousKey, currentKey)
+	case LatestTieNewest:
+		if current.GetPackageRevision().CreationTimestamp.After(previous.GetPackageRevision().CreationTimestamp.Time) {
+			winner = current
+		}
+	case LatestTieMostTasks:
+		if len(current.GetPackageRevision().Spec.Tasks) > len(previous.GetPackageRevision().Spec.Tasks) {
+			winner = current
+		}
+	default:
+		klog.Warningf("Encountered package revisions whose versions compare equal: %q, %q", currentKey, previousKey)
+		return winner, nil
+	}
+	klog.Infof("Package revisions %q and %q have versions which compare equal; %q tiebreak selected %q", previousKey, currentKey, policy, winner.Key())
+	return winner, nil
+}
+
+// RecomputeLatest recomputes the latest package revisions from the cached package revisions,
+// without refreshing them from the repository. Reconcilers use it to repair stale latest labels,
+// for example after the repository was edited directly, when polling is slow or disabled.
+func (r *cachedRepository) RecomputeLatest() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cachedPackages == nil {
+		// Nothing is cached; the latest revisions are computed when the packages are loaded.
+		return nil
+	}
+	if err := r.identifyLatestRevisions(r.cachedPackages); err != nil {
+		return fmt.Errorf("repository %q: %w", r.id, err)
+	}
+	return nil
 }
 
-func toPackageRevisionSlice(cached []*cachedPackageRevision, filter repository.ListPackageRevisionFilter) []repository.PackageRevision {
+// setBackend records the type and address of the backend of the repository, stripping any
+// credentials from the address.
+func (r *cachedRepository) setBackend(repoType configapi.RepositoryType, address string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.repoType = repoType
+	r.url = sanitizeURL(address)
+}
+
+func (r *cachedRepository) setLatestRevisionDisabled(disabled bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.latestRevisionDisabled != disabled {
+		r.latestRevisionDisabled = disabled
+		if r.cachedPackages != nil {
+			if err := r.identifyLatestRevisions(r.cachedPackages); err != nil {
+				klog.Warningf("repository %q: %v", r.id, err)
+			}
+		}
+	}
+}
+
+// setRevisionComparator replaces the comparator registered under the given name and
+// recomputes the latest package revisions if the name changed.
+func (r *cachedRepository) setRevisionComparator(name string, comparator RevisionComparator) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.comparatorName != name {
+		r.comparatorName = name
+		r.comparator = comparator
+		if r.cachedPackages != nil {
+			if err := r.identifyLatestRevisions(r.cachedPackages); err != nil {
+				klog.Warningf("repository %q: %v", r.id, err)
+			}
+		}
+	}
+}
+
+// setMinimumLatestRevisions sets the lowest revision of each package which may be the latest,
+// and recomputes the latest revisions if they changed.
+func (r *cachedRepository) setMinimumLatestRevisions(minimums map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !reflect.DeepEqual(r.minimumLatestRevisions, minimums) {
+		r.minimumLatestRevisions = minimums
+		if r.cachedPackages != nil {
+			if err := r.identifyLatestRevisions(r.cachedPackages); err != nil {
+				klog.Warningf("repository %q: %v", r.id, err)
+			}
+		}
+	}
+}
+
+// setCacheDisabled enables or disables caching; disabling drops the cached content.
+func (r *cachedRepository) setCacheDisabled(disabled bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cacheDisabled != disabled {
+		r.cacheDisabled = disabled
+		r.cachedPackages = nil
+		r.cachedFunctions = nil
+		r.refreshError = nil
+		r.consecutiveFailures = 0
+		klog.Infof("repository %q: cache disabled: %t", r.id, disabled)
+	}
+}
+
+func (r *cachedRepository) setRetentionPolicy(policy *configapi.RetentionPolicy) {
+	max := 0
+	if policy != nil {
+		max = policy.MaxPublishedRevisions
+	}
+
+	r.mutex.Lock()
+	r.maxPublishedRevisions = max
+	r.mutex.Unlock()
+}
+
+// ListExpiredPackageRevisions returns the published package revisions which exceed the repository
+// retention policy, i.e. all but the most recent published revisions of each package. The revisions
+// are not deleted; that is left to the caller. Frozen revisions and revisions whose version is not
+// a valid semantic version are never reported.
+func (r *cachedRepository) ListExpiredPackageRevisions(ctx context.Context) ([]repository.PackageRevision, error) {
+	packages, err := r.loadPackages(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return identifyExpiredRevisions(packages, r.maxPublishedRevisions, r.frozen, r.comparator), nil
+}
+
+// OrphanedDraft is a draft package revision which was never closed.
+type OrphanedDraft struct {
+	PackageRevision repository.PackageRevision
+	// Created is the creation time of the draft.
+	Created time.Time
+	// Age is the age of the draft when it was reported.
+	Age time.Duration
+}
+
+// ListOrphanedDrafts returns the draft package revisions which, as of the last refresh, were older
+// than the configured orphaned draft age and had no closed revision of the same package and version.
+// The drafts are not deleted; that is left to the caller.
+func (r *cachedRepository) ListOrphanedDrafts(ctx context.Context) ([]OrphanedDraft, error) {
+	if _, err := r.getPackages(ctx, repository.ListPackageRevisionFilter{}, false); err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	result := make([]OrphanedDraft, 0, len(r.orphanedDrafts))
+	for _, draft := range r.orphanedDrafts {
+		draft.Age = now.Sub(draft.Created)
+		result = append(result, draft)
+	}
+	return result, nil
+}
+
+// identifyOrphanedDrafts returns the drafts created more than maxAge before now which have no
+// proposed or published revision with the same package and version.
+func identifyOrphanedDrafts(revisions []*cachedPackageRevision, maxAge time.Duration, now time.Time) []OrphanedDraft {
+	if maxAge <= 0 {
+		return nil
+	}
+
+	closed := map[repository.PackageRevisionKey]bool{}
+	for _, current := range revisions {
+		if current.Lifecycle() != v1alpha1.PackageRevisionLifecycleDraft {
+			key := current.Key()
+			closed[repository.PackageRevisionKey{Package: key.Package, Revision: key.Revision}] = true
+		}
+	}
+
+	var orphaned []OrphanedDraft
+	for _, current := range revisions {
+		if current.Lifecycle() != v1alpha1.PackageRevisionLifecycleDraft {
+			continue
+		}
+		key := current.Key()
+		if closed[repository.PackageRevisionKey{Package: key.Package, Revision: key.Revision}] {
+			continue
+		}
+		created := current.GetPackageRevision().CreationTimestamp.Time
+		if created.IsZero() || now.Sub(created) <= maxAge {
+			continue
+		}
+		orphaned = append(orphaned, OrphanedDraft{
+			PackageRevision: current,
+			Created:         created,
+			Age:             now.Sub(created),
+		})
+	}
+	sort.Slice(orphaned, func(i, j int) bool {
+		return orphaned[i].PackageRevision.KubeObjectName() < orphaned[j].PackageRevision.KubeObjectName()
+	})
+	return orphaned
+}
+
+// verifyLatestRevisions recomputes the latest package revisions and checks their invariants.
+func (r *cachedRepository) verifyLatestRevisions(ctx context.Context) error {
+	packages, err := r.loadPackages(ctx, false)
+	if err != nil {
+		return fmt.Errorf("cannot list packages of repository %q: %w", r.id, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// There are no latest revisions to verify.
+	if r.latestRevisionDisabled {
+		return nil
+	}
+
+	if err := r.identifyLatestRevisions(packages); err != nil {
+		return fmt.Errorf("repository %q: %w", r.id, err)
+	}
+
+	latest := map[string][]string{}
+	published := map[string]bool{}
+	for _, current := range packages {
+		key := current.Key()
+		// Packages whose revisions are all below their minimum have no latest revision by design.
+		if current.Lifecycle() == v1alpha1.PackageRevisionLifecyclePublished && r.comparator.IsValid(key.Revision) &&
+			!belowMinimumLatestRevision(key, r.comparator, r.minimumLatestRevisions) {
+			published[key.Package] = true
+		}
+		if current.isLatestRevision {
+			if current.Lifecycle() != v1alpha1.PackageRevisionLifecyclePublished {
+				return fmt.Errorf("repository %q: package revision %q is labeled latest but is %s", r.id, current.KubeObjectName(), current.Lifecycle())
+			}
+			latest[key.Package] = append(latest[key.Package], key.Revision)
+		}
+	}
+
+	var violations []string
+	for pkg := range published {
+		if revisions := latest[pkg]; len(revisions) != 1 {
+			violations = append(violations, fmt.Sprintf("package %q has %d latest revisions %v", pkg, len(revisions), revisions))
+		}
+	}
+	if len(violations) > 0 {
+		sort.Strings(violations)
+		return fmt.Errorf("repository %q: %s", r.id, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// identifyExpiredRevisions returns the published revisions of each package beyond the max most recent ones.
+func identifyExpiredRevisions(revisions []*cachedPackageRevision, max int, frozen map[string]repository.PackageRevision, comparator RevisionComparator) []repository.PackageRevision {
+	if max <= 0 {
+		return nil
+	}
+
+	published := map[string][]*cachedPackageRevision{}
+	for _, current := range revisions {
+		if current.Lifecycle() != v1alpha1.PackageRevisionLifecyclePublished {
+			continue
+		}
+		key := current.Key()
+		if !comparator.IsValid(key.Revision) {
+			continue
+		}
+		published[key.Package] = append(published[key.Package], current)
+	}
+
+	var expired []*cachedPackageRevision
+	for _, list := range published {
+		// Most recent revisions first.
+		sort.SliceStable(list, func(i, j int) bool {
+			return comparator.Compare(list[i].Key().Revision, list[j].Key().Revision) > 0
+		})
+		for i := max; i < len(list); i++ {
+			if _, ok := frozen[list[i].KubeObjectName()]; ok {
+				continue
+			}
+			expired = append(expired, list[i])
+		}
+	}
+	return toPackageRevisionSlice(expired, repository.ListPackageRevisionFilter{}, comparator)
+}
+
+func toPackageRevisionSlice(cached []*cachedPackageRevision, filter repository.ListPackageRevisionFilter, comparator RevisionComparator) []repository.PackageRevision {
 	result := make([]repository.PackageRevision, 0, len(cached))
 	for _, p := range cached {
 		if filter.Matches(p) {
 			result = append(result, p)
 		}
 	}
-	sort.Slice(result, func(i, j int) bool {
-		ki, kl := result[i].Key(), result[j].Key()
-		switch res := strings.Compare(ki.Package, kl.Package); {
-		case res < 0:
-			return true
-		case res > 0:
-			return false
-		default:
-			// Equal. Compare next element
-		}
-		switch res := strings.Compare(ki.Revision, kl.Revision); {
-		case res < 0:
-			return true
-		case res > 0:
-			return false
-		default:
-			// Equal. Compare next element
-		}
-		switch res := strings.Compare(string(result[i].Lifecycle()), string(result[j].Lifecycle())); {
-		case res < 0:
-			return true
-		case res > 0:
-			return false
-		default:
-			// Equal. Compare next element
-		}
-
-		return strings.Compare(result[i].KubeObjectName(), result[j].KubeObjectName()) < 0
-	})
+	if filter.SortDirection == repository.SortDescending {
+		sort.Slice(result, func(i, j int) bool {
+			return lessPackageRevision(result[j], result[i], comparator)
+		})
+	} else {
+		sort.Slice(result, func(i, j int) bool {
+			return lessPackageRevision(result[i], result[j], comparator)
+		})
+	}
 	return result
 }
+
+// lessPackageRevision orders package revisions by package name, revision, lifecycle, and
+// object name.
+func lessPackageRevision(a, b repository.PackageRevision, comparator RevisionComparator) bool {
+	ka, kb := a.Key(), b.Key()
+	switch res := strings.Compare(ka.Package, kb.Package); {
+	case res < 0:
+		return true
+	case res > 0:
+		return false
+	default:
+		// Equal. Compare next element
+	}
+	switch res := compareRevisions(comparator, ka.Revision, kb.Revision); {
+	case res < 0:
+		return true
+	case res > 0:
+		return false
+	default:
+		// Equal. Compare next element
+	}
+	switch res := strings.Compare(string(a.Lifecycle()), string(b.Lifecycle())); {
+	case res < 0:
+		return true
+	case res > 0:
+		return false
+	default:
+		// Equal. Compare next element
+	}
+
+	return strings.Compare(a.KubeObjectName(), b.KubeObjectName()) < 0
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+)
+
+// resourcesCache is a cache of the resources of published package revisions, shared by all
+// repositories, whose total size is bounded. When adding resources exceeds the budget, the least
+// recently used resources are evicted; they are read from the backend again when next needed.
+// Only resources are evicted; the package revisions themselves stay cached by their repository.
+// A nil *resourcesCache caches nothing.
+type resourcesCache struct {
+	// Maximum total size of the cached files, in bytes.
+	maxBytes int64
+
+	mutex sync.Mutex
+	size  int64
+	// Entries ordered from the most to the least recently used.
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type resourcesCacheEntry struct {
+	key       string
+	resources *v1alpha1.PackageRevisionResources
+	size      int64
+}
+
+func newResourcesCache(maxBytes int64) *resourcesCache {
+	return &resourcesCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// get returns a copy of the cached resources, if any, and marks them as recently used.
+func (c *resourcesCache) get(key string) (*v1alpha1.PackageRevisionResources, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*resourcesCacheEntry).resources.DeepCopy(), true
+}
+
+// add caches a copy of the resources, evicting the least recently used resources to stay within
+// the budget. Resources larger than the whole budget are not cached.
+func (c *resourcesCache) add(key string, resources *v1alpha1.PackageRevisionResources) {
+	if c == nil {
+		return
+	}
+	size := resourcesSize(resources)
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	c.entries[key] = c.lru.PushFront(&resourcesCacheEntry{
+		key:       key,
+		resources: resources.DeepCopy(),
+		size:      size,
+	})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops the entry. Must be called with the mutex held.
+func (c *resourcesCache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*resourcesCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// resourcesSize approximates the memory held by the resources by the size of their files.
+func resourcesSize(resources *v1alpha1.PackageRevisionResources) int64 {
+	var size int64
+	for k, v := range resources.Spec.Resources {
+		size += int64(len(k) + len(v))
+	}
+	return size
+}
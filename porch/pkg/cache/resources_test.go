@@ -0,0 +1,147 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"testing"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+)
+
+func newTestResources(file, contents string) *api.PackageRevisionResources {
+	return &api.PackageRevisionResources{Spec: api.PackageRevisionResourcesSpec{
+		Resources: map[string]string{file: contents},
+	}}
+}
+
+func TestResourcesCache(t *testing.T) {
+	// Each entry below takes 8 bytes; the budget holds two.
+	c := newResourcesCache(16)
+	c.add("a", newTestResources("a", "aaaaaaa"))
+	c.add("b", newTestResources("b", "bbbbbbb"))
+	if _, ok := c.get("a"); !ok {
+		t.Fatalf("Resources a are not cached")
+	}
+	// b is now the least recently used.
+	c.add("c", newTestResources("c", "ccccccc"))
+	if _, ok := c.get("b"); ok {
+		t.Errorf("Least recently used resources b were not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("Resources %s were evicted", key)
+		}
+	}
+
+	c.add("large", newTestResources("large", "more than the whole budget"))
+	if _, ok := c.get("large"); ok {
+		t.Errorf("Resources larger than the budget were cached")
+	}
+
+	got, _ := c.get("a")
+	got.Spec.Resources["a"] = "modified"
+	if got, _ := c.get("a"); got.Spec.Resources["a"] != "aaaaaaa" {
+		t.Errorf("Modifying returned resources modified the cache: %q", got.Spec.Resources["a"])
+	}
+
+	var none *resourcesCache
+	none.add("a", newTestResources("a", "aaaaaaa"))
+	if _, ok := none.get("a"); ok {
+		t.Errorf("nil cache returned resources")
+	}
+}
+
+// countingPackageRevision counts the reads of its resources.
+type countingPackageRevision struct {
+	fake.PackageRevision
+	reads int
+}
+
+func (pr *countingPackageRevision) GetResources(ctx context.Context) (*api.PackageRevisionResources, error) {
+	pr.reads++
+	return pr.PackageRevision.GetResources(ctx)
+}
+
+func TestCachedPackageRevisionResources(t *testing.T) {
+	newRevision := func(pkg, revision string, lifecycle api.PackageRevisionLifecycle) *countingPackageRevision {
+		return &countingPackageRevision{PackageRevision: fake.PackageRevision{
+			Name:               pkg + "-" + revision,
+			PackageRevisionKey: repository.PackageRevisionKey{Repository: "repo", Package: pkg, Revision: revision},
+			PackageLifecycle:   lifecycle,
+			PackageRevision:    &api.PackageRevision{},
+			Resources:          newTestResources("Kptfile", "kind: Kptfile"),
+		}}
+	}
+	a := newRevision("a", "v1", api.PackageRevisionLifecyclePublished)
+	b := newRevision("b", "v1", api.PackageRevisionLifecyclePublished)
+	draft := newRevision("a", "v2", api.PackageRevisionLifecycleDraft)
+	backend := &listingRepository{revisions: []repository.PackageRevision{a, b, draft}}
+	// The budget holds the resources of one package revision.
+	cached := newRepository("fake://repo", backend, cachedRepositoryOptions{resources: newResourcesCache(20)})
+	defer cached.Close()
+
+	ctx := context.Background()
+	read := func(name string) {
+		revisions, err := cached.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
+		if err != nil {
+			t.Fatalf("ListPackageRevisions failed: %v", err)
+		}
+		for _, pr := range revisions {
+			if pr.KubeObjectName() == name {
+				if _, err := pr.GetResources(ctx); err != nil {
+					t.Fatalf("GetResources failed: %v", err)
+				}
+				return
+			}
+		}
+		t.Fatalf("Package revision %q not found", name)
+	}
+
+	read("a-v1")
+	read("a-v1")
+	if got, want := a.reads, 1; got != want {
+		t.Errorf("Resources of a published package revision read %d times; want %d", got, want)
+	}
+	read("a-v2")
+	read("a-v2")
+	if got, want := draft.reads, 2; got != want {
+		t.Errorf("Resources of a draft read %d times; want %d", got, want)
+	}
+
+	// Reading b evicts a, which is read from the backend again.
+	read("b-v1")
+	read("a-v1")
+	if got, want := a.reads, 2; got != want {
+		t.Errorf("Resources of an evicted package revision read %d times; want %d", got, want)
+	}
+
+	// Evicting resources keeps the package revisions and their latest revisions.
+	revisions, err := cached.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
+	if err != nil {
+		t.Fatalf("ListPackageRevisions failed: %v", err)
+	}
+	if got, want := len(revisions), 3; got != want {
+		t.Errorf("ListPackageRevisions returned %d package revisions; want %d", got, want)
+	}
+	for _, pr := range revisions {
+		latest := pr.GetPackageRevision().Labels[api.LatestPackageRevisionKey] == api.LatestPackageRevisionValue
+		if want := pr.Lifecycle() == api.PackageRevisionLifecyclePublished; latest != want {
+			t.Errorf("Package revision %q latest: got %t, want %t", pr.KubeObjectName(), latest, want)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -29,6 +30,7 @@ import (
 	sampleopenapi "github.com/GoogleContainerTools/kpt/porch/api/generated/openapi"
 	porchv1alpha1 "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/apiserver"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/cache"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apiserver/pkg/admission"
@@ -50,6 +52,11 @@ type PorchServerOptions struct {
 	CacheDirectory           string
 	CoreAPIKubeconfigPath    string
 	FunctionRunnerAddress    string
+	StartupLatestCheck       string
+	LatestTiePolicy          string
+	OrphanedDraftAge         time.Duration
+	FailureThreshold         int
+	TrustedUpstreamKeysFile  string
 
 	SharedInformerFactory informers.SharedInformerFactory
 	StdOut                io.Writer
@@ -110,6 +117,19 @@ func NewCommandStartPorchServer(ctx context.Context, defaults *PorchServerOption
 func (o PorchServerOptions) Validate(args []string) error {
 	errors := []error{}
 	errors = append(errors, o.RecommendedOptions.Validate()...)
+	switch o.StartupLatestCheck {
+	case apiserver.StartupLatestCheckNone, apiserver.StartupLatestCheckWarn, apiserver.StartupLatestCheckFail:
+	default:
+		errors = append(errors, fmt.Errorf("invalid --startup-latest-check value %q; must be %q or %q", o.StartupLatestCheck, apiserver.StartupLatestCheckWarn, apiserver.StartupLatestCheckFail))
+	}
+	switch cache.LatestTiePolicy(o.LatestTiePolicy) {
+	case cache.LatestTieWarn, cache.LatestTieFail, cache.LatestTieNewest, cache.LatestTieMostTasks:
+	default:
+		errors = append(errors, fmt.Errorf("invalid --latest-tie-policy value %q; must be %q, %q or %q", o.LatestTiePolicy, cache.LatestTieFail, cache.LatestTieNewest, cache.LatestTieMostTasks))
+	}
+	if o.FailureThreshold < 1 {
+		errors = append(errors, fmt.Errorf("invalid --repository-failure-threshold value %d; must be at least 1", o.FailureThreshold))
+	}
 	return utilerrors.NewAggregate(errors)
 }
 
@@ -179,9 +199,14 @@ func (o *PorchServerOptions) Config() (*apiserver.Config, error) {
 	config := &apiserver.Config{
 		GenericConfig: serverConfig,
 		ExtraConfig: apiserver.ExtraConfig{
-			CoreAPIKubeconfigPath: o.CoreAPIKubeconfigPath,
-			CacheDirectory:        o.CacheDirectory,
-			FunctionRunnerAddress: o.FunctionRunnerAddress,
+			CoreAPIKubeconfigPath:   o.CoreAPIKubeconfigPath,
+			CacheDirectory:          o.CacheDirectory,
+			FunctionRunnerAddress:   o.FunctionRunnerAddress,
+			StartupLatestCheck:      o.StartupLatestCheck,
+			LatestTiePolicy:         cache.LatestTiePolicy(o.LatestTiePolicy),
+			OrphanedDraftAge:        o.OrphanedDraftAge,
+			FailureThreshold:        o.FailureThreshold,
+			TrustedUpstreamKeysFile: o.TrustedUpstreamKeysFile,
 		},
 	}
 	return config, nil
@@ -226,4 +251,13 @@ func (o *PorchServerOptions) AddFlags(fs *pflag.FlagSet) {
 
 	fs.StringVar(&o.FunctionRunnerAddress, "function-runner", "", "Address of the function runner gRPC service.")
 	fs.StringVar(&o.CacheDirectory, "cache-directory", "", "Directory where Porch server stores repository and package caches.")
+	fs.StringVar(&o.StartupLatestCheck, "startup-latest-check", "", "Verify latest package revisions of all repositories on startup; "+
+		"\"warn\" logs inconsistencies, \"fail\" aborts startup. Disabled if empty.")
+	fs.StringVar(&o.LatestTiePolicy, "latest-tie-policy", "", "Selects the latest package revision among published revisions whose versions compare equal; "+
+		"\"fail\" fails the repository refresh, \"newest\" selects the most recently created, \"most-tasks\" selects the one with the most tasks. "+
+		"If empty, the first revision found is kept and a warning is logged.")
+	fs.DurationVar(&o.OrphanedDraftAge, "orphaned-draft-age", 0, "Age beyond which drafts without a closed revision are reported as orphaned. Disabled if zero.")
+	fs.StringVar(&o.TrustedUpstreamKeysFile, "trusted-upstream-keys", "", "File of the armored PGP key ring trusted to sign upstream commits. "+
+		"If set, only Git upstreams whose commits are signed by a trusted key can be cloned.")
+	fs.IntVar(&o.FailureThreshold, "repository-failure-threshold", 1, "Number of consecutive failed background refreshes of a repository after which it is reported as not ready.")
 }
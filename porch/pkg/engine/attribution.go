@@ -0,0 +1,55 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+)
+
+// FileAttribution identifies the mutation which last changed a file of a package.
+type FileAttribution struct {
+	// Mutation is the index of the mutation among the mutations applied, in order.
+	Mutation int
+	// Type is the type of the task recorded by the mutation; empty if it recorded none.
+	Type api.TaskType
+	// Image is the function image of eval tasks, or "render" for the render of the package.
+	Image string
+}
+
+// Attribution maps the files of a package to the mutation which last changed them. Files which
+// no mutation changed are not in the map.
+type Attribution map[string]FileAttribution
+
+// record attributes the files which the mutation added or changed to the mutation, and forgets
+// the files it deleted.
+func (a Attribution) record(mutation int, task *api.Task, before, after map[string]string) {
+	attribution := FileAttribution{Mutation: mutation}
+	if task != nil {
+		attribution.Type = task.Type
+		if task.Eval != nil {
+			attribution.Image = task.Eval.Image
+		}
+	}
+	for name, contents := range after {
+		if old, ok := before[name]; !ok || old != contents {
+			a[name] = attribution
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			delete(a, name)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"testing"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAttribution(t *testing.T) {
+	attribution := Attribution{}
+	attribution.record(0, &api.Task{Type: api.TaskTypeInit}, nil, map[string]string{"Kptfile": "a", "README.md": "a"})
+	attribution.record(1, &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "set-labels"}},
+		map[string]string{"Kptfile": "a", "README.md": "a"},
+		map[string]string{"Kptfile": "b", "README.md": "a", "cm.yaml": "b"})
+	attribution.record(2, nil,
+		map[string]string{"Kptfile": "b", "README.md": "a", "cm.yaml": "b"},
+		map[string]string{"Kptfile": "b", "cm.yaml": "b"})
+
+	want := Attribution{
+		"Kptfile": {Mutation: 1, Type: api.TaskTypeEval, Image: "set-labels"},
+		"cm.yaml": {Mutation: 1, Type: api.TaskTypeEval, Image: "set-labels"},
+	}
+	if diff := cmp.Diff(want, attribution); diff != "" {
+		t.Errorf("Unexpected attribution (-want, +got): %s", diff)
+	}
+}
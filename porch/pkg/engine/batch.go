@@ -0,0 +1,116 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
+)
+
+// BatchResourcesUpdate is one update of UpdatePackageResourcesBatch: the replacement of the
+// resources of a draft package revision, as in UpdatePackageResources.
+//
+// Repositories cannot commit to several package revisions atomically, so the batch is applied in
+// two phases. First the drafts of all package revisions are opened and their resources are
+// replaced and rendered; a failure in this phase leaves every package revision unchanged. Then
+// the drafts are closed one by one. If closing a draft fails, the package revisions closed before
+// it are rolled back by writing back their original resources. The rollback is best effort:
+// readers may observe the updated resources until it completes, and if the rollback itself fails
+// the package revision keeps the update, which is reported in the returned error.
+type BatchResourcesUpdate struct {
+	Repository      *configapi.Repository
+	PackageRevision repository.PackageRevision
+	Old, New        *api.PackageRevisionResources
+}
+
+// rollbackCommitMessage is the commit message of the compensating update of a rolled back
+// package revision.
+const rollbackCommitMessage = "Roll back failed batch update"
+
+func (cad *cadEngine) UpdatePackageResourcesBatch(ctx context.Context, updates []BatchResourcesUpdate) ([]repository.PackageRevision, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::UpdatePackageResourcesBatch", trace.WithAttributes())
+	defer span.End()
+
+	repositoryObjs := make([]*configapi.Repository, 0, len(updates))
+	for _, u := range updates {
+		repositoryObjs = append(repositoryObjs, u.Repository)
+	}
+	release, err := cad.repositoryLimiter.acquireAll(ctx, repositoryObjs)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	drafts := make([]*resourcesDraft, 0, len(updates))
+	for _, u := range updates {
+		d, err := cad.openResourcesDraft(withCommitMessage(ctx, u.New.Annotations), u.Repository, u.PackageRevision, u.Old, u.New)
+		if err != nil {
+			return nil, fmt.Errorf("cannot update package revision %q: %w", u.PackageRevision.KubeObjectName(), err)
+		}
+		drafts = append(drafts, d)
+	}
+
+	return cad.closeBatch(ctx, updates, drafts)
+}
+
+// closeBatch closes the drafts of the updates in order, rolling back the closed ones if closing
+// any draft fails.
+func (cad *cadEngine) closeBatch(ctx context.Context, updates []BatchResourcesUpdate, drafts []*resourcesDraft) ([]repository.PackageRevision, error) {
+	closed := make([]repository.PackageRevision, 0, len(drafts))
+	for i, d := range drafts {
+		pr, err := cad.closeDraft(withCommitMessage(ctx, updates[i].New.Annotations), d.draft, d.render)
+		if err == nil {
+			closed = append(closed, pr)
+			continue
+		}
+
+		err = fmt.Errorf("cannot update package revision %q: %w", updates[i].PackageRevision.KubeObjectName(), err)
+		for j, pr := range closed {
+			if rerr := restoreResources(ctx, drafts[j].repo, pr, drafts[j].original); rerr != nil {
+				klog.Warningf("failed to roll back package revision %q: %v", pr.KubeObjectName(), rerr)
+				err = fmt.Errorf("%w; failed to roll back package revision %q: %v", err, pr.KubeObjectName(), rerr)
+			}
+		}
+		return nil, err
+	}
+	return closed, nil
+}
+
+// restoreResources writes the resources back to the package revision.
+func restoreResources(ctx context.Context, repo repository.Repository, pr repository.PackageRevision, resources repository.PackageResources) error {
+	info := repository.CommitInfoFromContext(ctx)
+	info.Message = rollbackCommitMessage
+	ctx = repository.WithCommitInfo(ctx, info)
+
+	draft, err := repo.UpdatePackage(ctx, pr)
+	if err != nil {
+		return err
+	}
+	if err := draft.UpdateResources(ctx, &api.PackageRevisionResources{
+		Spec: api.PackageRevisionResourcesSpec{
+			Resources: resources.Contents,
+		},
+	}, nil); err != nil {
+		return err
+	}
+	_, err = draft.Close(ctx)
+	return err
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+)
+
+// memoryRepository stores the resources of its package revisions by name.
+type memoryRepository struct {
+	fake.Repository
+	contents map[string]map[string]string
+	messages []string
+}
+
+func (r *memoryRepository) UpdatePackage(_ context.Context, old repository.PackageRevision) (repository.PackageDraft, error) {
+	return &memoryDraft{repo: r, name: old.KubeObjectName()}, nil
+}
+
+// memoryDraft writes its resources to the repository on Close, or fails to close.
+type memoryDraft struct {
+	repo      *memoryRepository
+	name      string
+	contents  map[string]string
+	failClose bool
+}
+
+func (d *memoryDraft) UpdateResources(_ context.Context, new *api.PackageRevisionResources, _ *api.Task) error {
+	d.contents = new.Spec.Resources
+	return nil
+}
+
+func (d *memoryDraft) UpdateLifecycle(context.Context, api.PackageRevisionLifecycle) error {
+	return nil
+}
+
+func (d *memoryDraft) Close(ctx context.Context) (repository.PackageRevision, error) {
+	if d.failClose {
+		return nil, errors.New("push rejected")
+	}
+	d.repo.contents[d.name] = d.contents
+	d.repo.messages = append(d.repo.messages, repository.CommitInfoFromContext(ctx).Message)
+	return &fake.PackageRevision{Name: d.name}, nil
+}
+
+func TestCloseBatch(t *testing.T) {
+	original := map[string]string{"Kptfile": "original"}
+	updated := map[string]string{"Kptfile": "updated"}
+
+	newBatch := func(failLast bool) (*memoryRepository, []BatchResourcesUpdate, []*resourcesDraft) {
+		repo := &memoryRepository{contents: map[string]map[string]string{
+			"repo-a-v1": original,
+			"repo-b-v1": original,
+		}}
+		var updates []BatchResourcesUpdate
+		var drafts []*resourcesDraft
+		for i, name := range []string{"repo-a-v1", "repo-b-v1"} {
+			updates = append(updates, BatchResourcesUpdate{
+				PackageRevision: &fake.PackageRevision{Name: name},
+				New:             &api.PackageRevisionResources{},
+			})
+			drafts = append(drafts, &resourcesDraft{
+				repo:     repo,
+				draft:    &memoryDraft{repo: repo, name: name, contents: updated, failClose: failLast && i == 1},
+				original: repository.PackageResources{Contents: original},
+			})
+		}
+		return repo, updates, drafts
+	}
+
+	cad := &cadEngine{}
+
+	t.Run("success", func(t *testing.T) {
+		repo, updates, drafts := newBatch(false)
+		closed, err := cad.closeBatch(context.Background(), updates, drafts)
+		if err != nil {
+			t.Fatalf("closeBatch failed: %v", err)
+		}
+		if got, want := len(closed), 2; got != want {
+			t.Errorf("closeBatch returned %d package revisions; want %d", got, want)
+		}
+		want := map[string]map[string]string{"repo-a-v1": updated, "repo-b-v1": updated}
+		if diff := cmp.Diff(want, repo.contents); diff != "" {
+			t.Errorf("Unexpected contents (-want, +got): %s", diff)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		repo, updates, drafts := newBatch(true)
+		if _, err := cad.closeBatch(context.Background(), updates, drafts); err == nil {
+			t.Fatalf("closeBatch succeeded unexpectedly")
+		}
+		want := map[string]map[string]string{"repo-a-v1": original, "repo-b-v1": original}
+		if diff := cmp.Diff(want, repo.contents); diff != "" {
+			t.Errorf("Unexpected contents (-want, +got): %s", diff)
+		}
+		if diff := cmp.Diff([]string{"", rollbackCommitMessage}, repo.messages); diff != "" {
+			t.Errorf("Unexpected commit messages (-want, +got): %s", diff)
+		}
+	})
+}
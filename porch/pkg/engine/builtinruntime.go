@@ -91,7 +91,13 @@ type builtinRunner struct {
 	processor fnsdk.ResourceListProcessor
 }
 
-var _ fn.FunctionRunner = &builtinRunner{}
+var _ fn.ConfigurableFunctionRunner = &builtinRunner{}
+
+// SetRunnerOptions implements fn.ConfigurableFunctionRunner. Builtin functions run in-process
+// without network access, so they always run in the network sandbox.
+func (br *builtinRunner) SetRunnerOptions(opts fn.RunnerOptions) error {
+	return nil
+}
 
 func (br *builtinRunner) Run(r io.Reader, w io.Writer) error {
 	return fnsdk.Execute(br.processor, r, w)
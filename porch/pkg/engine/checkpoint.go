@@ -0,0 +1,242 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"k8s.io/klog/v2"
+)
+
+// checkpoint is the progress of an interrupted update of a draft.
+type checkpoint struct {
+	// Index of the last applied mutation.
+	Index int `json:"index"`
+	// Tasks recorded by the applied mutations, in order.
+	Tasks []api.Task `json:"tasks,omitempty"`
+	// Resources of the package after the last applied mutation.
+	Resources map[string]string `json:"resources"`
+}
+
+// checkpointStore persists checkpoints of draft updates as files in a directory, so that an
+// update interrupted by a crash resumes after the last applied mutation when it is retried. The
+// total size of the checkpoints is bounded; the oldest checkpoints are evicted first.
+type checkpointStore struct {
+	mutex    sync.Mutex
+	dir      string
+	maxBytes int64
+}
+
+func newCheckpointStore(dir string, maxBytes int64) (*checkpointStore, error) {
+	// Checkpoints hold the full contents of packages.
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create checkpoint directory %q: %w", dir, err)
+	}
+	return &checkpointStore{dir: dir, maxBytes: maxBytes}, nil
+}
+
+// checkpointKey identifies an update by the values which determine its mutations, so that only a
+// retry of the same update resumes from its checkpoint.
+func checkpointKey(values ...interface{}) string {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// updateCheckpointKey identifies an update of the package revision from its resources to the
+// tasks. If the resources change in between, for example because the draft was edited after the
+// update was interrupted, the update is no longer resumed from its stale checkpoint.
+func updateCheckpointKey(namespace, repositoryName, name string, resources map[string]string, tasks []api.Task) string {
+	return checkpointKey("update", namespace, repositoryName, name, repository.ContentHash(resources), tasks)
+}
+
+func (s *checkpointStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// load returns the checkpoint of the update, if any. Unreadable checkpoints are ignored.
+func (s *checkpointStore) load(key string) (*checkpoint, bool) {
+	if s == nil || key == "" {
+		return nil, false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			klog.Warningf("cannot read checkpoint %s: %v", key, err)
+		}
+		return nil, false
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		klog.Warningf("ignoring invalid checkpoint %s: %v", key, err)
+		return nil, false
+	}
+	return &cp, true
+}
+
+// save replaces the checkpoint of the update. A checkpoint larger than the storage bound is not
+// saved, and older checkpoints are evicted to keep the total size within the bound.
+func (s *checkpointStore) save(key string, cp *checkpoint) error {
+	if s == nil || key == "" {
+		return nil
+	}
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p := s.path(key)
+	if int64(len(b)) > s.maxBytes {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return fmt.Errorf("checkpoint of %d bytes exceeds the limit of %d bytes", len(b), s.maxBytes)
+	}
+	if err := os.WriteFile(p, b, 0600); err != nil {
+		return err
+	}
+	return s.evict(p)
+}
+
+// remove deletes the checkpoint of a completed update.
+func (s *checkpointStore) remove(key string) {
+	if s == nil || key == "" {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		klog.Warningf("cannot remove checkpoint %s: %v", key, err)
+	}
+}
+
+// evict removes the oldest checkpoints, other than keep, until the total size is within the
+// bound. The caller must hold the mutex.
+func (s *checkpointStore) evict(keep string) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var files []os.FileInfo
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, f := range files {
+		if total <= s.maxBytes {
+			break
+		}
+		p := filepath.Join(s.dir, f.Name())
+		if p == keep {
+			continue
+		}
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= f.Size()
+	}
+	return nil
+}
+
+// applyResourceMutationsWithCheckpoints is applyResourceMutations which checkpoints the update
+// after every mutation if checkpointing is enabled. If the update has a checkpoint, the mutations
+// up to the checkpoint are not applied again; the draft is updated with the checkpointed
+// resources, recording the checkpointed tasks, and the remaining mutations are applied.
+func (cad *cadEngine) applyResourceMutationsWithCheckpoints(ctx context.Context, key string, draft repository.PackageDraft, baseResources repository.PackageResources, mutations []mutation) error {
+	if cad.checkpoints == nil {
+		return applyResourceMutations(ctx, draft, baseResources, mutations)
+	}
+
+	mutations = dedupeMutations(mutations)
+	start := 0
+	var tasks []api.Task
+	if cp, ok := cad.checkpoints.load(key); ok && cp.Index < len(mutations) {
+		klog.Infof("resuming update after mutation %d of %d from checkpoint", cp.Index+1, len(mutations))
+		baseResources = repository.PackageResources{Contents: cp.Resources}
+		if err := restoreCheckpoint(ctx, draft, baseResources, cp.Tasks); err != nil {
+			return err
+		}
+		start = cp.Index + 1
+		tasks = cp.Tasks
+	}
+
+	for i := start; i < len(mutations); i++ {
+		applied, task, err := applyResourceMutation(ctx, draft, baseResources, mutations[i])
+		if err != nil {
+			return err
+		}
+		if task != nil {
+			tasks = append(tasks, *task)
+		}
+		baseResources = applied
+
+		// Failing to checkpoint only loses the ability to resume.
+		if err := cad.checkpoints.save(key, &checkpoint{Index: i, Tasks: tasks, Resources: applied.Contents}); err != nil {
+			klog.Warningf("cannot checkpoint update after mutation %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// restoreCheckpoint updates the draft with the checkpointed resources once per checkpointed task,
+// so that the draft records the tasks as if their mutations had been applied.
+func restoreCheckpoint(ctx context.Context, draft repository.PackageDraft, resources repository.PackageResources, tasks []api.Task) error {
+	update := &api.PackageRevisionResources{
+		Spec: api.PackageRevisionResourcesSpec{
+			Resources: resources.Contents,
+		},
+	}
+	if len(tasks) == 0 {
+		return draft.UpdateResources(ctx, update, nil)
+	}
+	for i := range tasks {
+		if err := draft.UpdateResources(ctx, update, &tasks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
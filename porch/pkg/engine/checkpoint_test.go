@@ -0,0 +1,185 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+)
+
+// addFileMutation adds a file to the resources and counts how often it is applied.
+type addFileMutation struct {
+	name  string
+	calls int
+	fail  bool
+}
+
+func (m *addFileMutation) Apply(_ context.Context, resources repository.PackageResources) (repository.PackageResources, *api.Task, error) {
+	m.calls++
+	if m.fail {
+		return repository.PackageResources{}, nil, errors.New("interrupted")
+	}
+	contents := map[string]string{m.name: m.name}
+	for k, v := range resources.Contents {
+		contents[k] = v
+	}
+	task := &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: m.name}}
+	return repository.PackageResources{Contents: contents}, task, nil
+}
+
+// taskRecordingDraft records the resources and tasks it is updated with.
+type taskRecordingDraft struct {
+	repository.PackageDraft
+	resources map[string]string
+	tasks     []string
+}
+
+func (d *taskRecordingDraft) UpdateResources(_ context.Context, new *api.PackageRevisionResources, task *api.Task) error {
+	d.resources = new.Spec.Resources
+	if task != nil {
+		d.tasks = append(d.tasks, task.Eval.Image)
+	}
+	return nil
+}
+
+func TestCheckpointResume(t *testing.T) {
+	ctx := context.Background()
+	cad := &cadEngine{}
+	if err := WithCheckpoints(t.TempDir(), 1<<20).apply(cad); err != nil {
+		t.Fatalf("WithCheckpoints failed: %v", err)
+	}
+	key := checkpointKey("create", "default", "repo", "app", "v1")
+
+	a, b, c := &addFileMutation{name: "a"}, &addFileMutation{name: "b"}, &addFileMutation{name: "c", fail: true}
+	mutations := []mutation{a, b, c}
+
+	if err := cad.applyResourceMutationsWithCheckpoints(ctx, key, &taskRecordingDraft{}, repository.PackageResources{}, mutations); err == nil {
+		t.Fatalf("Interrupted update succeeded unexpectedly")
+	}
+
+	c.fail = false
+	draft := &taskRecordingDraft{}
+	if err := cad.applyResourceMutationsWithCheckpoints(ctx, key, draft, repository.PackageResources{}, mutations); err != nil {
+		t.Fatalf("Resumed update failed: %v", err)
+	}
+	if a.calls != 1 || b.calls != 1 || c.calls != 2 {
+		t.Errorf("Mutations applied %d, %d, %d times; want 1, 1, 2", a.calls, b.calls, c.calls)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, draft.tasks); diff != "" {
+		t.Errorf("Unexpected draft tasks (-want, +got): %s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"a": "a", "b": "b", "c": "c"}, draft.resources); diff != "" {
+		t.Errorf("Unexpected draft resources (-want, +got): %s", diff)
+	}
+
+	cad.checkpoints.remove(key)
+	if _, ok := cad.checkpoints.load(key); ok {
+		t.Errorf("Checkpoint of a completed update was not removed")
+	}
+}
+
+func TestCheckpointResumeAfterEdit(t *testing.T) {
+	ctx := context.Background()
+	cad := &cadEngine{}
+	if err := WithCheckpoints(t.TempDir(), 1<<20).apply(cad); err != nil {
+		t.Fatalf("WithCheckpoints failed: %v", err)
+	}
+	tasks := []api.Task{{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/set-labels:v0.1"}}}
+	original := map[string]string{"Kptfile": "original"}
+
+	a, b := &addFileMutation{name: "a"}, &addFileMutation{name: "b", fail: true}
+	key := updateCheckpointKey("default", "repo", "repo-1234", original, tasks)
+	if err := cad.applyResourceMutationsWithCheckpoints(ctx, key, &taskRecordingDraft{}, repository.PackageResources{Contents: original}, []mutation{a, b}); err == nil {
+		t.Fatalf("Interrupted update succeeded unexpectedly")
+	}
+
+	// The draft is edited before the update is retried; the retry must not resume from the
+	// checkpoint of the original resources.
+	edited := map[string]string{"Kptfile": "edited"}
+	b.fail = false
+	key = updateCheckpointKey("default", "repo", "repo-1234", edited, tasks)
+	draft := &taskRecordingDraft{}
+	if err := cad.applyResourceMutationsWithCheckpoints(ctx, key, draft, repository.PackageResources{Contents: edited}, []mutation{a, b}); err != nil {
+		t.Fatalf("Retried update failed: %v", err)
+	}
+	if diff := cmp.Diff(map[string]string{"Kptfile": "edited", "a": "a", "b": "b"}, draft.resources); diff != "" {
+		t.Errorf("Unexpected draft resources (-want, +got): %s", diff)
+	}
+	if a.calls != 2 {
+		t.Errorf("Mutation applied %d times; want it applied again to the edited resources", a.calls)
+	}
+}
+
+func TestCheckpointFileMode(t *testing.T) {
+	store, err := newCheckpointStore(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("newCheckpointStore failed: %v", err)
+	}
+	if err := store.save("key", &checkpoint{Resources: map[string]string{"Kptfile": "secret"}}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	info, err := os.Stat(store.path("key"))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if got := info.Mode().Perm(); got != 0600 {
+		t.Errorf("Checkpoint file mode: got %v, want %v", got, os.FileMode(0600))
+	}
+}
+
+func TestCheckpointStorageBound(t *testing.T) {
+	resources := map[string]string{"Kptfile": strings.Repeat("x", 100)}
+	store, err := newCheckpointStore(t.TempDir(), 300)
+	if err != nil {
+		t.Fatalf("newCheckpointStore failed: %v", err)
+	}
+
+	// Saved a second apart, so that the order of the checkpoints does not depend on the
+	// resolution of file modification times.
+	saved := time.Now().Add(-time.Hour)
+	for _, key := range []string{"first", "second", "third"} {
+		if err := store.save(key, &checkpoint{Resources: resources}); err != nil {
+			t.Fatalf("save(%q) failed: %v", key, err)
+		}
+		saved = saved.Add(time.Second)
+		if err := os.Chtimes(store.path(key), saved, saved); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+	if _, ok := store.load("first"); ok {
+		t.Errorf("Oldest checkpoint was not evicted")
+	}
+	for _, key := range []string{"second", "third"} {
+		if _, ok := store.load(key); !ok {
+			t.Errorf("Checkpoint %q was evicted", key)
+		}
+	}
+
+	large := map[string]string{"Kptfile": strings.Repeat("x", 1000)}
+	if err := store.save("third", &checkpoint{Resources: large}); err == nil {
+		t.Errorf("save of a checkpoint over the limit succeeded unexpectedly")
+	}
+	if _, ok := store.load("third"); ok {
+		t.Errorf("Checkpoint over the limit replaced the previous one")
+	}
+}
@@ -16,10 +16,14 @@ package engine
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	iofs "io/fs"
 	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 
 	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
@@ -38,6 +42,11 @@ type clonePackageMutation struct {
 	cad                CaDEngine
 	credentialResolver repository.CredentialResolver
 	referenceResolver  ReferenceResolver
+	// Armored PGP key ring with keys trusted to sign upstream commits. If empty, signatures are not verified.
+	trustedUpstreamKeys string
+	// Local directory under which packages can be cloned from the file system. If empty, cloning from
+	// a directory is disabled.
+	localUpstreamRoot string
 }
 
 func (m *clonePackageMutation) Apply(ctx context.Context, resources repository.PackageResources) (repository.PackageResources, *api.Task, error) {
@@ -47,20 +56,56 @@ func (m *clonePackageMutation) Apply(ctx context.Context, resources repository.P
 	var cloned repository.PackageResources
 	var err error
 
-	if ref := m.task.Clone.Upstream.UpstreamRef; ref != nil {
+	// The task is copied so that clone provenance can be recorded in it.
+	task := m.task.DeepCopy()
+
+	// The signer is recorded by Porch only; a signer supplied by the client is never trusted.
+	if git := task.Clone.Upstream.Git; git != nil {
+		git.VerifiedSigner = ""
+	}
+	// Only Git commits can be verified; other upstreams cannot be cloned under a trust policy.
+	if m.trustedUpstreamKeys != "" && (task.Clone.Upstream.UpstreamRef != nil || task.Clone.Upstream.Git == nil) {
+		return repository.PackageResources{}, nil, errors.New("cannot clone package: only Git upstreams can be verified against the trusted upstream keys")
+	}
+
+	if ref := task.Clone.Upstream.UpstreamRef; ref != nil {
 		cloned, err = m.cloneFromRegisteredRepository(ctx, ref)
-	} else if git := m.task.Clone.Upstream.Git; git != nil {
+	} else if git := task.Clone.Upstream.Git; git != nil {
 		cloned, err = m.cloneFromGit(ctx, git)
-	} else if oci := m.task.Clone.Upstream.Oci; oci != nil {
+	} else if oci := task.Clone.Upstream.Oci; oci != nil {
 		cloned, err = m.cloneFromOci(ctx, oci)
+	} else if dir := task.Clone.Upstream.Directory; dir != nil {
+		cloned, err = m.cloneFromDirectory(ctx, dir)
 	} else {
-		err = errors.New("invalid clone source (neither of git, oci, nor upstream were specified)")
+		err = errors.New("invalid clone source (neither of git, oci, directory, nor upstream were specified)")
 	}
 
 	if err != nil {
 		return repository.PackageResources{}, nil, err
 	}
 
+	// Retain only the selected files of the cloned package; the task records the patterns as applied.
+	if len(task.Clone.Include) > 0 || len(task.Clone.Exclude) > 0 {
+		filtered, include, exclude, err := filterClonedFiles(cloned.Contents, task.Clone.Include, task.Clone.Exclude)
+		if err != nil {
+			return repository.PackageResources{}, nil, err
+		}
+		cloned.Contents = filtered
+		task.Clone.Include, task.Clone.Exclude = include, exclude
+	}
+
+	// Add user-supplied extra files; they must not replace files of the cloned package.
+	for k, v := range task.Clone.ExtraFiles {
+		k = normalizeResourcePath(k)
+		if _, err := filepathSafeJoin("/", k); err != nil {
+			return repository.PackageResources{}, nil, fmt.Errorf("invalid extra file: %w", err)
+		}
+		if _, exists := cloned.Contents[k]; exists {
+			return repository.PackageResources{}, nil, fmt.Errorf("extra file %q collides with a file of the cloned package", k)
+		}
+		cloned.Contents[k] = v
+	}
+
 	// Add any pre-existing parts of the config that have not been overwritten by the clone operation.
 	for k, v := range resources.Contents {
 		if _, exists := cloned.Contents[k]; !exists {
@@ -68,7 +113,74 @@ func (m *clonePackageMutation) Apply(ctx context.Context, resources repository.P
 		}
 	}
 
-	return cloned, m.task, nil
+	return cloned, task, nil
+}
+
+// filterClonedFiles returns the files of the cloned package which match an include pattern, or all
+// files if there are none, and no exclude pattern. The Kptfile of the package is always retained.
+// It also returns the patterns in the normalized form they were matched in. It fails if there are
+// include patterns and they match none of the files.
+func filterClonedFiles(contents map[string]string, include, exclude []string) (map[string]string, []string, []string, error) {
+	include, err := normalizeClonePatterns("include", include)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	exclude, err = normalizeClonePatterns("exclude", exclude)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	result := map[string]string{}
+	included := 0
+	for k, v := range contents {
+		name := normalizeResourcePath(k)
+		if name == v1.KptFileName {
+			result[k] = v
+			continue
+		}
+		if len(include) > 0 {
+			if !matchesClonePattern(include, name) {
+				continue
+			}
+			included++
+		}
+		if matchesClonePattern(exclude, name) {
+			continue
+		}
+		result[k] = v
+	}
+	if len(include) > 0 && included == 0 {
+		return nil, nil, nil, fmt.Errorf("include patterns %q match no files of the cloned package", include)
+	}
+	return result, include, exclude, nil
+}
+
+// normalizeClonePatterns returns the patterns in slash-delimited form relative to the package.
+func normalizeClonePatterns(kind string, patterns []string) ([]string, error) {
+	var result []string
+	for _, pattern := range patterns {
+		normalized := path.Clean(strings.TrimPrefix(normalizeResourcePath(pattern), "/"))
+		if pattern == "" || normalized == "." {
+			return nil, fmt.Errorf("invalid %s pattern %q: must select files of the package", kind, pattern)
+		}
+		if _, err := path.Match(normalized, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+		}
+		result = append(result, normalized)
+	}
+	return result, nil
+}
+
+// matchesClonePattern returns true if any of the patterns matches the file or one of its directories.
+func matchesClonePattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		for candidate := name; candidate != "." && candidate != "/"; candidate = path.Dir(candidate) {
+			if matched, _ := path.Match(pattern, candidate); matched {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (m *clonePackageMutation) cloneFromRegisteredRepository(ctx context.Context, ref *api.PackageRevisionRef) (repository.PackageResources, error) {
@@ -99,22 +211,61 @@ func (m *clonePackageMutation) cloneFromRegisteredRepository(ctx context.Context
 		return repository.PackageResources{}, fmt.Errorf("failed to apply upstream lock to pakcage %q: %w", ref.Name, err)
 	}
 
+	// Record the cloned package revision in the task.
+	key := revision.Key()
+	ref.Repository = key.Repository
+	ref.Package = key.Package
+	ref.Revision = key.Revision
+
 	return repository.PackageResources{
 		Contents: resources.Spec.Resources,
 	}, nil
 }
 
-func (m *clonePackageMutation) cloneFromGit(ctx context.Context, gitPackage *api.GitPackage) (repository.PackageResources, error) {
-	// TODO: Cache unregistered repositories with appropriate cache eviction policy.
-	// TODO: Separate low-level repository access from Repository abstraction?
-
+// openGitUpstream opens the repository of the Git upstream package, cloning it into dir.
+func openGitUpstream(ctx context.Context, gitPackage *api.GitPackage, dir string, credentialResolver repository.CredentialResolver) (git.GitRepository, error) {
 	spec := configapi.GitRepository{
 		Repo:      gitPackage.Repo,
+		Branch:    gitPackage.Branch,
 		Directory: gitPackage.Directory,
 		SecretRef: configapi.SecretRef{
 			Name: gitPackage.SecretRef.Name,
 		},
 	}
+	r, err := git.OpenRepository(ctx, "", "", &spec, dir, git.GitRepositoryOptions{
+		CredentialResolver:         credentialResolver,
+		SkipMainBranchVerification: true, // We are only reading so we don't need the main branch to exist.
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot clone Git repository: %w", err)
+	}
+	return r, nil
+}
+
+// verifyGitUpstreamCommit verifies the signature of the commit of the Git upstream package against
+// the trusted keys, and returns the signer.
+func verifyGitUpstreamCommit(ctx context.Context, gitPackage *api.GitPackage, commit, trustedKeys string, credentialResolver repository.CredentialResolver) (string, error) {
+	dir, err := ioutil.TempDir("", "verify-git-package-*")
+	if err != nil {
+		return "", fmt.Errorf("cannot create temporary directory to clone Git repository: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := openGitUpstream(ctx, gitPackage, dir, credentialResolver)
+	if err != nil {
+		return "", err
+	}
+	return r.VerifyCommit(ctx, commit, trustedKeys)
+}
+
+func (m *clonePackageMutation) cloneFromGit(ctx context.Context, gitPackage *api.GitPackage) (repository.PackageResources, error) {
+	// TODO: Cache unregistered repositories with appropriate cache eviction policy.
+	// TODO: Separate low-level repository access from Repository abstraction?
+
+	// Reject a malformed pin before fetching the repository.
+	if pinned := gitPackage.PinnedCommit; pinned != "" && !isFullCommitSHA(pinned) {
+		return repository.PackageResources{}, fmt.Errorf("invalid pinned commit %q: must be a full commit SHA", pinned)
+	}
 
 	dir, err := ioutil.TempDir("", "clone-git-package-*")
 	if err != nil {
@@ -122,18 +273,28 @@ func (m *clonePackageMutation) cloneFromGit(ctx context.Context, gitPackage *api
 	}
 	defer os.RemoveAll(dir)
 
-	r, err := git.OpenRepository(ctx, "", "", &spec, dir, git.GitRepositoryOptions{
-		CredentialResolver:         m.credentialResolver,
-		SkipMainBranchVerification: true, // We are only reading so we don't need the main branch to exist.
-	})
+	r, err := openGitUpstream(ctx, gitPackage, dir, m.credentialResolver)
 	if err != nil {
-		return repository.PackageResources{}, fmt.Errorf("cannot clone Git repository: %w", err)
+		return repository.PackageResources{}, err
 	}
 
 	revision, lock, err := r.GetPackage(ctx, gitPackage.Ref, gitPackage.Directory)
 	if err != nil {
 		return repository.PackageResources{}, fmt.Errorf("cannot find package %s@%s: %w", gitPackage.Directory, gitPackage.Ref, err)
 	}
+	if pinned := gitPackage.PinnedCommit; pinned != "" && !strings.EqualFold(pinned, lock.Commit) {
+		return repository.PackageResources{}, fmt.Errorf("cannot clone package %s@%s: ref resolves to commit %s, not to the pinned commit %s", gitPackage.Directory, gitPackage.Ref, lock.Commit, pinned)
+	}
+	// Record the cloned commit so that subsequent updates can use it as the merge base.
+	gitPackage.Commit = lock.Commit
+
+	if m.trustedUpstreamKeys != "" {
+		signer, err := r.VerifyCommit(ctx, lock.Commit, m.trustedUpstreamKeys)
+		if err != nil {
+			return repository.PackageResources{}, fmt.Errorf("cannot clone package %s@%s: %w", gitPackage.Directory, gitPackage.Ref, err)
+		}
+		gitPackage.VerifiedSigner = signer
+	}
 
 	resources, err := revision.GetResources(ctx)
 	if err != nil {
@@ -166,6 +327,64 @@ func (m *clonePackageMutation) cloneFromOci(ctx context.Context, ociPackage *api
 	return repository.PackageResources{}, errors.New("clone from OCI is not implemented")
 }
 
+// cloneFromDirectory reads the package from a directory under the local upstream root. The
+// Kptfile upstream is not updated, because it cannot describe a directory origin.
+func (m *clonePackageMutation) cloneFromDirectory(ctx context.Context, dirPackage *api.DirectoryPackage) (repository.PackageResources, error) {
+	if m.localUpstreamRoot == "" {
+		return repository.PackageResources{}, errors.New("clone from a directory is not enabled")
+	}
+	if dirPackage.Path == "" {
+		return repository.PackageResources{}, errors.New("directory.path is required")
+	}
+
+	// Record the normalized source path.
+	dirPackage.Path = filepath.ToSlash(filepath.Clean(filepath.FromSlash(dirPackage.Path)))
+	dir, err := filepathSafeJoin(m.localUpstreamRoot, filepath.FromSlash(dirPackage.Path))
+	if err != nil {
+		return repository.PackageResources{}, fmt.Errorf("invalid directory path: %w", err)
+	}
+	// The path is contained in the root only lexically; symbolic links along it must resolve
+	// under the root as well.
+	root, err := filepath.EvalSymlinks(m.localUpstreamRoot)
+	if err != nil {
+		return repository.PackageResources{}, fmt.Errorf("cannot resolve local upstream root: %w", err)
+	}
+	if dir, err = resolveSymlink(root, dir); err != nil {
+		return repository.PackageResources{}, fmt.Errorf("invalid directory path: %w", err)
+	}
+
+	contents := map[string]string{}
+	if err := filepath.WalkDir(dir, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Symbolic links could point outside of the root; only regular files are cloned.
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		contents[filepath.ToSlash(rel)] = string(data)
+		return nil
+	}); err != nil {
+		return repository.PackageResources{}, fmt.Errorf("cannot read package directory %q: %w", dirPackage.Path, err)
+	}
+
+	if _, ok := contents[v1.KptFileName]; !ok {
+		return repository.PackageResources{}, fmt.Errorf("directory %q is not a package; %s not found", dirPackage.Path, v1.KptFileName)
+	}
+
+	return repository.PackageResources{
+		Contents: contents,
+	}, nil
+}
+
 func parseUpstreamRepository(name string) (string, error) {
 	lastDash := strings.LastIndex(name, "-")
 	if lastDash < 0 {
@@ -173,3 +392,12 @@ func parseUpstreamRepository(name string) (string, error) {
 	}
 	return name[:lastDash], nil
 }
+
+// isFullCommitSHA reports whether s is a full (not abbreviated) hexadecimal git commit SHA.
+func isFullCommitSHA(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
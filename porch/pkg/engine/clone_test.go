@@ -15,6 +15,7 @@
 package engine
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -24,21 +25,34 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/git"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/go-git/go-billy/v5/memfs"
 	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/google/go-cmp/cmp"
 )
 
 func createRepoWithContents(t *testing.T, contentDir string) *gogit.Repository {
+	return createSignedRepoWithContents(t, contentDir, nil)
+}
+
+// createSignedRepoWithContents creates a repository whose commit is signed with the key, if any.
+func createSignedRepoWithContents(t *testing.T, contentDir string, signKey *openpgp.Entity) *gogit.Repository {
 	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
 	if err != nil {
 		t.Fatalf("Failed to initialize in-memory git repository: %v", err)
@@ -94,6 +108,7 @@ func createRepoWithContents(t *testing.T, contentDir string) *gogit.Repository {
 		All:       true,
 		Author:    &sig,
 		Committer: &sig,
+		SignKey:   signKey,
 	})
 	if err != nil {
 		t.Fatalf("Failed creating initial commit: %v", err)
@@ -229,3 +244,617 @@ func TestCloneGitBasicAuth(t *testing.T) {
 
 	t.Logf("%v", r)
 }
+
+func TestCloneGitRejectsUnsignedCommit(t *testing.T) {
+	testdata, err := filepath.Abs(filepath.Join(".", "testdata", "clone"))
+	if err != nil {
+		t.Fatalf("Failed to find testdata: %v", err)
+	}
+
+	repo := createRepoWithContents(t, testdata)
+	addr := startGitServer(t, repo)
+
+	cpm := clonePackageMutation{
+		task: &v1alpha1.Task{
+			Type: "clone",
+			Clone: &v1alpha1.PackageCloneTaskSpec{
+				Upstream: v1alpha1.UpstreamPackage{
+					Type: "git",
+					Git: &v1alpha1.GitPackage{
+						Repo:      addr,
+						Ref:       "main",
+						Directory: "configmap",
+					},
+				},
+			},
+		},
+		namespace:           "test-namespace",
+		name:                "test-configmap",
+		trustedUpstreamKeys: "-----BEGIN PGP PUBLIC KEY BLOCK-----\n-----END PGP PUBLIC KEY BLOCK-----\n",
+	}
+
+	if _, _, err := cpm.Apply(context.Background(), repository.PackageResources{}); err == nil {
+		t.Errorf("Expected error (unsigned commit); got none")
+	}
+
+	cpm.trustedUpstreamKeys = ""
+	if _, task, err := cpm.Apply(context.Background(), repository.PackageResources{}); err != nil {
+		t.Errorf("task apply failed: %v", err)
+	} else if got := task.Clone.Upstream.Git.VerifiedSigner; got != "" {
+		t.Errorf("Unexpected verified signer without trust policy: %q", got)
+	}
+}
+
+// newPGPKey returns a new PGP key and its armored public key.
+func newPGPKey(t *testing.T) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity("Porch Unit Test", "", "porch-unit-test@kpt.dev", nil)
+	if err != nil {
+		t.Fatalf("Failed to create PGP key: %v", err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("Failed to armor PGP key: %v", err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatalf("Failed to serialize PGP key: %v", err)
+	}
+	w.Close()
+	return entity, buf.String()
+}
+
+func TestCloneGitSignedCommit(t *testing.T) {
+	testdata, err := filepath.Abs(filepath.Join(".", "testdata", "clone"))
+	if err != nil {
+		t.Fatalf("Failed to find testdata: %v", err)
+	}
+	signKey, trusted := newPGPKey(t)
+	_, untrusted := newPGPKey(t)
+
+	addr := startGitServer(t, createSignedRepoWithContents(t, testdata, signKey))
+	newMutation := func(keys string) *clonePackageMutation {
+		return &clonePackageMutation{
+			task: &v1alpha1.Task{
+				Type: "clone",
+				Clone: &v1alpha1.PackageCloneTaskSpec{
+					Upstream: v1alpha1.UpstreamPackage{
+						Type: "git",
+						Git: &v1alpha1.GitPackage{
+							Repo:           addr,
+							Ref:            "main",
+							Directory:      "configmap",
+							VerifiedSigner: "Spoofed Signer",
+						},
+					},
+				},
+			},
+			namespace:           "test-namespace",
+			name:                "test-configmap",
+			trustedUpstreamKeys: keys,
+		}
+	}
+
+	_, task, err := newMutation(trusted).Apply(context.Background(), repository.PackageResources{})
+	if err != nil {
+		t.Fatalf("Clone of a commit signed by a trusted key failed: %v", err)
+	}
+	if got := task.Clone.Upstream.Git.VerifiedSigner; !strings.Contains(got, signKey.PrimaryKey.KeyIdString()) || !strings.Contains(got, "Porch Unit Test") {
+		t.Errorf("Verified signer: got %q, want the identity and key ID %s of the signing key", got, signKey.PrimaryKey.KeyIdString())
+	}
+
+	if _, _, err := newMutation(untrusted).Apply(context.Background(), repository.PackageResources{}); err == nil {
+		t.Errorf("Clone of a commit signed by an untrusted key succeeded unexpectedly")
+	}
+
+	// Without a trust policy, the client-supplied signer is not recorded.
+	if _, task, err := newMutation("").Apply(context.Background(), repository.PackageResources{}); err != nil {
+		t.Errorf("task apply failed: %v", err)
+	} else if got := task.Clone.Upstream.Git.VerifiedSigner; got != "" {
+		t.Errorf("Client-supplied verified signer was recorded: %q", got)
+	}
+
+	// Upstreams other than Git cannot be verified.
+	refClone := newMutation(trusted)
+	refClone.task.Clone.Upstream = v1alpha1.UpstreamPackage{UpstreamRef: &v1alpha1.PackageRevisionRef{Name: "blueprints-bucket-v1"}}
+	if _, _, err := refClone.Apply(context.Background(), repository.PackageResources{}); err == nil || !strings.Contains(err.Error(), "trusted upstream keys") {
+		t.Errorf("Clone of an upstream reference with trusted upstream keys: got error %v, want trust policy error", err)
+	}
+}
+
+func TestUpdateGitSignedCommit(t *testing.T) {
+	testdata, err := filepath.Abs(filepath.Join(".", "testdata", "clone"))
+	if err != nil {
+		t.Fatalf("Failed to find testdata: %v", err)
+	}
+	t.Setenv(gitutil.RepoCacheDirEnv, t.TempDir())
+	signKey, trusted := newPGPKey(t)
+
+	repo := createSignedRepoWithContents(t, testdata, signKey)
+	signed, err := repo.Reference(plumbing.ReferenceName("refs/heads/main"), true)
+	if err != nil {
+		t.Fatalf("Failed to resolve main: %v", err)
+	}
+	// Add an unsigned commit on the unsigned branch.
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get git repository worktree: %v", err)
+	}
+	f, err := wt.Filesystem.Create("configmap/unsigned.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	f.Write([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: unsigned\n"))
+	f.Close()
+	if _, err := wt.Add("configmap/unsigned.yaml"); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+	sig := &object.Signature{Name: "Porch Unit Test", Email: "porch-unit-test@kpt.dev", When: time.Now()}
+	unsigned, err := wt.Commit("Unsigned commit", &gogit.CommitOptions{Author: sig, Committer: sig})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	for name, hash := range map[string]plumbing.Hash{"refs/heads/main": signed.Hash(), "refs/heads/unsigned": unsigned} {
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), hash)); err != nil {
+			t.Fatalf("Failed to set %s: %v", name, err)
+		}
+	}
+	// kpt updates packages with the git command, which cannot fetch from the test git server, so
+	// the upstream is pushed to a repository on disk.
+	addr := "file://" + t.TempDir()
+	if _, err := gogit.PlainInit(strings.TrimPrefix(addr, "file://"), true); err != nil {
+		t.Fatalf("Failed to initialize git repository: %v", err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "upstream", URLs: []string{addr}}); err != nil {
+		t.Fatalf("Failed to create remote: %v", err)
+	}
+	if err := repo.Push(&gogit.PushOptions{RemoteName: "upstream", RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*"}}); err != nil {
+		t.Fatalf("Failed to push to git repository: %v", err)
+	}
+
+	cloneTask := &v1alpha1.Task{
+		Type: "clone",
+		Clone: &v1alpha1.PackageCloneTaskSpec{
+			Upstream: v1alpha1.UpstreamPackage{
+				Type: "git",
+				Git: &v1alpha1.GitPackage{
+					Repo:      addr,
+					Ref:       "main",
+					Directory: "configmap",
+				},
+			},
+		},
+	}
+	clone := &clonePackageMutation{
+		task:                cloneTask,
+		namespace:           "test-namespace",
+		name:                "configmap",
+		trustedUpstreamKeys: trusted,
+	}
+	cloned, _, err := clone.Apply(context.Background(), repository.PackageResources{})
+	if err != nil {
+		t.Fatalf("Clone of a commit signed by a trusted key failed: %v", err)
+	}
+	// The update operates on the package in the directory named after the upstream directory.
+	resources := repository.PackageResources{Contents: map[string]string{}}
+	for k, v := range cloned.Contents {
+		resources.Contents["configmap/"+k] = v
+	}
+
+	newUpdate := func(ref, keys string) *updatePackageMutation {
+		task := cloneTask.DeepCopy()
+		task.Clone.Upstream.Git.Ref = ref
+		task.Clone.Upstream.Git.VerifiedSigner = "Spoofed Signer"
+		return &updatePackageMutation{
+			task:                task,
+			originCommit:        signed.Hash().String(),
+			trustedUpstreamKeys: keys,
+		}
+	}
+
+	if _, _, err := newUpdate("unsigned", trusted).Apply(context.Background(), resources); err == nil || !strings.Contains(err.Error(), "cannot update package") {
+		t.Errorf("Update to an unsigned commit: got error %v, want signature verification error", err)
+	}
+
+	_, task, err := newUpdate("main", trusted).Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Update to a commit signed by a trusted key failed: %v", err)
+	}
+	if got := task.Clone.Upstream.Git.VerifiedSigner; !strings.Contains(got, signKey.PrimaryKey.KeyIdString()) {
+		t.Errorf("Verified signer: got %q, want the key ID %s of the signing key", got, signKey.PrimaryKey.KeyIdString())
+	}
+
+	// Without a trust policy, the update succeeds, but the client-supplied signer is not recorded.
+	_, task, err = newUpdate("unsigned", "").Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got, want := task.Clone.Upstream.Git.Commit, unsigned.String(); got != want {
+		t.Errorf("Updated commit: got %q, want %q", got, want)
+	}
+	if got := task.Clone.Upstream.Git.VerifiedSigner; got != "" {
+		t.Errorf("Client-supplied verified signer was recorded: %q", got)
+	}
+
+	// Without a ref, the package is updated to the head of the branch.
+	update := newUpdate("", "")
+	update.task.Clone.Upstream.Git.Branch = "unsigned"
+	_, task, err = update.Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Update to the head of the branch failed: %v", err)
+	}
+	if got, want := task.Clone.Upstream.Git.Commit, unsigned.String(); got != want {
+		t.Errorf("Updated commit of the branch: got %q, want %q", got, want)
+	}
+}
+
+func TestCloneGitPinnedCommit(t *testing.T) {
+	testdata, err := filepath.Abs(filepath.Join(".", "testdata", "clone"))
+	if err != nil {
+		t.Fatalf("Failed to find testdata: %v", err)
+	}
+
+	repo := createRepoWithContents(t, testdata)
+	addr := startGitServer(t, repo)
+
+	head, err := repo.Reference(plumbing.ReferenceName("refs/heads/main"), true)
+	if err != nil {
+		t.Fatalf("Failed to resolve main: %v", err)
+	}
+
+	newMutation := func(pinned string) *clonePackageMutation {
+		return &clonePackageMutation{
+			task: &v1alpha1.Task{
+				Type: "clone",
+				Clone: &v1alpha1.PackageCloneTaskSpec{
+					Upstream: v1alpha1.UpstreamPackage{
+						Type: "git",
+						Git: &v1alpha1.GitPackage{
+							Repo:         addr,
+							Ref:          "main",
+							Directory:    "configmap",
+							PinnedCommit: pinned,
+						},
+					},
+				},
+			},
+			namespace: "test-namespace",
+			name:      "test-configmap",
+		}
+	}
+
+	_, task, err := newMutation(strings.ToUpper(head.Hash().String())).Apply(context.Background(), repository.PackageResources{})
+	if err != nil {
+		t.Fatalf("task apply failed: %v", err)
+	}
+	if got, want := task.Clone.Upstream.Git.Commit, head.Hash().String(); got != want {
+		t.Errorf("Recorded commit: got %q, want %q", got, want)
+	}
+
+	for _, pinned := range []string{
+		strings.Repeat("0", 40),   // branch has moved
+		head.Hash().String()[:12], // abbreviated
+		strings.Repeat("z", 40),   // not a SHA
+	} {
+		if _, _, err := newMutation(pinned).Apply(context.Background(), repository.PackageResources{}); err == nil {
+			t.Errorf("Clone pinned to %q succeeded unexpectedly", pinned)
+		}
+	}
+
+	// A malformed pin is rejected before the repository is fetched.
+	unreachable := newMutation("main")
+	unreachable.task.Clone.Upstream.Git.Repo = "http://127.0.0.1:1/unreachable.git"
+	if _, _, err := unreachable.Apply(context.Background(), repository.PackageResources{}); err == nil || !strings.Contains(err.Error(), "invalid pinned commit") {
+		t.Errorf("Clone of an unreachable repository pinned to a branch: got error %v, want one rejecting the pin", err)
+	}
+}
+
+func TestCloneGitExtraFiles(t *testing.T) {
+	testdata, err := filepath.Abs(filepath.Join(".", "testdata", "clone"))
+	if err != nil {
+		t.Fatalf("Failed to find testdata: %v", err)
+	}
+
+	repo := createRepoWithContents(t, testdata)
+	addr := startGitServer(t, repo)
+
+	cpm := clonePackageMutation{
+		task: &v1alpha1.Task{
+			Type: "clone",
+			Clone: &v1alpha1.PackageCloneTaskSpec{
+				Upstream: v1alpha1.UpstreamPackage{
+					Type: "git",
+					Git: &v1alpha1.GitPackage{
+						Repo:      addr,
+						Ref:       "main",
+						Directory: "configmap",
+					},
+				},
+				ExtraFiles: map[string]string{
+					"setters.yaml": "kind: ConfigMap\n",
+				},
+			},
+		},
+		namespace: "test-namespace",
+		name:      "test-configmap",
+	}
+
+	r, task, err := cpm.Apply(context.Background(), repository.PackageResources{})
+	if err != nil {
+		t.Fatalf("task apply failed: %v", err)
+	}
+	if got, want := r.Contents["setters.yaml"], "kind: ConfigMap\n"; got != want {
+		t.Errorf("Extra file contents: got %q, want %q", got, want)
+	}
+	if _, ok := task.Clone.ExtraFiles["setters.yaml"]; !ok {
+		t.Errorf("Extra file not reported in task")
+	}
+
+	cpm.task.Clone.ExtraFiles = map[string]string{
+		"Kptfile": "",
+	}
+	if _, _, err := cpm.Apply(context.Background(), repository.PackageResources{}); err == nil {
+		t.Errorf("Expected error (extra file collides with Kptfile); got none")
+	}
+}
+
+func TestCloneDirectory(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join(".", "testdata", "clone"))
+	if err != nil {
+		t.Fatalf("Failed to find testdata: %v", err)
+	}
+
+	newMutation := func(root, path string) *clonePackageMutation {
+		return &clonePackageMutation{
+			task: &v1alpha1.Task{
+				Type: "clone",
+				Clone: &v1alpha1.PackageCloneTaskSpec{
+					Upstream: v1alpha1.UpstreamPackage{
+						Type:      v1alpha1.RepositoryTypeDirectory,
+						Directory: &v1alpha1.DirectoryPackage{Path: path},
+					},
+				},
+			},
+			namespace:         "test-namespace",
+			name:              "test-configmap",
+			localUpstreamRoot: root,
+		}
+	}
+
+	r, task, err := newMutation(root, "./configmap/").Apply(context.Background(), repository.PackageResources{})
+	if err != nil {
+		t.Fatalf("task apply failed: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join(root, "configmap", "Kptfile"))
+	if err != nil {
+		t.Fatalf("Failed to read Kptfile: %v", err)
+	}
+	if got := r.Contents["Kptfile"]; got != string(want) {
+		t.Errorf("Cloned Kptfile: got %q, want %q", got, string(want))
+	}
+	if got, want := task.Clone.Upstream.Directory.Path, "configmap"; got != want {
+		t.Errorf("Recorded source path: got %q, want %q", got, want)
+	}
+
+	for _, tc := range []struct {
+		root string
+		path string
+	}{
+		{root: "", path: "configmap"},
+		{root: root, path: ""},
+		{root: root, path: "../clone/configmap"},
+		{root: filepath.Join(root, "configmap"), path: ".."},
+		{root: root, path: "/configmap"},
+		{root: root, path: "missing"},
+	} {
+		if _, _, err := newMutation(tc.root, tc.path).Apply(context.Background(), repository.PackageResources{}); err == nil {
+			t.Errorf("Clone of directory %q under root %q succeeded unexpectedly", tc.path, tc.root)
+		}
+	}
+}
+
+func TestCloneDirectorySymlink(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	kptfile := "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n"
+	for _, dir := range []string{filepath.Join(root, "app"), filepath.Join(outside, "app")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "Kptfile"), []byte(kptfile), 0644); err != nil {
+			t.Fatalf("Failed to write Kptfile: %v", err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "app"), filepath.Join(root, "linked")); err != nil {
+		t.Fatalf("Failed to create symbolic link: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatalf("Failed to create symbolic link: %v", err)
+	}
+
+	newMutation := func(path string) *clonePackageMutation {
+		return &clonePackageMutation{
+			task: &v1alpha1.Task{
+				Type: "clone",
+				Clone: &v1alpha1.PackageCloneTaskSpec{
+					Upstream: v1alpha1.UpstreamPackage{
+						Type:      v1alpha1.RepositoryTypeDirectory,
+						Directory: &v1alpha1.DirectoryPackage{Path: path},
+					},
+				},
+			},
+			namespace:         "test-namespace",
+			name:              "test-app",
+			localUpstreamRoot: root,
+		}
+	}
+
+	// A link which resolves under the root is followed.
+	r, _, err := newMutation("linked").Apply(context.Background(), repository.PackageResources{})
+	if err != nil {
+		t.Fatalf("Clone of a linked directory under the root failed: %v", err)
+	}
+	if got := r.Contents["Kptfile"]; got != kptfile {
+		t.Errorf("Cloned Kptfile: got %q, want %q", got, kptfile)
+	}
+
+	for _, path := range []string{"escape/app", "escape"} {
+		if _, _, err := newMutation(path).Apply(context.Background(), repository.PackageResources{}); err == nil || !strings.Contains(err.Error(), "outside") {
+			t.Errorf("Clone of directory %q linked outside of the root: got error %v, want one rejecting the link", path, err)
+		}
+	}
+}
+
+func TestCloneRegisteredRepository(t *testing.T) {
+	upstream := &fake.PackageRevision{
+		Name:               "blueprints-1234567890",
+		PackageRevisionKey: repository.PackageRevisionKey{Repository: "blueprints", Package: "bucket", Revision: "v2"},
+		PackageLifecycle:   v1alpha1.PackageRevisionLifecyclePublished,
+		Resources: &v1alpha1.PackageRevisionResources{
+			Spec: v1alpha1.PackageRevisionResourcesSpec{
+				Resources: map[string]string{
+					"Kptfile":     "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: bucket\n",
+					"bucket.yaml": "apiVersion: storage.cnrm.cloud.google.com/v1beta1\nkind: StorageBucket\nmetadata:\n  name: bucket\n",
+				},
+			},
+		},
+	}
+	cpm := &clonePackageMutation{
+		task: &v1alpha1.Task{
+			Type: v1alpha1.TaskTypeClone,
+			Clone: &v1alpha1.PackageCloneTaskSpec{
+				Upstream: v1alpha1.UpstreamPackage{
+					UpstreamRef: &v1alpha1.PackageRevisionRef{Name: upstream.Name},
+				},
+			},
+		},
+		namespace:         "default",
+		name:              "my-bucket",
+		cad:               &fakeCaD{repository: &fake.Repository{PackageRevisions: []repository.PackageRevision{upstream}}},
+		referenceResolver: &fakeReferenceResolver{},
+	}
+
+	got, task, err := cpm.Apply(context.Background(), repository.PackageResources{})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, ok := got.Contents["bucket.yaml"]; !ok {
+		t.Errorf("Cloned package does not contain %q", "bucket.yaml")
+	}
+
+	want := &v1alpha1.PackageRevisionRef{Name: upstream.Name, Repository: "blueprints", Package: "bucket", Revision: "v2"}
+	if diff := cmp.Diff(want, task.Clone.Upstream.UpstreamRef); diff != "" {
+		t.Errorf("Unexpected upstream reference in the task (-want, +got): %s", diff)
+	}
+	if cpm.task.Clone.Upstream.UpstreamRef.Revision != "" {
+		t.Errorf("Apply modified the task of the mutation")
+	}
+}
+
+func TestFilterClonedFiles(t *testing.T) {
+	contents := map[string]string{
+		"Kptfile":               "kptfile",
+		"base/deployment.yaml":  "deployment",
+		"base/service.yaml":     "service",
+		"examples/Kptfile":      "example kptfile",
+		"examples/example.yaml": "example",
+		"README.md":             "readme",
+	}
+	for _, tc := range []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name:    "include directory",
+			include: []string{"base"},
+			want:    []string{"Kptfile", "base/deployment.yaml", "base/service.yaml"},
+		},
+		{
+			name:    "include glob",
+			include: []string{"./base/*.yaml", "README.md"},
+			want:    []string{"Kptfile", "README.md", "base/deployment.yaml", "base/service.yaml"},
+		},
+		{
+			name:    "exclude directory",
+			exclude: []string{"examples/"},
+			want:    []string{"Kptfile", "README.md", "base/deployment.yaml", "base/service.yaml"},
+		},
+		{
+			name:    "include and exclude",
+			include: []string{"base"},
+			exclude: []string{"base/service.yaml"},
+			want:    []string{"Kptfile", "base/deployment.yaml"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered, _, _, err := filterClonedFiles(contents, tc.include, tc.exclude)
+			if err != nil {
+				t.Fatalf("filterClonedFiles failed: %v", err)
+			}
+			var got []string
+			for name := range filtered {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Unexpected retained files (-want, +got): %s", diff)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		include []string
+		exclude []string
+	}{
+		{include: []string{"manifests"}},
+		{include: []string{"["}},
+		{exclude: []string{"."}},
+		{exclude: []string{""}},
+	} {
+		if _, _, _, err := filterClonedFiles(contents, tc.include, tc.exclude); err == nil {
+			t.Errorf("filterClonedFiles(include %q, exclude %q) succeeded unexpectedly", tc.include, tc.exclude)
+		}
+	}
+}
+
+func TestCloneDirectoryInclude(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join(".", "testdata", "clone"))
+	if err != nil {
+		t.Fatalf("Failed to find testdata: %v", err)
+	}
+	cpm := &clonePackageMutation{
+		task: &v1alpha1.Task{
+			Type: "clone",
+			Clone: &v1alpha1.PackageCloneTaskSpec{
+				Upstream: v1alpha1.UpstreamPackage{
+					Type:      v1alpha1.RepositoryTypeDirectory,
+					Directory: &v1alpha1.DirectoryPackage{Path: "configmap"},
+				},
+				Exclude: []string{`.\configmap.yaml`},
+			},
+		},
+		namespace:         "test-namespace",
+		name:              "test-configmap",
+		localUpstreamRoot: root,
+	}
+
+	r, task, err := cpm.Apply(context.Background(), repository.PackageResources{})
+	if err != nil {
+		t.Fatalf("task apply failed: %v", err)
+	}
+	if _, ok := r.Contents["configmap.yaml"]; ok {
+		t.Errorf("Excluded file configmap.yaml was cloned")
+	}
+	if _, ok := r.Contents["Kptfile"]; !ok {
+		t.Errorf("Kptfile was not cloned")
+	}
+	if diff := cmp.Diff([]string{"configmap.yaml"}, task.Clone.Exclude); diff != "" {
+		t.Errorf("Unexpected recorded exclude patterns (-want, +got): %s", diff)
+	}
+
+	cpm.task.Clone.Exclude = nil
+	cpm.task.Clone.Include = []string{"*.json"}
+	if _, _, err := cpm.Apply(context.Background(), repository.PackageResources{}); err == nil {
+		t.Errorf("Clone with include patterns which match nothing succeeded unexpectedly")
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+)
+
+// concurrencyLimitedRuntime bounds the number of functions executing simultaneously
+// across all runners obtained from it.
+type concurrencyLimitedRuntime struct {
+	runtime fn.FunctionRuntime
+	// Buffered channel used as a counting semaphore.
+	slots chan struct{}
+}
+
+var _ fn.FunctionRuntime = &concurrencyLimitedRuntime{}
+
+func newConcurrencyLimitedRuntime(runtime fn.FunctionRuntime, max int) *concurrencyLimitedRuntime {
+	return &concurrencyLimitedRuntime{
+		runtime: runtime,
+		slots:   make(chan struct{}, max),
+	}
+}
+
+func (r *concurrencyLimitedRuntime) GetRunner(ctx context.Context, funct *v1.Function) (fn.FunctionRunner, error) {
+	runner, err := r.runtime.GetRunner(ctx, funct)
+	if err != nil {
+		return nil, err
+	}
+	return &concurrencyLimitedRunner{
+		ctx:    ctx,
+		runner: runner,
+		image:  funct.Image,
+		slots:  r.slots,
+	}, nil
+}
+
+type concurrencyLimitedRunner struct {
+	ctx    context.Context
+	runner fn.FunctionRunner
+	image  string
+	slots  chan struct{}
+}
+
+var _ fn.IncrementalFunctionRunner = &concurrencyLimitedRunner{}
+var _ fn.LoggingFunctionRunner = &concurrencyLimitedRunner{}
+var _ fn.EnvFunctionRunner = &concurrencyLimitedRunner{}
+var _ fn.ConfigurableFunctionRunner = &concurrencyLimitedRunner{}
+
+func (r *concurrencyLimitedRunner) Run(in io.Reader, out io.Writer) error {
+	return r.run(func() error {
+		return r.runner.Run(in, out)
+	})
+}
+
+func (r *concurrencyLimitedRunner) RunIncremental(in io.Reader, out io.Writer, changedFiles []string) error {
+	return r.run(func() error {
+		if incremental, ok := r.runner.(fn.IncrementalFunctionRunner); ok {
+			return incremental.RunIncremental(in, out, changedFiles)
+		}
+		return r.runner.Run(in, out)
+	})
+}
+
+func (r *concurrencyLimitedRunner) RunWithLog(in io.Reader, out io.Writer, log io.Writer) error {
+	return r.run(func() error {
+		if logging, ok := r.runner.(fn.LoggingFunctionRunner); ok {
+			return logging.RunWithLog(in, out, log)
+		}
+		return r.runner.Run(in, out)
+	})
+}
+
+func (r *concurrencyLimitedRunner) SetEnv(env map[string]string) error {
+	if envRunner, ok := r.runner.(fn.EnvFunctionRunner); ok {
+		return envRunner.SetEnv(env)
+	}
+	return fmt.Errorf("function %q does not support environment variables", r.image)
+}
+
+func (r *concurrencyLimitedRunner) SetRunnerOptions(opts fn.RunnerOptions) error {
+	if configurable, ok := r.runner.(fn.ConfigurableFunctionRunner); ok {
+		return configurable.SetRunnerOptions(opts)
+	}
+	return fmt.Errorf("function %q does not support runner options", r.image)
+}
+
+func (r *concurrencyLimitedRunner) run(f func() error) error {
+	select {
+	case r.slots <- struct{}{}:
+	case <-r.ctx.Done():
+		return fmt.Errorf("cancelled while waiting to run function %q: %w", r.image, r.ctx.Err())
+	}
+	defer func() { <-r.slots }()
+
+	return f()
+}
+
+// repositoryLimiter bounds the number of operations running simultaneously against each
+// repository, so that a backlog of operations on a slow repository does not hold up operations
+// on the others. A nil *repositoryLimiter does not limit operations.
+type repositoryLimiter struct {
+	// Maximum number of concurrent operations of repositories without a limit of their own.
+	defaultMax int
+	// Maximum number of concurrent operations, keyed by "<namespace>/<name>" of the repository.
+	limits map[string]int
+
+	mutex sync.Mutex
+	// Counting semaphores of the repositories, created on first use; nil if unlimited.
+	slots map[string]chan struct{}
+}
+
+func newRepositoryLimiter(defaultMax int, limits map[string]int) *repositoryLimiter {
+	return &repositoryLimiter{
+		defaultMax: defaultMax,
+		limits:     limits,
+		slots:      map[string]chan struct{}{},
+	}
+}
+
+func repositoryLimiterKey(repositoryObj *configapi.Repository) string {
+	return repositoryObj.Namespace + "/" + repositoryObj.Name
+}
+
+// acquire waits until an operation may run against the repository, and returns the function
+// which ends the operation.
+func (l *repositoryLimiter) acquire(ctx context.Context, repositoryObj *configapi.Repository) (func(), error) {
+	key := repositoryLimiterKey(repositoryObj)
+	slots := l.repositorySlots(key)
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("cancelled while waiting to operate on repository %q: %w", key, ctx.Err())
+	}
+}
+
+// acquireAll waits until an operation may run against each of the repositories. The repositories
+// are acquired in a fixed order so that concurrent callers cannot deadlock.
+func (l *repositoryLimiter) acquireAll(ctx context.Context, repositoryObjs []*configapi.Repository) (func(), error) {
+	byKey := map[string]*configapi.Repository{}
+	for _, r := range repositoryObjs {
+		byKey[repositoryLimiterKey(r)] = r
+	}
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var releases []func()
+	releaseAll := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for _, k := range keys {
+		release, err := l.acquire(ctx, byKey[k])
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releaseAll, nil
+}
+
+func (l *repositoryLimiter) repositorySlots(key string) chan struct{} {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if slots, ok := l.slots[key]; ok {
+		return slots
+	}
+	max, ok := l.limits[key]
+	if !ok {
+		max = l.defaultMax
+	}
+	var slots chan struct{}
+	if max > 0 {
+		slots = make(chan struct{}, max)
+	}
+	l.slots[key] = slots
+	return slots
+}
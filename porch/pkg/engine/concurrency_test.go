@@ -0,0 +1,159 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// blockingRuns block until released and track the number of concurrent runs.
+type blockingRuns struct {
+	release chan struct{}
+	running int32
+	maxSeen int32
+}
+
+func (r *blockingRuns) run(io.Reader, io.Writer) error {
+	n := atomic.AddInt32(&r.running, 1)
+	for {
+		max := atomic.LoadInt32(&r.maxSeen)
+		if n <= max || atomic.CompareAndSwapInt32(&r.maxSeen, max, n) {
+			break
+		}
+	}
+	<-r.release
+	atomic.AddInt32(&r.running, -1)
+	return nil
+}
+
+func TestConcurrencyLimitedRuntime(t *testing.T) {
+	inner := &blockingRuns{release: make(chan struct{})}
+	runtime := newConcurrencyLimitedRuntime(&fake.FunctionRuntime{Run: inner.run}, 2)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		runner, err := runtime.GetRunner(ctx, &v1.Function{Image: "test"})
+		if err != nil {
+			t.Fatalf("GetRunner failed: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := runner.Run(&bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+				t.Errorf("Run failed: %v", err)
+			}
+		}()
+	}
+
+	// Give the goroutines a chance to start, then release them one at a time.
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		inner.release <- struct{}{}
+	}
+	wg.Wait()
+
+	if got, want := atomic.LoadInt32(&inner.maxSeen), int32(2); got != want {
+		t.Errorf("Maximum concurrent functions: got %d, want %d", got, want)
+	}
+}
+
+func TestConcurrencyLimitedRuntimeCancelled(t *testing.T) {
+	inner := &blockingRuns{release: make(chan struct{})}
+	runtime := newConcurrencyLimitedRuntime(&fake.FunctionRuntime{Run: inner.run}, 1)
+
+	// Occupy the only slot.
+	busy, err := runtime.GetRunner(context.Background(), &v1.Function{Image: "busy"})
+	if err != nil {
+		t.Fatalf("GetRunner failed: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- busy.Run(&bytes.Buffer{}, &bytes.Buffer{})
+	}()
+	for atomic.LoadInt32(&inner.running) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting, err := runtime.GetRunner(ctx, &v1.Function{Image: "waiting"})
+	if err != nil {
+		t.Fatalf("GetRunner failed: %v", err)
+	}
+	cancel()
+	if err := waiting.Run(&bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Errorf("Run with cancelled context succeeded unexpectedly")
+	}
+
+	inner.release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
+
+func TestRepositoryLimiter(t *testing.T) {
+	newRepositoryObj := func(name string) *configapi.Repository {
+		return &configapi.Repository{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+	}
+	slow, healthy, unlimited := newRepositoryObj("slow"), newRepositoryObj("healthy"), newRepositoryObj("unlimited")
+	limiter := newRepositoryLimiter(1, map[string]int{"default/unlimited": 0})
+	ctx := context.Background()
+
+	// A backlog on the slow repository.
+	release, err := limiter.acquire(ctx, slow)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(timeout, slow); err == nil {
+		t.Errorf("acquire of a busy repository succeeded unexpectedly")
+	}
+
+	// Operations on other repositories are not held up.
+	releaseHealthy, err := limiter.acquire(ctx, healthy)
+	if err != nil {
+		t.Fatalf("acquire of another repository failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := limiter.acquire(ctx, unlimited); err != nil {
+			t.Fatalf("acquire of an unlimited repository failed: %v", err)
+		}
+	}
+	releaseHealthy()
+
+	release()
+	releaseAll, err := limiter.acquireAll(ctx, []*configapi.Repository{healthy, slow, healthy})
+	if err != nil {
+		t.Fatalf("acquireAll failed: %v", err)
+	}
+	releaseAll()
+
+	var none *repositoryLimiter
+	if _, err := none.acquire(ctx, slow); err != nil {
+		t.Errorf("acquire without limits failed: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+// cowContents is a copy-on-write view of the contents of a package. It shares the contents map
+// it was created from until the first change, which copies it; a mutation which changes nothing
+// therefore returns the map it was given without allocating. The shared map is never modified.
+type cowContents struct {
+	contents map[string]string
+	// Set once contents is a private copy which may be modified.
+	owned bool
+}
+
+func newCOWContents(contents map[string]string) *cowContents {
+	return &cowContents{contents: contents}
+}
+
+func (c *cowContents) get(name string) (string, bool) {
+	v, ok := c.contents[name]
+	return v, ok
+}
+
+// set sets the contents of the file, copying the shared map unless the file already has them.
+func (c *cowContents) set(name, value string) {
+	if v, ok := c.contents[name]; ok && v == value {
+		return
+	}
+	c.own()
+	c.contents[name] = value
+}
+
+// delete removes the file, copying the shared map unless there is no such file.
+func (c *cowContents) delete(name string) {
+	if _, ok := c.contents[name]; !ok {
+		return
+	}
+	c.own()
+	delete(c.contents, name)
+}
+
+func (c *cowContents) own() {
+	if c.owned {
+		return
+	}
+	contents := make(map[string]string, len(c.contents)+1)
+	for k, v := range c.contents {
+		contents[k] = v
+	}
+	c.contents = contents
+	c.owned = true
+}
+
+// result returns the contents: the shared map if nothing changed, otherwise the private copy.
+func (c *cowContents) result() map[string]string {
+	if c.contents == nil {
+		return map[string]string{}
+	}
+	return c.contents
+}
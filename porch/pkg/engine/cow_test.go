@@ -0,0 +1,89 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+)
+
+func sameMap(a, b map[string]string) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+func TestCOWContents(t *testing.T) {
+	base := map[string]string{"a.yaml": "a", "b.yaml": "b"}
+
+	unchanged := newCOWContents(base)
+	unchanged.set("a.yaml", "a")
+	unchanged.delete("missing.yaml")
+	if !sameMap(unchanged.result(), base) {
+		t.Errorf("Contents were copied although nothing changed")
+	}
+
+	changed := newCOWContents(base)
+	changed.set("a.yaml", "modified")
+	changed.delete("b.yaml")
+	changed.set("c.yaml", "c")
+	if want := map[string]string{"a.yaml": "modified", "c.yaml": "c"}; !cmp.Equal(want, changed.result()) {
+		t.Errorf("Unexpected contents (-want,+got): %s", cmp.Diff(want, changed.result()))
+	}
+	if want := map[string]string{"a.yaml": "a", "b.yaml": "b"}; !cmp.Equal(want, base) {
+		t.Errorf("Shared contents were modified (-want,+got): %s", cmp.Diff(want, base))
+	}
+
+	if got := newCOWContents(nil).result(); got == nil {
+		t.Errorf("Contents of nil are nil; want empty")
+	}
+}
+
+func TestApplyPatchSharesContents(t *testing.T) {
+	resources := repository.PackageResources{Contents: map[string]string{
+		"Kptfile":        "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+		"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+	}}
+	newPatch := func(patches ...api.PatchSpec) *applyPatchMutation {
+		task := &api.Task{Type: api.TaskTypePatch, Patch: &api.PackagePatchTaskSpec{Patches: patches}}
+		return &applyPatchMutation{patchTask: task.Patch, task: task}
+	}
+
+	// Deleting a file which was already deleted changes nothing.
+	got, _, err := newPatch(api.PatchSpec{File: "deleted.yaml", PatchType: api.PatchTypeDeleteFile}).Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if !sameMap(got.Contents, resources.Contents) {
+		t.Errorf("Patch which changed nothing copied the contents")
+	}
+
+	got, _, err = newPatch(api.PatchSpec{File: "new.yaml", Contents: "new", PatchType: api.PatchTypeCreateFile}).Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, ok := resources.Contents["new.yaml"]; ok {
+		t.Errorf("Patch modified the input resources")
+	}
+	if got, want := got.Contents["new.yaml"], "new"; got != want {
+		t.Errorf("Created file: got %q, want %q", got, want)
+	}
+	if got, want := len(got.Contents), 3; got != want {
+		t.Errorf("Patched package has %d files; want %d", got, want)
+	}
+}
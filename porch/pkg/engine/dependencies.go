@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ComputeDependencies returns the upstream package revisions which the package revision was
+// cloned from, in task order. Only clones from registered repositories are dependencies;
+// clones from unregistered git or OCI upstreams are not package revisions and are ignored.
+func (cad *cadEngine) ComputeDependencies(ctx context.Context, pr repository.PackageRevision) ([]repository.PackageRevision, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::ComputeDependencies", trace.WithAttributes())
+	defer span.End()
+
+	fetcher := &PackageFetcher{
+		cad:               cad,
+		referenceResolver: cad.referenceResolver,
+	}
+	if err := checkDependencyCycles(ctx, fetcher, pr); err != nil {
+		return nil, err
+	}
+	return computeDependencies(ctx, fetcher, pr)
+}
+
+func computeDependencies(ctx context.Context, fetcher *PackageFetcher, pr repository.PackageRevision) ([]repository.PackageRevision, error) {
+	obj := pr.GetPackageRevision()
+
+	var dependencies []repository.PackageRevision
+	seen := map[string]bool{}
+	for _, task := range obj.Spec.Tasks {
+		if task.Type != api.TaskTypeClone || task.Clone == nil {
+			continue
+		}
+		ref := task.Clone.Upstream.UpstreamRef
+		if ref == nil || seen[ref.Name] {
+			continue
+		}
+		seen[ref.Name] = true
+
+		upstream, err := fetcher.FetchRevision(ctx, ref, obj.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve upstream %q of package revision %q: %w", ref.Name, pr.KubeObjectName(), err)
+		}
+		dependencies = append(dependencies, upstream)
+	}
+	return dependencies, nil
+}
+
+// CyclicDependencyError reports package revisions which are cloned from each other, directly or
+// transitively.
+type CyclicDependencyError struct {
+	// Cycle lists the names of the package revisions of the cycle, each cloned from the next one;
+	// the first package revision is repeated at the end.
+	Cycle []string
+}
+
+func (e *CyclicDependencyError) Error() string {
+	return fmt.Sprintf("cyclic clone dependency: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// checkDependencyCycles follows the clone dependencies of the package revision transitively and
+// returns a CyclicDependencyError if they form a cycle.
+func checkDependencyCycles(ctx context.Context, fetcher *PackageFetcher, pr repository.PackageRevision) error {
+	var path []string
+	// Index in path of the package revisions being visited.
+	visiting := map[string]int{}
+	visited := map[string]bool{}
+
+	var visit func(pr repository.PackageRevision) error
+	visit = func(pr repository.PackageRevision) error {
+		name := pr.KubeObjectName()
+		if i, ok := visiting[name]; ok {
+			cycle := append(append([]string{}, path[i:]...), name)
+			return &CyclicDependencyError{Cycle: cycle}
+		}
+		if visited[name] {
+			return nil
+		}
+		visiting[name] = len(path)
+		path = append(path, name)
+
+		dependencies, err := computeDependencies(ctx, fetcher, pr)
+		if err != nil {
+			return err
+		}
+		for _, dependency := range dependencies {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		delete(visiting, name)
+		visited[name] = true
+		return nil
+	}
+	return visit(pr)
+}
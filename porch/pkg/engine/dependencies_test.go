@@ -0,0 +1,162 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComputeDependencies(t *testing.T) {
+	bucket := &fake.PackageRevision{
+		Name:               "blueprints-bucket-v1",
+		PackageRevisionKey: repository.PackageRevisionKey{Repository: "blueprints", Package: "bucket", Revision: "v1"},
+	}
+	cluster := &fake.PackageRevision{
+		Name:               "blueprints-cluster-v2",
+		PackageRevisionKey: repository.PackageRevisionKey{Repository: "blueprints", Package: "cluster", Revision: "v2"},
+	}
+	fetcher := &PackageFetcher{
+		cad:               &fakeCaD{repository: &fake.Repository{PackageRevisions: []repository.PackageRevision{bucket, cluster}}},
+		referenceResolver: &fakeReferenceResolver{},
+	}
+
+	clone := func(upstream v1alpha1.UpstreamPackage) v1alpha1.Task {
+		return v1alpha1.Task{Type: v1alpha1.TaskTypeClone, Clone: &v1alpha1.PackageCloneTaskSpec{Upstream: upstream}}
+	}
+	downstream := func(tasks ...v1alpha1.Task) *fake.PackageRevision {
+		return &fake.PackageRevision{
+			Name: "deployment-app-v1",
+			PackageRevision: &v1alpha1.PackageRevision{
+				Spec: v1alpha1.PackageRevisionSpec{Tasks: tasks},
+			},
+		}
+	}
+
+	pr := downstream(
+		clone(v1alpha1.UpstreamPackage{UpstreamRef: &v1alpha1.PackageRevisionRef{Name: cluster.Name}}),
+		clone(v1alpha1.UpstreamPackage{Git: &v1alpha1.GitPackage{Repo: "https://example.com/repo.git"}}),
+		v1alpha1.Task{Type: v1alpha1.TaskTypeEval, Eval: &v1alpha1.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/set-namespace:v0.1"}},
+		clone(v1alpha1.UpstreamPackage{UpstreamRef: &v1alpha1.PackageRevisionRef{Name: bucket.Name}}),
+		clone(v1alpha1.UpstreamPackage{UpstreamRef: &v1alpha1.PackageRevisionRef{Name: cluster.Name}}),
+	)
+	got, err := computeDependencies(context.Background(), fetcher, pr)
+	if err != nil {
+		t.Fatalf("computeDependencies failed: %v", err)
+	}
+	var names []string
+	for _, rev := range got {
+		names = append(names, rev.KubeObjectName())
+	}
+	if want := []string{cluster.Name, bucket.Name}; !cmp.Equal(want, names) {
+		t.Errorf("Unexpected dependencies (-want, +got): %s", cmp.Diff(want, names))
+	}
+
+	if got, err := computeDependencies(context.Background(), fetcher, downstream()); err != nil || len(got) != 0 {
+		t.Errorf("computeDependencies of a package revision without clone tasks: got %v, %v; want no dependencies", got, err)
+	}
+
+	missing := downstream(clone(v1alpha1.UpstreamPackage{UpstreamRef: &v1alpha1.PackageRevisionRef{Name: "blueprints-missing-v1"}}))
+	if _, err := computeDependencies(context.Background(), fetcher, missing); err == nil {
+		t.Errorf("computeDependencies with a missing upstream succeeded unexpectedly")
+	}
+}
+
+func TestDependencyCycles(t *testing.T) {
+	newRevision := func(name string, upstreams ...string) *fake.PackageRevision {
+		var tasks []v1alpha1.Task
+		for _, upstream := range upstreams {
+			tasks = append(tasks, v1alpha1.Task{
+				Type:  v1alpha1.TaskTypeClone,
+				Clone: &v1alpha1.PackageCloneTaskSpec{Upstream: v1alpha1.UpstreamPackage{UpstreamRef: &v1alpha1.PackageRevisionRef{Name: upstream}}},
+			})
+		}
+		return &fake.PackageRevision{
+			Name:            name,
+			PackageRevision: &v1alpha1.PackageRevision{Spec: v1alpha1.PackageRevisionSpec{Tasks: tasks}},
+		}
+	}
+	newFetcher := func(revisions ...*fake.PackageRevision) *PackageFetcher {
+		repo := &fake.Repository{}
+		for _, r := range revisions {
+			repo.PackageRevisions = append(repo.PackageRevisions, r)
+		}
+		return &PackageFetcher{
+			cad:               &fakeCaD{repository: repo},
+			referenceResolver: &fakeReferenceResolver{},
+		}
+	}
+
+	for _, tc := range []struct {
+		name      string
+		revisions []*fake.PackageRevision
+		want      []string
+	}{
+		{
+			name: "two",
+			revisions: []*fake.PackageRevision{
+				newRevision("repo-a-v1", "repo-b-v1"),
+				newRevision("repo-b-v1", "repo-a-v1"),
+			},
+			want: []string{"repo-a-v1", "repo-b-v1", "repo-a-v1"},
+		},
+		{
+			name: "three",
+			revisions: []*fake.PackageRevision{
+				newRevision("repo-a-v1", "repo-b-v1"),
+				newRevision("repo-b-v1", "repo-c-v1"),
+				newRevision("repo-c-v1", "repo-a-v1"),
+			},
+			want: []string{"repo-a-v1", "repo-b-v1", "repo-c-v1", "repo-a-v1"},
+		},
+		{
+			name: "downstream of cycle",
+			revisions: []*fake.PackageRevision{
+				newRevision("repo-app-v1", "repo-a-v1"),
+				newRevision("repo-a-v1", "repo-b-v1"),
+				newRevision("repo-b-v1", "repo-a-v1"),
+			},
+			want: []string{"repo-a-v1", "repo-b-v1", "repo-a-v1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkDependencyCycles(context.Background(), newFetcher(tc.revisions...), tc.revisions[0])
+			var cyclic *CyclicDependencyError
+			if !errors.As(err, &cyclic) {
+				t.Fatalf("checkDependencyCycles returned %v; want CyclicDependencyError", err)
+			}
+			if !cmp.Equal(tc.want, cyclic.Cycle) {
+				t.Errorf("Unexpected cycle (-want, +got): %s", cmp.Diff(tc.want, cyclic.Cycle))
+			}
+		})
+	}
+
+	// Shared upstreams are not cycles.
+	diamond := []*fake.PackageRevision{
+		newRevision("repo-app-v1", "repo-a-v1", "repo-b-v1"),
+		newRevision("repo-a-v1", "repo-base-v1"),
+		newRevision("repo-b-v1", "repo-base-v1"),
+		newRevision("repo-base-v1"),
+	}
+	if err := checkDependencyCycles(context.Background(), newFetcher(diamond...), diamond[0]); err != nil {
+		t.Errorf("checkDependencyCycles of acyclic dependencies failed: %v", err)
+	}
+}
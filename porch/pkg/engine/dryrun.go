@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DryRunResult is the outcome of a dry run of an update of package resources.
+type DryRunResult struct {
+	// Resources are the package resources after the update and render. Empty if the render failed.
+	Resources repository.PackageResources
+	// Results are the function results of the render. Nil if the render failed; the result of
+	// the failing function is then reported by RenderErr.
+	Results *fnresult.ResultList
+	// Attribution maps the files to the mutation which last changed them: the update of the
+	// resources, or the render. Empty if the render failed.
+	Attribution Attribution
+	// RenderErr is the reason the render failed, if it did: a *RenderError, an
+	// *OutputViolationError if the rendered package violates the output guardrails, or a
+	// *DuplicateResourcesError if it has duplicated resources.
+	RenderErr error
+}
+
+// DryRunUpdatePackageResources applies the update of the package resources and renders the package
+// in memory, as UpdatePackageResources would, without persisting anything. A failed render is
+// reported in the result; an error is returned only if the update itself cannot be applied.
+func (cad *cadEngine) DryRunUpdatePackageResources(ctx context.Context, oldPackage repository.PackageRevision, old, new *api.PackageRevisionResources) (*DryRunResult, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::DryRunUpdatePackageResources", trace.WithAttributes())
+	defer span.End()
+
+	if err := checkResourcesUpdatable(oldPackage); err != nil {
+		return nil, err
+	}
+
+	apiResources, err := oldPackage.GetResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get package resources: %w", err)
+	}
+	resources := repository.PackageResources{
+		Contents: apiResources.Spec.Resources,
+	}
+
+	mutations, render, err := cad.resourcesMutations(ctx, oldPackage.GetPackageRevision().Namespace, oldPackage.Key(), old, new)
+	if err != nil {
+		return nil, err
+	}
+	attribution := Attribution{}
+	for i, m := range mutations {
+		applied, task, err := m.Apply(ctx, resources)
+		if err != nil {
+			if m == render && isRenderFailure(err) {
+				return &DryRunResult{RenderErr: err}, nil
+			}
+			return nil, err
+		}
+		attribution.record(i, task, resources.Contents, applied.Contents)
+		resources = applied
+	}
+	return &DryRunResult{
+		Resources:   resources,
+		Results:     render.results,
+		Attribution: attribution,
+	}, nil
+}
+
+func isRenderFailure(err error) bool {
+	var renderErr *RenderError
+	var violation *OutputViolationError
+	var duplicates *DuplicateResourcesError
+	return errors.As(err, &renderErr) || errors.As(err, &violation) || errors.As(err, &duplicates)
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+)
+
+func TestDryRunUpdatePackageResources(t *testing.T) {
+	kptfile := "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n"
+	original := &v1alpha1.PackageRevisionResources{
+		Spec: v1alpha1.PackageRevisionResourcesSpec{
+			Resources: map[string]string{"Kptfile": kptfile},
+		},
+	}
+	draft := func(lifecycle v1alpha1.PackageRevisionLifecycle) *fake.PackageRevision {
+		return &fake.PackageRevision{
+			Name:            "repo-app-v1",
+			PackageRevision: &v1alpha1.PackageRevision{Spec: v1alpha1.PackageRevisionSpec{Lifecycle: lifecycle}},
+			Resources:       original.DeepCopy(),
+		}
+	}
+	updated := original.DeepCopy()
+	updated.Spec.Resources["configmap.yaml"] = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"
+
+	results := fnresult.ResultList{Items: []fnresult.Result{
+		{Image: "gcr.io/kpt-fn/kubeval:v0.3", Results: framework.Results{{Message: "looks good", Severity: framework.Info}}},
+	}}
+	pr := draft(v1alpha1.PackageRevisionLifecycleDraft)
+	cad := &cadEngine{renderer: &resultsRenderer{results: results}}
+	got, err := cad.DryRunUpdatePackageResources(context.Background(), pr, original, updated)
+	if err != nil {
+		t.Fatalf("DryRunUpdatePackageResources failed: %v", err)
+	}
+	if got.RenderErr != nil {
+		t.Errorf("DryRunUpdatePackageResources reported render failure: %v", got.RenderErr)
+	}
+	if _, ok := got.Resources.Contents["configmap.yaml"]; !ok {
+		t.Errorf("Dry run result does not contain the updated resources")
+	}
+	if attribution, ok := got.Attribution["configmap.yaml"]; !ok || attribution.Mutation != 0 {
+		t.Errorf("Dry run attributes configmap.yaml to %v; want the update of the resources", got.Attribution["configmap.yaml"])
+	}
+	if got.Results == nil || len(got.Results.Items) != 1 {
+		t.Errorf("Dry run result has function results %v; want the render results", got.Results)
+	}
+	if _, ok := pr.Resources.Spec.Resources["configmap.yaml"]; ok {
+		t.Errorf("Dry run modified the package revision")
+	}
+
+	cad = &cadEngine{renderer: &resultsRenderer{err: errors.New("function failed")}}
+	got, err = cad.DryRunUpdatePackageResources(context.Background(), draft(v1alpha1.PackageRevisionLifecycleDraft), original, updated)
+	if err != nil {
+		t.Fatalf("DryRunUpdatePackageResources with a failing render failed: %v", err)
+	}
+	var renderErr *RenderError
+	if !errors.As(got.RenderErr, &renderErr) {
+		t.Errorf("Dry run with a failing render reported %v; want RenderError", got.RenderErr)
+	}
+
+	if _, err := cad.DryRunUpdatePackageResources(context.Background(), draft(v1alpha1.PackageRevisionLifecyclePublished), original, updated); err == nil {
+		t.Errorf("Dry run of an update of a published package revision succeeded unexpectedly")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"strings"
 	"testing"
 
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
 	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
@@ -123,10 +124,62 @@ func (f *fakeCaD) UpdatePackageResources(_ context.Context, _ *configapi.Reposit
 	return nil, nil
 }
 
+func (f *fakeCaD) UpdatePackageResourcesBatch(context.Context, []BatchResourcesUpdate) ([]repository.PackageRevision, error) {
+	return nil, nil
+}
+
 func (f *fakeCaD) DeletePackageRevision(context.Context, *configapi.Repository, repository.PackageRevision) error {
 	return nil
 }
 
-func (f *fakeCaD) ListFunctions(context.Context, *configapi.Repository) ([]repository.Function, error) {
+func (f *fakeCaD) ListFunctions(context.Context, *configapi.Repository, repository.ListFunctionFilter) ([]repository.Function, error) {
 	return []repository.Function{}, nil
 }
+
+func (f *fakeCaD) CheckUpstreamUpdate(context.Context, repository.PackageRevision) (*UpstreamUpdate, error) {
+	return &UpstreamUpdate{}, nil
+}
+
+func (f *fakeCaD) DiffUpstream(context.Context, repository.PackageRevision) ([]v1alpha1.PatchSpec, error) {
+	return nil, nil
+}
+
+func (f *fakeCaD) DeletePackage(context.Context, *configapi.Repository, string, bool) error {
+	return nil
+}
+
+func (f *fakeCaD) ComputeReadiness(context.Context, repository.PackageRevision) (*Readiness, error) {
+	return &Readiness{Ready: true}, nil
+}
+
+func (f *fakeCaD) ComputeDependencies(context.Context, repository.PackageRevision) ([]repository.PackageRevision, error) {
+	return nil, nil
+}
+
+func (f *fakeCaD) DryRunUpdatePackageResources(context.Context, repository.PackageRevision, *v1alpha1.PackageRevisionResources, *v1alpha1.PackageRevisionResources) (*DryRunResult, error) {
+	return &DryRunResult{}, nil
+}
+
+func (f *fakeCaD) ResultHistory(repository.PackageRevision) []RecordedResults {
+	return nil
+}
+
+func (f *fakeCaD) ValidatePackageRevision(context.Context, repository.PackageRevision) (*fnresult.ResultList, error) {
+	return &fnresult.ResultList{}, nil
+}
+
+func (f *fakeCaD) ComputeEffectivePipeline(context.Context, *configapi.Repository, *v1alpha1.PackageRevision) (*kptfile.Pipeline, error) {
+	return &kptfile.Pipeline{}, nil
+}
+
+func (f *fakeCaD) PromotePackageRevision(context.Context, repository.PackageRevision, *configapi.Repository) (repository.PackageRevision, error) {
+	return nil, nil
+}
+
+func (f *fakeCaD) PreviewPackageRevision(context.Context, *v1alpha1.PackageRevision, int) (*PreviewResult, error) {
+	return &PreviewResult{}, nil
+}
+
+func (f *fakeCaD) PackageSummary(context.Context, repository.PackageRevision) (*PackageSummary, error) {
+	return &PackageSummary{}, nil
+}
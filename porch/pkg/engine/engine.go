@@ -15,26 +15,34 @@
 package engine
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
-	"io/fs"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/debug"
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
 	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/cache"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/git"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/kpt"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/kustomize/kyaml/comments"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
@@ -46,8 +54,39 @@ type CaDEngine interface {
 	CreatePackageRevision(ctx context.Context, repositoryObj *configapi.Repository, obj *api.PackageRevision) (repository.PackageRevision, error)
 	UpdatePackageRevision(ctx context.Context, repositoryObj *configapi.Repository, oldPackage repository.PackageRevision, old, new *api.PackageRevision) (repository.PackageRevision, error)
 	UpdatePackageResources(ctx context.Context, repositoryObj *configapi.Repository, oldPackage repository.PackageRevision, old, new *api.PackageRevisionResources) (repository.PackageRevision, error)
+	// DryRunUpdatePackageResources applies the update of the package resources and renders the
+	// package in memory without persisting anything. See DryRunResult.
+	DryRunUpdatePackageResources(ctx context.Context, oldPackage repository.PackageRevision, old, new *api.PackageRevisionResources) (*DryRunResult, error)
+	// UpdatePackageResourcesBatch updates the resources of several draft package revisions
+	// together; if any update fails, the others are rolled back. See BatchResourcesUpdate.
+	UpdatePackageResourcesBatch(ctx context.Context, updates []BatchResourcesUpdate) ([]repository.PackageRevision, error)
 	DeletePackageRevision(ctx context.Context, repositoryObj *configapi.Repository, obj repository.PackageRevision) error
-	ListFunctions(ctx context.Context, repositoryObj *configapi.Repository) ([]repository.Function, error)
+	DeletePackage(ctx context.Context, repositoryObj *configapi.Repository, packageName string, force bool) error
+	ListFunctions(ctx context.Context, repositoryObj *configapi.Repository, filter repository.ListFunctionFilter) ([]repository.Function, error)
+	CheckUpstreamUpdate(ctx context.Context, pr repository.PackageRevision) (*UpstreamUpdate, error)
+	DiffUpstream(ctx context.Context, pr repository.PackageRevision) ([]api.PatchSpec, error)
+	ComputeReadiness(ctx context.Context, pr repository.PackageRevision) (*Readiness, error)
+	// ResultHistory returns the function results of the most recent renders of the package
+	// revision, oldest first. It is empty unless result history is enabled.
+	ResultHistory(pr repository.PackageRevision) []RecordedResults
+	// ValidatePackageRevision runs only the validators of the package revision, without
+	// persisting anything, and returns their results.
+	ValidatePackageRevision(ctx context.Context, pr repository.PackageRevision) (*fnresult.ResultList, error)
+	// ComputeEffectivePipeline previews the Kptfile pipeline of the package revision after its
+	// tasks are applied, without creating the package revision.
+	ComputeEffectivePipeline(ctx context.Context, repositoryObj *configapi.Repository, obj *api.PackageRevision) (*kptfilev1.Pipeline, error)
+	// ComputeDependencies returns the registered upstream package revisions which the package
+	// revision was cloned from.
+	ComputeDependencies(ctx context.Context, pr repository.PackageRevision) ([]repository.PackageRevision, error)
+	// PromotePackageRevision creates a published copy of the published source package revision
+	// in the destination repository, recording the source as its upstream.
+	PromotePackageRevision(ctx context.Context, source repository.PackageRevision, destRepo *configapi.Repository) (repository.PackageRevision, error)
+	// PreviewPackageRevision applies the tasks of the package revision up to and including the
+	// task with the given index in memory, and returns the intermediate resources. See PreviewResult.
+	PreviewPackageRevision(ctx context.Context, obj *api.PackageRevision, lastTask int) (*PreviewResult, error)
+	// PackageSummary returns the resource count, kinds and size of the package revision,
+	// without loading its resources if they were summarized before.
+	PackageSummary(ctx context.Context, pr repository.PackageRevision) (*PackageSummary, error)
 }
 
 func NewCaDEngine(opts ...EngineOption) (CaDEngine, error) {
@@ -57,6 +96,20 @@ func NewCaDEngine(opts ...EngineOption) (CaDEngine, error) {
 			return nil, err
 		}
 	}
+	// Fall back to the in-process implementations so that callers only need to
+	// provide the renderer or function runtime they want to substitute.
+	if engine.renderer == nil {
+		engine.renderer = kpt.NewRenderer()
+	}
+	if engine.runtime == nil {
+		engine.runtime = kpt.NewSimpleFunctionRuntime()
+	}
+	if engine.offline {
+		engine.runtime = newOfflineRuntime(engine.runtime, engine.offlineImages)
+	}
+	if engine.maxConcurrentFunctions > 0 {
+		engine.runtime = newConcurrencyLimitedRuntime(engine.runtime, engine.maxConcurrentFunctions)
+	}
 	return engine, nil
 }
 
@@ -67,6 +120,53 @@ type cadEngine struct {
 	credentialResolver repository.CredentialResolver
 	referenceResolver  ReferenceResolver
 	userInfoProvider   repository.UserInfoProvider
+	// Armored PGP key ring used to verify signatures of cloned upstream commits.
+	trustedUpstreamKeys string
+	// Glob patterns of files which are excluded from patches recorded by resource updates.
+	patchIgnorePatterns []string
+	draftNamer          DraftNamer
+	// Maximum number of functions executing simultaneously; zero means unlimited.
+	maxConcurrentFunctions int
+	// Limits of the operations running simultaneously against each repository; nil if unlimited.
+	repositoryLimiter *repositoryLimiter
+	// Extensions of the files fed to evaluated functions, and of the files never fed to them.
+	functionInputExtensions    []string
+	functionExcludedExtensions []string
+	// If set, resource updates record one patch per changed document of multi-document YAML files.
+	documentPatches bool
+	// Local directory under which packages can be cloned from the file system; empty disables it.
+	localUpstreamRoot string
+	// If set, only functions which run in-process or whose images are in offlineImages are run.
+	offline       bool
+	offlineImages []string
+	// Function results of recent renders; nil if result history is disabled.
+	resultHistory *resultHistory
+	// Retry policy of function evaluations; nil disables retries.
+	functionRetryPolicy *RetryPolicy
+	// Maximum number of tasks of a package revision; zero means unlimited.
+	maxTasks int
+	// Checkpoints of draft updates; nil if checkpointing is disabled.
+	checkpoints *checkpointStore
+	// If set, the output of renders and function evaluations is checked against the guardrails.
+	outputGuardrails *OutputGuardrails
+	// If set, Kptfiles are fed to evaluated functions.
+	functionInputKptfiles bool
+	// If set, files of updated packages which cannot be loaded are skipped instead of failing the update.
+	lenientPackageLoading bool
+	// Handling of symbolic links of updated packages.
+	symlinkPolicy SymlinkPolicy
+	// If set, evaluated functions run without network access.
+	networkSandbox bool
+	// If set, called with the results of each evaluated function as soon as it completes.
+	functionResultsCallback fn.ResultsCallback
+	// If set, rendered packages may have several resources with the same identity.
+	allowDuplicateResources bool
+	// Source of setter values merged into packages when they are rendered; nil if none.
+	setterValuesSource SetterValuesSource
+	// Summaries of the resources of package revisions.
+	summaries summaryStore
+	// Handling of creations of package revisions of packages which already have a draft.
+	existingDraftPolicy ExistingDraftPolicy
 }
 
 var _ CaDEngine = &cadEngine{}
@@ -86,6 +186,14 @@ func (cad *cadEngine) CreatePackageRevision(ctx context.Context, repositoryObj *
 	ctx, span := tracer.Start(ctx, "cadEngine::CreatePackageRevision", trace.WithAttributes())
 	defer span.End()
 
+	release, err := cad.repositoryLimiter.acquire(ctx, repositoryObj)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx = withCommitMessage(ctx, obj.Annotations)
+
 	// Validate package lifecycle. Cannot create a final package
 	switch obj.Spec.Lifecycle {
 	case "":
@@ -100,15 +208,130 @@ func (cad *cadEngine) CreatePackageRevision(ctx context.Context, repositoryObj *
 		return nil, fmt.Errorf("unsupported lifecycle value: %s", obj.Spec.Lifecycle)
 	}
 
+	if err := cad.checkTaskCount(obj); err != nil {
+		return nil, err
+	}
+
+	if cad.draftNamer != nil {
+		revision, err := cad.draftNamer.NameDraft(ctx, repositoryObj, obj)
+		if err != nil {
+			return nil, fmt.Errorf("draft name %q rejected: %w", obj.Spec.Revision, err)
+		}
+		obj.Spec.Revision = revision
+	}
+
 	repo, err := cad.cache.OpenRepository(ctx, repositoryObj)
 	if err != nil {
 		return nil, err
 	}
+	if cad.existingDraftPolicy != CreateNewDraft {
+		existing, err := findDraft(ctx, repo, obj.Spec.PackageName)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if cad.existingDraftPolicy == ReuseExistingDraft {
+				return existing, nil
+			}
+			return nil, fmt.Errorf("cannot create package revision of package %q: draft %q already exists", obj.Spec.PackageName, existing.KubeObjectName())
+		}
+	}
 	draft, err := repo.CreatePackageRevision(ctx, obj)
 	if err != nil {
 		return nil, err
 	}
 
+	mutations, err := cad.createMutations(ctx, repositoryObj, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// Render package after creation.
+	pkgKey := repository.PackageRevisionKey{Repository: repositoryObj.Name, Package: obj.Spec.PackageName, Revision: obj.Spec.Revision}
+	render, err := cad.renderMutation(ctx, repositoryObj.Namespace, pkgKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	mutations = append(mutations, render)
+
+	key := checkpointKey("create", repositoryObj.Namespace, repositoryObj.Name, obj.Spec.PackageName, obj.Spec.Revision, obj.Spec.Tasks)
+	baseResources := repository.PackageResources{}
+	if err := cad.applyResourceMutationsWithCheckpoints(ctx, key, draft, baseResources, mutations); err != nil {
+		return nil, err
+	}
+
+	if err := draft.UpdateLifecycle(ctx, obj.Spec.Lifecycle); err != nil {
+		return nil, err
+	}
+
+	// Updates are done.
+	pr, err := cad.closeDraft(ctx, draft, render)
+	if err != nil {
+		return nil, err
+	}
+	cad.checkpoints.remove(key)
+	return pr, nil
+}
+
+// ExistingDraftPolicy selects how CreatePackageRevision handles packages which already have a draft
+// package revision in the repository.
+type ExistingDraftPolicy string
+
+const (
+	// CreateNewDraft creates another draft of the package.
+	CreateNewDraft ExistingDraftPolicy = ""
+	// FailIfDraftExists fails the creation.
+	FailIfDraftExists ExistingDraftPolicy = "fail"
+	// ReuseExistingDraft returns the existing draft unchanged instead of creating one; the tasks of
+	// the new package revision are not applied.
+	ReuseExistingDraft ExistingDraftPolicy = "reuse"
+)
+
+// findDraft returns the first draft package revision of the package, or nil if it has none.
+func findDraft(ctx context.Context, repo repository.Repository, packageName string) (repository.PackageRevision, error) {
+	revisions, err := repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: packageName})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list package revisions of package %q: %w", packageName, err)
+	}
+	for _, pr := range revisions {
+		if pr.Lifecycle() == api.PackageRevisionLifecycleDraft {
+			return pr, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkTaskCount fails if the package revision has more tasks than the engine allows.
+func (cad *cadEngine) checkTaskCount(obj *api.PackageRevision) error {
+	if cad.maxTasks > 0 && len(obj.Spec.Tasks) > cad.maxTasks {
+		return fmt.Errorf("package revision has %d tasks; the maximum is %d", len(obj.Spec.Tasks), cad.maxTasks)
+	}
+	return nil
+}
+
+// createMutations returns the mutations which create the contents of a new package revision
+// from its tasks, not including the final render.
+func (cad *cadEngine) createMutations(ctx context.Context, repositoryObj *configapi.Repository, obj *api.PackageRevision) ([]mutation, error) {
+	mutations, err := cad.taskMutations(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// If creating a package in a deployment repository, generate context
+	if repositoryObj.Spec.Deployment {
+		mutation, err := newBuiltinFunctionMutation(fnruntime.FuncGenPkgContext)
+		if err != nil {
+			return nil, err
+		}
+		mutations = append(mutations, mutation)
+	}
+
+	return mutations, nil
+}
+
+// taskMutations returns the mutations of the tasks of the package revision, preceded by an
+// implicit init if the first task neither initializes nor clones the package.
+func (cad *cadEngine) taskMutations(ctx context.Context, obj *api.PackageRevision) ([]mutation, error) {
 	var mutations []mutation
 
 	// Unless first task is Init or Clone, insert Init to create an empty package.
@@ -132,32 +355,7 @@ func (cad *cadEngine) CreatePackageRevision(ctx context.Context, repositoryObj *
 		mutations = append(mutations, mutation)
 	}
 
-	// If creating a package in a deployment repository, generate context
-	if repositoryObj.Spec.Deployment {
-		mutation, err := newBuiltinFunctionMutation(fnruntime.FuncGenPkgContext)
-		if err != nil {
-			return nil, err
-		}
-		mutations = append(mutations, mutation)
-	}
-
-	// Render package after creation.
-	mutations = append(mutations, &renderPackageMutation{
-		renderer: cad.renderer,
-		runtime:  cad.runtime,
-	})
-
-	baseResources := repository.PackageResources{}
-	if err := applyResourceMutations(ctx, draft, baseResources, mutations); err != nil {
-		return nil, err
-	}
-
-	if err := draft.UpdateLifecycle(ctx, obj.Spec.Lifecycle); err != nil {
-		return nil, err
-	}
-
-	// Updates are done.
-	return draft.Close(ctx)
+	return mutations, nil
 }
 
 func (cad *cadEngine) mapTaskToMutation(ctx context.Context, obj *api.PackageRevision, task *api.Task) (mutation, error) {
@@ -175,12 +373,14 @@ func (cad *cadEngine) mapTaskToMutation(ctx context.Context, obj *api.PackageRev
 			return nil, fmt.Errorf("clone not set for task of type %q", task.Type)
 		}
 		return &clonePackageMutation{
-			task:               task,
-			namespace:          obj.Namespace,
-			name:               obj.Spec.PackageName,
-			cad:                cad,
-			credentialResolver: cad.credentialResolver,
-			referenceResolver:  cad.referenceResolver,
+			task:                task,
+			namespace:           obj.Namespace,
+			name:                obj.Spec.PackageName,
+			cad:                 cad,
+			credentialResolver:  cad.credentialResolver,
+			referenceResolver:   cad.referenceResolver,
+			trustedUpstreamKeys: cad.trustedUpstreamKeys,
+			localUpstreamRoot:   cad.localUpstreamRoot,
 		}, nil
 
 	case api.TaskTypePatch:
@@ -202,8 +402,17 @@ func (cad *cadEngine) mapTaskToMutation(ctx context.Context, obj *api.PackageRev
 			return nil, fmt.Errorf("eval not set for task of type %q", task.Type)
 		}
 		return &evalFunctionMutation{
-			runtime: cad.runtime,
-			task:    task,
+			runtime:           cad.runtime,
+			task:              task,
+			includeExtensions: cad.functionInputExtensions,
+			excludeExtensions: cad.functionExcludedExtensions,
+			includeKptfiles:   cad.functionInputKptfiles,
+			retryPolicy:       cad.functionRetryPolicy,
+			guardrails:        cad.outputGuardrails,
+			runnerOptions: fn.RunnerOptions{
+				NetworkSandbox: cad.networkSandbox,
+				OnResults:      cad.functionResultsCallback,
+			},
 		}, nil
 
 	default:
@@ -215,21 +424,30 @@ func (cad *cadEngine) UpdatePackageRevision(ctx context.Context, repositoryObj *
 	ctx, span := tracer.Start(ctx, "cadEngine::UpdatePackageRevision", trace.WithAttributes())
 	defer span.End()
 
-	// Validate package lifecycle. Can only update a draft.
-	switch lifecycle := oldObj.Spec.Lifecycle; lifecycle {
-	default:
-		return nil, fmt.Errorf("invalid original lifecycle value: %q", lifecycle)
-	case api.PackageRevisionLifecycleDraft, api.PackageRevisionLifecycleProposed:
-		// Draft or proposed can be updated.
-	case api.PackageRevisionLifecyclePublished:
+	release, err := cad.repositoryLimiter.acquire(ctx, repositoryObj)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx = withCommitMessage(ctx, newObj.Annotations)
+
+	// Validate package lifecycle transition. Draft or proposed can be updated.
+	lifecycles := repository.PackageRevisionLifecycles
+	switch from, to := oldObj.Spec.Lifecycle, newObj.Spec.Lifecycle; {
+	case !lifecycles.IsValid(from):
+		return nil, fmt.Errorf("invalid original lifecycle value: %q", from)
+	case from == api.PackageRevisionLifecyclePublished:
 		// TODO: generate errors that can be translated to correct HTTP responses
-		return nil, fmt.Errorf("cannot update a package revision with lifecycle value %q", lifecycle)
+		return nil, fmt.Errorf("cannot update a package revision with lifecycle value %q", from)
+	case !lifecycles.IsValid(to):
+		return nil, fmt.Errorf("invalid desired lifecycle value: %q", to)
+	case !lifecycles.CanTransition(from, to):
+		return nil, fmt.Errorf("cannot change lifecycle of a package revision from %q to %q", from, to)
 	}
-	switch lifecycle := newObj.Spec.Lifecycle; lifecycle {
-	default:
-		return nil, fmt.Errorf("invalid desired lifecycle value: %q", lifecycle)
-	case api.PackageRevisionLifecycleDraft, api.PackageRevisionLifecycleProposed, api.PackageRevisionLifecyclePublished:
-		// These values are ok
+
+	if err := cad.checkTaskCount(newObj); err != nil {
+		return nil, err
 	}
 
 	repo, err := cad.cache.OpenRepository(ctx, repositoryObj)
@@ -264,7 +482,14 @@ func (cad *cadEngine) UpdatePackageRevision(ctx context.Context, repositoryObj *
 				return nil, fmt.Errorf("clone only supported as first task")
 			}
 			mutation := &updatePackageMutation{
-				task: newTask,
+				task:                newTask,
+				lenientLoad:         cad.lenientPackageLoading,
+				symlinks:            cad.symlinkPolicy,
+				credentialResolver:  cad.credentialResolver,
+				trustedUpstreamKeys: cad.trustedUpstreamKeys,
+			}
+			if oldTask.Clone != nil && oldTask.Clone.Upstream.Git != nil {
+				mutation.originCommit = oldTask.Clone.Upstream.Git.Commit
 			}
 			mutations = append(mutations, mutation)
 
@@ -274,11 +499,13 @@ func (cad *cadEngine) UpdatePackageRevision(ctx context.Context, repositoryObj *
 	}
 
 	// Re-render if we are making changes.
+	var render *renderPackageMutation
 	if len(mutations) > 0 {
-		mutations = append(mutations, &renderPackageMutation{
-			renderer: cad.renderer,
-			runtime:  cad.runtime,
-		})
+		render, err = cad.renderMutation(ctx, repositoryObj.Namespace, oldPackage.Key(), nil)
+		if err != nil {
+			return nil, err
+		}
+		mutations = append(mutations, render)
 	}
 
 	draft, err := repo.UpdatePackage(ctx, oldPackage)
@@ -288,16 +515,18 @@ func (cad *cadEngine) UpdatePackageRevision(ctx context.Context, repositoryObj *
 
 	// TODO: Handle the case if alongside lifecycle change, tasks are changed too.
 	// Update package contents only if the package is in draft state
+	var key string
 	if oldObj.Spec.Lifecycle == api.PackageRevisionLifecycleDraft {
 		apiResources, err := oldPackage.GetResources(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("cannot get package resources: %w", err)
 		}
+		key = updateCheckpointKey(repositoryObj.Namespace, repositoryObj.Name, oldPackage.KubeObjectName(), apiResources.Spec.Resources, newObj.Spec.Tasks)
 		resources := repository.PackageResources{
 			Contents: apiResources.Spec.Resources,
 		}
 
-		if err := applyResourceMutations(ctx, draft, resources, mutations); err != nil {
+		if err := cad.applyResourceMutationsWithCheckpoints(ctx, key, draft, resources, mutations); err != nil {
 			return nil, err
 		}
 	}
@@ -307,13 +536,24 @@ func (cad *cadEngine) UpdatePackageRevision(ctx context.Context, repositoryObj *
 	}
 
 	// Updates are done.
-	return draft.Close(ctx)
+	pr, err := cad.closeDraft(ctx, draft, render)
+	if err != nil {
+		return nil, err
+	}
+	cad.checkpoints.remove(key)
+	return pr, nil
 }
 
 func (cad *cadEngine) DeletePackageRevision(ctx context.Context, repositoryObj *configapi.Repository, oldPackage repository.PackageRevision) error {
 	ctx, span := tracer.Start(ctx, "cadEngine::DeletePackageRevision", trace.WithAttributes())
 	defer span.End()
 
+	release, err := cad.repositoryLimiter.acquire(ctx, repositoryObj)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	repo, err := cad.cache.OpenRepository(ctx, repositoryObj)
 	if err != nil {
 		return err
@@ -322,25 +562,133 @@ func (cad *cadEngine) DeletePackageRevision(ctx context.Context, repositoryObj *
 	if err := repo.DeletePackageRevision(ctx, oldPackage); err != nil {
 		return err
 	}
+	cad.summaries.remove(oldPackage.KubeObjectName())
+	cad.resultHistory.remove(oldPackage.KubeObjectName())
 
 	return nil
 }
 
+// DeletePackage deletes all revisions of the named package, drafts and proposed revisions
+// before published ones. Unless forced, it refuses to delete a package whose revisions are
+// the upstream of package revisions cloned in any cached repository.
+func (cad *cadEngine) DeletePackage(ctx context.Context, repositoryObj *configapi.Repository, packageName string, force bool) error {
+	ctx, span := tracer.Start(ctx, "cadEngine::DeletePackage", trace.WithAttributes())
+	defer span.End()
+
+	release, err := cad.repositoryLimiter.acquire(ctx, repositoryObj)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	repo, err := cad.cache.OpenRepository(ctx, repositoryObj)
+	if err != nil {
+		return err
+	}
+
+	all, err := repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
+	if err != nil {
+		return err
+	}
+
+	var revisions []repository.PackageRevision
+	for _, pr := range all {
+		if pr.Key().Package == packageName {
+			revisions = append(revisions, pr)
+		}
+	}
+	if len(revisions) == 0 {
+		return fmt.Errorf("package %q not found in repository %q", packageName, repositoryObj.Name)
+	}
+
+	if !force {
+		// Packages may be cloned from other repositories, so look for clones in every cached repository.
+		cached, err := cad.cache.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
+		if err != nil {
+			return err
+		}
+		if downstream := findDownstreamClones(cached, revisions); len(downstream) > 0 {
+			return fmt.Errorf("cannot delete package %q; its revisions are cloned by %s", packageName, strings.Join(downstream, ", "))
+		}
+	}
+
+	// Delete unpublished revisions first so that a partial failure leaves published revisions in place.
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].Lifecycle() != api.PackageRevisionLifecyclePublished &&
+			revisions[j].Lifecycle() == api.PackageRevisionLifecyclePublished
+	})
+
+	err = repo.DeletePackageRevisions(ctx, revisions)
+	// Summaries of revisions which survive a partial failure are recomputed on use, but their
+	// result history is lost.
+	for _, pr := range revisions {
+		cad.summaries.remove(pr.KubeObjectName())
+		cad.resultHistory.remove(pr.KubeObjectName())
+	}
+	return err
+}
+
+// findDownstreamClones returns the sorted names of package revisions, not themselves among the
+// deleted revisions, which were cloned from one of the deleted revisions.
+func findDownstreamClones(all, deleted []repository.PackageRevision) []string {
+	names := map[string]bool{}
+	for _, pr := range deleted {
+		names[pr.KubeObjectName()] = true
+	}
+
+	var downstream []string
+	for _, pr := range all {
+		if names[pr.KubeObjectName()] {
+			continue
+		}
+		for _, task := range pr.GetPackageRevision().Spec.Tasks {
+			if task.Type != api.TaskTypeClone || task.Clone == nil {
+				continue
+			}
+			if ref := task.Clone.Upstream.UpstreamRef; ref != nil && names[ref.Name] {
+				downstream = append(downstream, pr.KubeObjectName())
+				break
+			}
+		}
+	}
+	sort.Strings(downstream)
+	return downstream
+}
+
 func (cad *cadEngine) UpdatePackageResources(ctx context.Context, repositoryObj *configapi.Repository, oldPackage repository.PackageRevision, old, new *api.PackageRevisionResources) (repository.PackageRevision, error) {
 	ctx, span := tracer.Start(ctx, "cadEngine::UpdatePackageResources", trace.WithAttributes())
 	defer span.End()
 
-	rev := oldPackage.GetPackageRevision()
+	release, err := cad.repositoryLimiter.acquire(ctx, repositoryObj)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	// Validate package lifecycle. Can only update a draft.
-	switch lifecycle := rev.Spec.Lifecycle; lifecycle {
-	default:
-		return nil, fmt.Errorf("invalid original lifecycle value: %q", lifecycle)
-	case api.PackageRevisionLifecycleDraft:
-		// Only draf can be updated.
-	case api.PackageRevisionLifecycleProposed, api.PackageRevisionLifecyclePublished:
-		// TODO: generate errors that can be translated to correct HTTP responses
-		return nil, fmt.Errorf("cannot update a package revision with lifecycle value %q; package must be Draft", lifecycle)
+	ctx = withCommitMessage(ctx, new.Annotations)
+
+	d, err := cad.openResourcesDraft(ctx, repositoryObj, oldPackage, old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	// No lifecycle change when updating package resources; updates are done.
+	return cad.closeDraft(ctx, d.draft, d.render)
+}
+
+// resourcesDraft is a draft with updated resources which is yet to be closed.
+type resourcesDraft struct {
+	repo   repository.Repository
+	draft  repository.PackageDraft
+	render *renderPackageMutation
+	// original are the resources of the package revision before the update.
+	original repository.PackageResources
+}
+
+// openResourcesDraft opens a draft of the package revision and replaces its resources.
+func (cad *cadEngine) openResourcesDraft(ctx context.Context, repositoryObj *configapi.Repository, oldPackage repository.PackageRevision, old, new *api.PackageRevisionResources) (*resourcesDraft, error) {
+	if err := checkResourcesUpdatable(oldPackage); err != nil {
+		return nil, err
 	}
 
 	repo, err := cad.cache.OpenRepository(ctx, repositoryObj)
@@ -353,15 +701,9 @@ func (cad *cadEngine) UpdatePackageResources(ctx context.Context, repositoryObj
 		return nil, err
 	}
 
-	mutations := []mutation{
-		&mutationReplaceResources{
-			newResources: new,
-			oldResources: old,
-		},
-		&renderPackageMutation{
-			renderer: cad.renderer,
-			runtime:  cad.runtime,
-		},
+	mutations, render, err := cad.resourcesMutations(ctx, repositoryObj.Namespace, oldPackage.Key(), old, new)
+	if err != nil {
+		return nil, err
 	}
 
 	apiResources, err := oldPackage.GetResources(ctx)
@@ -376,30 +718,140 @@ func (cad *cadEngine) UpdatePackageResources(ctx context.Context, repositoryObj
 		return nil, err
 	}
 
-	// No lifecycle change when updating package resources; updates are done.
-	return draft.Close(ctx)
+	return &resourcesDraft{
+		repo:     repo,
+		draft:    draft,
+		render:   render,
+		original: resources,
+	}, nil
+}
+
+// checkResourcesUpdatable checks that the resources of the package revision can be updated.
+func checkResourcesUpdatable(oldPackage repository.PackageRevision) error {
+	rev := oldPackage.GetPackageRevision()
+
+	// Validate package lifecycle. Can only update a draft.
+	switch lifecycle := rev.Spec.Lifecycle; lifecycle {
+	default:
+		return fmt.Errorf("invalid original lifecycle value: %q", lifecycle)
+	case api.PackageRevisionLifecycleDraft:
+		// Only draf can be updated.
+	case api.PackageRevisionLifecycleProposed, api.PackageRevisionLifecyclePublished:
+		// TODO: generate errors that can be translated to correct HTTP responses
+		return fmt.Errorf("cannot update a package revision with lifecycle value %q; package must be Draft", lifecycle)
+	}
+	return nil
+}
+
+// renderMutation returns the mutation which renders the package revision, which belongs to a
+// repository in the namespace.
+func (cad *cadEngine) renderMutation(ctx context.Context, namespace string, key repository.PackageRevisionKey, changed *changedFiles) (*renderPackageMutation, error) {
+	setterValues, err := cad.setterValues(ctx, namespace, key)
+	if err != nil {
+		return nil, err
+	}
+	return &renderPackageMutation{
+		renderer:        cad.renderer,
+		runtime:         cad.runtime,
+		changed:         changed,
+		guardrails:      cad.outputGuardrails,
+		allowDuplicates: cad.allowDuplicateResources,
+		setterValues:    setterValues,
+	}, nil
+}
+
+// resourcesMutations returns the mutations which replace the resources of a package revision and
+// render it, and the render mutation among them.
+func (cad *cadEngine) resourcesMutations(ctx context.Context, namespace string, key repository.PackageRevisionKey, old, new *api.PackageRevisionResources) ([]mutation, *renderPackageMutation, error) {
+	changed := &changedFiles{}
+	render, err := cad.renderMutation(ctx, namespace, key, changed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []mutation{
+		&mutationReplaceResources{
+			newResources:    new,
+			oldResources:    old,
+			ignorePatterns:  cad.patchIgnorePatterns,
+			changed:         changed,
+			documentPatches: cad.documentPatches,
+		},
+		render,
+	}, render, nil
+}
+
+// withCommitMessage returns a context which carries the commit message requested by the
+// annotations of the request object, if any, to the repository.
+func withCommitMessage(ctx context.Context, annotations map[string]string) context.Context {
+	message, ok := annotations[api.CommitMessageAnnotation]
+	if !ok || message == "" {
+		return ctx
+	}
+	info := repository.CommitInfoFromContext(ctx)
+	info.Message = message
+	return repository.WithCommitInfo(ctx, info)
+}
+
+// closeDraft closes the draft and records the function results of its render, if any.
+func (cad *cadEngine) closeDraft(ctx context.Context, draft repository.PackageDraft, render *renderPackageMutation) (repository.PackageRevision, error) {
+	pr, err := draft.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cad.recordResults(pr, render)
+	cad.recordSummary(ctx, pr)
+	return pr, nil
+}
+
+// dedupeMutations collapses runs of adjacent renders into the last render of the run. Other
+// mutations are kept even if identical, since evaluations and clones are not idempotent and each
+// of them records a task which must stay aligned with the tasks of the package revision.
+func dedupeMutations(mutations []mutation) []mutation {
+	var result []mutation
+	for i, m := range mutations {
+		if i+1 < len(mutations) && isRender(m) && isRender(mutations[i+1]) {
+			klog.Infof("skipping render followed by another render")
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+func isRender(m mutation) bool {
+	_, ok := m.(*renderPackageMutation)
+	return ok
 }
 
 func applyResourceMutations(ctx context.Context, draft repository.PackageDraft, baseResources repository.PackageResources, mutations []mutation) error {
-	for _, m := range mutations {
-		applied, task, err := m.Apply(ctx, baseResources)
+	for _, m := range dedupeMutations(mutations) {
+		applied, _, err := applyResourceMutation(ctx, draft, baseResources, m)
 		if err != nil {
 			return err
 		}
-		if err := draft.UpdateResources(ctx, &api.PackageRevisionResources{
-			Spec: api.PackageRevisionResourcesSpec{
-				Resources: applied.Contents,
-			},
-		}, task); err != nil {
-			return err
-		}
 		baseResources = applied
 	}
 
 	return nil
 }
 
-func (cad *cadEngine) ListFunctions(ctx context.Context, repositoryObj *configapi.Repository) ([]repository.Function, error) {
+// applyResourceMutation applies the mutation to the resources and updates the draft with the result.
+func applyResourceMutation(ctx context.Context, draft repository.PackageDraft, baseResources repository.PackageResources, m mutation) (repository.PackageResources, *api.Task, error) {
+	applied, task, err := m.Apply(ctx, baseResources)
+	if err != nil {
+		return repository.PackageResources{}, nil, err
+	}
+	if err := draft.UpdateResources(ctx, &api.PackageRevisionResources{
+		Spec: api.PackageRevisionResourcesSpec{
+			Resources: applied.Contents,
+		},
+	}, task); err != nil {
+		return repository.PackageResources{}, nil, err
+	}
+	return applied, task, nil
+}
+
+func (cad *cadEngine) ListFunctions(ctx context.Context, repositoryObj *configapi.Repository, filter repository.ListFunctionFilter) ([]repository.Function, error) {
 	ctx, span := tracer.Start(ctx, "cadEngine::ListFunctions", trace.WithAttributes())
 	defer span.End()
 
@@ -413,11 +865,27 @@ func (cad *cadEngine) ListFunctions(ctx context.Context, repositoryObj *configap
 		return nil, err
 	}
 
-	return fns, nil
+	result := make([]repository.Function, 0, len(fns))
+	for _, fn := range fns {
+		if filter.Matches(fn) {
+			result = append(result, fn)
+		}
+	}
+	return result, nil
 }
 
 type updatePackageMutation struct {
 	task *api.Task
+	// originCommit is the upstream commit the package was previously cloned from; it is used as the merge base.
+	originCommit string
+	// If set, files of the updated package which cannot be loaded are skipped with a warning.
+	lenientLoad bool
+	// symlinks selects how symbolic links of the updated package are loaded.
+	symlinks SymlinkPolicy
+	// credentialResolver resolves the credentials of the upstream repository.
+	credentialResolver repository.CredentialResolver
+	// If set, the updated upstream commit must be signed by one of these armored PGP keys.
+	trustedUpstreamKeys string
 }
 
 func (m *updatePackageMutation) Apply(ctx context.Context, resources repository.PackageResources) (repository.PackageResources, *api.Task, error) {
@@ -431,76 +899,259 @@ func (m *updatePackageMutation) Apply(ctx context.Context, resources repository.
 	}
 	defer os.RemoveAll(dir)
 
-	if err := writeResourcesToDirectory(dir, resources); err != nil {
+	task := m.task.DeepCopy()
+	gitPackage := task.Clone.Upstream.Git
+	if gitPackage == nil {
+		return repository.PackageResources{}, nil, fmt.Errorf("updating packages is only supported for Git upstreams")
+	}
+	// The signer is recorded only once the updated commit is verified.
+	gitPackage.VerifiedSigner = ""
+
+	if err := writeResourcesToDirectory(filesys.MakeFsOnDisk(), dir, resources); err != nil {
 		return repository.PackageResources{}, nil, err
 	}
 
-	ref := m.task.Clone.Upstream.Git.Ref
+	// As when cloning, an unspecified ref refers to the head of the repository branch.
+	ref := gitPackage.Ref
+	if ref == "" {
+		ref = gitPackage.Branch
+	}
+	if ref == "" {
+		ref = string(git.MainBranch)
+	}
 
 	// TODO: This is a hack
-	packageName := filepath.Base(m.task.Clone.Upstream.Git.Directory)
+	packageName := filepath.Base(gitPackage.Directory)
 	packageName = strings.TrimPrefix(packageName, ".git")
 
 	packageDir := filepath.Join(dir, packageName)
-	if err := kpt.PkgUpdate(ctx, ref, packageDir, kpt.PkgUpdateOpts{}); err != nil {
+	if err := kpt.PkgUpdate(ctx, ref, packageDir, kpt.PkgUpdateOpts{
+		OriginCommit: m.originCommit,
+	}); err != nil {
 		return repository.PackageResources{}, nil, err
 	}
 
-	loaded, err := loadResourcesFromDirectory(dir)
+	commit, err := kpt.ReadUpstreamCommit(packageDir)
+	if err != nil {
+		return repository.PackageResources{}, nil, err
+	}
+	if m.trustedUpstreamKeys != "" {
+		signer, err := verifyGitUpstreamCommit(ctx, gitPackage, commit, m.trustedUpstreamKeys, m.credentialResolver)
+		if err != nil {
+			return repository.PackageResources{}, nil, fmt.Errorf("cannot update package %s@%s: %w", gitPackage.Directory, ref, err)
+		}
+		gitPackage.VerifiedSigner = signer
+	}
+	gitPackage.Commit = commit
+
+	loaded, fileErrs, err := loadResources(filesys.MakeFsOnDisk(), dir, m.lenientLoad, m.symlinks)
+	for _, fileErr := range fileErrs {
+		klog.Warningf("skipping file of updated package %q: %v", packageName, fileErr)
+	}
 	if err != nil {
 		return repository.PackageResources{}, nil, err
 	}
 
-	return loaded, m.task, nil
+	return loaded, task, nil
 }
 
-func writeResourcesToDirectory(dir string, resources repository.PackageResources) error {
+// gzipExtension is the extension of gzip-compressed package files. They are loaded decompressed,
+// under the name without the extension.
+const gzipExtension = ".gz"
+
+// writeResourcesToDirectory writes the resources into the directory. The gzipped resources are
+// written gzip-compressed, with the gzipExtension appended to their name.
+func writeResourcesToDirectory(fsys filesys.FileSystem, dir string, resources repository.PackageResources, gzipped ...string) error {
+	compress := map[string]bool{}
+	for _, k := range gzipped {
+		compress[normalizeResourcePath(k)] = true
+	}
 	for k, v := range resources.Contents {
-		p := filepath.Join(dir, k)
+		k = normalizeResourcePath(k)
+		contents := []byte(v)
+		if compress[k] {
+			var buf bytes.Buffer
+			w := gzip.NewWriter(&buf)
+			if _, err := w.Write(contents); err != nil {
+				return fmt.Errorf("failed to compress file %q: %w", k, err)
+			}
+			if err := w.Close(); err != nil {
+				return fmt.Errorf("failed to compress file %q: %w", k, err)
+			}
+			contents = buf.Bytes()
+			k += gzipExtension
+		}
+		p := filepath.Join(dir, filepath.FromSlash(k))
 		dir := filepath.Dir(p)
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		if err := fsys.MkdirAll(dir); err != nil {
 			return fmt.Errorf("failed to create directory %q: %w", dir, err)
 		}
-		if err := os.WriteFile(p, []byte(v), 0644); err != nil {
+		if err := fsys.WriteFile(p, contents); err != nil {
 			return fmt.Errorf("failed to write file %q: %w", dir, err)
 		}
 	}
 	return nil
 }
 
-func loadResourcesFromDirectory(dir string) (repository.PackageResources, error) {
+// loadResourcesFromDirectory loads the files of the directory. It fails if any file cannot be loaded.
+func loadResourcesFromDirectory(fsys filesys.FileSystem, dir string) (repository.PackageResources, error) {
+	result, _, err := loadResources(fsys, dir, false, SymlinksSkip)
+	return result, err
+}
+
+// loadResourcesFromDirectoryLenient loads the files of the directory which can be loaded. Instead of
+// failing, it returns the errors of the files which cannot be loaded.
+func loadResourcesFromDirectoryLenient(fsys filesys.FileSystem, dir string) (repository.PackageResources, []error, error) {
+	return loadResources(fsys, dir, true, SymlinksSkip)
+}
+
+// SymlinkPolicy selects how symbolic links are handled when loading package directories.
+type SymlinkPolicy string
+
+const (
+	// SymlinksSkip skips symbolic links with a warning.
+	SymlinksSkip SymlinkPolicy = ""
+	// SymlinksFollow loads the files and directories which symbolic links point at, under the path
+	// of the link. Links which point outside of the package directory, dangling links and loops
+	// of links cannot be loaded.
+	SymlinksFollow SymlinkPolicy = "follow"
+)
+
+func loadResources(fsys filesys.FileSystem, dir string, lenient bool, symlinks SymlinkPolicy) (repository.PackageResources, []error, error) {
 	// TODO: return abstraction instead of loading everything
 	result := repository.PackageResources{
 		Contents: map[string]string{},
 	}
-	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
+	var fileErrs []error
+	fail := func(err error) error {
+		if lenient {
+			fileErrs = append(fileErrs, err)
 			return nil
 		}
-		rel, err := filepath.Rel(dir, path)
+		return err
+	}
+
+	// Resolved package directory, and resolved directories being walked through followed
+	// symbolic links; these are only computed if the package has symbolic links.
+	var root string
+	walking := map[string]bool{}
+
+	var walk func(walkDir, prefix string) error
+	walk = func(walkDir, prefix string) error {
+		return fsys.Walk(walkDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if lenient && path != walkDir {
+					fileErrs = append(fileErrs, fmt.Errorf("cannot read %q: %w", path, err))
+					if info != nil && info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(walkDir, path)
+			if err != nil {
+				return fmt.Errorf("cannot compute relative path %q, %q, %w", walkDir, path, err)
+			}
+			rel = filepath.Join(prefix, rel)
+
+			if info.Mode()&os.ModeSymlink != 0 {
+				if symlinks != SymlinksFollow {
+					klog.Warningf("skipping symbolic link %q of package directory %q", rel, dir)
+					return nil
+				}
+				if root == "" {
+					if root, err = filepath.EvalSymlinks(dir); err != nil {
+						return fmt.Errorf("cannot resolve package directory %q: %w", dir, err)
+					}
+					walking[root] = true
+				}
+				target, err := resolveSymlink(root, path)
+				if err != nil {
+					return fail(err)
+				}
+				targetInfo, err := os.Stat(target)
+				if err != nil {
+					return fail(fmt.Errorf("cannot read symbolic link %q: %w", rel, err))
+				}
+				if targetInfo.IsDir() {
+					if walking[target] {
+						return fail(fmt.Errorf("symbolic link %q forms a loop", rel))
+					}
+					walking[target] = true
+					defer delete(walking, target)
+					return walk(target, rel)
+				}
+				path = target
+			}
+
+			name, contents, err := loadResource(fsys, path, filepath.ToSlash(rel))
+			if err == nil {
+				if _, exists := result.Contents[name]; exists {
+					err = fmt.Errorf("file %q is present both compressed and uncompressed", name)
+				}
+			}
+			if err != nil {
+				return fail(err)
+			}
+			result.Contents[name] = contents
+			return nil
+		})
+	}
+	if err := walk(dir, ""); err != nil {
+		return repository.PackageResources{}, nil, err
+	}
+
+	return result, fileErrs, nil
+}
+
+// resolveSymlink returns the path which the symbolic link resolves to. It fails if the link is
+// dangling, or resolves outside of the root directory.
+func resolveSymlink(root, link string) (string, error) {
+	target, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve symbolic link %q: %w", link, err)
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("symbolic link %q points outside of the package directory", link)
+	}
+	return target, nil
+}
+
+// loadResource reads the file at path, decompressing gzipped files. It returns the resource name
+// of the file, which is name stripped of the gzipExtension, and its contents.
+func loadResource(fsys filesys.FileSystem, path, name string) (string, string, error) {
+	contents, err := fsys.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read file %q: %w", path, err)
+	}
+	if strings.HasSuffix(name, gzipExtension) {
+		r, err := gzip.NewReader(bytes.NewReader(contents))
 		if err != nil {
-			return fmt.Errorf("cannot compute relative path %q, %q, %w", dir, path, err)
+			return "", "", fmt.Errorf("cannot decompress file %q: %w", path, err)
 		}
-
-		contents, err := ioutil.ReadFile(path)
+		contents, err = ioutil.ReadAll(r)
 		if err != nil {
-			return fmt.Errorf("cannot read file %q: %w", dir, err)
+			return "", "", fmt.Errorf("cannot decompress file %q: %w", path, err)
 		}
-		result.Contents[rel] = string(contents)
-		return nil
-	}); err != nil {
-		return repository.PackageResources{}, err
+		name = strings.TrimSuffix(name, gzipExtension)
 	}
-
-	return result, nil
+	return name, string(contents), nil
 }
 
 type mutationReplaceResources struct {
 	newResources *api.PackageRevisionResources
 	oldResources *api.PackageRevisionResources
+	// Files matching any of the glob patterns are updated, but not recorded in the patch.
+	ignorePatterns []string
+	// If set, records the files which were created, modified or deleted.
+	changed *changedFiles
+	// If set, changes to multi-document YAML files are recorded as one patch per changed document.
+	documentPatches bool
 }
 
 func (m *mutationReplaceResources) Apply(ctx context.Context, resources repository.PackageResources) (repository.PackageResources, *api.Task, error) {
@@ -509,13 +1160,42 @@ func (m *mutationReplaceResources) Apply(ctx context.Context, resources reposito
 
 	patch := &api.PackagePatchTaskSpec{}
 
-	old := resources.Contents
-	new, err := healConfig(old, m.newResources.Spec.Resources)
+	old, err := normalizeResourcePaths(resources.Contents)
+	if err != nil {
+		return repository.PackageResources{}, nil, err
+	}
+	updated, err := normalizeResourcePaths(m.newResources.Spec.Resources)
+	if err != nil {
+		return repository.PackageResources{}, nil, err
+	}
+	// Files outside of the subpackage, if any, are neither replaced nor diffed.
+	var preserved map[string]string
+	if dir := m.newResources.Spec.Subpackage; dir != "" {
+		dir, err = normalizeSubpackagePath(dir)
+		if err != nil {
+			return repository.PackageResources{}, nil, err
+		}
+		for k := range updated {
+			if !strings.HasPrefix(k, dir+"/") {
+				return repository.PackageResources{}, nil, fmt.Errorf("resource %q is outside of subpackage %q", k, dir)
+			}
+		}
+		old, preserved = splitSubpackage(old, dir)
+	}
+	new, err := healConfig(old, updated)
 	if err != nil {
 		return repository.PackageResources{}, nil, fmt.Errorf("failed to heal resources: %w", err)
 	}
 
 	for k, newV := range new {
+		if oldV, ok := old[k]; !ok || oldV != newV {
+			m.changed.add(k)
+		}
+		if ignore, err := m.isIgnored(k); err != nil {
+			return repository.PackageResources{}, nil, err
+		} else if ignore {
+			continue
+		}
 		oldV, ok := old[k]
 		// New config or changed config
 		if !ok {
@@ -525,6 +1205,13 @@ func (m *mutationReplaceResources) Apply(ctx context.Context, resources reposito
 				Contents:  newV,
 			}
 			patch.Patches = append(patch.Patches, patchSpec)
+		} else if newV != oldV && m.documentPatches {
+			patchSpecs, err := generateDocumentPatches(k, oldV, newV)
+			if err != nil {
+				return repository.PackageResources{}, nil, fmt.Errorf("error generating patch: %w", err)
+			}
+
+			patch.Patches = append(patch.Patches, patchSpecs...)
 		} else if newV != oldV {
 			patchSpec, err := GeneratePatch(k, oldV, newV)
 			if err != nil {
@@ -535,6 +1222,14 @@ func (m *mutationReplaceResources) Apply(ctx context.Context, resources reposito
 		}
 	}
 	for k := range old {
+		if _, ok := new[k]; !ok {
+			m.changed.add(k)
+		}
+		if ignore, err := m.isIgnored(k); err != nil {
+			return repository.PackageResources{}, nil, err
+		} else if ignore {
+			continue
+		}
 		// Deleted config
 		if _, ok := new[k]; !ok {
 			patchSpec := api.PatchSpec{
@@ -544,14 +1239,64 @@ func (m *mutationReplaceResources) Apply(ctx context.Context, resources reposito
 			patch.Patches = append(patch.Patches, patchSpec)
 		}
 	}
+	// Map iteration order is random; sort the patches so that the recorded task is reproducible.
+	sort.SliceStable(patch.Patches, func(i, j int) bool {
+		return patch.Patches[i].File < patch.Patches[j].File
+	})
 	task := &api.Task{
 		Type:  api.TaskTypePatch,
 		Patch: patch,
 	}
 
+	for k, v := range preserved {
+		new[k] = v
+	}
 	return repository.PackageResources{Contents: new}, task, nil
 }
 
+// changedFiles is the set of files changed by a mutation, passed as a hint to subsequent mutations.
+// A nil *changedFiles ignores additions and reports that any file may have changed.
+type changedFiles struct {
+	files map[string]bool
+}
+
+func (c *changedFiles) add(file string) {
+	if c == nil {
+		return
+	}
+	if c.files == nil {
+		c.files = map[string]bool{}
+	}
+	c.files[file] = true
+}
+
+// list returns the sorted changed files, or nil if changes were not tracked.
+func (c *changedFiles) list() []string {
+	if c == nil {
+		return nil
+	}
+	result := make([]string, 0, len(c.files))
+	for file := range c.files {
+		result = append(result, file)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// isIgnored returns true if the file should be excluded from the recorded patch.
+func (m *mutationReplaceResources) isIgnored(file string) (bool, error) {
+	for _, pattern := range m.ignorePatterns {
+		matched, err := path.Match(pattern, file)
+		if err != nil {
+			return false, fmt.Errorf("invalid patch ignore pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func healConfig(old, new map[string]string) (map[string]string, error) {
 	// Copy comments from old config to new
 	oldResources, err := (&packageReader{
@@ -0,0 +1,677 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/cache"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/git"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/kpt"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestWriteAndLoadResourcesInMemory(t *testing.T) {
+	want := repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":            "kind: Kptfile\n",
+			"configmap.yaml":     "kind: ConfigMap\n",
+			"sub/nested/a.yaml":  "kind: Namespace\n",
+			"sub/nested/b.txt":   "hello",
+			"sub/Kptfile":        "kind: Kptfile\n",
+			"sub/service.yaml":   "kind: Service\n",
+			"sub/deployment.yml": "kind: Deployment\n",
+		},
+	}
+
+	fsys := filesys.MakeFsInMemory()
+	if err := writeResourcesToDirectory(fsys, "/work/pkg", want); err != nil {
+		t.Fatalf("writeResourcesToDirectory failed: %v", err)
+	}
+
+	got, err := loadResourcesFromDirectory(fsys, "/work/pkg")
+	if err != nil {
+		t.Fatalf("loadResourcesFromDirectory failed: %v", err)
+	}
+
+	if !cmp.Equal(want, got) {
+		t.Errorf("Loaded resources differ (-want,+got): %s", cmp.Diff(want, got))
+	}
+}
+
+func TestLoadResourcesLenient(t *testing.T) {
+	fsys := filesys.MakeFsInMemory()
+	if err := writeResourcesToDirectory(fsys, "/work/pkg", repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":        "kind: Kptfile\n",
+			"configmap.yaml": "kind: ConfigMap\n",
+		},
+	}); err != nil {
+		t.Fatalf("writeResourcesToDirectory failed: %v", err)
+	}
+	if err := fsys.WriteFile("/work/pkg/broken.yaml.gz", []byte("not gzipped")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := loadResourcesFromDirectory(fsys, "/work/pkg"); err == nil {
+		t.Errorf("loadResourcesFromDirectory of a package with a broken file succeeded unexpectedly")
+	}
+
+	got, fileErrs, err := loadResourcesFromDirectoryLenient(fsys, "/work/pkg")
+	if err != nil {
+		t.Fatalf("loadResourcesFromDirectoryLenient failed: %v", err)
+	}
+	want := repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":        "kind: Kptfile\n",
+			"configmap.yaml": "kind: ConfigMap\n",
+		},
+	}
+	if !cmp.Equal(want, got) {
+		t.Errorf("Loaded resources differ (-want,+got): %s", cmp.Diff(want, got))
+	}
+	if got, want := len(fileErrs), 1; got != want {
+		t.Fatalf("loadResourcesFromDirectoryLenient returned %d file errors; want %d: %v", got, want, fileErrs)
+	}
+	if !strings.Contains(fileErrs[0].Error(), "broken.yaml.gz") {
+		t.Errorf("File error %q does not name the broken file", fileErrs[0])
+	}
+}
+
+func TestLoadResourcesSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	fsys := filesys.MakeFsOnDisk()
+	if err := writeResourcesToDirectory(fsys, dir, repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":             "kind: Kptfile\n",
+			"base/configmap.yaml": "kind: ConfigMap\n",
+		},
+	}); err != nil {
+		t.Fatalf("writeResourcesToDirectory failed: %v", err)
+	}
+	outside := filepath.Join(t.TempDir(), "secret.yaml")
+	if err := os.WriteFile(outside, []byte("kind: Secret\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	symlink := func(target, link string) {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatalf("Symlink failed: %v", err)
+		}
+	}
+	symlink("base/configmap.yaml", "configmap.yaml")
+	symlink("base", "linked")
+
+	// Links are skipped by default.
+	got, err := loadResourcesFromDirectory(fsys, dir)
+	if err != nil {
+		t.Fatalf("loadResourcesFromDirectory failed: %v", err)
+	}
+	want := map[string]string{
+		"Kptfile":             "kind: Kptfile\n",
+		"base/configmap.yaml": "kind: ConfigMap\n",
+	}
+	if !cmp.Equal(want, got.Contents) {
+		t.Errorf("Loaded resources differ (-want,+got): %s", cmp.Diff(want, got.Contents))
+	}
+
+	got, _, err = loadResources(fsys, dir, false, SymlinksFollow)
+	if err != nil {
+		t.Fatalf("loadResources following symbolic links failed: %v", err)
+	}
+	want["configmap.yaml"] = "kind: ConfigMap\n"
+	want["linked/configmap.yaml"] = "kind: ConfigMap\n"
+	if !cmp.Equal(want, got.Contents) {
+		t.Errorf("Loaded resources differ (-want,+got): %s", cmp.Diff(want, got.Contents))
+	}
+
+	symlink("missing.yaml", "dangling.yaml")
+	symlink(outside, "outside.yaml")
+	symlink("..", "base/parent")
+
+	if _, err := loadResourcesFromDirectory(fsys, dir); err != nil {
+		t.Errorf("loadResourcesFromDirectory skipping broken symbolic links failed: %v", err)
+	}
+	if _, _, err := loadResources(fsys, dir, false, SymlinksFollow); err == nil {
+		t.Errorf("loadResources following broken symbolic links succeeded unexpectedly")
+	}
+	got, fileErrs, err := loadResources(fsys, dir, true, SymlinksFollow)
+	if err != nil {
+		t.Fatalf("loadResources following symbolic links leniently failed: %v", err)
+	}
+	if !cmp.Equal(want, got.Contents) {
+		t.Errorf("Loaded resources differ (-want,+got): %s", cmp.Diff(want, got.Contents))
+	}
+	var errs []string
+	for _, err := range fileErrs {
+		errs = append(errs, err.Error())
+	}
+	for _, want := range []string{"dangling.yaml", "outside of the package directory", "forms a loop"} {
+		if !strings.Contains(strings.Join(errs, "\n"), want) {
+			t.Errorf("File errors do not contain %q: %v", want, errs)
+		}
+	}
+}
+
+func TestDeletePackage(t *testing.T) {
+	ctx := context.Background()
+	tarfile := filepath.Join("..", "git", "testdata", "nested-repository.tar")
+	_, address := git.ServeGitRepository(t, tarfile, t.TempDir())
+
+	repositoryObj := &configapi.Repository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "delete-test",
+			Namespace: "default",
+		},
+		Spec: configapi.RepositorySpec{
+			Type:    configapi.RepositoryTypeGit,
+			Content: configapi.RepositoryContentPackage,
+			Git: &configapi.GitRepository{
+				Repo: address,
+			},
+		},
+	}
+	cad := &cadEngine{cache: cache.NewCache(t.TempDir(), cache.CacheOptions{})}
+	if err := WithResultHistory(1).apply(cad); err != nil {
+		t.Fatalf("WithResultHistory failed: %v", err)
+	}
+
+	repo, err := cad.OpenRepository(ctx, repositoryObj)
+	if err != nil {
+		t.Fatalf("OpenRepository failed: %v", err)
+	}
+	revisions, err := repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: "catalog/gcp/bucket"})
+	if err != nil {
+		t.Fatalf("ListPackageRevisions failed: %v", err)
+	}
+	for _, pr := range revisions {
+		if _, err := cad.PackageSummary(ctx, pr); err != nil {
+			t.Fatalf("PackageSummary failed: %v", err)
+		}
+		cad.recordResults(pr, &renderPackageMutation{results: &fnresult.ResultList{}})
+	}
+
+	if err := cad.DeletePackage(ctx, repositoryObj, "catalog/gcp/bucket", false); err != nil {
+		t.Fatalf("DeletePackage failed: %v", err)
+	}
+
+	for _, pr := range revisions {
+		if _, ok := cad.summaries.entries[pr.KubeObjectName()]; ok {
+			t.Errorf("Summary of deleted package revision %q was not removed", pr.KubeObjectName())
+		}
+		if got := cad.ResultHistory(pr); len(got) != 0 {
+			t.Errorf("Result history of deleted package revision %q was not removed", pr.KubeObjectName())
+		}
+	}
+	revisions, err = repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: "catalog/gcp/bucket"})
+	if err != nil {
+		t.Fatalf("ListPackageRevisions failed: %v", err)
+	}
+	if len(revisions) != 0 {
+		t.Errorf("ListPackageRevisions returned %d revisions of a deleted package; want 0", len(revisions))
+	}
+
+	if err := cad.DeletePackage(ctx, repositoryObj, "catalog/gcp/bucket", false); err == nil {
+		t.Errorf("DeletePackage of a missing package succeeded unexpectedly")
+	}
+}
+
+func TestDeletePackageClonedInOtherRepository(t *testing.T) {
+	ctx := context.Background()
+	newRepositoryObj := func(name, tarfile string) *configapi.Repository {
+		_, address := git.ServeGitRepository(t, filepath.Join("..", "git", "testdata", tarfile), t.TempDir())
+		return &configapi.Repository{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: configapi.RepositorySpec{
+				Type:    configapi.RepositoryTypeGit,
+				Content: configapi.RepositoryContentPackage,
+				Git: &configapi.GitRepository{
+					Repo: address,
+				},
+			},
+		}
+	}
+	blueprintsObj := newRepositoryObj("blueprints", "nested-repository.tar")
+	deploymentsObj := newRepositoryObj("deployments", "drafts-repository.tar")
+	cad := &cadEngine{cache: cache.NewCache(t.TempDir(), cache.CacheOptions{})}
+
+	blueprints, err := cad.OpenRepository(ctx, blueprintsObj)
+	if err != nil {
+		t.Fatalf("OpenRepository failed: %v", err)
+	}
+	upstream, err := blueprints.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: "catalog/gcp/bucket"})
+	if err != nil || len(upstream) == 0 {
+		t.Fatalf("ListPackageRevisions returned %d revisions, error %v; want revisions of the package", len(upstream), err)
+	}
+
+	// Clone the package into the other repository.
+	deployments, err := cad.OpenRepository(ctx, deploymentsObj)
+	if err != nil {
+		t.Fatalf("OpenRepository failed: %v", err)
+	}
+	draft, err := deployments.CreatePackageRevision(ctx, &api.PackageRevision{
+		Spec: api.PackageRevisionSpec{
+			PackageName: "my-bucket",
+			Revision:    "v1",
+			Lifecycle:   api.PackageRevisionLifecycleDraft,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreatePackageRevision failed: %v", err)
+	}
+	task := &api.Task{
+		Type: api.TaskTypeClone,
+		Clone: &api.PackageCloneTaskSpec{
+			Upstream: api.UpstreamPackage{
+				UpstreamRef: &api.PackageRevisionRef{Name: upstream[0].KubeObjectName()},
+			},
+		},
+	}
+	resources := &api.PackageRevisionResources{
+		Spec: api.PackageRevisionResourcesSpec{
+			Resources: map[string]string{"Kptfile": "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: my-bucket\n"},
+		},
+	}
+	if err := draft.UpdateResources(ctx, resources, task); err != nil {
+		t.Fatalf("UpdateResources failed: %v", err)
+	}
+	if _, err := draft.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := cad.DeletePackage(ctx, blueprintsObj, "catalog/gcp/bucket", false); err == nil || !strings.Contains(err.Error(), "deployments-") {
+		t.Fatalf("DeletePackage of a package cloned in another repository: got error %v, want one naming the clone", err)
+	}
+	if err := cad.DeletePackage(ctx, blueprintsObj, "catalog/gcp/bucket", true); err != nil {
+		t.Fatalf("Forced DeletePackage failed: %v", err)
+	}
+}
+
+func TestFindDownstreamClones(t *testing.T) {
+	upstream := &fake.PackageRevision{Name: "repo-upstream-v1", PackageRevision: &api.PackageRevision{}}
+	cloned := &fake.PackageRevision{
+		Name: "repo-downstream-v1",
+		PackageRevision: &api.PackageRevision{
+			Spec: api.PackageRevisionSpec{
+				Tasks: []api.Task{
+					{
+						Type: api.TaskTypeClone,
+						Clone: &api.PackageCloneTaskSpec{
+							Upstream: api.UpstreamPackage{
+								UpstreamRef: &api.PackageRevisionRef{Name: "repo-upstream-v1"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	unrelated := &fake.PackageRevision{Name: "repo-unrelated-v1", PackageRevision: &api.PackageRevision{}}
+
+	all := []repository.PackageRevision{upstream, cloned, unrelated}
+	if got, want := findDownstreamClones(all, []repository.PackageRevision{upstream}), []string{"repo-downstream-v1"}; !cmp.Equal(want, got) {
+		t.Errorf("findDownstreamClones differs (-want,+got): %s", cmp.Diff(want, got))
+	}
+	if got := findDownstreamClones(all, []repository.PackageRevision{upstream, cloned}); len(got) != 0 {
+		t.Errorf("findDownstreamClones returned %v for revisions deleted together; want none", got)
+	}
+}
+
+func TestLoadAndWriteGzipResources(t *testing.T) {
+	configmap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(configmap)); err != nil {
+		t.Fatalf("gzip write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip close failed: %v", err)
+	}
+
+	fsys := filesys.MakeFsInMemory()
+	if err := fsys.MkdirAll("/work/pkg"); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := fsys.WriteFile("/work/pkg/configmap.yaml.gz", buf.Bytes()); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	got, err := loadResourcesFromDirectory(fsys, "/work/pkg")
+	if err != nil {
+		t.Fatalf("loadResourcesFromDirectory failed: %v", err)
+	}
+	want := map[string]string{"configmap.yaml": configmap}
+	if !cmp.Equal(want, got.Contents) {
+		t.Errorf("Loaded resources differ (-want,+got): %s", cmp.Diff(want, got.Contents))
+	}
+
+	// Write back compressed and load again.
+	out := filesys.MakeFsInMemory()
+	if err := writeResourcesToDirectory(out, "/out", got, "configmap.yaml"); err != nil {
+		t.Fatalf("writeResourcesToDirectory failed: %v", err)
+	}
+	if out.Exists("/out/configmap.yaml") || !out.Exists("/out/configmap.yaml.gz") {
+		t.Errorf("configmap.yaml was not written compressed")
+	}
+	reloaded, err := loadResourcesFromDirectory(out, "/out")
+	if err != nil {
+		t.Fatalf("loadResourcesFromDirectory failed: %v", err)
+	}
+	if !cmp.Equal(want, reloaded.Contents) {
+		t.Errorf("Reloaded resources differ (-want,+got): %s", cmp.Diff(want, reloaded.Contents))
+	}
+}
+
+func TestDedupeMutations(t *testing.T) {
+	evalTask := func(image string) *api.Task {
+		return &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: image}}
+	}
+	cloneTask := &api.Task{Type: api.TaskTypeClone, Clone: &api.PackageCloneTaskSpec{
+		Upstream: api.UpstreamPackage{Type: api.RepositoryTypeGit, Git: &api.GitPackage{Repo: "https://example.com/repo.git"}},
+	}}
+
+	clone1 := &clonePackageMutation{task: cloneTask}
+	clone2 := &clonePackageMutation{task: cloneTask.DeepCopy()}
+	eval1 := &evalFunctionMutation{task: evalTask("gcr.io/kpt-fn/set-namespace:v0.4")}
+	eval2 := &evalFunctionMutation{task: evalTask("gcr.io/kpt-fn/set-namespace:v0.4")}
+	eval3 := &evalFunctionMutation{task: evalTask("gcr.io/kpt-fn/set-labels:v0.1")}
+	render1 := &renderPackageMutation{}
+	render2 := &renderPackageMutation{}
+	patch := &mutationReplaceResources{}
+
+	got := dedupeMutations([]mutation{clone1, clone2, eval1, eval2, eval3, render1, render2, patch, patch})
+	want := []mutation{clone1, clone2, eval1, eval2, eval3, render2, patch, patch}
+	if len(got) != len(want) {
+		t.Fatalf("dedupeMutations: got %d mutations, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("dedupeMutations[%d]: got %T %p, want %T %p", i, got[i], got[i], want[i], want[i])
+		}
+	}
+}
+
+func TestDedupeMutationsRecordsAllTasks(t *testing.T) {
+	obj := &api.PackageRevision{
+		Spec: api.PackageRevisionSpec{
+			PackageName: "app",
+			Tasks: []api.Task{
+				{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/echo:v1"}},
+				{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/echo:v1"}},
+			},
+		},
+	}
+	cad := &cadEngine{runtime: &fake.FunctionRuntime{}}
+	mutations, err := cad.taskMutations(context.Background(), obj)
+	if err != nil {
+		t.Fatalf("taskMutations failed: %v", err)
+	}
+	base := repository.PackageResources{Contents: map[string]string{
+		"Kptfile": "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+	}}
+	// Skip the implicit init; the package already exists.
+	draft := &taskRecordingDraft{}
+	if err := applyResourceMutations(context.Background(), draft, base, mutations[1:]); err != nil {
+		t.Fatalf("applyResourceMutations failed: %v", err)
+	}
+
+	// Every task is recorded, so that a later update, which requires the tasks of the package
+	// revision to be unchanged in number, succeeds.
+	if got, want := len(draft.tasks), len(obj.Spec.Tasks); got != want {
+		t.Errorf("Recorded tasks: got %d (%v), want %d", got, draft.tasks, want)
+	}
+}
+
+func TestMaxTasks(t *testing.T) {
+	ctx := context.Background()
+	cad := &cadEngine{}
+	if err := WithMaxTasks(2).apply(cad); err != nil {
+		t.Fatalf("WithMaxTasks failed: %v", err)
+	}
+
+	newObj := func(tasks int) *api.PackageRevision {
+		obj := &api.PackageRevision{
+			Spec: api.PackageRevisionSpec{
+				PackageName: "app",
+				Lifecycle:   api.PackageRevisionLifecycleDraft,
+			},
+		}
+		for i := 0; i < tasks; i++ {
+			obj.Spec.Tasks = append(obj.Spec.Tasks, api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/set-labels:v0.1"}})
+		}
+		return obj
+	}
+	repositoryObj := &configapi.Repository{}
+
+	// The engine has no cache; the limit must be enforced before the repository is opened.
+	if _, err := cad.CreatePackageRevision(ctx, repositoryObj, newObj(3)); err == nil || !strings.Contains(err.Error(), "maximum is 2") {
+		t.Errorf("CreatePackageRevision with too many tasks: got error %v, want one naming the limit", err)
+	}
+	if _, err := cad.UpdatePackageRevision(ctx, repositoryObj, nil, newObj(3), newObj(3)); err == nil || !strings.Contains(err.Error(), "maximum is 2") {
+		t.Errorf("UpdatePackageRevision with too many tasks: got error %v, want one naming the limit", err)
+	}
+	if _, err := cad.ComputeEffectivePipeline(ctx, repositoryObj, newObj(3)); err == nil || !strings.Contains(err.Error(), "maximum is 2") {
+		t.Errorf("ComputeEffectivePipeline with too many tasks: got error %v, want one naming the limit", err)
+	}
+	if err := cad.checkTaskCount(newObj(2)); err != nil {
+		t.Errorf("checkTaskCount of a package revision at the limit failed: %v", err)
+	}
+
+	if err := WithMaxTasks(-1).apply(&cadEngine{}); err == nil {
+		t.Errorf("WithMaxTasks(-1) succeeded unexpectedly")
+	}
+}
+
+func TestExistingDraftPolicy(t *testing.T) {
+	ctx := context.Background()
+	tarfile := filepath.Join("..", "git", "testdata", "drafts-repository.tar")
+	_, address := git.ServeGitRepository(t, tarfile, t.TempDir())
+
+	repositoryObj := &configapi.Repository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "drafts",
+			Namespace: "default",
+		},
+		Spec: configapi.RepositorySpec{
+			Type:    configapi.RepositoryTypeGit,
+			Content: configapi.RepositoryContentPackage,
+			Git: &configapi.GitRepository{
+				Repo: address,
+			},
+		},
+	}
+	newObj := func() *api.PackageRevision {
+		return &api.PackageRevision{
+			Spec: api.PackageRevisionSpec{
+				PackageName: "bucket",
+				Revision:    "v2",
+				Lifecycle:   api.PackageRevisionLifecycleDraft,
+			},
+		}
+	}
+	cad := &cadEngine{cache: cache.NewCache(t.TempDir(), cache.CacheOptions{})}
+
+	if err := WithExistingDraftPolicy(FailIfDraftExists).apply(cad); err != nil {
+		t.Fatalf("WithExistingDraftPolicy failed: %v", err)
+	}
+	if _, err := cad.CreatePackageRevision(ctx, repositoryObj, newObj()); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("CreatePackageRevision of a package with a draft: got error %v, want one naming the draft", err)
+	}
+
+	if err := WithExistingDraftPolicy(ReuseExistingDraft).apply(cad); err != nil {
+		t.Fatalf("WithExistingDraftPolicy failed: %v", err)
+	}
+	pr, err := cad.CreatePackageRevision(ctx, repositoryObj, newObj())
+	if err != nil {
+		t.Fatalf("CreatePackageRevision failed: %v", err)
+	}
+	if got, want := pr.Key(), (repository.PackageRevisionKey{Repository: "drafts", Package: "bucket", Revision: "v1"}); got != want {
+		t.Errorf("Reused draft: got %v, want %v", got, want)
+	}
+
+	if err := WithExistingDraftPolicy("replace").apply(&cadEngine{}); err == nil {
+		t.Errorf("WithExistingDraftPolicy(%q) succeeded unexpectedly", "replace")
+	}
+}
+
+func TestDraftNamer(t *testing.T) {
+	ctx := context.Background()
+	tarfile := filepath.Join("..", "git", "testdata", "drafts-repository.tar")
+
+	for _, tc := range []struct {
+		name    string
+		namer   DraftNamer
+		want    string
+		wantErr string
+	}{
+		{
+			name: "default",
+			want: "v1",
+		},
+		{
+			name: "pass-through",
+			namer: DraftNamerFunc(func(_ context.Context, _ *configapi.Repository, obj *api.PackageRevision) (string, error) {
+				return obj.Spec.Revision, nil
+			}),
+			want: "v1",
+		},
+		{
+			name: "transform",
+			namer: DraftNamerFunc(func(_ context.Context, repositoryObj *configapi.Repository, obj *api.PackageRevision) (string, error) {
+				return repositoryObj.Name + "-" + obj.Spec.Revision, nil
+			}),
+			want: "drafts-v1",
+		},
+		{
+			name: "reject",
+			namer: DraftNamerFunc(func(context.Context, *configapi.Repository, *api.PackageRevision) (string, error) {
+				return "", errors.New("revision must start with \"draft-\"")
+			}),
+			wantErr: `draft name "v1" rejected: revision must start with "draft-"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, address := git.ServeGitRepository(t, tarfile, t.TempDir())
+			repositoryObj := &configapi.Repository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "drafts",
+					Namespace: "default",
+				},
+				Spec: configapi.RepositorySpec{
+					Type:    configapi.RepositoryTypeGit,
+					Content: configapi.RepositoryContentPackage,
+					Git: &configapi.GitRepository{
+						Repo: address,
+					},
+				},
+			}
+			cad := &cadEngine{
+				cache:    cache.NewCache(t.TempDir(), cache.CacheOptions{}),
+				renderer: kpt.NewRenderer(),
+				runtime:  kpt.NewSimpleFunctionRuntime(),
+			}
+			if err := WithDraftNamer(tc.namer).apply(cad); err != nil {
+				t.Fatalf("WithDraftNamer failed: %v", err)
+			}
+
+			pr, err := cad.CreatePackageRevision(ctx, repositoryObj, &api.PackageRevision{
+				Spec: api.PackageRevisionSpec{
+					PackageName: "named",
+					Revision:    "v1",
+					Lifecycle:   api.PackageRevisionLifecycleDraft,
+				},
+			})
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("CreatePackageRevision: got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreatePackageRevision failed: %v", err)
+			}
+			if got, want := pr.Key(), (repository.PackageRevisionKey{Repository: "drafts", Package: "named", Revision: tc.want}); got != want {
+				t.Errorf("Created draft: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// runtimeRecordingRenderer records the runtimes it renders packages with, without changing them.
+type runtimeRecordingRenderer struct {
+	runtimes []fn.FunctionRuntime
+}
+
+func (r *runtimeRecordingRenderer) Render(_ context.Context, _ filesys.FileSystem, opts fn.RenderOptions) error {
+	r.runtimes = append(r.runtimes, opts.Runtime)
+	return nil
+}
+
+func TestNewCaDEngine(t *testing.T) {
+	engine, err := NewCaDEngine()
+	if err != nil {
+		t.Fatalf("NewCaDEngine failed: %v", err)
+	}
+	cad := engine.(*cadEngine)
+	if got, want := reflect.TypeOf(cad.renderer), reflect.TypeOf(kpt.NewRenderer()); got != want {
+		t.Errorf("Default renderer: got %v, want %v", got, want)
+	}
+	if got, want := reflect.TypeOf(cad.runtime), reflect.TypeOf(kpt.NewSimpleFunctionRuntime()); got != want {
+		t.Errorf("Default function runtime: got %v, want %v", got, want)
+	}
+
+	renderer, runtime := &runtimeRecordingRenderer{}, &fake.FunctionRuntime{}
+	engine, err = NewCaDEngine(WithRenderer(renderer), WithFunctionRuntime(runtime))
+	if err != nil {
+		t.Fatalf("NewCaDEngine failed: %v", err)
+	}
+	cad = engine.(*cadEngine)
+	if cad.renderer != renderer || cad.runtime != runtime {
+		t.Fatalf("NewCaDEngine did not use the renderer and function runtime options")
+	}
+
+	ctx := context.Background()
+	render, err := cad.renderMutation(ctx, "default", repository.PackageRevisionKey{Repository: "repo", Package: "app", Revision: "v1"}, nil)
+	if err != nil {
+		t.Fatalf("renderMutation failed: %v", err)
+	}
+	if _, _, err := render.Apply(ctx, repository.PackageResources{Contents: map[string]string{
+		"Kptfile": "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+	}}); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(renderer.runtimes) != 1 || renderer.runtimes[0] != runtime {
+		t.Errorf("Render did not use the configured renderer with the configured function runtime: %v", renderer.runtimes)
+	}
+}
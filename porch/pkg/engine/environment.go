@@ -17,6 +17,8 @@ package engine
 import (
 	"context"
 
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -29,3 +31,19 @@ type Object interface {
 type ReferenceResolver interface {
 	ResolveReference(ctx context.Context, namespace, name string, result Object) error
 }
+
+// DraftNamer enforces the naming policy for new draft package revisions.
+type DraftNamer interface {
+	// NameDraft returns the revision name to use for the draft package revision being created.
+	// The requested name is in obj.Spec.Revision. Returning an error rejects the request.
+	NameDraft(ctx context.Context, repositoryObj *configapi.Repository, obj *api.PackageRevision) (string, error)
+}
+
+// DraftNamerFunc is an adapter allowing use of a function as a DraftNamer.
+type DraftNamerFunc func(ctx context.Context, repositoryObj *configapi.Repository, obj *api.PackageRevision) (string, error)
+
+var _ DraftNamer = DraftNamerFunc(nil)
+
+func (f DraftNamerFunc) NameDraft(ctx context.Context, repositoryObj *configapi.Repository, obj *api.PackageRevision) (string, error) {
+	return f(ctx, repositoryObj, obj)
+}
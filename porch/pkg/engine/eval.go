@@ -15,15 +15,22 @@
 package engine
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
 	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -32,6 +39,20 @@ import (
 type evalFunctionMutation struct {
 	runtime fn.FunctionRuntime
 	task    *api.Task
+	// Extensions of the files fed to the function; defaults to defaultFunctionInputExtensions.
+	includeExtensions []string
+	// Extensions of the files never fed to the function. Excluded files are kept unchanged.
+	excludeExtensions []string
+	// If set, Kptfiles are fed to the function; by default they are kept unchanged.
+	includeKptfiles bool
+	// If set, runs which fail with a transient error are retried.
+	retryPolicy *RetryPolicy
+	// If set, the function output is checked against the guardrails.
+	guardrails *OutputGuardrails
+	// Options of the function runner. With the network sandbox, the function runs without network
+	// access and functions requiring network are rejected. OnResults, if set, is called with the
+	// results of the function once it completes.
+	runnerOptions fn.RunnerOptions
 }
 
 func (m *evalFunctionMutation) Apply(ctx context.Context, resources repository.PackageResources) (repository.PackageResources, *api.Task, error) {
@@ -40,6 +61,19 @@ func (m *evalFunctionMutation) Apply(ctx context.Context, resources repository.P
 
 	e := m.task.Eval
 
+	if e.When != nil {
+		matched, err := matchesAnyResource(resources, e.When)
+		if err != nil {
+			return repository.PackageResources{}, nil, fmt.Errorf("failed to evaluate function condition: %w", err)
+		}
+		if !matched {
+			klog.Infof("skipping function %q; no resource matches the condition", e.Image)
+			skipped := m.task.DeepCopy()
+			skipped.Eval.Skipped = true
+			return resources, skipped, nil
+		}
+	}
+
 	// TODO: Apply should accept filesystem instead of PackageResources
 
 	runner, err := m.runtime.GetRunner(ctx, &v1.Function{
@@ -48,6 +82,11 @@ func (m *evalFunctionMutation) Apply(ctx context.Context, resources repository.P
 	if err != nil {
 		return repository.PackageResources{}, nil, fmt.Errorf("failed to create function runner: %w", err)
 	}
+	if len(e.Env) > 0 {
+		if err := setFunctionEnv(runner, e.Image, e.Env); err != nil {
+			return repository.PackageResources{}, nil, err
+		}
+	}
 
 	var functionConfig *yaml.RNode
 	if m.task.Eval.ConfigMap != nil {
@@ -65,15 +104,121 @@ func (m *evalFunctionMutation) Apply(ctx context.Context, resources repository.P
 		functionConfig = config
 	}
 
+	if m.runnerOptions.NetworkSandbox {
+		if err := sandboxFunction(runner, e, functionConfig, m.runnerOptions); err != nil {
+			return repository.PackageResources{}, nil, err
+		}
+	}
+
+	scoped := resources
+	if e.MergedView {
+		if scoped, err = mergedViewInput(resources, e.Subpackage); err != nil {
+			return repository.PackageResources{}, nil, err
+		}
+	}
+	input, err := withMountedFiles(scoped, e.MountedFiles)
+	if err != nil {
+		return repository.PackageResources{}, nil, err
+	}
+
+	var result repository.PackageResources
+	var results *yaml.RNode
+	var log bytes.Buffer
+	attempts, err := m.retryPolicy.run(ctx, func() (bool, error) {
+		log.Reset()
+		var retryable bool
+		var err error
+		result, results, retryable, err = m.evaluate(runner, functionConfig, input, &log)
+		return retryable, err
+	})
+	if m.runnerOptions.OnResults != nil || e.MergedView {
+		reported := functionResult(e.Image, results, log.String(), err)
+		if e.MergedView {
+			attributeResults(reported, scoped)
+			if listed := attributedErrors(reported); err != nil && listed != "" {
+				err = fmt.Errorf("%w; errors:\n%s", err, listed)
+			}
+		}
+		if m.runnerOptions.OnResults != nil {
+			m.runnerOptions.OnResults(reported)
+		}
+	}
+	if err != nil {
+		if log.Len() > 0 {
+			return repository.PackageResources{}, nil, fmt.Errorf("failed to evaluate function: %w; function log:\n%s", err, log.String())
+		}
+		return repository.PackageResources{}, nil, fmt.Errorf("failed to evaluate function: %w", err)
+	}
+	if log.Len() > 0 {
+		klog.Infof("function %q log:\n%s", e.Image, log.String())
+	}
+	if e.MergedView {
+		// Validators over the merged view do not change the package.
+		return resources, m.recordedTask(attempts, log.String()), nil
+	}
+	if len(e.MountedFiles) > 0 {
+		result = withoutMountedFiles(result)
+	}
+	if err := m.guardrails.check(resources, result, ""); err != nil {
+		return repository.PackageResources{}, nil, fmt.Errorf("function %q: %w", e.Image, err)
+	}
+
+	return result, m.recordedTask(attempts, log.String()), nil
+}
+
+// maxRecordedLogBytes bounds the function log recorded on the task; the end of longer logs is kept.
+const maxRecordedLogBytes = 4096
+
+// recordedTask returns the task to record for the evaluation, with the log of the function and,
+// if evaluations are retried, the number of attempts.
+func (m *evalFunctionMutation) recordedTask(attempts int, log string) *api.Task {
+	task := m.task.DeepCopy()
+	if m.retryPolicy != nil {
+		task.Eval.Attempts = int32(attempts)
+	}
+	if len(log) > maxRecordedLogBytes {
+		start := len(log) - maxRecordedLogBytes
+		for start < len(log) && !utf8.RuneStart(log[start]) {
+			start++
+		}
+		log = log[start:]
+	}
+	// The log is recorded by Porch; a log supplied by the client is dropped.
+	task.Eval.Log = log
+	return task
+}
+
+// evaluate runs the function once over the resources, writing the function log, if the runner
+// reports it, to log. It returns the results reported by the function, if any. On failure, it
+// reports whether the function runner failed with a transient error, so that the evaluation can
+// be retried.
+func (m *evalFunctionMutation) evaluate(runner fn.FunctionRunner, functionConfig *yaml.RNode, resources repository.PackageResources, log io.Writer) (repository.PackageResources, *yaml.RNode, bool, error) {
+	var runErr error
+	run := func(r io.Reader, w io.Writer) error {
+		if logging, ok := runner.(fn.LoggingFunctionRunner); ok {
+			runErr = logging.RunWithLog(r, w, log)
+		} else {
+			runErr = runner.Run(r, w)
+		}
+		return runErr
+	}
+
 	ff := &runtimeutil.FunctionFilter{
-		Run:            runner.Run,
+		Run:            run,
 		FunctionConfig: functionConfig,
 		Results:        &yaml.RNode{},
 	}
 
+	include := m.includeExtensions
+	if include == nil {
+		include = defaultFunctionInputExtensions
+	}
 	pr := &packageReader{
-		input: resources,
-		extra: map[string]string{},
+		input:             resources,
+		extra:             map[string]string{},
+		includeExtensions: include,
+		excludeExtensions: m.excludeExtensions,
+		excludeKptfiles:   !m.includeKptfiles,
 	}
 
 	// r := &kio.LocalPackageReader{
@@ -93,10 +238,15 @@ func (m *evalFunctionMutation) Apply(ctx context.Context, resources repository.P
 		Outputs: []kio.Writer{&packageWriter{
 			output: result,
 		}},
+		ContinueOnEmptyResult: m.task.Eval.ContinueOnEmptyResult,
 	}
 
 	if err := pipeline.Execute(); err != nil {
-		return repository.PackageResources{}, nil, fmt.Errorf("failed to evaluate function: %w", err)
+		return repository.PackageResources{}, ff.Results, runErr != nil && isRetryableFunctionError(runErr), err
+	}
+	// If any resources were fed to the function, an empty output means that it removed them all.
+	if fed := len(resources.Contents) > len(pr.extra); fed && len(result.Contents) == 0 && !m.task.Eval.ContinueOnEmptyResult {
+		return repository.PackageResources{}, ff.Results, false, fmt.Errorf("function returned no resources; set continueOnEmptyResult to accept an empty output")
 	}
 
 	// Return extras. TODO: Apply should accept FS.
@@ -104,5 +254,167 @@ func (m *evalFunctionMutation) Apply(ctx context.Context, resources repository.P
 		result.Contents[k] = v
 	}
 
-	return result, m.task, nil
+	return result, ff.Results, false, nil
+}
+
+// functionResult returns the result of a function run which reported the results and log, and
+// failed with err if it is not nil. Results which cannot be parsed are left out.
+func functionResult(image string, results *yaml.RNode, log string, err error) *fnresult.Result {
+	result := &fnresult.Result{
+		Image:  image,
+		Stderr: log,
+	}
+	if err != nil {
+		result.ExitCode = 1
+	}
+	if !results.IsNilOrEmpty() {
+		if err := yaml.Unmarshal([]byte(results.MustString()), &result.Results); err != nil {
+			klog.Warningf("cannot parse results of function %q: %v", image, err)
+		}
+	}
+	return result
+}
+
+// mountedFilesDir is the reserved directory under which the mounted files of an eval task are
+// made available to the function.
+const mountedFilesDir = ".porch/mounts"
+
+func isMountedFile(p string) bool {
+	return strings.HasPrefix(p, mountedFilesDir+"/")
+}
+
+// withMountedFiles returns the resources with the mounted files added under mountedFilesDir.
+// It fails if the package has files of its own in that directory, or if a mounted file is not
+// inside it.
+func withMountedFiles(resources repository.PackageResources, mounted map[string]string) (repository.PackageResources, error) {
+	if len(mounted) == 0 {
+		return resources, nil
+	}
+
+	contents := make(map[string]string, len(resources.Contents)+len(mounted))
+	for k, v := range resources.Contents {
+		if isMountedFile(k) {
+			return repository.PackageResources{}, fmt.Errorf("package file %q collides with the directory %q reserved for mounted files", k, mountedFilesDir)
+		}
+		contents[k] = v
+	}
+	for k, v := range mounted {
+		n := path.Clean(normalizeResourcePath(k))
+		if path.IsAbs(n) || n == "." || n == ".." || strings.HasPrefix(n, "../") {
+			return repository.PackageResources{}, fmt.Errorf("invalid mounted file %q: must be a relative path inside the mount directory", k)
+		}
+		contents[path.Join(mountedFilesDir, n)] = v
+	}
+	return repository.PackageResources{Contents: contents}, nil
+}
+
+// withoutMountedFiles returns the resources without the files under mountedFilesDir, which the
+// function may have returned or modified.
+func withoutMountedFiles(resources repository.PackageResources) repository.PackageResources {
+	contents := newCOWContents(resources.Contents)
+	for k := range resources.Contents {
+		if isMountedFile(k) {
+			contents.delete(k)
+		}
+	}
+	return repository.PackageResources{Contents: contents.result()}
+}
+
+// reservedFunctionEnv are the environment variables which the function runtime sets and which
+// tasks therefore cannot override.
+var reservedFunctionEnv = map[string]bool{
+	"PATH":            true,
+	"HOME":            true,
+	"HOSTNAME":        true,
+	"USER":            true,
+	"LD_PRELOAD":      true,
+	"LD_LIBRARY_PATH": true,
+}
+
+// reservedFunctionEnvPrefixes are the prefixes of environment variables reserved for kpt and the
+// function runtime.
+var reservedFunctionEnvPrefixes = []string{"KPT_", "PORCH_"}
+
+// validateFunctionEnv checks that the environment variables are well formed and not reserved.
+func validateFunctionEnv(env map[string]string) error {
+	for name := range env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+		if reservedFunctionEnv[name] {
+			return fmt.Errorf("environment variable %q is reserved", name)
+		}
+		for _, prefix := range reservedFunctionEnvPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				return fmt.Errorf("environment variable %q uses the reserved prefix %q", name, prefix)
+			}
+		}
+	}
+	return nil
+}
+
+// setFunctionEnv passes the environment variables to the runner, failing if they are invalid or
+// the runner cannot pass them to the function.
+func setFunctionEnv(runner fn.FunctionRunner, image string, env map[string]string) error {
+	if err := validateFunctionEnv(env); err != nil {
+		return err
+	}
+	envRunner, ok := runner.(fn.EnvFunctionRunner)
+	if !ok {
+		return fmt.Errorf("function %q does not support environment variables", image)
+	}
+	if err := envRunner.SetEnv(env); err != nil {
+		return fmt.Errorf("failed to set environment of function %q: %w", image, err)
+	}
+	return nil
+}
+
+// sandboxFunction configures the runner to run the function without network access. It fails if
+// the function requires network access, by enabling network in the task or by annotating its
+// config with api.FunctionRequiresNetworkAnnotation, or if the runner cannot sandbox the function.
+func sandboxFunction(runner fn.FunctionRunner, e *api.FunctionEvalTaskSpec, functionConfig *yaml.RNode, opts fn.RunnerOptions) error {
+	requiresNetwork := e.EnableNetwork
+	if functionConfig != nil && functionConfig.GetAnnotations()[api.FunctionRequiresNetworkAnnotation] == "true" {
+		requiresNetwork = true
+	}
+	if requiresNetwork {
+		return fmt.Errorf("function %q requires network access, which the network sandbox does not allow", e.Image)
+	}
+	configurable, ok := runner.(fn.ConfigurableFunctionRunner)
+	if !ok {
+		return fmt.Errorf("function %q cannot run in the network sandbox", e.Image)
+	}
+	if err := configurable.SetRunnerOptions(opts); err != nil {
+		return fmt.Errorf("failed to sandbox function %q: %w", e.Image, err)
+	}
+	return nil
+}
+
+// matchesAnyResource reports whether at least one resource matches the selector. Empty
+// selector fields match any value.
+func matchesAnyResource(resources repository.PackageResources, selector *api.Selector) (bool, error) {
+	pr := &packageReader{
+		input: resources,
+		extra: map[string]string{},
+	}
+	nodes, err := pr.Read()
+	if err != nil {
+		return false, err
+	}
+	for _, node := range nodes {
+		if selector.APIVersion != "" && selector.APIVersion != node.GetApiVersion() {
+			continue
+		}
+		if selector.Kind != "" && selector.Kind != node.GetKind() {
+			continue
+		}
+		if selector.Name != "" && selector.Name != node.GetName() {
+			continue
+		}
+		if selector.Namespace != "" && selector.Namespace != node.GetNamespace() {
+			continue
+		}
+		return true, nil
+	}
+	return false, nil
 }
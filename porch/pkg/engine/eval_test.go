@@ -0,0 +1,633 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// newFailingRuntime returns a runtime whose functions write a log and fail.
+func newFailingRuntime() *fake.FunctionRuntime {
+	return &fake.FunctionRuntime{
+		Log: "missing required field: spec.replicas",
+		Run: func(io.Reader, io.Writer) error {
+			return errors.New("function failed")
+		},
+	}
+}
+
+func TestEvalFunctionLogInError(t *testing.T) {
+	eval := &evalFunctionMutation{
+		runtime: newFailingRuntime(),
+		task: &api.Task{
+			Type: api.TaskTypeEval,
+			Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/validate:v1"},
+		},
+	}
+
+	_, _, err := eval.Apply(context.Background(), repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	})
+	if err == nil {
+		t.Fatalf("Apply of a failing function succeeded unexpectedly")
+	}
+	if want := "missing required field: spec.replicas"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Apply error %q does not contain the function log %q", err, want)
+	}
+}
+
+func TestEvalFunctionLogRecorded(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	}
+	newEval := func(log string) *evalFunctionMutation {
+		return &evalFunctionMutation{
+			runtime: &fake.FunctionRuntime{Log: log},
+			task: &api.Task{
+				Type: api.TaskTypeEval,
+				Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/set-labels:v0.1", Log: "client-supplied log"},
+			},
+		}
+	}
+
+	long := strings.Repeat("x", maxRecordedLogBytes) + "deprecated field: spec.selector"
+	for _, tc := range []struct {
+		log  string
+		want string
+	}{
+		{log: "deprecated field: spec.selector", want: "deprecated field: spec.selector"},
+		{log: long, want: long[len(long)-maxRecordedLogBytes:]},
+		{log: "", want: ""},
+	} {
+		eval := newEval(tc.log)
+		_, task, err := eval.Apply(context.Background(), resources)
+		if err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if got := task.Eval.Log; got != tc.want {
+			t.Errorf("Recorded log of %d bytes differs from the last %d bytes of the function log", len(got), len(tc.want))
+		}
+		if eval.task.Eval.Log != "client-supplied log" {
+			t.Errorf("Apply modified the requested task")
+		}
+	}
+}
+
+func TestEvalFunctionWhen(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	}
+	newEval := func(when *api.Selector) *evalFunctionMutation {
+		return &evalFunctionMutation{
+			runtime: newFailingRuntime(),
+			task: &api.Task{
+				Type: api.TaskTypeEval,
+				Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/annotate-ingress:v1", When: when},
+			},
+		}
+	}
+
+	// No Ingress; the failing function must not run.
+	eval := newEval(&api.Selector{Kind: "Ingress"})
+	got, task, err := eval.Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if !task.Eval.Skipped {
+		t.Errorf("Apply did not record the skipped function in the task")
+	}
+	if eval.task.Eval.Skipped {
+		t.Errorf("Apply modified the requested task")
+	}
+	if got, want := got.Contents["configmap.yaml"], resources.Contents["configmap.yaml"]; got != want {
+		t.Errorf("Apply of a skipped function changed resources: got %q, want %q", got, want)
+	}
+
+	// The ConfigMap matches; the failing function runs.
+	if _, _, err := newEval(&api.Selector{APIVersion: "v1", Kind: "ConfigMap", Name: "cm"}).Apply(context.Background(), resources); err == nil {
+		t.Errorf("Apply of a matching failing function succeeded unexpectedly")
+	}
+}
+
+func TestEvalFunctionInputExtensions(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: yaml-cm\n",
+			"configmap.json": `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "json-cm"}}` + "\n",
+			"generated.yml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: yml-cm\n",
+			"setup.sh":       "#!/bin/sh\necho kind: ConfigMap\n",
+			"Kptfile":        "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: kptfile-app\n",
+		},
+	}
+	unchangedJSON := map[string]string{
+		"configmap.json": resources.Contents["configmap.json"],
+		"settings.json":  `{"editor": {"tabSize": 2}, "files": ["a.yaml", "b.yaml"]}` + "\n",
+	}
+	resources.Contents["settings.json"] = unchangedJSON["settings.json"]
+
+	for _, tc := range []struct {
+		name           string
+		include        []string
+		exclude        []string
+		includeKptfile bool
+		wantSeen       []string
+		wantNot        []string
+	}{
+		{
+			name:     "default",
+			wantSeen: []string{"yaml-cm", "yml-cm"},
+			wantNot:  []string{"json-cm", "echo", "kptfile-app"},
+		},
+		{
+			name:     "excluded",
+			exclude:  []string{".yml"},
+			wantSeen: []string{"yaml-cm"},
+			wantNot:  []string{"yml-cm", "json-cm", "echo", "kptfile-app"},
+		},
+		{
+			name:     "json",
+			include:  []string{".yaml", ".json"},
+			wantSeen: []string{"yaml-cm", "json-cm"},
+			wantNot:  []string{"yml-cm", "echo", "kptfile-app"},
+		},
+		{
+			name:     "included",
+			include:  []string{".yaml"},
+			wantSeen: []string{"yaml-cm"},
+			wantNot:  []string{"json-cm", "yml-cm", "echo", "kptfile-app"},
+		},
+		{
+			name:           "kptfile",
+			includeKptfile: true,
+			wantSeen:       []string{"yaml-cm", "yml-cm", "kptfile-app"},
+			wantNot:        []string{"json-cm", "echo"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := &fake.FunctionRuntime{}
+			eval := &evalFunctionMutation{
+				runtime:           runtime,
+				task:              &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/echo:v1"}},
+				includeExtensions: tc.include,
+				excludeExtensions: tc.exclude,
+				includeKptfiles:   tc.includeKptfile,
+			}
+			got, _, err := eval.Apply(context.Background(), resources)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+
+			input := runtime.Input()
+			for _, want := range tc.wantSeen {
+				if !strings.Contains(input, want) {
+					t.Errorf("Function input does not contain %q:\n%s", want, input)
+				}
+			}
+			for _, unwanted := range tc.wantNot {
+				if strings.Contains(input, unwanted) {
+					t.Errorf("Function input contains %q:\n%s", unwanted, input)
+				}
+			}
+			for name := range resources.Contents {
+				if _, ok := got.Contents[name]; !ok {
+					t.Errorf("Apply dropped file %q", name)
+				}
+			}
+			if got, want := got.Contents["setup.sh"], resources.Contents["setup.sh"]; got != want {
+				t.Errorf("Apply changed a file not fed to the function: got %q, want %q", got, want)
+			}
+			if !containsString(tc.include, ".json") {
+				for name, want := range unchangedJSON {
+					if got := got.Contents[name]; got != want {
+						t.Errorf("Apply changed JSON file %q not fed to the function: got %q, want %q", name, got, want)
+					}
+				}
+			}
+			if got, want := got.Contents["Kptfile"], resources.Contents["Kptfile"]; !tc.includeKptfile && got != want {
+				t.Errorf("Apply changed the Kptfile not fed to the function: got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestEvalFunctionSourceAnnotations(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"config/configmaps.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: first\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: second\n  annotations:\n    internal.config.kubernetes.io/annotations-migration-resource-id: \"1\"\n",
+		},
+	}
+	echo := &fake.FunctionRuntime{}
+	m := &evalFunctionMutation{
+		runtime: echo,
+		task:    &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/echo:v1"}},
+	}
+	result, _, err := m.Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	input := echo.Input()
+	for _, want := range []string{
+		"config.kubernetes.io/path: 'config/configmaps.yaml'",
+		"internal.config.kubernetes.io/path: 'config/configmaps.yaml'",
+		"config.kubernetes.io/index: '1'",
+		"internal.config.kubernetes.io/index: '1'",
+	} {
+		if !strings.Contains(input, want) {
+			t.Errorf("Function input does not contain %q:\n%s", want, input)
+		}
+	}
+
+	output := result.Contents["config/configmaps.yaml"]
+	if strings.Contains(output, "config.kubernetes.io/") {
+		t.Errorf("Output contains internal annotations:\n%s", output)
+	}
+	if !strings.Contains(output, "name: second") {
+		t.Errorf("Output is missing resources:\n%s", output)
+	}
+}
+
+func TestEvalFunctionRetryPolicy(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	}
+	unavailable := status.Error(codes.Unavailable, "function runner unavailable")
+	rejected := errors.New("function rejected the input")
+	policy := &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	for _, tc := range []struct {
+		name         string
+		errs         []error
+		policy       *RetryPolicy
+		wantErr      bool
+		wantRuns     int
+		wantAttempts int32
+	}{
+		{
+			name:         "transient failure is retried",
+			errs:         []error{unavailable, unavailable},
+			policy:       policy,
+			wantRuns:     3,
+			wantAttempts: 3,
+		},
+		{
+			name:     "attempts are exhausted",
+			errs:     []error{unavailable, unavailable, unavailable},
+			policy:   policy,
+			wantErr:  true,
+			wantRuns: 3,
+		},
+		{
+			name:     "rejection is not retried",
+			errs:     []error{rejected},
+			policy:   policy,
+			wantErr:  true,
+			wantRuns: 1,
+		},
+		{
+			name:     "no retry policy",
+			errs:     []error{unavailable},
+			wantErr:  true,
+			wantRuns: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The function fails with the errors of the test case before echoing its input.
+			runs, errs := 0, tc.errs
+			runtime := &fake.FunctionRuntime{
+				Run: func(in io.Reader, out io.Writer) error {
+					runs++
+					if len(errs) > 0 {
+						err := errs[0]
+						errs = errs[1:]
+						return err
+					}
+					_, err := io.Copy(out, in)
+					return err
+				},
+			}
+			eval := &evalFunctionMutation{
+				runtime:     runtime,
+				task:        &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/echo:v1"}},
+				retryPolicy: tc.policy,
+			}
+			got, task, err := eval.Apply(context.Background(), resources)
+			if runs != tc.wantRuns {
+				t.Errorf("function ran %d times, want %d", runs, tc.wantRuns)
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Apply succeeded unexpectedly")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if got, want := task.Eval.Attempts, tc.wantAttempts; got != want {
+				t.Errorf("recorded attempts: got %d, want %d", got, want)
+			}
+			if eval.task.Eval.Attempts != 0 {
+				t.Errorf("Apply modified the task of the mutation")
+			}
+			if _, ok := got.Contents["configmap.yaml"]; !ok {
+				t.Errorf("function output is missing configmap.yaml")
+			}
+		})
+	}
+}
+
+func TestEvalFunctionEmptyResult(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+			"README.md":      "# app\n",
+		},
+	}
+	// The function removes all resources.
+	prune := &fake.FunctionRuntime{
+		Run: func(in io.Reader, out io.Writer) error {
+			if _, err := io.Copy(io.Discard, in); err != nil {
+				return err
+			}
+			_, err := io.WriteString(out, "apiVersion: config.kubernetes.io/v1\nkind: ResourceList\nitems: []\n")
+			return err
+		},
+	}
+	newEval := func(continueOnEmpty bool) *evalFunctionMutation {
+		return &evalFunctionMutation{
+			runtime: prune,
+			task: &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{
+				Image:                 "gcr.io/kpt-fn/prune:v1",
+				ContinueOnEmptyResult: continueOnEmpty,
+			}},
+		}
+	}
+
+	if _, _, err := newEval(false).Apply(context.Background(), resources); err == nil {
+		t.Errorf("Apply of a function removing all resources succeeded unexpectedly")
+	}
+
+	got, _, err := newEval(true).Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	want := map[string]string{"README.md": resources.Contents["README.md"]}
+	if diff := cmp.Diff(want, got.Contents); diff != "" {
+		t.Errorf("Unexpected resources (-want, +got): %s", diff)
+	}
+}
+
+func TestEvalFunctionMountedFiles(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	}
+	newEval := func(runtime fn.FunctionRuntime, mounted map[string]string) *evalFunctionMutation {
+		return &evalFunctionMutation{
+			runtime: runtime,
+			task: &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{
+				Image:        "gcr.io/kpt-fn/echo:v1",
+				MountedFiles: mounted,
+			}},
+		}
+	}
+
+	runtime := &fake.FunctionRuntime{}
+	got, _, err := newEval(runtime, map[string]string{
+		"policies/policy.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: policy\n",
+		"ca.pem":               "-----BEGIN CERTIFICATE-----\n",
+	}).Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if input := runtime.Input(); !strings.Contains(input, "policy") || !strings.Contains(input, ".porch/mounts/policies/policy.yaml") {
+		t.Errorf("Function input does not contain the mounted policy:\n%s", input)
+	}
+	for name := range got.Contents {
+		if name != "configmap.yaml" {
+			t.Errorf("Apply returned unexpected file %q", name)
+		}
+	}
+	if _, ok := got.Contents["configmap.yaml"]; !ok {
+		t.Errorf("Apply dropped file %q", "configmap.yaml")
+	}
+
+	for name, mounted := range map[string]map[string]string{
+		"escaping": {"../configmap.yaml": "apiVersion: v1\nkind: ConfigMap\n"},
+		"absolute": {"/etc/ca.pem": "-----BEGIN CERTIFICATE-----\n"},
+	} {
+		if _, _, err := newEval(&fake.FunctionRuntime{}, mounted).Apply(context.Background(), resources); err == nil {
+			t.Errorf("Apply with %s mounted file succeeded unexpectedly", name)
+		}
+	}
+
+	colliding := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml":       resources.Contents["configmap.yaml"],
+			".porch/mounts/ca.pem": "-----BEGIN CERTIFICATE-----\n",
+		},
+	}
+	if _, _, err := newEval(&fake.FunctionRuntime{}, map[string]string{"ca.pem": "x"}).Apply(context.Background(), colliding); err == nil {
+		t.Errorf("Apply with package files in the mount directory succeeded unexpectedly")
+	}
+}
+
+// newSandboxRuntime returns a runtime which stores the options its runners are configured with.
+func newSandboxRuntime(opts **fn.RunnerOptions) *fake.FunctionRuntime {
+	return &fake.FunctionRuntime{
+		SetRunnerOptions: func(o fn.RunnerOptions) error {
+			*opts = &o
+			return nil
+		},
+	}
+}
+
+// newEnvRuntime returns a runtime which stores the environment variables its runners are given.
+func newEnvRuntime(env *map[string]string) *fake.FunctionRuntime {
+	return &fake.FunctionRuntime{
+		SetEnv: func(e map[string]string) error {
+			*env = e
+			return nil
+		},
+	}
+}
+
+func TestEvalFunctionNetworkSandbox(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	}
+	newEval := func(r fn.FunctionRuntime, eval api.FunctionEvalTaskSpec) *evalFunctionMutation {
+		eval.Image = "gcr.io/kpt-fn/echo:v1"
+		return &evalFunctionMutation{
+			runtime:       r,
+			task:          &api.Task{Type: api.TaskTypeEval, Eval: &eval},
+			runnerOptions: fn.RunnerOptions{NetworkSandbox: true},
+		}
+	}
+
+	var opts *fn.RunnerOptions
+	if _, _, err := newEval(newSandboxRuntime(&opts), api.FunctionEvalTaskSpec{}).Apply(context.Background(), resources); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if opts == nil || !opts.NetworkSandbox {
+		t.Errorf("Function did not run in the network sandbox: %v", opts)
+	}
+
+	annotated := api.FunctionEvalTaskSpec{Config: runtime.RawExtension{
+		Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"config","annotations":{"` + api.FunctionRequiresNetworkAnnotation + `":"true"}}}`),
+	}}
+	for name, eval := range map[string]api.FunctionEvalTaskSpec{
+		"annotation": annotated,
+		"network":    {EnableNetwork: true},
+	} {
+		var opts *fn.RunnerOptions
+		_, _, err := newEval(newSandboxRuntime(&opts), eval).Apply(context.Background(), resources)
+		if err == nil || !strings.Contains(err.Error(), "requires network access") {
+			t.Errorf("%s: Apply of a function requiring network returned %v; want network sandbox error", name, err)
+		}
+		if opts != nil {
+			t.Errorf("%s: Function requiring network was configured to run", name)
+		}
+	}
+
+	if _, _, err := newEval(&fake.FunctionRuntime{}, api.FunctionEvalTaskSpec{}).Apply(context.Background(), resources); err == nil {
+		t.Errorf("Apply in the network sandbox on a runner without sandbox support succeeded unexpectedly")
+	}
+}
+
+func TestEvalFunctionEnv(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	}
+	newEval := func(runtime fn.FunctionRuntime, env map[string]string) *evalFunctionMutation {
+		return &evalFunctionMutation{
+			runtime: runtime,
+			task: &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{
+				Image: "gcr.io/kpt-fn/echo:v1",
+				Env:   env,
+			}},
+		}
+	}
+
+	env := map[string]string{"LOG_LEVEL": "debug"}
+	var got map[string]string
+	if _, _, err := newEval(newEnvRuntime(&got), env).Apply(context.Background(), resources); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if diff := cmp.Diff(env, got); diff != "" {
+		t.Errorf("Unexpected function environment (-want, +got): %s", diff)
+	}
+
+	for _, name := range []string{"PATH", "HOME", "KPT_FN_RUNTIME", "PORCH_TOKEN", "", "A=B"} {
+		var got map[string]string
+		if _, _, err := newEval(newEnvRuntime(&got), map[string]string{name: "x"}).Apply(context.Background(), resources); err == nil {
+			t.Errorf("Apply with environment variable %q succeeded unexpectedly", name)
+		}
+		if got != nil {
+			t.Errorf("Environment variable %q was passed to the function", name)
+		}
+	}
+
+	if _, _, err := newEval(&fake.FunctionRuntime{}, env).Apply(context.Background(), resources); err == nil {
+		t.Errorf("Apply with environment variables on a runner without environment support succeeded unexpectedly")
+	}
+}
+
+// resultsRun returns a ConfigMap and reports a warning.
+func resultsRun(in io.Reader, out io.Writer) error {
+	if _, err := io.Copy(io.Discard, in); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm
+results:
+- message: replicas not set
+  severity: warning
+`)
+	return err
+}
+
+func TestEvalFunctionResultsCallback(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	}
+	var reported []*fnresult.Result
+	newEval := func(r fn.FunctionRuntime) *evalFunctionMutation {
+		return &evalFunctionMutation{
+			runtime: r,
+			task:    &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/validate:v1"}},
+			runnerOptions: fn.RunnerOptions{OnResults: func(result *fnresult.Result) {
+				reported = append(reported, result)
+			}},
+		}
+	}
+
+	if _, _, err := newEval(&fake.FunctionRuntime{Run: resultsRun}).Apply(context.Background(), resources); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if got, want := len(reported), 1; got != want {
+		t.Fatalf("Results reported %d times; want %d", got, want)
+	}
+	if got := reported[0]; got.Image != "gcr.io/kpt-fn/validate:v1" || got.ExitCode != 0 || len(got.Results) != 1 || got.Results[0].Message != "replicas not set" {
+		t.Errorf("Unexpected results of a successful function: %+v", got)
+	}
+
+	reported = nil
+	if _, _, err := newEval(newFailingRuntime()).Apply(context.Background(), resources); err == nil {
+		t.Fatalf("Apply of a failing function succeeded unexpectedly")
+	}
+	if got, want := len(reported), 1; got != want {
+		t.Fatalf("Results reported %d times; want %d", got, want)
+	}
+	if got := reported[0]; got.ExitCode == 0 || !strings.Contains(got.Stderr, "missing required field") {
+		t.Errorf("Unexpected results of a failing function: %+v", got)
+	}
+}
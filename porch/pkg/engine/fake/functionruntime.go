@@ -0,0 +1,123 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+)
+
+// Implementation of the fn.FunctionRuntime interface for testing. Its runners write Log to the
+// function log, record their input and return it unchanged, unless the hooks say otherwise.
+type FunctionRuntime struct {
+	// Run, if set, runs the function instead of returning the input unchanged.
+	Run func(in io.Reader, out io.Writer) error
+	// Log is written to the function log before the function runs.
+	Log string
+	// SetEnv, if set, makes the runners support environment variables and is called with them.
+	SetEnv func(env map[string]string) error
+	// SetRunnerOptions, if set, makes the runners configurable and is called with the options.
+	SetRunnerOptions func(opts fn.RunnerOptions) error
+
+	mutex sync.Mutex
+	input bytes.Buffer
+}
+
+var _ fn.FunctionRuntime = &FunctionRuntime{}
+
+func (r *FunctionRuntime) GetRunner(context.Context, *v1.Function) (fn.FunctionRunner, error) {
+	runner := &functionRunner{runtime: r}
+	switch {
+	case r.SetEnv != nil && r.SetRunnerOptions != nil:
+		return &configurableEnvFunctionRunner{envFunctionRunner{runner}}, nil
+	case r.SetEnv != nil:
+		return &envFunctionRunner{runner}, nil
+	case r.SetRunnerOptions != nil:
+		return &configurableFunctionRunner{runner}, nil
+	}
+	return runner, nil
+}
+
+// Input returns the input of all runs of the runners, in the order they completed.
+func (r *FunctionRuntime) Input() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.input.String()
+}
+
+type functionRunner struct {
+	runtime *FunctionRuntime
+}
+
+var _ fn.LoggingFunctionRunner = &functionRunner{}
+
+func (fr *functionRunner) Run(in io.Reader, out io.Writer) error {
+	return fr.RunWithLog(in, out, io.Discard)
+}
+
+func (fr *functionRunner) RunWithLog(in io.Reader, out io.Writer, log io.Writer) error {
+	if _, err := io.WriteString(log, fr.runtime.Log); err != nil {
+		return err
+	}
+
+	var input bytes.Buffer
+	defer func() {
+		fr.runtime.mutex.Lock()
+		defer fr.runtime.mutex.Unlock()
+		fr.runtime.input.Write(input.Bytes())
+	}()
+	in = io.TeeReader(in, &input)
+
+	if fr.runtime.Run != nil {
+		return fr.runtime.Run(in, out)
+	}
+	_, err := io.Copy(out, in)
+	return err
+}
+
+type envFunctionRunner struct {
+	*functionRunner
+}
+
+var _ fn.EnvFunctionRunner = &envFunctionRunner{}
+
+func (er *envFunctionRunner) SetEnv(env map[string]string) error {
+	return er.runtime.SetEnv(env)
+}
+
+type configurableFunctionRunner struct {
+	*functionRunner
+}
+
+var _ fn.ConfigurableFunctionRunner = &configurableFunctionRunner{}
+
+func (cr *configurableFunctionRunner) SetRunnerOptions(opts fn.RunnerOptions) error {
+	return cr.runtime.SetRunnerOptions(opts)
+}
+
+type configurableEnvFunctionRunner struct {
+	envFunctionRunner
+}
+
+var _ fn.ConfigurableFunctionRunner = &configurableEnvFunctionRunner{}
+
+func (cr *configurableEnvFunctionRunner) SetRunnerOptions(opts fn.RunnerOptions) error {
+	return cr.runtime.SetRunnerOptions(opts)
+}
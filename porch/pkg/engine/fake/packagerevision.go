@@ -46,13 +46,33 @@ func (pr *PackageRevision) Lifecycle() v1alpha1.PackageRevisionLifecycle {
 }
 
 func (pr *PackageRevision) GetPackageRevision() *v1alpha1.PackageRevision {
-	return nil
+	if pr.PackageRevision == nil {
+		return &v1alpha1.PackageRevision{}
+	}
+	return pr.PackageRevision
+}
+
+func (pr *PackageRevision) Tasks() []v1alpha1.Task {
+	if pr.PackageRevision == nil {
+		return nil
+	}
+	return pr.PackageRevision.Spec.Tasks
 }
 
 func (f *PackageRevision) GetResources(context.Context) (*v1alpha1.PackageRevisionResources, error) {
+	if f.Resources == nil {
+		return &v1alpha1.PackageRevisionResources{}, nil
+	}
 	return f.Resources, nil
 }
 
+func (f *PackageRevision) ContentHash(context.Context) (string, error) {
+	if f.Resources == nil {
+		return repository.ContentHash(nil), nil
+	}
+	return repository.ContentHash(f.Resources.Spec.Resources), nil
+}
+
 func (f *PackageRevision) GetUpstreamLock() (kptfile.Upstream, kptfile.UpstreamLock, error) {
-	return kptfile.Upstream{}, kptfile.UpstreamLock{}, nil
+	return f.Upstream, f.UpstreamLock, nil
 }
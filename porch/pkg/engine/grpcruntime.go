@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 
 	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
@@ -78,19 +79,58 @@ type grpcRunner struct {
 	ctx    context.Context
 	client evaluator.FunctionEvaluatorClient
 	image  string
+	env    map[string]string
+	// If set, the function runner service runs the function without network access.
+	networkSandbox bool
 }
 
-var _ fn.FunctionRunner = &grpcRunner{}
+var _ fn.LoggingFunctionRunner = &grpcRunner{}
+var _ fn.EnvFunctionRunner = &grpcRunner{}
+var _ fn.ConfigurableFunctionRunner = &grpcRunner{}
+var _ fn.IncrementalFunctionRunner = &grpcRunner{}
+
+func (gr *grpcRunner) SetEnv(env map[string]string) error {
+	gr.env = env
+	return nil
+}
+
+// SetRunnerOptions implements fn.ConfigurableFunctionRunner. The network sandbox is requested from
+// the function runner service, which isolates the function from the network.
+func (gr *grpcRunner) SetRunnerOptions(opts fn.RunnerOptions) error {
+	gr.networkSandbox = opts.NetworkSandbox
+	return nil
+}
 
 func (gr *grpcRunner) Run(r io.Reader, w io.Writer) error {
+	return gr.RunWithLog(r, w, ioutil.Discard)
+}
+
+func (gr *grpcRunner) RunWithLog(r io.Reader, w io.Writer, log io.Writer) error {
+	return gr.run(r, w, log, gr.env)
+}
+
+// RunIncremental implements fn.IncrementalFunctionRunner. The changed files are passed to the
+// function in the fn.ChangedFilesEnv environment variable.
+func (gr *grpcRunner) RunIncremental(r io.Reader, w io.Writer, changedFiles []string) error {
+	env := map[string]string{}
+	for k, v := range gr.env {
+		env[k] = v
+	}
+	env[fn.ChangedFilesEnv] = strings.Join(changedFiles, ",")
+	return gr.run(r, w, ioutil.Discard, env)
+}
+
+func (gr *grpcRunner) run(r io.Reader, w io.Writer, log io.Writer, env map[string]string) error {
 	in, err := ioutil.ReadAll(r)
 	if err != nil {
 		return fmt.Errorf("failed to read function runner input: %w", err)
 	}
 
 	res, err := gr.client.EvaluateFunction(gr.ctx, &evaluator.EvaluateFunctionRequest{
-		ResourceList: in,
-		Image:        gr.image,
+		ResourceList:   in,
+		Image:          gr.image,
+		Env:            env,
+		NetworkSandbox: gr.networkSandbox,
 	})
 	if err != nil {
 		return fmt.Errorf("func eval %q failed: %w", gr.image, err)
@@ -98,5 +138,8 @@ func (gr *grpcRunner) Run(r io.Reader, w io.Writer) error {
 	if _, err := w.Write(res.ResourceList); err != nil {
 		return fmt.Errorf("failed to write function runner output: %w", err)
 	}
+	if _, err := log.Write(res.Log); err != nil {
+		return fmt.Errorf("failed to write function log: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,134 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/func/evaluator"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/kpt"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"google.golang.org/grpc"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// recordingEvaluator is a function evaluator service which records the requests it receives and
+// returns the input unchanged.
+type recordingEvaluator struct {
+	evaluator.UnimplementedFunctionEvaluatorServer
+
+	mutex    sync.Mutex
+	requests []*evaluator.EvaluateFunctionRequest
+}
+
+func (e *recordingEvaluator) EvaluateFunction(ctx context.Context, req *evaluator.EvaluateFunctionRequest) (*evaluator.EvaluateFunctionResponse, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.requests = append(e.requests, req)
+	return &evaluator.EvaluateFunctionResponse{ResourceList: req.ResourceList}, nil
+}
+
+// serveEvaluator serves the evaluator on a local port and returns its address.
+func serveEvaluator(t *testing.T, e evaluator.FunctionEvaluatorServer) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	evaluator.RegisterFunctionEvaluatorServer(server, e)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestGRPCRuntimeNetworkSandbox(t *testing.T) {
+	e := &recordingEvaluator{}
+	runtime, err := newGRPCFunctionRuntime(serveEvaluator(t, e))
+	if err != nil {
+		t.Fatalf("newGRPCFunctionRuntime failed: %v", err)
+	}
+	defer runtime.Close()
+
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	}
+	for _, sandbox := range []bool{true, false} {
+		eval := &evalFunctionMutation{
+			runtime:       runtime,
+			task:          &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/echo:v1"}},
+			runnerOptions: fn.RunnerOptions{NetworkSandbox: sandbox},
+		}
+		if _, _, err := eval.Apply(context.Background(), resources); err != nil {
+			t.Fatalf("Apply with network sandbox %t failed: %v", sandbox, err)
+		}
+	}
+
+	if len(e.requests) != 2 {
+		t.Fatalf("Function evaluator received %d requests; want 2", len(e.requests))
+	}
+	if !e.requests[0].NetworkSandbox {
+		t.Errorf("Function evaluator was not requested to run the function in the network sandbox")
+	}
+	if e.requests[1].NetworkSandbox {
+		t.Errorf("Function evaluator was requested to run the function in the network sandbox unexpectedly")
+	}
+}
+
+func TestGRPCRuntimeChangedFiles(t *testing.T) {
+	e := &recordingEvaluator{}
+	runtime, err := newGRPCFunctionRuntime(serveEvaluator(t, e))
+	if err != nil {
+		t.Fatalf("newGRPCFunctionRuntime failed: %v", err)
+	}
+	defer runtime.Close()
+
+	fs := filesys.MakeFsInMemory()
+	if err := writeResourcesToDirectory(fs, "/", repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":        "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\npipeline:\n  mutators:\n  - image: gcr.io/kpt-fn/echo:v1\n",
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+			"service.yaml":   "apiVersion: v1\nkind: Service\nmetadata:\n  name: svc\n",
+		},
+	}); err != nil {
+		t.Fatalf("writeResourcesToDirectory failed: %v", err)
+	}
+
+	for _, changed := range [][]string{{"configmap.yaml", "service.yaml"}, nil} {
+		if err := kpt.NewRenderer().Render(context.Background(), fs, fn.RenderOptions{
+			PkgPath:      "/",
+			Runtime:      runtime,
+			ChangedFiles: changed,
+		}); err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+	}
+
+	if len(e.requests) != 2 {
+		t.Fatalf("Function evaluator received %d requests; want 2", len(e.requests))
+	}
+	if got, want := e.requests[0].Env[fn.ChangedFilesEnv], "configmap.yaml,service.yaml"; got != want {
+		t.Errorf("Changed files passed to the function: got %q, want %q", got, want)
+	}
+	if got, ok := e.requests[1].Env[fn.ChangedFilesEnv]; ok {
+		t.Errorf("Changed files passed to the function of a full render: %q", got)
+	}
+}
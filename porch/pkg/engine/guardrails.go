@@ -0,0 +1,116 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// OutputGuardrails are checks of the output of renders and function evaluations. Output which
+// violates them is rejected.
+type OutputGuardrails struct {
+	// Namespace, if set, is the namespace which all namespaced resources must have. Resources of
+	// unknown kinds are only checked if they set a namespace; local configuration is not checked.
+	Namespace string
+}
+
+// OutputViolationError is returned when the output of a render or function evaluation violates
+// the output guardrails.
+type OutputViolationError struct {
+	// Violations describe the violations, sorted.
+	Violations []string
+}
+
+func (e *OutputViolationError) Error() string {
+	return fmt.Sprintf("function output violates %d guardrail(s):\n%s", len(e.Violations), strings.Join(e.Violations, "\n"))
+}
+
+// check verifies the output of a mutation of the input. Files created by the mutation must be
+// inside root, a slash-delimited directory relative to the package; empty means the package root.
+func (g *OutputGuardrails) check(input, output repository.PackageResources, root string) error {
+	if g == nil {
+		return nil
+	}
+	root = strings.Trim(root, "/")
+
+	var violations []string
+	for k, v := range output.Contents {
+		if _, existed := input.Contents[k]; !existed {
+			if n := path.Clean(normalizeResourcePath(k)); !isInsideDir(n, root) {
+				violations = append(violations, fmt.Sprintf("file %q is outside the package", k))
+				continue
+			}
+		}
+		if g.Namespace != "" {
+			violations = append(violations, g.checkNamespaces(k, v)...)
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	sort.Strings(violations)
+	return &OutputViolationError{Violations: violations}
+}
+
+// isInsideDir reports whether the clean relative path p is inside the directory dir.
+func isInsideDir(p, dir string) bool {
+	if path.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return false
+	}
+	return dir == "" || strings.HasPrefix(p, dir+"/")
+}
+
+// checkNamespaces returns the violations of the expected namespace by the resources in the file.
+// Files which are not YAML, or cannot be parsed, are not checked.
+func (g *OutputGuardrails) checkNamespaces(file, content string) []string {
+	if ext := path.Ext(file); ext != ".yaml" && ext != ".yml" {
+		return nil
+	}
+	nodes, err := (&kio.ByteReader{Reader: strings.NewReader(content), OmitReaderAnnotations: true}).Read()
+	if err != nil {
+		return nil
+	}
+
+	var violations []string
+	for _, node := range nodes {
+		if isLocalConfig(node) {
+			continue
+		}
+		ns := node.GetNamespace()
+		if ns == g.Namespace {
+			continue
+		}
+		namespaced, known := openapi.IsNamespaceScoped(yaml.TypeMeta{APIVersion: node.GetApiVersion(), Kind: node.GetKind()})
+		if (known && !namespaced) || (!known && ns == "") {
+			continue
+		}
+		violations = append(violations, fmt.Sprintf("%s %q in file %q has namespace %q, not %q", node.GetKind(), node.GetName(), file, ns, g.Namespace))
+	}
+	return violations
+}
+
+func isLocalConfig(node *yaml.RNode) bool {
+	_, local := node.GetAnnotations()[filters.LocalConfigAnnotation]
+	return local
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOutputGuardrails(t *testing.T) {
+	input := repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":        "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n  namespace: app\n",
+		},
+	}
+	output := repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":        input.Contents["Kptfile"],
+			"configmap.yaml": input.Contents["configmap.yaml"],
+			"namespace.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: app\n",
+			"local.yaml":     "apiVersion: fn.kpt.dev/v1alpha1\nkind: SetNamespace\nmetadata:\n  name: ns\n  annotations:\n    config.kubernetes.io/local-config: \"true\"\n",
+			"widget.yaml":    "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: cluster-widget\n",
+			"other.yaml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: other\n  namespace: kube-system\n---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n",
+			"../escape.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: escape\n  namespace: app\n",
+			"/etc/passwd":    "root:x:0:0::/root:/bin/sh\n",
+		},
+	}
+
+	var nilGuardrails *OutputGuardrails
+	if err := nilGuardrails.check(input, output, ""); err != nil {
+		t.Errorf("check without guardrails failed: %v", err)
+	}
+
+	err := (&OutputGuardrails{Namespace: "app"}).check(input, output, "")
+	var violation *OutputViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("check returned %v; want OutputViolationError", err)
+	}
+	want := []string{
+		`ConfigMap "other" in file "other.yaml" has namespace "kube-system", not "app"`,
+		`Deployment "app" in file "other.yaml" has namespace "", not "app"`,
+		`file "../escape.yaml" is outside the package`,
+		`file "/etc/passwd" is outside the package`,
+	}
+	if diff := cmp.Diff(want, violation.Violations); diff != "" {
+		t.Errorf("Unexpected violations (-want, +got): %s", diff)
+	}
+
+	// Without a namespace, only the files are checked. Existing files outside the root are kept.
+	rendered := repository.PackageResources{
+		Contents: map[string]string{
+			"README.md":          "# app\n",
+			"app/Kptfile":        input.Contents["Kptfile"],
+			"app/configmap.yaml": input.Contents["configmap.yaml"],
+		},
+	}
+	before := repository.PackageResources{Contents: map[string]string{"README.md": "# app\n"}}
+	if err := (&OutputGuardrails{}).check(before, rendered, "/app"); err != nil {
+		t.Errorf("check of files inside the package root failed: %v", err)
+	}
+	rendered.Contents["generated.yaml"] = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: generated\n"
+	if err := (&OutputGuardrails{}).check(before, rendered, "/app"); err == nil {
+		t.Errorf("check of a file created outside the package root succeeded unexpectedly")
+	}
+}
+
+func TestEvalFunctionOutputGuardrails(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n  namespace: default\n",
+		},
+	}
+	eval := func(guardrails *OutputGuardrails) error {
+		_, _, err := (&evalFunctionMutation{
+			runtime: &fake.FunctionRuntime{},
+			task: &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{
+				Image: "gcr.io/kpt-fn/echo:v1",
+			}},
+			guardrails: guardrails,
+		}).Apply(context.Background(), resources)
+		return err
+	}
+
+	if err := eval(&OutputGuardrails{Namespace: "default"}); err != nil {
+		t.Errorf("Apply with output in the expected namespace failed: %v", err)
+	}
+	var violation *OutputViolationError
+	if err := eval(&OutputGuardrails{Namespace: "app"}); !errors.As(err, &violation) {
+		t.Errorf("Apply with output in another namespace returned %v; want OutputViolationError", err)
+	}
+}
@@ -26,9 +26,19 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
+// Extensions of the files fed to functions by default.
+var defaultFunctionInputExtensions = []string{".yaml", ".yml"}
+
 type packageReader struct {
 	input repository.PackageResources
 	extra map[string]string
+	// If set, only files with these extensions, and the Kptfile, are read; by default
+	// only YAML files are read.
+	includeExtensions []string
+	// Files with these extensions are never read.
+	excludeExtensions []string
+	// If set, Kptfiles are not read.
+	excludeKptfiles bool
 }
 
 var _ kio.Reader = &packageReader{}
@@ -40,15 +50,18 @@ func (r *packageReader) Read() ([]*yaml.RNode, error) {
 		ext := path.Ext(base)
 
 		// TODO: use authoritative kpt filtering
-		if ext != ".yaml" && ext != ".yml" && base != "Kptfile" {
+		if !r.includes(base, ext) {
 			r.extra[k] = v
 			continue
 		}
 
+		// The reader also sets the index annotations, so that functions can report the
+		// source location of each resource.
 		var reader kio.Reader = &kio.ByteReader{
 			Reader: strings.NewReader(v),
 			SetAnnotations: map[string]string{
-				kioutil.PathAnnotation: k,
+				kioutil.PathAnnotation:       k,
+				kioutil.LegacyPathAnnotation: k,
 			},
 			DisableUnwrapping: true,
 		}
@@ -63,6 +76,28 @@ func (r *packageReader) Read() ([]*yaml.RNode, error) {
 	return results, nil
 }
 
+func (r *packageReader) includes(base, ext string) bool {
+	if containsString(r.excludeExtensions, ext) {
+		return false
+	}
+	if base == "Kptfile" {
+		return !r.excludeKptfiles
+	}
+	if r.includeExtensions != nil {
+		return containsString(r.includeExtensions, ext)
+	}
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 type packageWriter struct {
 	output repository.PackageResources
 }
@@ -81,11 +116,8 @@ func (w *packageWriter) Write(nodes []*yaml.RNode) error {
 	buf := &bytes.Buffer{}
 	for path, nodes := range paths {
 		bw := kio.ByteWriter{
-			Writer: buf,
-			ClearAnnotations: []string{
-				kioutil.PathAnnotation,
-				kioutil.LegacyPathAnnotation,
-			},
+			Writer:           buf,
+			ClearAnnotations: clearedAnnotations(nodes),
 		}
 		if err := bw.Write(nodes); err != nil {
 			return err
@@ -96,11 +128,37 @@ func (w *packageWriter) Write(nodes []*yaml.RNode) error {
 	return nil
 }
 
+// internalAnnotationPrefix is the prefix of the annotations which the function orchestrator
+// and functions use to track resources, and which are never written to the package.
+const internalAnnotationPrefix = "internal.config.kubernetes.io/"
+
+// clearedAnnotations returns the annotations which locate resources in the package, or are
+// internal, so that they are stripped from the written resources. The index annotations are
+// stripped by the writer.
+func clearedAnnotations(nodes []*yaml.RNode) []string {
+	cleared := []string{
+		kioutil.PathAnnotation,
+		kioutil.LegacyPathAnnotation,
+		kioutil.LegacyIdAnnotation,
+	}
+	for _, node := range nodes {
+		for k := range node.GetAnnotations() {
+			if strings.HasPrefix(k, internalAnnotationPrefix) {
+				cleared = append(cleared, k)
+			}
+		}
+	}
+	return cleared
+}
+
 func getPath(node *yaml.RNode) string {
 	ann := node.GetAnnotations()
 	if path, ok := ann[kioutil.PathAnnotation]; ok {
 		return path
 	}
+	if path, ok := ann[kioutil.LegacyPathAnnotation]; ok {
+		return path
+	}
 	ns := node.GetNamespace()
 	if ns == "" {
 		ns = "non-namespaced"
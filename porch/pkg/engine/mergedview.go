@@ -0,0 +1,126 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// mergedViewInput returns the files of the package at the subpackage directory, or of the whole
+// package if it is empty, and of all of its subpackages, at their paths in the package.
+func mergedViewInput(resources repository.PackageResources, subpackage string) (repository.PackageResources, error) {
+	if subpackage == "" {
+		return resources, nil
+	}
+	dir, err := normalizeSubpackagePath(subpackage)
+	if err != nil {
+		return repository.PackageResources{}, err
+	}
+	if _, ok := resources.Contents[path.Join(dir, kptfilev1.KptFileName)]; !ok {
+		return repository.PackageResources{}, fmt.Errorf("subpackage %q has no Kptfile", subpackage)
+	}
+	inside, _ := splitSubpackage(resources.Contents, dir)
+	return repository.PackageResources{Contents: inside}, nil
+}
+
+// attributeResults tags each function result which refers to a file, or to a resource of the
+// package, with the subpackage of the file or resource; see api.SubpackageResultTag.
+func attributeResults(result *fnresult.Result, resources repository.PackageResources) {
+	packages := map[string]bool{}
+	for k := range resources.Contents {
+		if path.Base(k) == kptfilev1.KptFileName {
+			packages[path.Dir(k)] = true
+		}
+	}
+	subpackageOf := func(file string) string {
+		dir := path.Dir(normalizeResourcePath(file))
+		for dir != "." && dir != "/" && !packages[dir] {
+			dir = path.Dir(dir)
+		}
+		if dir == "/" {
+			return "."
+		}
+		return dir
+	}
+
+	var files map[yaml.ResourceIdentifier]string
+	for _, r := range result.Results {
+		var file string
+		switch {
+		case r.File != nil && r.File.Path != "":
+			file = r.File.Path
+		case r.ResourceRef != nil:
+			if files == nil {
+				files = resourceFiles(resources)
+			}
+			file = files[*r.ResourceRef]
+		}
+		if file == "" {
+			continue
+		}
+		if r.Tags == nil {
+			r.Tags = map[string]string{}
+		}
+		r.Tags[api.SubpackageResultTag] = subpackageOf(file)
+	}
+}
+
+// resourceFiles returns the file of each resource of the package.
+func resourceFiles(resources repository.PackageResources) map[yaml.ResourceIdentifier]string {
+	files := map[yaml.ResourceIdentifier]string{}
+	for k, v := range resources.Contents {
+		if ext := path.Ext(k); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		nodes, err := (&kio.ByteReader{Reader: strings.NewReader(v), OmitReaderAnnotations: true}).Read()
+		if err != nil {
+			continue
+		}
+		for _, node := range nodes {
+			id := yaml.ResourceIdentifier{
+				TypeMeta: yaml.TypeMeta{APIVersion: node.GetApiVersion(), Kind: node.GetKind()},
+				NameMeta: yaml.NameMeta{Name: node.GetName(), Namespace: node.GetNamespace()},
+			}
+			files[id] = k
+		}
+	}
+	return files
+}
+
+// attributedErrors lists the error results of the function with their subpackages, one per line.
+func attributedErrors(result *fnresult.Result) string {
+	var lines []string
+	for _, r := range result.Results {
+		if r.Severity != framework.Error {
+			continue
+		}
+		if subpackage, ok := r.Tags[api.SubpackageResultTag]; ok {
+			lines = append(lines, fmt.Sprintf("subpackage %s: %s", subpackage, r.String()))
+		} else {
+			lines = append(lines, r.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
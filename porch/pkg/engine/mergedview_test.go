@@ -0,0 +1,136 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// validatorRun returns a function which records the files of its input, drops all resources
+// and reports one result by file and one by resource.
+func validatorRun(files *[]string) func(io.Reader, io.Writer) error {
+	return func(in io.Reader, out io.Writer) error {
+		nodes, err := (&kio.ByteReader{Reader: in}).Read()
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			*files = append(*files, node.GetAnnotations()[kioutil.PathAnnotation])
+		}
+		sort.Strings(*files)
+		_, err = io.WriteString(out, `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: replaced
+results:
+- message: port conflicts with backend
+  severity: warning
+  file:
+    path: apps/frontend/service.yaml
+- message: name conflicts with frontend
+  severity: warning
+  resourceRef:
+    apiVersion: v1
+    kind: Service
+    name: app
+- message: general
+  severity: info
+`)
+		return err
+	}
+}
+
+func TestEvalMergedView(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":                          "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: root\n",
+			"namespace.yaml":                   "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns\n",
+			"apps/Kptfile":                     "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: apps\n",
+			"apps/frontend/Kptfile":            "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: frontend\n",
+			"apps/frontend/service.yaml":       "apiVersion: v1\nkind: Service\nmetadata:\n  name: frontend\n",
+			"apps/backend/Kptfile":             "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: backend\n",
+			"apps/backend/config/service.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: app\n",
+		},
+	}
+	var files []string
+	runtime := &fake.FunctionRuntime{Run: validatorRun(&files)}
+	var reported *fnresult.Result
+	eval := &evalFunctionMutation{
+		runtime: runtime,
+		task: &api.Task{Type: api.TaskTypeEval, Eval: &api.FunctionEvalTaskSpec{
+			Image:      "gcr.io/kpt-fn/validate-ports:v1",
+			Subpackage: "apps",
+			MergedView: true,
+		}},
+		runnerOptions: fn.RunnerOptions{OnResults: func(result *fnresult.Result) {
+			reported = result
+		}},
+	}
+
+	result, _, err := eval.Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if diff := cmp.Diff(resources.Contents, result.Contents); diff != "" {
+		t.Errorf("Validator over the merged view changed the package (-want, +got): %s", diff)
+	}
+	if diff := cmp.Diff([]string{"apps/backend/config/service.yaml", "apps/frontend/service.yaml"}, files); diff != "" {
+		t.Errorf("Unexpected validator input (-want, +got): %s", diff)
+	}
+
+	if reported == nil || len(reported.Results) != 3 {
+		t.Fatalf("Unexpected reported results: %+v", reported)
+	}
+	for i, want := range []string{"apps/frontend", "apps/backend", ""} {
+		if got := reported.Results[i].Tags[api.SubpackageResultTag]; got != want {
+			t.Errorf("Subpackage of result %q: got %q, want %q", reported.Results[i].Message, got, want)
+		}
+	}
+
+	eval.task.Eval.Subpackage = "missing"
+	if _, _, err := eval.Apply(context.Background(), resources); err == nil {
+		t.Errorf("Apply over the merged view of a missing subpackage succeeded unexpectedly")
+	}
+}
+
+func TestAttributedErrors(t *testing.T) {
+	result := &fnresult.Result{Results: framework.Results{
+		{Message: "conflict", Severity: framework.Error, Tags: map[string]string{api.SubpackageResultTag: "apps/backend"}},
+		{Message: "unattributed", Severity: framework.Error},
+		{Message: "ignored", Severity: framework.Warning, ResourceRef: &yaml.ResourceIdentifier{}},
+	}}
+	got := attributedErrors(result)
+	if !strings.Contains(got, "subpackage apps/backend: [error]: conflict") || !strings.Contains(got, "unattributed") || strings.Contains(got, "ignored") {
+		t.Errorf("Unexpected attributed errors:\n%s", got)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+)
+
+// FunctionNotAvailableOfflineError is returned in offline mode for functions whose image would
+// have to be pulled.
+type FunctionNotAvailableOfflineError struct {
+	Function v1.Function
+}
+
+func (e *FunctionNotAvailableOfflineError) Error() string {
+	return fmt.Sprintf("function image %q not available offline", e.Function.Image)
+}
+
+// offlineRuntime restricts a function runtime to the functions which can run without network
+// access: functions executed in-process, and functions whose images are available locally.
+type offlineRuntime struct {
+	runtime fn.FunctionRuntime
+	// Images available locally, e.g. cached or loaded from tarballs by the function runner.
+	images map[string]bool
+}
+
+var _ fn.FunctionRuntime = &offlineRuntime{}
+
+func newOfflineRuntime(runtime fn.FunctionRuntime, images []string) *offlineRuntime {
+	available := make(map[string]bool, len(images))
+	for _, image := range images {
+		available[image] = true
+	}
+	return &offlineRuntime{
+		runtime: runtime,
+		images:  available,
+	}
+}
+
+func (r *offlineRuntime) GetRunner(ctx context.Context, funct *v1.Function) (fn.FunctionRunner, error) {
+	runner, err := r.runtime.GetRunner(ctx, funct)
+	if err != nil {
+		return nil, err
+	}
+	// Only the function runner may pull images; in-process runners are always available.
+	if _, remote := runner.(*grpcRunner); remote && !r.images[funct.Image] {
+		return nil, &FunctionNotAvailableOfflineError{Function: *funct}
+	}
+	return runner, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+)
+
+func TestOfflineRuntime(t *testing.T) {
+	const localImage = "example.com/functions/local:v1"
+	const remoteImage = "example.com/functions/remote:v1"
+
+	runtime := newOfflineRuntime(fn.NewMultiRuntime([]fn.FunctionRuntime{
+		newBuiltinRuntime(),
+		&grpcRuntime{},
+	}), []string{localImage})
+
+	ctx := context.Background()
+	for _, image := range []string{setNamespaceImageAliases[0], localImage} {
+		if _, err := runtime.GetRunner(ctx, &v1.Function{Image: image}); err != nil {
+			t.Errorf("GetRunner(%q) failed: %v", image, err)
+		}
+	}
+
+	_, err := runtime.GetRunner(ctx, &v1.Function{Image: remoteImage})
+	var offlineErr *FunctionNotAvailableOfflineError
+	if !errors.As(err, &offlineErr) {
+		t.Fatalf("GetRunner(%q): got error %v, want FunctionNotAvailableOfflineError", remoteImage, err)
+	}
+	if got, want := offlineErr.Function.Image, remoteImage; got != want {
+		t.Errorf("unexpected image in offline error: got %q, want %q", got, want)
+	}
+}
@@ -16,6 +16,9 @@ package engine
 
 import (
 	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/cache"
@@ -114,3 +117,280 @@ func WithUserInfoProvider(provider repository.UserInfoProvider) EngineOption {
 		return nil
 	})
 }
+
+// WithTrustedUpstreamKeys enables signature verification of commits cloned from Git upstreams.
+// Only commits signed by a key in the armored PGP key ring will be cloned or updated to, and
+// packages cannot be cloned from other upstreams, whose commits cannot be verified.
+func WithTrustedUpstreamKeys(armoredKeyRing string) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.trustedUpstreamKeys = armoredKeyRing
+		return nil
+	})
+}
+
+// WithPatchIgnorePatterns excludes files matching any of the glob patterns from the patches
+// recorded when package resources are updated. The contents of the files are still persisted.
+func WithPatchIgnorePatterns(patterns ...string) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid patch ignore pattern %q: %w", pattern, err)
+			}
+		}
+		engine.patchIgnorePatterns = append(engine.patchIgnorePatterns, patterns...)
+		return nil
+	})
+}
+
+// WithDraftNamer configures the policy applied to revision names of new drafts. By default,
+// the requested name is used as-is.
+func WithDraftNamer(namer DraftNamer) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.draftNamer = namer
+		return nil
+	})
+}
+
+// WithMaxConcurrentFunctions limits the number of functions executing simultaneously across
+// all renders and function evaluations. Zero, the default, means unlimited.
+func WithMaxConcurrentFunctions(max int) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		if max < 0 {
+			return fmt.Errorf("invalid maximum number of concurrent functions: %d", max)
+		}
+		engine.maxConcurrentFunctions = max
+		return nil
+	})
+}
+
+// WithRepositoryConcurrency limits the number of operations creating, updating or deleting package
+// revisions which run simultaneously against each repository, so that a slow repository does not
+// starve operations on the others. Repositories are limited to defaultMax operations unless limits,
+// keyed by "<namespace>/<name>" of the repository, sets their own maximum. Zero means unlimited.
+func WithRepositoryConcurrency(defaultMax int, limits map[string]int) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		if defaultMax < 0 {
+			return fmt.Errorf("invalid maximum number of concurrent repository operations: %d", defaultMax)
+		}
+		for repo, max := range limits {
+			if max < 0 {
+				return fmt.Errorf("invalid maximum number of concurrent operations of repository %q: %d", repo, max)
+			}
+		}
+		engine.repositoryLimiter = newRepositoryLimiter(defaultMax, limits)
+		return nil
+	})
+}
+
+// WithFunctionInputExtensions selects the files fed to evaluated functions by file extension,
+// such as ".yaml". Files with an extension in exclude are never fed to functions; otherwise only
+// files with an extension in include are. If include is empty, ".yaml" and ".yml" files are
+// included. JSON files can be included with ".json"; the resources functions output for them are
+// written back as YAML. Files which are not fed to functions are kept unchanged.
+func WithFunctionInputExtensions(include, exclude []string) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		for _, ext := range append(append([]string{}, include...), exclude...) {
+			if !strings.HasPrefix(ext, ".") || strings.Contains(ext, "/") {
+				return fmt.Errorf("invalid file extension %q; must start with a dot", ext)
+			}
+		}
+		if len(include) > 0 {
+			engine.functionInputExtensions = include
+		}
+		engine.functionExcludedExtensions = exclude
+		return nil
+	})
+}
+
+// WithKptfileFunctionInput feeds Kptfiles to evaluated functions along with the other resources.
+// By default, Kptfiles are not fed to eval task functions, which could mangle them, and are kept
+// unchanged; the render of the package still reads them.
+func WithKptfileFunctionInput() EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.functionInputKptfiles = true
+		return nil
+	})
+}
+
+// WithLenientPackageLoading loads the files of updated packages which can be loaded, rather than
+// failing the update if any file cannot be loaded. The files which cannot be loaded are logged as
+// warnings and left out of the package revision. By default, such updates fail.
+func WithLenientPackageLoading() EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.lenientPackageLoading = true
+		return nil
+	})
+}
+
+// WithSymlinkPolicy selects how symbolic links of updated packages are loaded. By default, they
+// are skipped with a warning.
+func WithSymlinkPolicy(policy SymlinkPolicy) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		switch policy {
+		case SymlinksSkip, SymlinksFollow:
+			engine.symlinkPolicy = policy
+			return nil
+		default:
+			return fmt.Errorf("unknown symbolic link policy %q", policy)
+		}
+	})
+}
+
+// WithDocumentPatches records changes to multi-document YAML files made by resource updates as
+// one patch per changed document, rather than one patch of the whole file, so that the recorded
+// patches reflect which documents changed.
+func WithDocumentPatches() EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.documentPatches = true
+		return nil
+	})
+}
+
+// WithLocalUpstreamRoot enables cloning packages from directories on the local file system, for
+// development. Only directories under root can be cloned.
+func WithLocalUpstreamRoot(root string) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return fmt.Errorf("invalid local upstream root %q: %w", root, err)
+		}
+		engine.localUpstreamRoot = abs
+		return nil
+	})
+}
+
+// WithOfflineFunctions enables offline mode, in which renders and function evaluations fail fast
+// with a FunctionNotAvailableOfflineError rather than pulling function images. Only functions
+// which run in-process and functions whose images are listed as available locally are run.
+func WithOfflineFunctions(localImages ...string) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.offline = true
+		engine.offlineImages = append(engine.offlineImages, localImages...)
+		return nil
+	})
+}
+
+// WithNetworkSandbox runs eval task functions without network access, for untrusted functions.
+// Evaluations fail if the function requires network access, by enabling network in its task or by
+// the api.FunctionRequiresNetworkAnnotation of its config, or if its runner cannot run it without
+// network access.
+func WithNetworkSandbox() EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.networkSandbox = true
+		return nil
+	})
+}
+
+// WithFunctionResultsCallback calls the callback with the results of each eval task function as
+// soon as the function completes, successfully or not, so that progress can be streamed to clients
+// instead of only returning the results once the whole update is done.
+func WithFunctionResultsCallback(callback fn.ResultsCallback) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.functionResultsCallback = callback
+		return nil
+	})
+}
+
+// WithDuplicateResourcesAllowed disables the check that the resources of rendered packages have
+// unique identities. By default, a render which leaves several resources with the same group,
+// version, kind, namespace and name fails with a DuplicateResourcesError.
+func WithDuplicateResourcesAllowed() EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.allowDuplicateResources = true
+		return nil
+	})
+}
+
+// WithResultHistory keeps the function results of the last n renders of each package revision,
+// available from CaDEngine.ResultHistory. The history is held in memory and is lost on restart.
+func WithResultHistory(n int) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		if n < 0 {
+			return fmt.Errorf("invalid result history length: %d", n)
+		}
+		if n == 0 {
+			engine.resultHistory = nil
+		} else {
+			engine.resultHistory = newResultHistory(n)
+		}
+		return nil
+	})
+}
+
+// WithFunctionRetryPolicy retries function evaluations which fail with a transient error, such
+// as the function runner being unavailable or failing to pull the function image. Functions which
+// reject their input are not retried. The number of attempts is recorded on the eval task.
+func WithFunctionRetryPolicy(policy RetryPolicy) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		if policy.MaxAttempts < 0 || policy.Backoff < 0 || policy.MaxBackoff < 0 {
+			return fmt.Errorf("invalid function retry policy: %+v", policy)
+		}
+		engine.functionRetryPolicy = &policy
+		return nil
+	})
+}
+
+// WithMaxTasks limits the number of tasks of a package revision. Creating, updating or previewing
+// a package revision with more tasks fails before any task is applied. Zero means unlimited.
+func WithMaxTasks(n int) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		if n < 0 {
+			return fmt.Errorf("invalid maximum number of tasks: %d", n)
+		}
+		engine.maxTasks = n
+		return nil
+	})
+}
+
+// WithCheckpoints checkpoints creations and updates of package revisions in the directory after
+// every applied task, so that an update interrupted by a crash resumes after the last applied
+// task when the same request is retried, instead of running the earlier tasks again. The
+// checkpoints take at most maxBytes; the oldest are evicted first.
+func WithCheckpoints(dir string, maxBytes int64) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		if maxBytes <= 0 {
+			return fmt.Errorf("invalid checkpoint storage limit: %d", maxBytes)
+		}
+		store, err := newCheckpointStore(dir, maxBytes)
+		if err != nil {
+			return err
+		}
+		engine.checkpoints = store
+		return nil
+	})
+}
+
+// WithOutputGuardrails rejects renders and function evaluations whose output creates files outside
+// the package or, if the guardrails have a namespace, has namespaced resources in another namespace.
+// The error lists all violations; see OutputViolationError.
+func WithOutputGuardrails(guardrails OutputGuardrails) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.outputGuardrails = &guardrails
+		return nil
+	})
+}
+
+// WithSetterValues merges the setter values provided by the source into the configs of the
+// apply-setters functions of packages before they are rendered, so that values, such as those of
+// an environment, can be supplied at render time without editing the packages. The injected values
+// override the values in the packages and are not persisted in them.
+func WithSetterValues(source SetterValuesSource) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		engine.setterValuesSource = source
+		return nil
+	})
+}
+
+// WithExistingDraftPolicy selects how the creation of a package revision is handled when the
+// package already has a draft in the repository. By default, another draft is created.
+func WithExistingDraftPolicy(policy ExistingDraftPolicy) EngineOption {
+	return EngineOptionFunc(func(engine *cadEngine) error {
+		switch policy {
+		case CreateNewDraft, FailIfDraftExists, ReuseExistingDraft:
+			engine.existingDraftPolicy = policy
+			return nil
+		default:
+			return fmt.Errorf("unknown existing draft policy %q", policy)
+		}
+	})
+}
@@ -45,6 +45,62 @@ func GeneratePatch(fileName string, oldV, newV string) (api.PatchSpec, error) {
 	return patchSpec, nil
 }
 
+// generateDocumentPatches generates the patches which change a multi-document YAML file
+// one document at a time, so that each patch reflects a single changed document. The patches
+// must be applied in order. If the number of documents changed, or the file contains a single
+// document, a single patch of the whole file is generated.
+func generateDocumentPatches(fileName string, oldV, newV string) ([]api.PatchSpec, error) {
+	oldDocs, newDocs := splitDocuments(oldV), splitDocuments(newV)
+	if len(oldDocs) != len(newDocs) || len(oldDocs) < 2 {
+		patchSpec, err := GeneratePatch(fileName, oldV, newV)
+		if err != nil {
+			return nil, err
+		}
+		return []api.PatchSpec{patchSpec}, nil
+	}
+
+	var patches []api.PatchSpec
+	current := oldDocs
+	for i := range newDocs {
+		if newDocs[i] == oldDocs[i] {
+			continue
+		}
+		next := append(append(append([]string{}, current[:i]...), newDocs[i]), current[i+1:]...)
+		patchSpec, err := GeneratePatch(fileName, strings.Join(current, ""), strings.Join(next, ""))
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patchSpec)
+		current = next
+	}
+	return patches, nil
+}
+
+// splitDocuments splits YAML content into its documents. Each document includes the separator
+// line which ends it, so joining the documents reproduces the content.
+func splitDocuments(content string) []string {
+	var docs []string
+	start := 0
+	for start < len(content) {
+		end := len(content)
+		for i := start; i < len(content); {
+			eol := strings.IndexByte(content[i:], '\n')
+			if eol < 0 {
+				break
+			}
+			line := content[i : i+eol]
+			i += eol + 1
+			if strings.TrimRight(line, " \t\r") == "---" && i-eol-1 > start {
+				end = i
+				break
+			}
+		}
+		docs = append(docs, content[start:end])
+		start = end
+	}
+	return docs
+}
+
 type applyPatchMutation struct {
 	patchTask *api.PackagePatchTaskSpec
 	task      *api.Task
@@ -56,76 +112,78 @@ func (m *applyPatchMutation) Apply(ctx context.Context, resources repository.Pac
 	ctx, span := tracer.Start(ctx, "applyPatchMutation:::Apply", trace.WithAttributes())
 	defer span.End()
 
-	result := repository.PackageResources{
-		Contents: map[string]string{},
-	}
-
+	// Files which the patches do not touch are shared with the input resources.
+	contents := newCOWContents(resources.Contents)
 	for k, v := range resources.Contents {
-		result.Contents[k] = v
+		if n := normalizeResourcePath(k); n != k {
+			contents.delete(k)
+			contents.set(n, v)
+		}
 	}
 
 	for _, patchSpec := range m.patchTask.Patches {
+		patchSpec.File = normalizeResourcePath(patchSpec.File)
 		switch patchSpec.PatchType {
 		case api.PatchTypeCreateFile:
-			if _, found := result.Contents[patchSpec.File]; found {
+			if _, found := contents.get(patchSpec.File); found {
 				// TODO: We should be able to tolerate this.  Either do a merge or create as a different filename "-2"
-				return result, nil, fmt.Errorf("patch wants to create file %q but already exists", patchSpec.File)
+				return repository.PackageResources{}, nil, fmt.Errorf("patch wants to create file %q but already exists", patchSpec.File)
 			}
-			result.Contents[patchSpec.File] = patchSpec.Contents
+			contents.set(patchSpec.File, patchSpec.Contents)
 		case api.PatchTypeDeleteFile:
-			if _, found := result.Contents[patchSpec.File]; !found {
+			if _, found := contents.get(patchSpec.File); !found {
 				// TODO: I don't think this should be an error, but maybe we should use object manipulation more than file manipulation.
 				// TODO: Support object based patches where we can.
 				klog.Warningf("patch wants to delete file %q, but already deleted", patchSpec.File)
 			}
-			delete(result.Contents, patchSpec.File)
+			contents.delete(patchSpec.File)
 		case api.PatchTypePatchFile:
-			oldContents, found := result.Contents[patchSpec.File]
+			oldContents, found := contents.get(patchSpec.File)
 			if !found {
-				return result, nil, fmt.Errorf("patch specifies file %q which does not exist", patchSpec.File)
+				return repository.PackageResources{}, nil, fmt.Errorf("patch specifies file %q which does not exist", patchSpec.File)
 			}
 
 			files, preamble, err := gitdiff.Parse(strings.NewReader(patchSpec.Contents))
 			if err != nil {
-				return result, nil, fmt.Errorf("error parsing patch: %w", err)
+				return repository.PackageResources{}, nil, fmt.Errorf("error parsing patch: %w", err)
 			}
 
 			if len(files) == 0 {
-				return result, nil, fmt.Errorf("patch did not specify any files")
+				return repository.PackageResources{}, nil, fmt.Errorf("patch did not specify any files")
 			}
 			if len(files) > 1 {
-				return result, nil, fmt.Errorf("patch specified multiple files")
+				return repository.PackageResources{}, nil, fmt.Errorf("patch specified multiple files")
 			}
 			if preamble != "" {
-				return result, nil, fmt.Errorf("patch had unexpected preamble %q", preamble)
+				return repository.PackageResources{}, nil, fmt.Errorf("patch had unexpected preamble %q", preamble)
 			}
 
 			if files[0].OldName != patchSpec.File {
-				return result, nil, fmt.Errorf("patch contained unexpected name; got %q, want %q", files[0].OldName, patchSpec.File)
+				return repository.PackageResources{}, nil, fmt.Errorf("patch contained unexpected name; got %q, want %q", files[0].OldName, patchSpec.File)
 			}
 
 			if files[0].IsBinary {
-				return result, nil, fmt.Errorf("patch was a binary diff; expected text diff")
+				return repository.PackageResources{}, nil, fmt.Errorf("patch was a binary diff; expected text diff")
 			}
 			if files[0].IsCopy || files[0].IsDelete || files[0].IsNew || files[0].IsRename {
-				return result, nil, fmt.Errorf("patch was of an unexpected type (copy/delete/new/rename)")
+				return repository.PackageResources{}, nil, fmt.Errorf("patch was of an unexpected type (copy/delete/new/rename)")
 			}
 			if files[0].OldMode != files[0].NewMode {
-				return result, nil, fmt.Errorf("patch contained file mode change")
+				return repository.PackageResources{}, nil, fmt.Errorf("patch contained file mode change")
 			}
 			var output bytes.Buffer
 			if err := gitdiff.Apply(&output, strings.NewReader(oldContents), files[0]); err != nil {
-				return result, nil, fmt.Errorf("error applying patch: %w", err)
+				return repository.PackageResources{}, nil, fmt.Errorf("error applying patch: %w", err)
 			}
 
 			patched := output.String()
-			result.Contents[patchSpec.File] = patched
+			contents.set(patchSpec.File, patched)
 		default:
-			return result, nil, fmt.Errorf("unhandled patch type %q", patchSpec.PatchType)
+			return repository.PackageResources{}, nil, fmt.Errorf("unhandled patch type %q", patchSpec.PatchType)
 		}
 	}
 
-	return result, m.task, nil
+	return repository.PackageResources{Contents: contents.result()}, m.task, nil
 }
 
 func buildPatchMutation(ctx context.Context, task *api.Task) (mutation, error) {
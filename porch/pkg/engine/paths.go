@@ -0,0 +1,64 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// normalizeResourcePath converts a resource key to the slash-delimited form, regardless of the
+// separator used by the client (for example backslashes sent by clients on Windows).
+func normalizeResourcePath(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// normalizeResourcePaths returns the contents with all keys in slash-delimited form.
+// It fails if different keys normalize to the same path.
+func normalizeResourcePaths(contents map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(contents))
+	for k, v := range contents {
+		n := normalizeResourcePath(k)
+		if _, exists := result[n]; exists {
+			return nil, fmt.Errorf("resource %q conflicts with another resource with the same normalized path %q", k, n)
+		}
+		result[n] = v
+	}
+	return result, nil
+}
+
+// normalizeSubpackagePath converts a subpackage directory to the slash-delimited form relative to
+// the package root. It fails if the directory is not strictly inside the package.
+func normalizeSubpackagePath(dir string) (string, error) {
+	n := path.Clean(normalizeResourcePath(dir))
+	if path.IsAbs(n) || n == "." || n == ".." || strings.HasPrefix(n, "../") {
+		return "", fmt.Errorf("invalid subpackage %q: must be a directory inside the package", dir)
+	}
+	return n, nil
+}
+
+// splitSubpackage splits the contents into the files under the subpackage directory and the rest.
+func splitSubpackage(contents map[string]string, dir string) (inside, outside map[string]string) {
+	inside, outside = map[string]string{}, map[string]string{}
+	for k, v := range contents {
+		if strings.HasPrefix(k, dir+"/") {
+			inside[k] = v
+		} else {
+			outside[k] = v
+		}
+	}
+	return inside, outside
+}
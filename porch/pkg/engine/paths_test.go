@@ -0,0 +1,123 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestNormalizeResourcePaths(t *testing.T) {
+	got, err := normalizeResourcePaths(map[string]string{
+		"Kptfile":              "kptfile",
+		`sub\Kptfile`:          "sub kptfile",
+		`sub\nested/cm.yaml`:   "cm",
+		"sub/nested/svc.yaml":  "svc",
+		`sub\nested\deep\x.md`: "x",
+	})
+	if err != nil {
+		t.Fatalf("normalizeResourcePaths failed: %v", err)
+	}
+	want := map[string]string{
+		"Kptfile":              "kptfile",
+		"sub/Kptfile":          "sub kptfile",
+		"sub/nested/cm.yaml":   "cm",
+		"sub/nested/svc.yaml":  "svc",
+		"sub/nested/deep/x.md": "x",
+	}
+	if !cmp.Equal(want, got) {
+		t.Errorf("Normalized paths differ (-want,+got): %s", cmp.Diff(want, got))
+	}
+}
+
+func TestNormalizeResourcePathsConflict(t *testing.T) {
+	if _, err := normalizeResourcePaths(map[string]string{
+		`a\b.yaml`: "one",
+		"a/b.yaml": "two",
+	}); err == nil {
+		t.Errorf("normalizeResourcePaths with conflicting keys succeeded unexpectedly")
+	}
+}
+
+func TestReplaceResourcesMixedSeparators(t *testing.T) {
+	replace := &mutationReplaceResources{
+		newResources: &v1alpha1.PackageRevisionResources{
+			Spec: v1alpha1.PackageRevisionResourcesSpec{
+				Resources: map[string]string{
+					"Kptfile":           "kind: Kptfile\n",
+					`sub\cm.txt`:        "updated",
+					`sub\nested/new.md`: "new",
+				},
+			},
+		},
+	}
+
+	output, task, err := replace.Apply(context.Background(), repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":    "kind: Kptfile\n",
+			"sub/cm.txt": "original",
+		},
+	})
+	if err != nil {
+		t.Fatalf("mutationReplaceResources.Apply failed: %v", err)
+	}
+
+	want := map[string]string{
+		"Kptfile":           "kind: Kptfile\n",
+		"sub/cm.txt":        "updated",
+		"sub/nested/new.md": "new",
+	}
+	if !cmp.Equal(want, output.Contents) {
+		t.Errorf("Resources differ (-want,+got): %s", cmp.Diff(want, output.Contents))
+	}
+	for _, p := range task.Patch.Patches {
+		if p.PatchType == v1alpha1.PatchTypeDeleteFile {
+			t.Errorf("Unexpected deletion of %q", p.File)
+		}
+	}
+}
+
+func TestWriteAndLoadResourcesMixedSeparators(t *testing.T) {
+	fsys := filesys.MakeFsInMemory()
+	if err := writeResourcesToDirectory(fsys, "/work", repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":         "kind: Kptfile\n",
+			`sub\a.yaml`:      "a",
+			`sub\nested\b.md`: "b",
+			"sub/nested/c.md": "c",
+		},
+	}); err != nil {
+		t.Fatalf("writeResourcesToDirectory failed: %v", err)
+	}
+
+	got, err := loadResourcesFromDirectory(fsys, "/work")
+	if err != nil {
+		t.Fatalf("loadResourcesFromDirectory failed: %v", err)
+	}
+	want := map[string]string{
+		"Kptfile":         "kind: Kptfile\n",
+		"sub/a.yaml":      "a",
+		"sub/nested/b.md": "b",
+		"sub/nested/c.md": "c",
+	}
+	if !cmp.Equal(want, got.Contents) {
+		t.Errorf("Loaded resources differ (-want,+got): %s", cmp.Diff(want, got.Contents))
+	}
+}
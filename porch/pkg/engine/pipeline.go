@@ -0,0 +1,71 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	internalpkg "github.com/GoogleContainerTools/kpt/internal/pkg"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ComputeEffectivePipeline applies the tasks of the package revision in memory, without creating
+// the package revision, and returns the pipeline of the resulting root Kptfile: the functions the
+// next render will run. The returned pipeline is empty if the Kptfile has none.
+func (cad *cadEngine) ComputeEffectivePipeline(ctx context.Context, repositoryObj *configapi.Repository, obj *api.PackageRevision) (*kptfilev1.Pipeline, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::ComputeEffectivePipeline", trace.WithAttributes())
+	defer span.End()
+
+	if err := cad.checkTaskCount(obj); err != nil {
+		return nil, err
+	}
+
+	mutations, err := cad.createMutations(ctx, repositoryObj, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := repository.PackageResources{}
+	for _, m := range dedupeMutations(mutations) {
+		applied, _, err := m.Apply(ctx, resources)
+		if err != nil {
+			return nil, err
+		}
+		resources = applied
+	}
+
+	contents, err := normalizeResourcePaths(resources.Contents)
+	if err != nil {
+		return nil, err
+	}
+	kptfile, ok := contents[kptfilev1.KptFileName]
+	if !ok {
+		return nil, fmt.Errorf("package has no Kptfile")
+	}
+	kf, err := internalpkg.DecodeKptfile(strings.NewReader(kptfile))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Kptfile: %w", err)
+	}
+	if kf.Pipeline == nil {
+		return &kptfilev1.Pipeline{}, nil
+	}
+	return kf.Pipeline, nil
+}
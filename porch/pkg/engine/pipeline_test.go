@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+)
+
+func TestComputeEffectivePipeline(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "app"), 0755); err != nil {
+		t.Fatalf("Failed to create package directory: %v", err)
+	}
+	kptfile := `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: app
+pipeline:
+  mutators:
+  - image: gcr.io/kpt-fn/set-namespace:v0.4
+    configMap:
+      namespace: test
+  validators:
+  - image: gcr.io/kpt-fn/kubeval:v0.3
+`
+	if err := os.WriteFile(filepath.Join(root, "app", "Kptfile"), []byte(kptfile), 0644); err != nil {
+		t.Fatalf("Failed to write Kptfile: %v", err)
+	}
+
+	cad := &cadEngine{runtime: &fake.FunctionRuntime{}, localUpstreamRoot: root}
+	obj := &api.PackageRevision{
+		Spec: api.PackageRevisionSpec{
+			PackageName: "app",
+			Tasks: []api.Task{
+				{
+					Type: api.TaskTypeClone,
+					Clone: &api.PackageCloneTaskSpec{
+						Upstream: api.UpstreamPackage{
+							Type:      api.RepositoryTypeDirectory,
+							Directory: &api.DirectoryPackage{Path: "app"},
+						},
+					},
+				},
+				{
+					Type: api.TaskTypeEval,
+					Eval: &api.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/echo:v1"},
+				},
+			},
+		},
+	}
+
+	pipeline, err := cad.ComputeEffectivePipeline(context.Background(), &configapi.Repository{}, obj)
+	if err != nil {
+		t.Fatalf("ComputeEffectivePipeline failed: %v", err)
+	}
+	if got, want := len(pipeline.Mutators), 1; got != want {
+		t.Fatalf("got %d mutators, want %d", got, want)
+	}
+	if got, want := pipeline.Mutators[0].ConfigMap["namespace"], "test"; got != want {
+		t.Errorf("mutator namespace: got %q, want %q", got, want)
+	}
+	if got, want := len(pipeline.Validators), 1; got != want {
+		t.Fatalf("got %d validators, want %d", got, want)
+	}
+	if got, want := pipeline.Validators[0].Image, "gcr.io/kpt-fn/kubeval:v0.3"; got != want {
+		t.Errorf("validator image: got %q, want %q", got, want)
+	}
+
+	// A package initialized without a pipeline has an empty effective pipeline.
+	pipeline, err = cad.ComputeEffectivePipeline(context.Background(), &configapi.Repository{}, &api.PackageRevision{
+		Spec: api.PackageRevisionSpec{PackageName: "empty"},
+	})
+	if err != nil {
+		t.Fatalf("ComputeEffectivePipeline failed: %v", err)
+	}
+	if len(pipeline.Mutators) != 0 || len(pipeline.Validators) != 0 {
+		t.Errorf("unexpected pipeline of an initialized package: %+v", pipeline)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PreviewResult is the outcome of a preview of the tasks of a package revision.
+type PreviewResult struct {
+	// Resources are the package resources after the last previewed task.
+	Resources repository.PackageResources
+	// Attribution maps the files to the mutation which last changed them. Mutation indexes
+	// include the implicit init of packages created without one.
+	Attribution Attribution
+}
+
+// PreviewPackageRevision applies the tasks of the package revision in memory, without creating
+// the package revision, and stops after the task with index lastTask. The returned resources are
+// those the remaining tasks would start from; the package is not rendered. It is meant for
+// debugging pipelines which fail or produce unexpected output part way through.
+func (cad *cadEngine) PreviewPackageRevision(ctx context.Context, obj *api.PackageRevision, lastTask int) (*PreviewResult, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::PreviewPackageRevision", trace.WithAttributes())
+	defer span.End()
+
+	if err := cad.checkTaskCount(obj); err != nil {
+		return nil, err
+	}
+	if lastTask < 0 || lastTask >= len(obj.Spec.Tasks) {
+		return nil, fmt.Errorf("task index %d out of range; package revision has %d tasks", lastTask, len(obj.Spec.Tasks))
+	}
+
+	partial := obj.DeepCopy()
+	partial.Spec.Tasks = partial.Spec.Tasks[:lastTask+1]
+	mutations, err := cad.taskMutations(ctx, partial)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := repository.PackageResources{}
+	attribution := Attribution{}
+	for i, m := range dedupeMutations(mutations) {
+		applied, task, err := m.Apply(ctx, resources)
+		if err != nil {
+			return nil, err
+		}
+		attribution.record(i, task, resources.Contents, applied.Contents)
+		resources = applied
+	}
+	return &PreviewResult{
+		Resources:   resources,
+		Attribution: attribution,
+	}, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"testing"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPreviewPackageRevision(t *testing.T) {
+	createFile := func(file string) api.Task {
+		return api.Task{
+			Type: api.TaskTypePatch,
+			Patch: &api.PackagePatchTaskSpec{
+				Patches: []api.PatchSpec{{
+					File:      file,
+					Contents:  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + file + "\n",
+					PatchType: api.PatchTypeCreateFile,
+				}},
+			},
+		}
+	}
+	obj := &api.PackageRevision{
+		Spec: api.PackageRevisionSpec{
+			PackageName: "app",
+			Tasks: []api.Task{
+				{
+					Type: api.TaskTypeInit,
+					Init: &api.PackageInitTaskSpec{Description: "app"},
+				},
+				createFile("first.yaml"),
+				createFile("second.yaml"),
+			},
+		},
+	}
+	cad := &cadEngine{}
+
+	for _, tc := range []struct {
+		lastTask int
+		want     []string
+		wantNot  []string
+	}{
+		{lastTask: 0, want: []string{"Kptfile"}, wantNot: []string{"first.yaml", "second.yaml"}},
+		{lastTask: 1, want: []string{"Kptfile", "first.yaml"}, wantNot: []string{"second.yaml"}},
+		{lastTask: 2, want: []string{"Kptfile", "first.yaml", "second.yaml"}},
+	} {
+		result, err := cad.PreviewPackageRevision(context.Background(), obj, tc.lastTask)
+		if err != nil {
+			t.Fatalf("PreviewPackageRevision(%d) failed: %v", tc.lastTask, err)
+		}
+		for _, file := range tc.want {
+			if _, ok := result.Resources.Contents[file]; !ok {
+				t.Errorf("PreviewPackageRevision(%d): missing %q", tc.lastTask, file)
+			}
+		}
+		for _, file := range tc.wantNot {
+			if _, ok := result.Resources.Contents[file]; ok {
+				t.Errorf("PreviewPackageRevision(%d): unexpected %q", tc.lastTask, file)
+			}
+		}
+	}
+
+	result, err := cad.PreviewPackageRevision(context.Background(), obj, 2)
+	if err != nil {
+		t.Fatalf("PreviewPackageRevision failed: %v", err)
+	}
+	for file, want := range map[string]FileAttribution{
+		// The init mutation records no task.
+		"Kptfile":     {Mutation: 0},
+		"first.yaml":  {Mutation: 1, Type: api.TaskTypePatch},
+		"second.yaml": {Mutation: 2, Type: api.TaskTypePatch},
+	} {
+		if diff := cmp.Diff(want, result.Attribution[file]); diff != "" {
+			t.Errorf("Unexpected attribution of %q (-want, +got): %s", file, diff)
+		}
+	}
+
+	if got, want := len(obj.Spec.Tasks), 3; got != want {
+		t.Errorf("PreviewPackageRevision modified the tasks: got %d, want %d", got, want)
+	}
+	for _, lastTask := range []int{-1, 3} {
+		if _, err := cad.PreviewPackageRevision(context.Background(), obj, lastTask); err == nil {
+			t.Errorf("PreviewPackageRevision(%d) succeeded unexpectedly", lastTask)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/kpt"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PromotePackageRevision creates a published package revision in the destination repository with
+// the package name, revision and resources of the published source package revision. The new
+// package revision records the source in its clone task and in the upstream of its Kptfile. It
+// fails if the destination repository already has the revision of the package.
+func (cad *cadEngine) PromotePackageRevision(ctx context.Context, source repository.PackageRevision, destRepo *configapi.Repository) (repository.PackageRevision, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::PromotePackageRevision", trace.WithAttributes())
+	defer span.End()
+
+	release, err := cad.repositoryLimiter.acquire(ctx, destRepo)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if got, want := source.Lifecycle(), api.PackageRevisionLifecyclePublished; got != want {
+		return nil, fmt.Errorf("cannot promote package revision %q in lifecycle %q; must be %q", source.KubeObjectName(), got, want)
+	}
+
+	key := source.Key()
+	repo, err := cad.cache.OpenRepository(ctx, destRepo)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: key.Package, Revision: key.Revision})
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("cannot promote package revision %q: repository %q already has revision %q of package %q (%s)",
+			source.KubeObjectName(), destRepo.Name, key.Revision, key.Package, existing[0].KubeObjectName())
+	}
+
+	obj := &api.PackageRevision{
+		Spec: api.PackageRevisionSpec{
+			PackageName:    key.Package,
+			Revision:       key.Revision,
+			RepositoryName: destRepo.Name,
+			Lifecycle:      api.PackageRevisionLifecycleDraft,
+		},
+	}
+	obj.Namespace = destRepo.Namespace
+	draft, err := repo.CreatePackageRevision(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := applyResourceMutation(ctx, draft, repository.PackageResources{}, &promotePackageMutation{source: source}); err != nil {
+		return nil, err
+	}
+	if err := draft.UpdateLifecycle(ctx, api.PackageRevisionLifecyclePublished); err != nil {
+		return nil, err
+	}
+	return cad.closeDraft(ctx, draft, nil)
+}
+
+// promotePackageMutation replaces the resources with those of the source package revision, and
+// records the source as the upstream of the package.
+type promotePackageMutation struct {
+	source repository.PackageRevision
+}
+
+func (m *promotePackageMutation) Apply(ctx context.Context, resources repository.PackageResources) (repository.PackageResources, *api.Task, error) {
+	ctx, span := tracer.Start(ctx, "promotePackageMutation::Apply", trace.WithAttributes())
+	defer span.End()
+
+	name := m.source.KubeObjectName()
+	sourceResources, err := m.source.GetResources(ctx)
+	if err != nil {
+		return repository.PackageResources{}, nil, fmt.Errorf("cannot read contents of package revision %q: %w", name, err)
+	}
+	contents := map[string]string{}
+	for k, v := range sourceResources.Spec.Resources {
+		contents[k] = v
+	}
+
+	key := m.source.Key()
+	upstream, lock, err := m.source.GetUpstreamLock()
+	if err != nil {
+		return repository.PackageResources{}, nil, fmt.Errorf("cannot determine upstream lock for package revision %q: %w", name, err)
+	}
+	if err := kpt.UpdateKptfileUpstream(key.Package, contents, upstream, lock); err != nil {
+		return repository.PackageResources{}, nil, fmt.Errorf("failed to apply upstream lock to package revision %q: %w", name, err)
+	}
+
+	task := &api.Task{
+		Type: api.TaskTypeClone,
+		Clone: &api.PackageCloneTaskSpec{
+			Upstream: api.UpstreamPackage{
+				UpstreamRef: &api.PackageRevisionRef{
+					Name:       name,
+					Repository: key.Repository,
+					Package:    key.Package,
+					Revision:   key.Revision,
+				},
+			},
+		},
+	}
+	return repository.PackageResources{Contents: contents}, task, nil
+}
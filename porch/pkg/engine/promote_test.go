@@ -0,0 +1,115 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/cache"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/git"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPromotePackageRevision(t *testing.T) {
+	ctx := context.Background()
+	newRepositoryObj := func(name, tarfile string) *configapi.Repository {
+		_, address := git.ServeGitRepository(t, filepath.Join("..", "git", "testdata", tarfile), t.TempDir())
+		return &configapi.Repository{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: configapi.RepositorySpec{
+				Type:    configapi.RepositoryTypeGit,
+				Content: configapi.RepositoryContentPackage,
+				Git: &configapi.GitRepository{
+					Repo: address,
+				},
+			},
+		}
+	}
+	sandboxObj := newRepositoryObj("sandbox", "nested-repository.tar")
+	productionObj := newRepositoryObj("production", "trivial-repository.tar")
+	cad := &cadEngine{cache: cache.NewCache(t.TempDir(), cache.CacheOptions{})}
+
+	sandbox, err := cad.OpenRepository(ctx, sandboxObj)
+	if err != nil {
+		t.Fatalf("OpenRepository failed: %v", err)
+	}
+	sources, err := sandbox.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: "catalog/gcp/bucket", Revision: "v1"})
+	if err != nil || len(sources) != 1 {
+		t.Fatalf("ListPackageRevisions returned %d package revisions, %v; want 1", len(sources), err)
+	}
+	source := sources[0]
+
+	promoted, err := cad.PromotePackageRevision(ctx, source, productionObj)
+	if err != nil {
+		t.Fatalf("PromotePackageRevision failed: %v", err)
+	}
+	if got, want := promoted.Key(), (repository.PackageRevisionKey{Repository: "production", Package: "catalog/gcp/bucket", Revision: "v1"}); got != want {
+		t.Errorf("Promoted package revision: got %v, want %v", got, want)
+	}
+	if got, want := promoted.Lifecycle(), api.PackageRevisionLifecyclePublished; got != want {
+		t.Errorf("Promoted package revision lifecycle: got %q, want %q", got, want)
+	}
+
+	tasks := promoted.GetPackageRevision().Spec.Tasks
+	if len(tasks) != 1 || tasks[0].Type != api.TaskTypeClone || tasks[0].Clone.Upstream.UpstreamRef == nil {
+		t.Fatalf("Promoted package revision tasks: got %v, want one clone task", tasks)
+	}
+	if got, want := *tasks[0].Clone.Upstream.UpstreamRef, (api.PackageRevisionRef{Name: source.KubeObjectName(), Repository: "sandbox", Package: "catalog/gcp/bucket", Revision: "v1"}); got != want {
+		t.Errorf("Promoted package revision upstream: got %v, want %v", got, want)
+	}
+
+	resources, err := promoted.GetResources(ctx)
+	if err != nil {
+		t.Fatalf("GetResources failed: %v", err)
+	}
+	sourceResources, err := source.GetResources(ctx)
+	if err != nil {
+		t.Fatalf("GetResources failed: %v", err)
+	}
+	for name, contents := range sourceResources.Spec.Resources {
+		if name == "Kptfile" {
+			continue
+		}
+		if got := resources.Spec.Resources[name]; got != contents {
+			t.Errorf("Promoted file %q differs from the source:\n%s", name, got)
+		}
+	}
+	if kptfile := resources.Spec.Resources["Kptfile"]; !strings.Contains(kptfile, "upstreamLock:") {
+		t.Errorf("Promoted Kptfile does not record the upstream:\n%s", kptfile)
+	}
+
+	if _, err := cad.PromotePackageRevision(ctx, source, productionObj); err == nil || !strings.Contains(err.Error(), "already has revision") {
+		t.Errorf("Repeated PromotePackageRevision: got error %v, want name conflict", err)
+	}
+
+	draft := &fake.PackageRevision{
+		Name:               "sandbox-bucket-v2",
+		PackageRevisionKey: repository.PackageRevisionKey{Repository: "sandbox", Package: "bucket", Revision: "v2"},
+		PackageLifecycle:   api.PackageRevisionLifecycleDraft,
+	}
+	if _, err := cad.PromotePackageRevision(ctx, draft, productionObj); err == nil {
+		t.Errorf("PromotePackageRevision of a draft package revision succeeded unexpectedly")
+	}
+}
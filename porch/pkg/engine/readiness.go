@@ -0,0 +1,130 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	internalpkg "github.com/GoogleContainerTools/kpt/internal/pkg"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Directory of the in-memory render filesystem where function results are saved.
+// It is outside of the package so that it never becomes part of the package contents.
+const renderResultsDir = "/.porch-results"
+
+// Readiness describes whether a package revision passes its validators.
+type Readiness struct {
+	// Ready is true if the render succeeded and the last validator of the package
+	// produced no error or warning results.
+	Ready bool
+	// BlockingResults are the error and warning results which prevent the package revision from being ready.
+	BlockingResults []*framework.Result
+}
+
+func (cad *cadEngine) ComputeReadiness(ctx context.Context, pr repository.PackageRevision) (*Readiness, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::ComputeReadiness", trace.WithAttributes())
+	defer span.End()
+
+	resources, err := pr.GetResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get package resources: %w", err)
+	}
+
+	fs := filesys.MakeFsInMemory()
+	pkgPath, err := writeResources(fs, repository.PackageResources{Contents: resources.Spec.Resources})
+	if err != nil {
+		return nil, err
+	}
+	if pkgPath == "" {
+		return nil, fmt.Errorf("package revision %q has no Kptfile", pr.KubeObjectName())
+	}
+
+	kf, err := readKptfile(fs, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	renderErr := cad.renderer.Render(ctx, fs, fn.RenderOptions{
+		PkgPath:        pkgPath,
+		Runtime:        cad.runtime,
+		ResultsDirPath: renderResultsDir,
+	})
+
+	results, err := readResultList(fs)
+	if err != nil {
+		if renderErr != nil {
+			return nil, fmt.Errorf("cannot render package revision %q: %w", pr.KubeObjectName(), renderErr)
+		}
+		return nil, err
+	}
+
+	readiness := &Readiness{Ready: renderErr == nil}
+	if renderErr == nil && (kf.Pipeline == nil || len(kf.Pipeline.Validators) == 0) {
+		// Nothing to validate.
+		return readiness, nil
+	}
+
+	// Validators of the root package run last, so the last result is from the last validator,
+	// or, if the render failed, from the function which failed.
+	if len(results.Items) > 0 {
+		last := results.Items[len(results.Items)-1]
+		for _, result := range last.Results {
+			switch result.Severity {
+			case framework.Error, framework.Warning:
+				readiness.BlockingResults = append(readiness.BlockingResults, result)
+			}
+		}
+	}
+	if len(readiness.BlockingResults) > 0 {
+		readiness.Ready = false
+	}
+	return readiness, nil
+}
+
+func readKptfile(fs filesys.FileSystem, pkgPath string) (*kptfilev1.KptFile, error) {
+	f, err := fs.Open(path.Join(pkgPath, kptfilev1.KptFileName))
+	if err != nil {
+		return nil, fmt.Errorf("error opening Kptfile: %w", err)
+	}
+	defer f.Close()
+
+	kf, err := internalpkg.DecodeKptfile(f)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Kptfile: %w", err)
+	}
+	return kf, nil
+}
+
+func readResultList(fs filesys.FileSystem) (*fnresult.ResultList, error) {
+	data, err := fs.ReadFile(path.Join(renderResultsDir, "results.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read function results: %w", err)
+	}
+	var results fnresult.ResultList
+	if err := yaml.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("cannot parse function results: %w", err)
+	}
+	return &results, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"path"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const readinessKptfile = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: app
+pipeline:
+  validators:
+  - image: gcr.io/kpt-fn/kubeval:v0.3
+`
+
+// resultsRenderer saves the configured results to the results directory and returns the configured error.
+type resultsRenderer struct {
+	results fnresult.ResultList
+	err     error
+}
+
+func (r *resultsRenderer) Render(ctx context.Context, fs filesys.FileSystem, opts fn.RenderOptions) error {
+	data, err := yaml.Marshal(&r.results)
+	if err != nil {
+		return err
+	}
+	if err := fs.MkdirAll(opts.ResultsDirPath); err != nil {
+		return err
+	}
+	if err := fs.WriteFile(path.Join(opts.ResultsDirPath, "results.yaml"), data); err != nil {
+		return err
+	}
+	return r.err
+}
+
+func TestComputeReadiness(t *testing.T) {
+	warning := &framework.Result{Message: "deprecated field", Severity: framework.Warning}
+	info := &framework.Result{Message: "looks good", Severity: framework.Info}
+
+	for _, tc := range []struct {
+		name      string
+		kptfile   string
+		renderer  *resultsRenderer
+		wantReady bool
+		wantCount int
+	}{
+		{
+			name:    "passing validator",
+			kptfile: readinessKptfile,
+			renderer: &resultsRenderer{results: fnresult.ResultList{Items: []fnresult.Result{
+				{Image: "gcr.io/kpt-fn/kubeval:v0.3", Results: framework.Results{info}},
+			}}},
+			wantReady: true,
+		},
+		{
+			name:    "validator warning",
+			kptfile: readinessKptfile,
+			renderer: &resultsRenderer{results: fnresult.ResultList{Items: []fnresult.Result{
+				{Image: "gcr.io/kpt-fn/kubeval:v0.3", Results: framework.Results{info, warning}},
+			}}},
+			wantReady: false,
+			wantCount: 1,
+		},
+		{
+			name:    "render failure",
+			kptfile: readinessKptfile,
+			renderer: &resultsRenderer{
+				results: fnresult.ResultList{},
+				err:     errors.New("function failed"),
+			},
+			wantReady: false,
+		},
+		{
+			name:      "no validators",
+			kptfile:   "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+			renderer:  &resultsRenderer{},
+			wantReady: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := &fake.PackageRevision{
+				Name: "app",
+				Resources: &v1alpha1.PackageRevisionResources{
+					Spec: v1alpha1.PackageRevisionResourcesSpec{
+						Resources: map[string]string{"Kptfile": tc.kptfile},
+					},
+				},
+			}
+			cad := &cadEngine{renderer: tc.renderer}
+
+			got, err := cad.ComputeReadiness(context.Background(), pr)
+			if err != nil {
+				t.Fatalf("ComputeReadiness failed: %v", err)
+			}
+			if got.Ready != tc.wantReady {
+				t.Errorf("Ready: got %t, want %t", got.Ready, tc.wantReady)
+			}
+			if len(got.BlockingResults) != tc.wantCount {
+				t.Errorf("BlockingResults: got %d, want %d", len(got.BlockingResults), tc.wantCount)
+			}
+		})
+	}
+}
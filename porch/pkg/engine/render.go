@@ -16,10 +16,12 @@ package engine
 
 import (
 	"context"
+	"fmt"
 	iofs "io/fs"
 	"path"
 	"strings"
 
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
@@ -31,17 +33,56 @@ import (
 type renderPackageMutation struct {
 	renderer fn.Renderer
 	runtime  fn.FunctionRuntime
+	// If set, the files changed by the preceding mutations are passed to the renderer as a hint.
+	changed *changedFiles
+	// Function results of the last successful render.
+	results *fnresult.ResultList
+	// If set, the rendered package is checked against the guardrails.
+	guardrails *OutputGuardrails
+	// If set, the rendered package may have several resources with the same identity.
+	allowDuplicates bool
+	// Setter values merged into the apply-setters function configs of the package for the render.
+	setterValues map[string]string
 }
 
 var _ mutation = &renderPackageMutation{}
 
+// RenderError is returned when rendering a package fails. It captures the package as it
+// looked when the render failed, for debugging.
+type RenderError struct {
+	// Err is the error returned by the renderer.
+	Err error
+	// Resources are the package resources at the point of failure.
+	Resources repository.PackageResources
+	// Result is the result of the last function which ran, usually the one which failed.
+	// Nil if no function ran.
+	Result *fnresult.Result
+}
+
+func (e *RenderError) Error() string {
+	if e.Result != nil && e.Result.Image != "" {
+		return fmt.Sprintf("render failed in function %q: %v", e.Result.Image, e.Err)
+	}
+	return fmt.Sprintf("render failed: %v", e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
 func (m *renderPackageMutation) Apply(ctx context.Context, resources repository.PackageResources) (repository.PackageResources, *api.Task, error) {
 	ctx, span := tracer.Start(ctx, "renderPackageMutation::Apply", trace.WithAttributes())
 	defer span.End()
 
+	input := newCOWContents(resources.Contents)
+	injected, err := injectSetterValues(input, m.setterValues)
+	if err != nil {
+		return repository.PackageResources{}, nil, err
+	}
+
 	fs := filesys.MakeFsInMemory()
 
-	pkgPath, err := writeResources(fs, resources)
+	pkgPath, err := writeResources(fs, repository.PackageResources{Contents: input.result()})
 	if err != nil {
 		return repository.PackageResources{}, nil, err
 	}
@@ -51,11 +92,23 @@ func (m *renderPackageMutation) Apply(ctx context.Context, resources repository.
 		// TODO: we should handle this better
 		klog.Warningf("skipping render as no package was found")
 	} else {
-		if err := m.renderer.Render(ctx, fs, fn.RenderOptions{
-			PkgPath: pkgPath,
-			Runtime: m.runtime,
-		}); err != nil {
-			return repository.PackageResources{}, nil, err
+		renderErr := m.renderer.Render(ctx, fs, fn.RenderOptions{
+			PkgPath:        pkgPath,
+			Runtime:        m.runtime,
+			ChangedFiles:   m.changed.list(),
+			ResultsDirPath: renderResultsDir,
+		})
+		if renderErr != nil {
+			return repository.PackageResources{}, nil, newRenderError(fs, renderErr)
+		}
+		if results, err := readResultList(fs); err == nil {
+			m.results = results
+		}
+		// Function results are not part of the package.
+		if fs.Exists(renderResultsDir) {
+			if err := fs.RemoveAll(renderResultsDir); err != nil {
+				return repository.PackageResources{}, nil, err
+			}
 		}
 	}
 
@@ -63,6 +116,21 @@ func (m *renderPackageMutation) Apply(ctx context.Context, resources repository.
 	if err != nil {
 		return repository.PackageResources{}, nil, err
 	}
+	// The injected setter values are not persisted in the package, unless the render changed the
+	// files further.
+	for name, original := range injected {
+		if v, _ := input.get(name); result.Contents[name] == v {
+			result.Contents[name] = original
+		}
+	}
+	if err := m.guardrails.check(resources, result, pkgPath); err != nil {
+		return repository.PackageResources{}, nil, err
+	}
+	if !m.allowDuplicates {
+		if err := checkResourceUniqueness(result); err != nil {
+			return repository.PackageResources{}, nil, err
+		}
+	}
 
 	// TODO: There are internal tasks not represented in the API; Update the Apply interface to enable them.
 	return result, &api.Task{
@@ -74,10 +142,30 @@ func (m *renderPackageMutation) Apply(ctx context.Context, resources repository.
 	}, nil
 }
 
+// newRenderError captures the state of the render filesystem after the render failed.
+func newRenderError(fs filesys.FileSystem, err error) *RenderError {
+	renderErr := &RenderError{Err: err}
+	if results, err := readResultList(fs); err == nil && len(results.Items) > 0 {
+		renderErr.Result = &results.Items[len(results.Items)-1]
+	}
+	if fs.Exists(renderResultsDir) {
+		if err := fs.RemoveAll(renderResultsDir); err != nil {
+			klog.Warningf("cannot remove function results of failed render: %v", err)
+		}
+	}
+	if resources, err := readResources(fs); err == nil {
+		renderErr.Resources = resources
+	} else {
+		klog.Warningf("cannot read package resources of failed render: %v", err)
+	}
+	return renderErr
+}
+
 // TODO: Implement filesystem abstraction directly rather than on top of PackageResources
 func writeResources(fs filesys.FileSystem, resources repository.PackageResources) (string, error) {
 	var packageDir string // path to the topmost directory containing Kptfile
 	for k, v := range resources.Contents {
+		k = normalizeResourcePath(k)
 		dir := path.Dir(k)
 		if dir == "." {
 			dir = "/"
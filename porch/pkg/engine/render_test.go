@@ -16,10 +16,12 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
 
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/kpt"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
@@ -75,3 +77,44 @@ func TestRender(t *testing.T) {
 		t.Errorf("Unexpected result (-want, +got): %s", diff)
 	}
 }
+
+func TestRenderError(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile":        "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+			"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		},
+	}
+	failed := fnresult.Result{Image: "gcr.io/kpt-fn/set-namespace:v0.4", ExitCode: 1, Stderr: "namespace is required"}
+	render := &renderPackageMutation{
+		renderer: &resultsRenderer{
+			results: fnresult.ResultList{Items: []fnresult.Result{{Image: "gcr.io/kpt-fn/set-labels:v0.1"}, failed}},
+			err:     errors.New("function failed"),
+		},
+	}
+
+	_, _, err := render.Apply(context.Background(), resources)
+	var renderErr *RenderError
+	if !errors.As(err, &renderErr) {
+		t.Fatalf("Apply returned %v; want a RenderError", err)
+	}
+	if renderErr.Result == nil {
+		t.Fatalf("RenderError has no function result")
+	}
+	if diff := cmp.Diff(failed, *renderErr.Result); diff != "" {
+		t.Errorf("Unexpected function result (-want, +got): %s", diff)
+	}
+	if diff := cmp.Diff(resources.Contents, renderErr.Resources.Contents); diff != "" {
+		t.Errorf("Unexpected resources at failure (-want, +got): %s", diff)
+	}
+
+	// On success, function results do not become part of the package.
+	render.renderer = &resultsRenderer{}
+	rendered, _, err := render.Apply(context.Background(), resources)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if diff := cmp.Diff(resources.Contents, rendered.Contents); diff != "" {
+		t.Errorf("Unexpected rendered resources (-want, +got): %s", diff)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
@@ -56,6 +57,149 @@ func TestReplaceResources(t *testing.T) {
 	}
 }
 
+func TestReplaceResourcesIgnorePatterns(t *testing.T) {
+	ctx := context.Background()
+
+	input := readPackage(t, filepath.Join("testdata", "replace"))
+
+	updated := map[string]string{}
+	for k, v := range input.Contents {
+		updated[k] = v
+	}
+	updated["status/cache.txt"] = "timestamp: 2"
+	updated["notes.txt"] = "hello"
+
+	replace := &mutationReplaceResources{
+		newResources: &v1alpha1.PackageRevisionResources{
+			Spec: v1alpha1.PackageRevisionResourcesSpec{
+				Resources: updated,
+			},
+		},
+		ignorePatterns: []string{"status/*"},
+	}
+
+	output, task, err := replace.Apply(ctx, input)
+	if err != nil {
+		t.Fatalf("mutationReplaceResources.Apply failed: %v", err)
+	}
+
+	if got, want := output.Contents["status/cache.txt"], "timestamp: 2"; got != want {
+		t.Errorf("Ignored file contents: got %q, want %q", got, want)
+	}
+
+	var patched []string
+	for _, p := range task.Patch.Patches {
+		patched = append(patched, p.File)
+	}
+	if want := []string{"notes.txt"}; !cmp.Equal(want, patched) {
+		t.Errorf("Patched files differ (-want,+got): %s", cmp.Diff(want, patched))
+	}
+}
+
+func TestReplaceResourcesRecordsChangedFiles(t *testing.T) {
+	ctx := context.Background()
+
+	input := readPackage(t, filepath.Join("testdata", "replace"))
+
+	updated := map[string]string{}
+	for k, v := range input.Contents {
+		updated[k] = v
+	}
+	updated["status/cache.txt"] = "timestamp: 2"
+	updated["notes.txt"] = "hello"
+
+	changed := &changedFiles{}
+	replace := &mutationReplaceResources{
+		newResources: &v1alpha1.PackageRevisionResources{
+			Spec: v1alpha1.PackageRevisionResourcesSpec{
+				Resources: updated,
+			},
+		},
+		ignorePatterns: []string{"status/*"},
+		changed:        changed,
+	}
+
+	if _, _, err := replace.Apply(ctx, input); err != nil {
+		t.Fatalf("mutationReplaceResources.Apply failed: %v", err)
+	}
+
+	// Ignored files are excluded from the patch, but still reported as changed.
+	if got, want := changed.list(), []string{"notes.txt", "status/cache.txt"}; !cmp.Equal(want, got) {
+		t.Errorf("Changed files differ (-want,+got): %s", cmp.Diff(want, got))
+	}
+}
+
+func TestReplaceResourcesSubpackage(t *testing.T) {
+	ctx := context.Background()
+
+	input := repository.PackageResources{Contents: map[string]string{
+		"Kptfile":        "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: root\n",
+		"cm.yaml":        "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: root\n",
+		"sub/Kptfile":    "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: sub\n",
+		"sub/cm.yaml":    "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: sub\n",
+		"sub/old.yaml":   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: old\n",
+		"subpkg/cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: sibling\n",
+	}}
+	updated := map[string]string{
+		"sub/Kptfile":  input.Contents["sub/Kptfile"],
+		"sub/cm.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: sub\ndata:\n  key: value\n",
+		"sub/new.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: new\n",
+	}
+
+	replace := &mutationReplaceResources{
+		newResources: &v1alpha1.PackageRevisionResources{
+			Spec: v1alpha1.PackageRevisionResourcesSpec{
+				Resources:  updated,
+				Subpackage: "sub/",
+			},
+		},
+	}
+	output, task, err := replace.Apply(ctx, input)
+	if err != nil {
+		t.Fatalf("mutationReplaceResources.Apply failed: %v", err)
+	}
+
+	want := map[string]string{
+		"Kptfile":        input.Contents["Kptfile"],
+		"cm.yaml":        input.Contents["cm.yaml"],
+		"subpkg/cm.yaml": input.Contents["subpkg/cm.yaml"],
+		"sub/Kptfile":    updated["sub/Kptfile"],
+		"sub/cm.yaml":    updated["sub/cm.yaml"],
+		"sub/new.yaml":   updated["sub/new.yaml"],
+	}
+	if diff := cmp.Diff(want, output.Contents); diff != "" {
+		t.Errorf("Unexpected resources (-want, +got): %s", diff)
+	}
+
+	var got []string
+	for _, patch := range task.Patch.Patches {
+		got = append(got, patch.File+":"+string(patch.PatchType))
+	}
+	wantPatches := []string{
+		"sub/cm.yaml:" + string(v1alpha1.PatchTypePatchFile),
+		"sub/new.yaml:" + string(v1alpha1.PatchTypeCreateFile),
+		"sub/old.yaml:" + string(v1alpha1.PatchTypeDeleteFile),
+	}
+	if diff := cmp.Diff(wantPatches, got); diff != "" {
+		t.Errorf("Unexpected patches (-want, +got): %s", diff)
+	}
+
+	for _, tc := range []struct {
+		subpackage string
+		resources  map[string]string
+	}{
+		{subpackage: "sub", resources: map[string]string{"cm.yaml": ""}},
+		{subpackage: "../sub", resources: map[string]string{}},
+		{subpackage: "/", resources: map[string]string{}},
+	} {
+		replace.newResources.Spec.Subpackage = tc.subpackage
+		replace.newResources.Spec.Resources = tc.resources
+		if _, _, err := replace.Apply(ctx, input); err == nil {
+			t.Errorf("Apply with subpackage %q and resources %v succeeded unexpectedly", tc.subpackage, tc.resources)
+		}
+	}
+}
+
 func removeComments(t *testing.T, r repository.PackageResources) repository.PackageResources {
 	t.Helper()
 
@@ -91,3 +235,113 @@ func removeCommentsFromFile(t *testing.T, name, contents string) string {
 
 	return nocomment.String()
 }
+
+func TestReplaceResourcesSortsPatches(t *testing.T) {
+	ctx := context.Background()
+
+	input := repository.PackageResources{Contents: map[string]string{
+		"Kptfile": "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+		"b.yaml":  "b: 1\n",
+		"d.yaml":  "d: 1\n",
+	}}
+	updated := map[string]string{
+		"Kptfile": input.Contents["Kptfile"],
+		"a.yaml":  "a: 1\n",
+		"b.yaml":  "b: 2\n",
+		"c.yaml":  "c: 1\n",
+	}
+
+	for i := 0; i < 10; i++ {
+		replace := &mutationReplaceResources{
+			newResources: &v1alpha1.PackageRevisionResources{
+				Spec: v1alpha1.PackageRevisionResourcesSpec{
+					Resources: updated,
+				},
+			},
+		}
+		_, task, err := replace.Apply(ctx, input)
+		if err != nil {
+			t.Fatalf("mutationReplaceResources.Apply failed: %v", err)
+		}
+
+		var patched []string
+		for _, p := range task.Patch.Patches {
+			patched = append(patched, p.File)
+		}
+		if want := []string{"a.yaml", "b.yaml", "c.yaml", "d.yaml"}; !cmp.Equal(want, patched) {
+			t.Fatalf("Patched files differ (-want,+got): %s", cmp.Diff(want, patched))
+		}
+	}
+}
+
+func TestReplaceResourcesDocumentPatches(t *testing.T) {
+	ctx := context.Background()
+
+	doc := func(name, value string) string {
+		return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\ndata:\n  key: " + value + "\n"
+	}
+	old := doc("a", "1") + "---\n" + doc("b", "1") + "---\n" + doc("c", "1")
+
+	for _, tc := range []struct {
+		name        string
+		new         string
+		wantPatches int
+	}{
+		{name: "one document", new: doc("a", "1") + "---\n" + doc("b", "2") + "---\n" + doc("c", "1"), wantPatches: 1},
+		{name: "two documents", new: doc("a", "2") + "---\n" + doc("b", "1") + "---\n" + doc("c", "2"), wantPatches: 2},
+		{name: "renamed document", new: doc("a", "1") + "---\n" + doc("b2", "1") + "---\n" + doc("c", "1"), wantPatches: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			input := repository.PackageResources{Contents: map[string]string{"configmaps.yaml": old}}
+			replace := &mutationReplaceResources{
+				newResources: &v1alpha1.PackageRevisionResources{
+					Spec: v1alpha1.PackageRevisionResourcesSpec{
+						Resources: map[string]string{"configmaps.yaml": tc.new},
+					},
+				},
+				oldResources: &v1alpha1.PackageRevisionResources{
+					Spec: v1alpha1.PackageRevisionResourcesSpec{
+						Resources: input.Contents,
+					},
+				},
+				documentPatches: true,
+			}
+
+			output, task, err := replace.Apply(ctx, input)
+			if err != nil {
+				t.Fatalf("mutationReplaceResources.Apply failed: %v", err)
+			}
+			if got, want := output.Contents["configmaps.yaml"], tc.new; got != want {
+				t.Errorf("Unexpected output (-want,+got): %s", cmp.Diff(want, got))
+			}
+			if got := len(task.Patch.Patches); got != tc.wantPatches {
+				t.Errorf("Got %d patches; want %d", got, tc.wantPatches)
+			}
+
+			// Each patch changes a single document.
+			for _, patch := range task.Patch.Patches {
+				if got := strings.Count(patch.Contents, "\n+ "); got != 1 {
+					t.Errorf("Patch adds %d lines; want 1:\n%s", got, patch.Contents)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitDocuments(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"a: 1\n",
+		"a: 1\n---\nb: 2\n",
+		"---\na: 1\n---\nb: 2\n---\n",
+		"a: 1\n--- \nb: 2",
+	} {
+		docs := splitDocuments(content)
+		if got := strings.Join(docs, ""); got != content {
+			t.Errorf("Joined documents of %q differ: got %q", content, got)
+		}
+	}
+	if got, want := len(splitDocuments("---\na: 1\n---\nb: 2\n")), 2; got != want {
+		t.Errorf("Got %d documents; want %d", got, want)
+	}
+}
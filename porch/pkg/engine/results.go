@@ -0,0 +1,94 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"sync"
+	"time"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+)
+
+// RecordedResults are the function results of one render of a package revision.
+type RecordedResults struct {
+	// Time is when the render completed.
+	Time    time.Time
+	Results *fnresult.ResultList
+}
+
+// resultHistory keeps the function results of the most recent renders of each package revision,
+// oldest first.
+type resultHistory struct {
+	mutex   sync.Mutex
+	limit   int
+	entries map[string][]RecordedResults
+}
+
+func newResultHistory(limit int) *resultHistory {
+	return &resultHistory{
+		limit:   limit,
+		entries: map[string][]RecordedResults{},
+	}
+}
+
+func (h *resultHistory) record(pr repository.PackageRevision, results *fnresult.ResultList, now time.Time) {
+	if h == nil || pr == nil || results == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	name := pr.KubeObjectName()
+	entries := append(h.entries[name], RecordedResults{Time: now, Results: results})
+	if len(entries) > h.limit {
+		entries = append([]RecordedResults(nil), entries[len(entries)-h.limit:]...)
+	}
+	h.entries[name] = entries
+}
+
+func (h *resultHistory) get(pr repository.PackageRevision) []RecordedResults {
+	if h == nil {
+		return nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return append([]RecordedResults(nil), h.entries[pr.KubeObjectName()]...)
+}
+
+// remove forgets the results of the named package revision, once it is deleted.
+func (h *resultHistory) remove(name string) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.entries, name)
+}
+
+func (cad *cadEngine) ResultHistory(pr repository.PackageRevision) []RecordedResults {
+	return cad.resultHistory.get(pr)
+}
+
+// recordResults adds the results of the render to the history of the package revision, if
+// result history is enabled.
+func (cad *cadEngine) recordResults(pr repository.PackageRevision, render *renderPackageMutation) {
+	if render == nil {
+		return
+	}
+	cad.resultHistory.record(pr, render.results, time.Now())
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/cache"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/git"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResultHistory(t *testing.T) {
+	resources := repository.PackageResources{
+		Contents: map[string]string{
+			"Kptfile": "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+		},
+	}
+	pr := &fake.PackageRevision{Name: "repo-app-v1"}
+	other := &fake.PackageRevision{Name: "repo-other-v1"}
+
+	cad := &cadEngine{}
+	if err := WithResultHistory(2).apply(cad); err != nil {
+		t.Fatalf("WithResultHistory failed: %v", err)
+	}
+
+	images := []string{"gcr.io/kpt-fn/a:v1", "gcr.io/kpt-fn/b:v1", "gcr.io/kpt-fn/c:v1"}
+	for _, image := range images {
+		render := &renderPackageMutation{
+			renderer: &resultsRenderer{results: fnresult.ResultList{Items: []fnresult.Result{{Image: image}}}},
+		}
+		if _, _, err := render.Apply(context.Background(), resources); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		cad.recordResults(pr, render)
+	}
+
+	history := cad.ResultHistory(pr)
+	if got, want := len(history), 2; got != want {
+		t.Fatalf("ResultHistory: got %d entries, want %d", got, want)
+	}
+	for i, want := range images[1:] {
+		if got := history[i].Results.Items[0].Image; got != want {
+			t.Errorf("ResultHistory[%d]: got results of %q, want %q", i, got, want)
+		}
+		if history[i].Time.IsZero() {
+			t.Errorf("ResultHistory[%d] has no time", i)
+		}
+	}
+	if history[0].Time.After(history[1].Time) {
+		t.Errorf("ResultHistory is not ordered oldest first")
+	}
+	if got := cad.ResultHistory(other); len(got) != 0 {
+		t.Errorf("ResultHistory of unrendered package revision: got %d entries, want 0", len(got))
+	}
+
+	// Without result history, nothing is recorded.
+	disabled := &cadEngine{}
+	disabled.recordResults(pr, &renderPackageMutation{results: &fnresult.ResultList{}})
+	if got := disabled.ResultHistory(pr); len(got) != 0 {
+		t.Errorf("ResultHistory with history disabled: got %d entries, want 0", len(got))
+	}
+}
+
+func TestResultHistoryOfDeletedPackageRevision(t *testing.T) {
+	ctx := context.Background()
+	tarfile := filepath.Join("..", "git", "testdata", "nested-repository.tar")
+	_, address := git.ServeGitRepository(t, tarfile, t.TempDir())
+
+	repositoryObj := &configapi.Repository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "results",
+			Namespace: "default",
+		},
+		Spec: configapi.RepositorySpec{
+			Type:    configapi.RepositoryTypeGit,
+			Content: configapi.RepositoryContentPackage,
+			Git: &configapi.GitRepository{
+				Repo: address,
+			},
+		},
+	}
+	cad := &cadEngine{cache: cache.NewCache(t.TempDir(), cache.CacheOptions{})}
+	if err := WithResultHistory(1).apply(cad); err != nil {
+		t.Fatalf("WithResultHistory failed: %v", err)
+	}
+
+	repo, err := cad.OpenRepository(ctx, repositoryObj)
+	if err != nil {
+		t.Fatalf("OpenRepository failed: %v", err)
+	}
+	revisions, err := repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
+	if err != nil || len(revisions) < 2 {
+		t.Fatalf("ListPackageRevisions returned %d revisions, error %v; want at least 2", len(revisions), err)
+	}
+	deleted, kept := revisions[0], revisions[1]
+	for _, pr := range []repository.PackageRevision{deleted, kept} {
+		cad.recordResults(pr, &renderPackageMutation{results: &fnresult.ResultList{}})
+	}
+
+	if err := cad.DeletePackageRevision(ctx, repositoryObj, deleted); err != nil {
+		t.Fatalf("DeletePackageRevision failed: %v", err)
+	}
+	if got := cad.ResultHistory(deleted); len(got) != 0 {
+		t.Errorf("ResultHistory of deleted package revision: got %d entries, want 0", len(got))
+	}
+	if got := cad.ResultHistory(kept); len(got) != 1 {
+		t.Errorf("ResultHistory of other package revision: got %d entries, want 1", len(got))
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy controls how function evaluations which fail with a transient error are retried.
+// Functions which reject their input fail immediately.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a function is run; values below 2 disable retries.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. The delay doubles with every further retry.
+	Backoff time.Duration
+	// MaxBackoff, if positive, caps the delay between retries.
+	MaxBackoff time.Duration
+}
+
+// RetryableError can be implemented by errors of function runners to report whether the failure
+// is transient, and running the function again may succeed.
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+// isRetryableFunctionError reports whether the function run failed with a transient error.
+func isRetryableFunctionError(err error) bool {
+	var retryable RetryableError
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+	// Failures to reach the function runner, or of the function runner to start the function
+	// (such as image pull failures), are reported as gRPC status codes.
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		switch grpcErr.GRPCStatus().Code() {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the delay before the given retry, starting at 1.
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// run calls f until it succeeds, fails with an error which f reports as not retryable, or the
+// attempts are exhausted. It returns the number of attempts made.
+func (p *RetryPolicy) run(ctx context.Context, f func() (retryable bool, err error)) (int, error) {
+	attempts := 1
+	for {
+		retryable, err := f()
+		if err == nil || !retryable || p == nil || attempts >= p.MaxAttempts {
+			return attempts, err
+		}
+		timer := time.NewTimer(p.delay(attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempts, err
+		case <-timer.C:
+		}
+		attempts++
+	}
+}
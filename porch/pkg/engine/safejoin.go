@@ -30,7 +30,7 @@ func filepathSafeJoin(dir string, relative string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("invalid relative path %q", relative)
 	}
-	if rel != relative || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || strings.HasPrefix(rel, "."+string(filepath.Separator)) {
+	if rel != relative || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || strings.HasPrefix(rel, "."+string(filepath.Separator)) {
 		return "", fmt.Errorf("invalid relative path %q", relative)
 	}
 	return p, nil
@@ -63,6 +63,11 @@ func TestSafeJoin(t *testing.T) {
 			relative:  "../foo",
 			wantError: true,
 		},
+		{
+			base:      "tmp/",
+			relative:  "..",
+			wantError: true,
+		},
 		{
 			base:      "tmp/",
 			relative:  "a/../foo",
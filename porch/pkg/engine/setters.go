@@ -0,0 +1,200 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SetterValuesSource provides setter values from outside of the packages, such as the values of
+// an environment, which override the values of the apply-setters functions of packages when they
+// are rendered.
+type SetterValuesSource interface {
+	// SetterValues returns the setter values for rendering the package revision, which belongs to
+	// a repository in the namespace. Returning no values leaves the package unchanged.
+	SetterValues(ctx context.Context, namespace string, key repository.PackageRevisionKey) (map[string]string, error)
+}
+
+// SetterValuesSourceFunc is an adapter allowing use of a function as a SetterValuesSource.
+type SetterValuesSourceFunc func(ctx context.Context, namespace string, key repository.PackageRevisionKey) (map[string]string, error)
+
+var _ SetterValuesSource = SetterValuesSourceFunc(nil)
+
+func (f SetterValuesSourceFunc) SetterValues(ctx context.Context, namespace string, key repository.PackageRevisionKey) (map[string]string, error) {
+	return f(ctx, namespace, key)
+}
+
+// NewConfigMapSetterValues returns a SetterValuesSource which reads the setter values from the
+// data of the ConfigMap with the name, in the namespace of the repository of the package revision.
+func NewConfigMapSetterValues(resolver ReferenceResolver, name string) SetterValuesSource {
+	return SetterValuesSourceFunc(func(ctx context.Context, namespace string, key repository.PackageRevisionKey) (map[string]string, error) {
+		var configMap corev1.ConfigMap
+		if err := resolver.ResolveReference(ctx, namespace, name, &configMap); err != nil {
+			return nil, fmt.Errorf("cannot resolve setter values ConfigMap %s/%s: %w", namespace, name, err)
+		}
+		return configMap.Data, nil
+	})
+}
+
+// NewFileSetterValues returns a SetterValuesSource which reads the setter values from the YAML
+// file, either a map of setter names to values or a ConfigMap with the values in its data. The file
+// is read for every render, so changes apply without restarting.
+func NewFileSetterValues(filename string) SetterValuesSource {
+	return SetterValuesSourceFunc(func(ctx context.Context, namespace string, key repository.PackageRevisionKey) (map[string]string, error) {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read setter values: %w", err)
+		}
+		node, err := yaml.Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse setter values file %q: %w", filename, err)
+		}
+		if node.GetKind() == "ConfigMap" {
+			return node.GetDataMap(), nil
+		}
+		var values map[string]string
+		if err := node.YNode().Decode(&values); err != nil {
+			return nil, fmt.Errorf("cannot parse setter values file %q: %w", filename, err)
+		}
+		return values, nil
+	})
+}
+
+// setterValues returns the setter values for rendering the package revision, if there is a source
+// of setter values.
+func (cad *cadEngine) setterValues(ctx context.Context, namespace string, key repository.PackageRevisionKey) (map[string]string, error) {
+	if cad.setterValuesSource == nil {
+		return nil, nil
+	}
+	values, err := cad.setterValuesSource.SetterValues(ctx, namespace, key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get setter values for package %q: %w", key.Package, err)
+	}
+	return values, nil
+}
+
+// injectSetterValues merges the setter values into the configs of the apply-setters functions in
+// the pipelines of all Kptfiles, inline or in the files referenced by configPath, overriding the
+// values of the package. It returns the contents of the files it changed before the change.
+func injectSetterValues(contents *cowContents, values map[string]string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var kptfiles []string
+	for name := range contents.result() {
+		if path.Base(name) == kptfilev1.KptFileName {
+			kptfiles = append(kptfiles, name)
+		}
+	}
+	sort.Strings(kptfiles)
+
+	original := map[string]string{}
+	update := func(name string, node *yaml.RNode) error {
+		updated, err := node.String()
+		if err != nil {
+			return err
+		}
+		old, _ := contents.get(name)
+		if old == updated {
+			return nil
+		}
+		if _, ok := original[name]; !ok {
+			original[name] = old
+		}
+		contents.set(name, updated)
+		return nil
+	}
+
+	for _, kptfileName := range kptfiles {
+		kptfileContents, _ := contents.get(kptfileName)
+		kptfile, err := yaml.Parse(kptfileContents)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", kptfileName, err)
+		}
+		mutators, err := kptfile.Pipe(yaml.Lookup("pipeline", "mutators"))
+		if err != nil || mutators == nil {
+			continue
+		}
+		elements, err := mutators.Elements()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read pipeline of %s: %w", kptfileName, err)
+		}
+		changed := false
+		for _, function := range elements {
+			if image, _ := function.GetString("image"); !isApplySetters(image) {
+				continue
+			}
+			if configPath, _ := function.GetString("configPath"); configPath != "" {
+				configName := path.Join(path.Dir(kptfileName), configPath)
+				configContents, ok := contents.get(configName)
+				if !ok {
+					return nil, fmt.Errorf("cannot find apply-setters config %q of %s", configPath, kptfileName)
+				}
+				config, err := yaml.Parse(configContents)
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse apply-setters config %q: %w", configName, err)
+				}
+				data := config.GetDataMap()
+				for k, v := range values {
+					data[k] = v
+				}
+				config.SetDataMap(data)
+				if err := update(configName, config); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			configMap, err := function.Pipe(yaml.LookupCreate(yaml.MappingNode, "configMap"))
+			if err != nil {
+				return nil, fmt.Errorf("cannot set apply-setters config of %s: %w", kptfileName, err)
+			}
+			for k, v := range values {
+				if err := configMap.PipeE(yaml.SetField(k, yaml.NewStringRNode(v))); err != nil {
+					return nil, fmt.Errorf("cannot set apply-setters config of %s: %w", kptfileName, err)
+				}
+			}
+			changed = true
+		}
+		// Only write back Kptfiles with inline configs, since writing may reformat them.
+		if changed {
+			if err := update(kptfileName, kptfile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return original, nil
+}
+
+// isApplySetters returns true if the function image is apply-setters, of any registry or version.
+func isApplySetters(image string) bool {
+	name := image
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.IndexAny(name, ":@"); i >= 0 {
+		name = name[:i]
+	}
+	return name == "apply-setters"
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/porch/pkg/kpt"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRenderSetterValues(t *testing.T) {
+	const bucket = "apiVersion: storage.cnrm.cloud.google.com/v1beta1\n" +
+		"kind: StorageBucket\n" +
+		"metadata:\n" +
+		"  name: bucket # kpt-set: ${name}\n" +
+		"  namespace: default # kpt-set: ${namespace}\n"
+
+	for _, tc := range []struct {
+		name     string
+		contents map[string]string
+	}{
+		{
+			name: "inline config",
+			contents: map[string]string{
+				"Kptfile": "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: bucket\npipeline:\n  mutators:\n" +
+					"  - image: gcr.io/kpt-fn/apply-setters:v0.2.0\n    configMap:\n      name: bucket\n      namespace: default\n",
+				"bucket.yaml": bucket,
+			},
+		},
+		{
+			name: "config path",
+			contents: map[string]string{
+				"Kptfile": "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: bucket\npipeline:\n  mutators:\n" +
+					"  - image: gcr.io/kpt-fn/apply-setters:v0.2.0\n    configPath: setters.yaml\n",
+				"setters.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: setters\n" +
+					"  annotations:\n    config.kubernetes.io/local-config: \"true\"\ndata:\n  name: bucket\n  namespace: default\n",
+				"bucket.yaml": bucket,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			render := &renderPackageMutation{
+				renderer:     kpt.NewRenderer(),
+				runtime:      kpt.NewSimpleFunctionRuntime(),
+				setterValues: map[string]string{"namespace": "production"},
+			}
+			rendered, _, err := render.Apply(context.Background(), repository.PackageResources{Contents: tc.contents})
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			got := rendered.Contents["bucket.yaml"]
+			if !strings.Contains(got, "namespace: production # kpt-set: ${namespace}") {
+				t.Errorf("Injected setter value was not applied:\n%s", got)
+			}
+			if !strings.Contains(got, "name: bucket # kpt-set: ${name}") {
+				t.Errorf("Setter value of the package was not applied:\n%s", got)
+			}
+			// The setter configs of the package are unchanged.
+			for _, name := range []string{"Kptfile", "setters.yaml"} {
+				if diff := cmp.Diff(tc.contents[name], rendered.Contents[name]); diff != "" {
+					t.Errorf("Unexpected %s (-want, +got): %s", name, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestInjectSetterValuesMissingConfig(t *testing.T) {
+	contents := newCOWContents(map[string]string{
+		"Kptfile": "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\npipeline:\n  mutators:\n" +
+			"  - image: apply-setters:v0.2\n    configPath: missing.yaml\n",
+	})
+	if _, err := injectSetterValues(contents, map[string]string{"name": "app"}); err == nil {
+		t.Errorf("injectSetterValues succeeded unexpectedly with a missing config file")
+	}
+}
+
+func TestFileSetterValues(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"values.yaml":    "name: app\nnamespace: production\n",
+		"configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: values\ndata:\n  name: app\n  namespace: production\n",
+	} {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		values, err := NewFileSetterValues(filename).SetterValues(context.Background(), "default", repository.PackageRevisionKey{})
+		if err != nil {
+			t.Fatalf("SetterValues of %s failed: %v", name, err)
+		}
+		if diff := cmp.Diff(map[string]string{"name": "app", "namespace": "production"}, values); diff != "" {
+			t.Errorf("Unexpected setter values of %s (-want, +got): %s", name, diff)
+		}
+	}
+}
+
+func TestIsApplySetters(t *testing.T) {
+	for image, want := range map[string]bool{
+		"gcr.io/kpt-fn/apply-setters:v0.2.0":  true,
+		"apply-setters":                       true,
+		"example.com/apply-setters@sha256:00": true,
+		"gcr.io/kpt-fn/set-namespace:v0.4":    false,
+		"gcr.io/kpt-fn/apply-setters-x:v0.1":  false,
+	} {
+		if got := isApplySetters(image); got != want {
+			t.Errorf("isApplySetters(%q): got %t, want %t", image, got, want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+// PackageSummary gives statistics of the resources of a package revision, for listing views which
+// do not load the resources of every package revision.
+type PackageSummary struct {
+	// Files is the number of files of the package.
+	Files int
+	// Bytes is the total size of the contents of the files.
+	Bytes int64
+	// Resources is the number of KRM resources in the YAML files of the package, including local
+	// configuration. Files which cannot be parsed are not counted.
+	Resources int
+	// Kinds is the number of resources of each kind, keyed by kind and group, such as
+	// "Deployment.apps", or kind alone for the core group.
+	Kinds map[string]int
+}
+
+// summarizePackage computes the summary of the package resources.
+func summarizePackage(resources map[string]string) *PackageSummary {
+	summary := &PackageSummary{Kinds: map[string]int{}}
+	for k, v := range resources {
+		summary.Files++
+		summary.Bytes += int64(len(v))
+		if ext := path.Ext(k); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		nodes, err := (&kio.ByteReader{Reader: strings.NewReader(v), OmitReaderAnnotations: true}).Read()
+		if err != nil {
+			continue
+		}
+		for _, node := range nodes {
+			summary.Resources++
+			summary.Kinds[schema.FromAPIVersionAndKind(node.GetApiVersion(), node.GetKind()).GroupKind().String()]++
+		}
+	}
+	return summary
+}
+
+// summaryStore keeps the summary of each package revision, computed from the resources of the
+// revision at the resource version it was computed for.
+type summaryStore struct {
+	mutex   sync.Mutex
+	entries map[string]summaryEntry
+}
+
+type summaryEntry struct {
+	resourceVersion string
+	summary         *PackageSummary
+}
+
+func (s *summaryStore) get(name, resourceVersion string) (*PackageSummary, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[name]
+	if !ok || entry.resourceVersion != resourceVersion {
+		return nil, false
+	}
+	return entry.summary, true
+}
+
+func (s *summaryStore) put(name, resourceVersion string, summary *PackageSummary) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.entries == nil {
+		s.entries = map[string]summaryEntry{}
+	}
+	s.entries[name] = summaryEntry{resourceVersion: resourceVersion, summary: summary}
+}
+
+func (s *summaryStore) remove(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.entries, name)
+}
+
+// PackageSummary returns the summary of the resources of the package revision. Summaries are
+// computed when package revisions are closed, and otherwise on first use; they are held in memory
+// and recomputed if the package revision changes. The returned summary must not be modified.
+func (cad *cadEngine) PackageSummary(ctx context.Context, pr repository.PackageRevision) (*PackageSummary, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::PackageSummary", trace.WithAttributes())
+	defer span.End()
+
+	name, resourceVersion := pr.KubeObjectName(), pr.GetPackageRevision().ResourceVersion
+	if summary, ok := cad.summaries.get(name, resourceVersion); ok {
+		return summary, nil
+	}
+	resources, err := pr.GetResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot summarize package revision %q: %w", name, err)
+	}
+	summary := summarizePackage(resources.Spec.Resources)
+	cad.summaries.put(name, resourceVersion, summary)
+	return summary, nil
+}
+
+// recordSummary computes the summary of the closed package revision. Failing to compute it only
+// defers the computation to the first use of the summary.
+func (cad *cadEngine) recordSummary(ctx context.Context, pr repository.PackageRevision) {
+	if _, err := cad.PackageSummary(ctx, pr); err != nil {
+		klog.Warningf("%v", err)
+	}
+}
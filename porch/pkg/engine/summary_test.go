@@ -0,0 +1,100 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"testing"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSummarizePackage(t *testing.T) {
+	resources := map[string]string{
+		"Kptfile":   "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+		"README.md": "# app\n",
+		"app.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n---\n" +
+			"apiVersion: v1\nkind: Service\nmetadata:\n  name: app\n",
+		"config.yml":   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n",
+		"invalid.yaml": "{",
+	}
+	var bytes int64
+	for _, v := range resources {
+		bytes += int64(len(v))
+	}
+
+	want := &PackageSummary{
+		Files:     5,
+		Bytes:     bytes,
+		Resources: 4,
+		Kinds:     map[string]int{"Deployment.apps": 1, "Service": 1, "ConfigMap": 2},
+	}
+	if diff := cmp.Diff(want, summarizePackage(resources)); diff != "" {
+		t.Errorf("Unexpected summary (-want, +got): %s", diff)
+	}
+}
+
+// countingResources counts the reads of the resources of a package revision.
+type countingResources struct {
+	fake.PackageRevision
+	reads int
+}
+
+func (pr *countingResources) GetResources(ctx context.Context) (*api.PackageRevisionResources, error) {
+	pr.reads++
+	return pr.PackageRevision.GetResources(ctx)
+}
+
+func TestPackageSummary(t *testing.T) {
+	pr := &countingResources{PackageRevision: fake.PackageRevision{
+		Name:            "repo-app-v1",
+		PackageRevision: &api.PackageRevision{},
+		Resources: &api.PackageRevisionResources{Spec: api.PackageRevisionResourcesSpec{Resources: map[string]string{
+			"cm.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		}}},
+	}}
+	pr.PackageRevision.PackageRevision.ResourceVersion = "1"
+	cad := &cadEngine{}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		summary, err := cad.PackageSummary(ctx, pr)
+		if err != nil {
+			t.Fatalf("PackageSummary failed: %v", err)
+		}
+		if got, want := summary.Resources, 1; got != want {
+			t.Errorf("Summary resources: got %d, want %d", got, want)
+		}
+	}
+	if got, want := pr.reads, 1; got != want {
+		t.Errorf("Resources read %d times; want %d", got, want)
+	}
+
+	// A changed package revision is summarized again.
+	pr.Resources.Spec.Resources["cm2.yaml"] = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm2\n"
+	pr.PackageRevision.PackageRevision.ResourceVersion = "2"
+	summary, err := cad.PackageSummary(ctx, pr)
+	if err != nil {
+		t.Fatalf("PackageSummary failed: %v", err)
+	}
+	if got, want := summary.Kinds["ConfigMap"], 2; got != want {
+		t.Errorf("Summary ConfigMaps after change: got %d, want %d", got, want)
+	}
+	if got, want := pr.reads, 2; got != want {
+		t.Errorf("Resources read %d times; want %d", got, want)
+	}
+}
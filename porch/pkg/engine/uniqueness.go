@@ -0,0 +1,90 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+// DuplicateResource is a resource identity shared by several resources of a package.
+type DuplicateResource struct {
+	// Identity is the group, version and kind, namespace and name of the resources.
+	Identity string
+	// Files are the files of the resources, sorted; a file appears once per resource.
+	Files []string
+}
+
+// DuplicateResourcesError is returned when the rendered package has several resources with the
+// same identity, which cannot all be applied.
+type DuplicateResourcesError struct {
+	// Duplicates are the duplicated identities, sorted by identity.
+	Duplicates []DuplicateResource
+}
+
+func (e *DuplicateResourcesError) Error() string {
+	var lines []string
+	for _, d := range e.Duplicates {
+		lines = append(lines, fmt.Sprintf("%s in files %s", d.Identity, strings.Join(d.Files, ", ")))
+	}
+	return fmt.Sprintf("package has %d duplicated resource(s):\n%s", len(e.Duplicates), strings.Join(lines, "\n"))
+}
+
+// checkResourceUniqueness fails with a *DuplicateResourcesError if several resources of the
+// package have the same group, version, kind, namespace and name. Local configuration, including
+// Kptfiles, is not applied and is not checked; nor are files which are not YAML or cannot be parsed.
+func checkResourceUniqueness(resources repository.PackageResources) error {
+	files := map[string][]string{}
+	for k, v := range resources.Contents {
+		if ext := path.Ext(k); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		if path.Base(normalizeResourcePath(k)) == kptfilev1.KptFileName {
+			continue
+		}
+		nodes, err := (&kio.ByteReader{Reader: strings.NewReader(v), OmitReaderAnnotations: true}).Read()
+		if err != nil {
+			continue
+		}
+		for _, node := range nodes {
+			if isLocalConfig(node) {
+				continue
+			}
+			id := fmt.Sprintf("%s %s namespace=%q name=%q", node.GetApiVersion(), node.GetKind(), node.GetNamespace(), node.GetName())
+			files[id] = append(files[id], k)
+		}
+	}
+
+	var duplicates []DuplicateResource
+	for id, f := range files {
+		if len(f) > 1 {
+			sort.Strings(f)
+			duplicates = append(duplicates, DuplicateResource{Identity: id, Files: f})
+		}
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].Identity < duplicates[j].Identity
+	})
+	return &DuplicateResourcesError{Duplicates: duplicates}
+}
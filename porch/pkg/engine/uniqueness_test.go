@@ -0,0 +1,85 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckResourceUniqueness(t *testing.T) {
+	const cm = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n  namespace: app\n"
+	const local = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: local\n  annotations:\n    config.kubernetes.io/local-config: \"true\"\n"
+
+	for _, tc := range []struct {
+		name     string
+		contents map[string]string
+		want     []DuplicateResource
+	}{
+		{
+			name: "unique",
+			contents: map[string]string{
+				"cm.yaml":    cm,
+				"other.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n  namespace: other\n",
+				"secret.yml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: cm\n  namespace: app\n",
+			},
+		},
+		{
+			name: "duplicated across files",
+			contents: map[string]string{
+				"a.yaml": cm,
+				"b.yaml": cm,
+			},
+			want: []DuplicateResource{{Identity: `v1 ConfigMap namespace="app" name="cm"`, Files: []string{"a.yaml", "b.yaml"}}},
+		},
+		{
+			name: "duplicated within a file",
+			contents: map[string]string{
+				"all.yaml": cm + "---\n" + cm,
+			},
+			want: []DuplicateResource{{Identity: `v1 ConfigMap namespace="app" name="cm"`, Files: []string{"all.yaml", "all.yaml"}}},
+		},
+		{
+			name: "local config and Kptfiles",
+			contents: map[string]string{
+				"Kptfile":     "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+				"sub/Kptfile": "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n",
+				"a.yaml":      local,
+				"b.yaml":      local,
+				"README.md":   cm + "---\n" + cm,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkResourceUniqueness(repository.PackageResources{Contents: tc.contents})
+			if tc.want == nil {
+				if err != nil {
+					t.Errorf("checkResourceUniqueness failed: %v", err)
+				}
+				return
+			}
+			var duplicates *DuplicateResourcesError
+			if !errors.As(err, &duplicates) {
+				t.Fatalf("checkResourceUniqueness returned %v; want DuplicateResourcesError", err)
+			}
+			if !cmp.Equal(tc.want, duplicates.Duplicates) {
+				t.Errorf("Unexpected duplicates (-want,+got): %s", cmp.Diff(tc.want, duplicates.Duplicates))
+			}
+		})
+	}
+}
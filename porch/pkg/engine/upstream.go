@@ -0,0 +1,268 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/git"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/kpt"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/mod/semver"
+)
+
+// UpstreamUpdate describes whether the upstream of a cloned package has a newer published revision.
+type UpstreamUpdate struct {
+	// CurrentRevision is the upstream revision the package was cloned from.
+	CurrentRevision string
+	// LatestRevision is the latest published revision of the upstream package, or empty if there is none.
+	LatestRevision string
+	// Available is true if LatestRevision is newer than CurrentRevision.
+	Available bool
+}
+
+func (cad *cadEngine) CheckUpstreamUpdate(ctx context.Context, pr repository.PackageRevision) (*UpstreamUpdate, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::CheckUpstreamUpdate", trace.WithAttributes())
+	defer span.End()
+
+	obj := pr.GetPackageRevision()
+	if len(obj.Spec.Tasks) == 0 || obj.Spec.Tasks[0].Type != api.TaskTypeClone || obj.Spec.Tasks[0].Clone == nil {
+		return nil, fmt.Errorf("package revision %q was not cloned from an upstream package", pr.KubeObjectName())
+	}
+	upstream := obj.Spec.Tasks[0].Clone.Upstream
+
+	if ref := upstream.UpstreamRef; ref != nil {
+		if err := checkDependencyCycles(ctx, &PackageFetcher{cad: cad, referenceResolver: cad.referenceResolver}, pr); err != nil {
+			return nil, err
+		}
+		current, revisions, err := cad.listRegisteredUpstreamRevisions(ctx, ref, obj.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		// The cache labels the latest revision, ordering the revisions with the comparator of the
+		// upstream repository.
+		latest := labeledLatestRevision(revisions)
+		return &UpstreamUpdate{
+			CurrentRevision: current,
+			LatestRevision:  latest,
+			Available:       latest != "" && latest != current,
+		}, nil
+	}
+
+	gitPackage := upstream.Git
+	if gitPackage == nil {
+		return nil, fmt.Errorf("upstream of package revision %q is not a registered or git package", pr.KubeObjectName())
+	}
+	current, revisions, err := cad.listGitUpstreamRevisions(ctx, gitPackage)
+	if err != nil {
+		return nil, err
+	}
+	latest := latestPublishedRevision(revisions)
+	return &UpstreamUpdate{
+		CurrentRevision: current,
+		LatestRevision:  latest,
+		Available:       latest != "" && semver.IsValid(current) && semver.Compare(latest, current) > 0,
+	}, nil
+}
+
+// DiffUpstream compares the resources of a cloned package revision with the exact upstream
+// revision it was cloned from. The result describes the local modifications as patches, sorted
+// by file, which transform the upstream resources into the package revision resources.
+func (cad *cadEngine) DiffUpstream(ctx context.Context, pr repository.PackageRevision) ([]api.PatchSpec, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::DiffUpstream", trace.WithAttributes())
+	defer span.End()
+
+	obj := pr.GetPackageRevision()
+	if len(obj.Spec.Tasks) == 0 || obj.Spec.Tasks[0].Type != api.TaskTypeClone || obj.Spec.Tasks[0].Clone == nil {
+		return nil, fmt.Errorf("package revision %q was not cloned from an upstream package", pr.KubeObjectName())
+	}
+
+	task := obj.Spec.Tasks[0].DeepCopy()
+	// Extra files are local additions.
+	task.Clone.ExtraFiles = nil
+	// Fetch the commit which was cloned, even if the ref moved since.
+	if gitPackage := task.Clone.Upstream.Git; gitPackage != nil && gitPackage.Commit != "" {
+		gitPackage.Ref = gitPackage.Commit
+	}
+
+	clone := &clonePackageMutation{
+		task:               task,
+		namespace:          obj.Namespace,
+		name:               obj.Spec.PackageName,
+		cad:                cad,
+		credentialResolver: cad.credentialResolver,
+		referenceResolver:  cad.referenceResolver,
+		localUpstreamRoot:  cad.localUpstreamRoot,
+	}
+	upstream, _, err := clone.Apply(ctx, repository.PackageResources{Contents: map[string]string{}})
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch upstream of package revision %q: %w", pr.KubeObjectName(), err)
+	}
+
+	// Record the same upstream in the Kptfile so that only local modifications differ.
+	upstreamInfo, lock, err := pr.GetUpstreamLock()
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine upstream lock of package revision %q: %w", pr.KubeObjectName(), err)
+	}
+	if err := kpt.UpdateKptfileUpstream(obj.Spec.PackageName, upstream.Contents, upstreamInfo, lock); err != nil {
+		return nil, err
+	}
+
+	resources, err := pr.GetResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get package resources: %w", err)
+	}
+
+	return diffResources(upstream.Contents, resources.Spec.Resources)
+}
+
+// diffResources returns the patches, sorted by file, which transform the old resources into the new ones.
+func diffResources(old, new map[string]string) ([]api.PatchSpec, error) {
+	var patches []api.PatchSpec
+	for k, newV := range new {
+		oldV, ok := old[k]
+		switch {
+		case !ok:
+			patches = append(patches, api.PatchSpec{
+				File:      k,
+				PatchType: api.PatchTypeCreateFile,
+				Contents:  newV,
+			})
+		case oldV != newV:
+			patch, err := GeneratePatch(k, oldV, newV)
+			if err != nil {
+				return nil, fmt.Errorf("error generating patch: %w", err)
+			}
+			patches = append(patches, patch)
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			patches = append(patches, api.PatchSpec{
+				File:      k,
+				PatchType: api.PatchTypeDeleteFile,
+			})
+		}
+	}
+	sort.Slice(patches, func(i, j int) bool {
+		return patches[i].File < patches[j].File
+	})
+	return patches, nil
+}
+
+// listRegisteredUpstreamRevisions returns the cloned revision and all revisions of an upstream package in a registered repository.
+func (cad *cadEngine) listRegisteredUpstreamRevisions(ctx context.Context, ref *api.PackageRevisionRef, namespace string) (string, []repository.PackageRevision, error) {
+	repositoryName, err := parseUpstreamRepository(ref.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	var resolved configapi.Repository
+	if err := cad.referenceResolver.ResolveReference(ctx, namespace, repositoryName, &resolved); err != nil {
+		return "", nil, fmt.Errorf("cannot find repository %s/%s: %w", namespace, repositoryName, err)
+	}
+
+	repo, err := cad.OpenRepository(ctx, &resolved)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cloned, err := repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{KubeObjectName: ref.Name})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(cloned) == 0 {
+		return "", nil, fmt.Errorf("cannot find upstream package revision %q", ref.Name)
+	}
+	key := cloned[0].Key()
+
+	revisions, err := repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: key.Package})
+	if err != nil {
+		return "", nil, err
+	}
+	return key.Revision, revisions, nil
+}
+
+// listGitUpstreamRevisions returns the cloned revision and all revisions of an upstream package in an unregistered git repository.
+func (cad *cadEngine) listGitUpstreamRevisions(ctx context.Context, gitPackage *api.GitPackage) (string, []repository.PackageRevision, error) {
+	dir, err := ioutil.TempDir("", "check-git-upstream-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot create temporary directory to clone Git repository: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := git.OpenRepository(ctx, "", "", &configapi.GitRepository{
+		Repo:      gitPackage.Repo,
+		Branch:    gitPackage.Branch,
+		Directory: gitPackage.Directory,
+		SecretRef: configapi.SecretRef{
+			Name: gitPackage.SecretRef.Name,
+		},
+	}, dir, git.GitRepositoryOptions{
+		CredentialResolver:         cad.credentialResolver,
+		SkipMainBranchVerification: true, // We are only reading so we don't need the main branch to exist.
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot clone Git repository: %w", err)
+	}
+
+	packagePath := strings.Trim(gitPackage.Directory, "/")
+	revisions, err := r.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: packagePath})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Tags of package revisions are named <package path>/<revision>.
+	current := strings.TrimPrefix(gitPackage.Ref, "refs/tags/")
+	current = strings.TrimPrefix(current, packagePath+"/")
+	return current, revisions, nil
+}
+
+// labeledLatestRevision returns the revision labeled as the latest by the cache, or empty if there is none.
+func labeledLatestRevision(revisions []repository.PackageRevision) string {
+	for _, rev := range revisions {
+		if rev.GetPackageRevision().Labels[api.LatestPackageRevisionKey] == api.LatestPackageRevisionValue {
+			return rev.Key().Revision
+		}
+	}
+	return ""
+}
+
+// latestPublishedRevision returns the highest semantic version among the published revisions, or
+// empty if there is none. Unregistered git repositories have no comparator, so their revisions
+// are ordered as semantic versions.
+func latestPublishedRevision(revisions []repository.PackageRevision) string {
+	var latest string
+	for _, rev := range revisions {
+		if rev.Lifecycle() != api.PackageRevisionLifecyclePublished {
+			continue
+		}
+		revision := rev.Key().Revision
+		if !semver.IsValid(revision) {
+			continue
+		}
+		if latest == "" || semver.Compare(revision, latest) > 0 {
+			latest = revision
+		}
+	}
+	return latest
+}
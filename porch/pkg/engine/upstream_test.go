@@ -0,0 +1,262 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	internalpkg "github.com/GoogleContainerTools/kpt/internal/pkg"
+	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/cache"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/git"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/mod/semver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckUpstreamUpdateGit(t *testing.T) {
+	tarfile := filepath.Join("..", "git", "testdata", "nested-repository.tar")
+	_, address := git.ServeGitRepository(t, tarfile, t.TempDir())
+
+	for _, tc := range []struct {
+		ref  string
+		want UpstreamUpdate
+	}{
+		{
+			ref:  "catalog/namespace/basens/v1",
+			want: UpstreamUpdate{CurrentRevision: "v1", LatestRevision: "v3", Available: true},
+		},
+		{
+			ref:  "catalog/namespace/basens/v3",
+			want: UpstreamUpdate{CurrentRevision: "v3", LatestRevision: "v3", Available: false},
+		},
+	} {
+		t.Run(tc.ref, func(t *testing.T) {
+			pr := &fake.PackageRevision{
+				Name: "downstream",
+				PackageRevision: &v1alpha1.PackageRevision{
+					Spec: v1alpha1.PackageRevisionSpec{
+						Tasks: []v1alpha1.Task{
+							{
+								Type: v1alpha1.TaskTypeClone,
+								Clone: &v1alpha1.PackageCloneTaskSpec{
+									Upstream: v1alpha1.UpstreamPackage{
+										Type: v1alpha1.RepositoryTypeGit,
+										Git: &v1alpha1.GitPackage{
+											Repo:      address,
+											Ref:       tc.ref,
+											Directory: "catalog/namespace/basens",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			got, err := (&cadEngine{}).CheckUpstreamUpdate(context.Background(), pr)
+			if err != nil {
+				t.Fatalf("CheckUpstreamUpdate failed: %v", err)
+			}
+			if !cmp.Equal(&tc.want, got) {
+				t.Errorf("CheckUpstreamUpdate result differs (-want,+got): %s", cmp.Diff(&tc.want, got))
+			}
+		})
+	}
+}
+
+// repositoryResolver resolves references to the Repository resources it holds, by name.
+type repositoryResolver map[string]*configapi.Repository
+
+func (r repositoryResolver) ResolveReference(_ context.Context, _, name string, result Object) error {
+	repo, ok := r[name]
+	if !ok {
+		return fmt.Errorf("repository %q not found", name)
+	}
+	repo.DeepCopyInto(result.(*configapi.Repository))
+	return nil
+}
+
+// newestFirstComparator orders semantic versions in reverse.
+type newestFirstComparator struct{}
+
+func (newestFirstComparator) IsValid(revision string) bool { return semver.IsValid(revision) }
+func (newestFirstComparator) Compare(a, b string) int      { return semver.Compare(b, a) }
+
+func TestCheckUpstreamUpdateRegistered(t *testing.T) {
+	ctx := context.Background()
+	tarfile := filepath.Join("..", "git", "testdata", "nested-repository.tar")
+	_, address := git.ServeGitRepository(t, tarfile, t.TempDir())
+
+	blueprints := &configapi.Repository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "blueprints",
+			Namespace: "default",
+		},
+		Spec: configapi.RepositorySpec{
+			Type:    configapi.RepositoryTypeGit,
+			Content: configapi.RepositoryContentPackage,
+			Git: &configapi.GitRepository{
+				Repo: address,
+			},
+			RevisionComparator: "newest-first",
+		},
+	}
+	cad := &cadEngine{
+		cache: cache.NewCache(t.TempDir(), cache.CacheOptions{
+			RevisionComparators: map[string]cache.RevisionComparator{"newest-first": newestFirstComparator{}},
+		}),
+		referenceResolver: repositoryResolver{"blueprints": blueprints},
+	}
+
+	repo, err := cad.OpenRepository(ctx, blueprints)
+	if err != nil {
+		t.Fatalf("OpenRepository failed: %v", err)
+	}
+	revisions, err := repo.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{Package: "catalog/namespace/basens", Revision: "v3"})
+	if err != nil || len(revisions) != 1 {
+		t.Fatalf("ListPackageRevisions returned %d revisions, error %v; want 1", len(revisions), err)
+	}
+
+	pr := &fake.PackageRevision{
+		Name: "downstream",
+		PackageRevision: &v1alpha1.PackageRevision{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+			Spec: v1alpha1.PackageRevisionSpec{
+				Tasks: []v1alpha1.Task{
+					{
+						Type: v1alpha1.TaskTypeClone,
+						Clone: &v1alpha1.PackageCloneTaskSpec{
+							Upstream: v1alpha1.UpstreamPackage{
+								UpstreamRef: &v1alpha1.PackageRevisionRef{Name: revisions[0].KubeObjectName()},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// The comparator of the upstream repository, not semantic versioning, determines the latest revision.
+	got, err := cad.CheckUpstreamUpdate(ctx, pr)
+	if err != nil {
+		t.Fatalf("CheckUpstreamUpdate failed: %v", err)
+	}
+	want := &UpstreamUpdate{CurrentRevision: "v3", LatestRevision: "v1", Available: true}
+	if !cmp.Equal(want, got) {
+		t.Errorf("CheckUpstreamUpdate result differs (-want,+got): %s", cmp.Diff(want, got))
+	}
+}
+
+func TestCheckUpstreamUpdateNotCloned(t *testing.T) {
+	pr := &fake.PackageRevision{
+		Name: "not-cloned",
+		PackageRevision: &v1alpha1.PackageRevision{
+			Spec: v1alpha1.PackageRevisionSpec{
+				Tasks: []v1alpha1.Task{
+					{
+						Type: v1alpha1.TaskTypeInit,
+						Init: &v1alpha1.PackageInitTaskSpec{},
+					},
+				},
+			},
+		},
+	}
+	if _, err := (&cadEngine{}).CheckUpstreamUpdate(context.Background(), pr); err == nil {
+		t.Errorf("CheckUpstreamUpdate of a package without clone task succeeded unexpectedly")
+	}
+}
+
+func TestDiffUpstream(t *testing.T) {
+	ctx := context.Background()
+	tarfile := filepath.Join("..", "git", "testdata", "nested-repository.tar")
+	_, address := git.ServeGitRepository(t, tarfile, t.TempDir())
+
+	task := v1alpha1.Task{
+		Type: v1alpha1.TaskTypeClone,
+		Clone: &v1alpha1.PackageCloneTaskSpec{
+			Upstream: v1alpha1.UpstreamPackage{
+				Type: v1alpha1.RepositoryTypeGit,
+				Git: &v1alpha1.GitPackage{
+					Repo:      address,
+					Ref:       "catalog/namespace/basens/v1",
+					Directory: "catalog/namespace/basens",
+				},
+			},
+		},
+	}
+
+	// Clone the upstream and modify it locally.
+	clone := &clonePackageMutation{task: task.DeepCopy(), name: "basens"}
+	cloned, recorded, err := clone.Apply(ctx, repository.PackageResources{Contents: map[string]string{}})
+	if err != nil {
+		t.Fatalf("clone failed: %v", err)
+	}
+	kf, err := internalpkg.DecodeKptfile(strings.NewReader(cloned.Contents[kptfile.KptFileName]))
+	if err != nil {
+		t.Fatalf("cannot parse cloned Kptfile: %v", err)
+	}
+
+	var removed string
+	for k := range cloned.Contents {
+		if k != kptfile.KptFileName {
+			removed = k
+			break
+		}
+	}
+	delete(cloned.Contents, removed)
+	cloned.Contents["local.yaml"] = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: local\n"
+
+	pr := &fake.PackageRevision{
+		Name: "downstream",
+		PackageRevision: &v1alpha1.PackageRevision{
+			Spec: v1alpha1.PackageRevisionSpec{
+				PackageName: "basens",
+				Tasks:       []v1alpha1.Task{*recorded},
+			},
+		},
+		Resources: &v1alpha1.PackageRevisionResources{
+			Spec: v1alpha1.PackageRevisionResourcesSpec{Resources: cloned.Contents},
+		},
+		Upstream:     *kf.Upstream,
+		UpstreamLock: *kf.UpstreamLock,
+	}
+
+	patches, err := (&cadEngine{}).DiffUpstream(ctx, pr)
+	if err != nil {
+		t.Fatalf("DiffUpstream failed: %v", err)
+	}
+
+	got := map[string]v1alpha1.PatchType{}
+	for _, p := range patches {
+		got[p.File] = p.PatchType
+	}
+	want := map[string]v1alpha1.PatchType{
+		"local.yaml": v1alpha1.PatchTypeCreateFile,
+		removed:      v1alpha1.PatchTypeDeleteFile,
+	}
+	if !cmp.Equal(want, got) {
+		t.Errorf("DiffUpstream patches differ (-want,+got): %s", cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ValidatePackageRevision runs the validators of the package revision and its subpackages, but
+// not the mutators, and returns their results. Nothing is persisted. Validation failures are
+// reported in the results; an error is returned only if the validators could not be run.
+func (cad *cadEngine) ValidatePackageRevision(ctx context.Context, pr repository.PackageRevision) (*fnresult.ResultList, error) {
+	ctx, span := tracer.Start(ctx, "cadEngine::ValidatePackageRevision", trace.WithAttributes())
+	defer span.End()
+
+	resources, err := pr.GetResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get package resources: %w", err)
+	}
+
+	contents, err := withoutMutators(resources.Spec.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := filesys.MakeFsInMemory()
+	pkgPath, err := writeResources(fs, repository.PackageResources{Contents: contents})
+	if err != nil {
+		return nil, err
+	}
+	if pkgPath == "" {
+		return nil, fmt.Errorf("package revision %q has no Kptfile", pr.KubeObjectName())
+	}
+
+	renderErr := cad.renderer.Render(ctx, fs, fn.RenderOptions{
+		PkgPath:        pkgPath,
+		Runtime:        cad.runtime,
+		ResultsDirPath: renderResultsDir,
+	})
+
+	results, err := readResultList(fs)
+	if err != nil {
+		if renderErr != nil {
+			return nil, fmt.Errorf("cannot validate package revision %q: %w", pr.KubeObjectName(), renderErr)
+		}
+		return nil, err
+	}
+	return results, nil
+}
+
+// withoutMutators returns a copy of the package contents in which the pipelines of all Kptfiles
+// have no mutators.
+func withoutMutators(contents map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(contents))
+	for k, v := range contents {
+		result[k] = v
+		if path.Base(normalizeResourcePath(k)) != kptfilev1.KptFileName {
+			continue
+		}
+		kf, err := yaml.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", k, err)
+		}
+		pipeline, err := kf.Pipe(yaml.Lookup("pipeline"))
+		if err != nil {
+			return nil, fmt.Errorf("error reading pipeline of %s: %w", k, err)
+		}
+		if pipeline == nil {
+			continue
+		}
+		if _, err := pipeline.Pipe(yaml.Clear("mutators")); err != nil {
+			return nil, fmt.Errorf("error removing mutators of %s: %w", k, err)
+		}
+		s, err := kf.String()
+		if err != nil {
+			return nil, fmt.Errorf("error writing %s: %w", k, err)
+		}
+		result[k] = s
+	}
+	return result, nil
+}
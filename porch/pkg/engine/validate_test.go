@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"path"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/engine/fake"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+)
+
+const validateKptfile = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: app
+pipeline:
+  mutators:
+  - image: gcr.io/kpt-fn/set-namespace:v0.4
+  validators:
+  - image: gcr.io/kpt-fn/kubeval:v0.3
+`
+
+// recordingRenderer records the filesystem it rendered, and saves the configured results.
+type recordingRenderer struct {
+	resultsRenderer
+	pkg filesys.FileSystem
+}
+
+func (r *recordingRenderer) Render(ctx context.Context, fs filesys.FileSystem, opts fn.RenderOptions) error {
+	r.pkg = fs
+	return r.resultsRenderer.Render(ctx, fs, opts)
+}
+
+func TestValidatePackageRevision(t *testing.T) {
+	failure := &framework.Result{Message: "invalid field", Severity: framework.Error}
+	renderer := &recordingRenderer{
+		resultsRenderer: resultsRenderer{
+			results: fnresult.ResultList{Items: []fnresult.Result{
+				{Image: "gcr.io/kpt-fn/kubeval:v0.3", ExitCode: 1, Results: framework.Results{failure}},
+			}},
+			err: errors.New("validation failed"),
+		},
+	}
+	pr := &fake.PackageRevision{
+		Name: "app",
+		Resources: &v1alpha1.PackageRevisionResources{
+			Spec: v1alpha1.PackageRevisionResourcesSpec{
+				Resources: map[string]string{
+					"Kptfile":     validateKptfile,
+					"sub/Kptfile": validateKptfile,
+				},
+			},
+		},
+	}
+	cad := &cadEngine{renderer: renderer}
+
+	results, err := cad.ValidatePackageRevision(context.Background(), pr)
+	if err != nil {
+		t.Fatalf("ValidatePackageRevision failed: %v", err)
+	}
+	if got, want := len(results.Items), 1; got != want {
+		t.Fatalf("got %d results, want %d", got, want)
+	}
+	if got, want := results.Items[0].Results[0].Message, failure.Message; got != want {
+		t.Errorf("unexpected result message: got %q, want %q", got, want)
+	}
+
+	for _, dir := range []string{"/", "/sub"} {
+		kf, err := readKptfile(renderer.pkg, dir)
+		if err != nil {
+			t.Fatalf("cannot read rendered Kptfile in %s: %v", dir, err)
+		}
+		if kf.Pipeline == nil || len(kf.Pipeline.Validators) != 1 {
+			t.Errorf("%s: validators were not kept: %+v", path.Join(dir, "Kptfile"), kf.Pipeline)
+		} else if len(kf.Pipeline.Mutators) != 0 {
+			t.Errorf("%s: mutators were not removed: %+v", path.Join(dir, "Kptfile"), kf.Pipeline.Mutators)
+		}
+	}
+
+	// The stored package is unchanged.
+	if got := pr.Resources.Spec.Resources["Kptfile"]; got != validateKptfile {
+		t.Errorf("ValidatePackageRevision modified the package revision:\n%s", got)
+	}
+}
@@ -35,6 +35,11 @@ type gitAnnotation struct {
 
 	// Tasks holds the task we performed, if a task caused the commit.
 	Task *v1alpha1.Task `json:"task,omitempty"`
+
+	// Tasks holds all tasks of the package revision, in order, on the commit which published it.
+	// Published package revisions are committed without the history of their draft, so this is
+	// the only record of the tasks which produced them.
+	Tasks []v1alpha1.Task `json:"tasks,omitempty"`
 }
 
 // ExtractGitAnnotations reads the gitAnnotations from the given commit.
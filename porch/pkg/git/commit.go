@@ -256,8 +256,8 @@ func (h *commitHelper) commit(ctx context.Context, message string, pkgPath strin
 		return plumbing.ZeroHash, plumbing.ZeroHash, err
 	}
 
-	var ui *repository.UserInfo
-	if h.userInfoProvider != nil {
+	ui := repository.CommitInfoFromContext(ctx).Author
+	if ui == nil && h.userInfoProvider != nil {
 		ui = h.userInfoProvider.GetUserInfo(ctx)
 	}
 
@@ -275,6 +275,15 @@ func (h *commitHelper) commit(ctx context.Context, message string, pkgPath strin
 	return commit, pkgTree, nil
 }
 
+// commitMessage returns the commit message carried by the context, or the default message if
+// there is none.
+func commitMessage(ctx context.Context, defaultMessage string) string {
+	if message := repository.CommitInfoFromContext(ctx).Message; message != "" {
+		return message
+	}
+	return defaultMessage
+}
+
 // storeBlob is a helper method to write a blob to the git store.
 func (h *commitHelper) storeBlob(value string) (plumbing.Hash, error) {
 	data := []byte(value)
@@ -76,7 +76,7 @@ func (d *gitPackageDraft) UpdateResources(ctx context.Context, new *v1alpha1.Pac
 		message += fmt.Sprintf(": %s", change.Type)
 		d.tasks = append(d.tasks, *change)
 	}
-	message += "\n"
+	message = commitMessage(ctx, message) + "\n"
 
 	message, err = AnnotateCommitMessage(message, annotation)
 	if err != nil {
@@ -229,9 +229,14 @@ func (r *gitRepository) commitPackageToMain(ctx context.Context, d *gitPackageDr
 	if err != nil {
 		return zero, zero, nil, fmt.Errorf("failed to initialize commit of package %s to %s", packagePath, localRef)
 	}
-	message := fmt.Sprintf("Approve %s", packagePath)
-
-	// TODO: Should we annotate this in some way?  Should we include the tasks?
+	message := commitMessage(ctx, fmt.Sprintf("Approve %s", packagePath)) + "\n"
+	message, err = AnnotateCommitMessage(message, &gitAnnotation{
+		PackagePath: packagePath,
+		Tasks:       d.tasks,
+	})
+	if err != nil {
+		return zero, zero, nil, err
+	}
 
 	commitHash, newPackageTreeHash, err = ch.commit(ctx, message, packagePath)
 	if err != nil {
@@ -49,6 +49,9 @@ const (
 type GitRepository interface {
 	repository.Repository
 	GetPackage(ctx context.Context, ref, path string) (repository.PackageRevision, kptfilev1.GitLock, error)
+	// VerifyCommit verifies the PGP signature of the commit against the armored key ring and
+	// returns the identity of the signer.
+	VerifyCommit(ctx context.Context, commit string, armoredKeyRing string) (string, error)
 }
 
 type GitRepositoryOptions struct {
@@ -220,6 +223,39 @@ func (r *gitRepository) ListPackageRevisions(ctx context.Context, filter reposit
 	return result, nil
 }
 
+var _ repository.ResourcesBatchReader = &gitRepository{}
+
+// GetResourcesBatch reads the resources of the package revisions, reading the files of each
+// distinct package tree only once.
+func (r *gitRepository) GetResourcesBatch(ctx context.Context, revisions []repository.PackageRevision) (map[string]*v1alpha1.PackageRevisionResources, error) {
+	ctx, span := tracer.Start(ctx, "gitRepository::GetResourcesBatch", trace.WithAttributes())
+	defer span.End()
+
+	trees := map[plumbing.Hash]map[string]string{}
+	result := make(map[string]*v1alpha1.PackageRevisionResources, len(revisions))
+	for _, pr := range revisions {
+		p, ok := pr.(*gitPackageRevision)
+		if !ok {
+			return nil, fmt.Errorf("cannot read resources of non-git package revision %T", pr)
+		}
+		contents, ok := trees[p.tree]
+		if !ok {
+			var err error
+			if contents, err = p.loadContents(); err != nil {
+				return nil, fmt.Errorf("cannot read resources of package revision %q: %w", p.KubeObjectName(), err)
+			}
+			trees[p.tree] = contents
+		}
+		// Each package revision gets its own copy, so that callers can modify it.
+		resources := make(map[string]string, len(contents))
+		for k, v := range contents {
+			resources[k] = v
+		}
+		result[p.KubeObjectName()] = p.resourcesObject(resources)
+	}
+	return result, nil
+}
+
 func (r *gitRepository) CreatePackageRevision(ctx context.Context, obj *v1alpha1.PackageRevision) (repository.PackageDraft, error) {
 	ctx, span := tracer.Start(ctx, "gitRepository::CreatePackageRevision", trace.WithAttributes())
 	defer span.End()
@@ -362,6 +398,11 @@ func (r *gitRepository) GetPackage(ctx context.Context, version, path string) (r
 	// Trim leading and trailing slashes
 	path = strings.Trim(path, "/")
 
+	// An unspecified version refers to the head of the repository branch.
+	if version == "" {
+		version = string(r.branch)
+	}
+
 	// Versions map to git tags in one of two ways:
 	//
 	// * directly (tag=version)- but then this means that all packages in the repo must be versioned together.
@@ -399,6 +440,29 @@ func (r *gitRepository) GetPackage(ctx context.Context, version, path string) (r
 	return r.loadPackageRevision(ctx, version, path, hash)
 }
 
+func (r *gitRepository) VerifyCommit(ctx context.Context, commit string, armoredKeyRing string) (string, error) {
+	ctx, span := tracer.Start(ctx, "gitRepository::VerifyCommit", trace.WithAttributes())
+	defer span.End()
+
+	c, err := r.repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return "", fmt.Errorf("cannot find commit %s: %w", commit, err)
+	}
+	if c.PGPSignature == "" {
+		return "", fmt.Errorf("commit %s is not signed", commit)
+	}
+	entity, err := c.Verify(armoredKeyRing)
+	if err != nil {
+		return "", fmt.Errorf("commit %s is not signed by a trusted key: %w", commit, err)
+	}
+
+	signer := entity.PrimaryKey.KeyIdString()
+	if identity := entity.PrimaryIdentity(); identity != nil {
+		signer = fmt.Sprintf("%s (%s)", identity.Name, signer)
+	}
+	return signer, nil
+}
+
 func (r *gitRepository) loadPackageRevision(ctx context.Context, version, path string, hash plumbing.Hash) (repository.PackageRevision, kptfilev1.GitLock, error) {
 	ctx, span := tracer.Start(ctx, "gitRepository::loadPackageRevision", trace.WithAttributes())
 	defer span.End()
@@ -759,7 +823,7 @@ func (r *gitRepository) createPackageDeleteCommit(ctx context.Context, branch pl
 		return zero, fmt.Errorf("failed to initialize commit of package %q to %q: %w", packagePath, ref, err)
 	}
 
-	message := fmt.Sprintf("Delete %s", packagePath)
+	message := commitMessage(ctx, fmt.Sprintf("Delete %s", packagePath))
 	commitHash, _, err := ch.commit(ctx, message, packagePath)
 	if err != nil {
 		return zero, fmt.Errorf("failed to commit package %q to %q: %w", packagePath, ref, err)
@@ -791,6 +855,17 @@ func (r *gitRepository) pushAndCleanup(ctx context.Context, ph *pushRefSpecBuild
 }
 
 func (r *gitRepository) loadTasks(ctx context.Context, startCommit *object.Commit, packagePath string) ([]v1alpha1.Task, error) {
+	// A published package revision records all of its tasks on the commit which published it.
+	startAnnotations, err := ExtractGitAnnotations(startCommit)
+	if err != nil {
+		return nil, err
+	}
+	for _, gitAnnotation := range startAnnotations {
+		if gitAnnotation.Tasks != nil && gitAnnotation.PackagePath == packagePath {
+			return gitAnnotation.Tasks, nil
+		}
+	}
+
 	var logOptions = git.LogOptions{
 		From:  startCommit.Hash,
 		Order: git.LogOrderCommitterTime,
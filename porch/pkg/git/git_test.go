@@ -554,6 +554,32 @@ func (g GitSuite) TestListPackagesSimple(t *testing.T) {
 	}
 }
 
+func (g GitSuite) TestGetPackageDefaultBranch(t *testing.T) {
+	tempdir := t.TempDir()
+	tarfile := filepath.Join("testdata", "simple-repository.tar")
+	_, address := ServeGitRepositoryWithBranch(t, tarfile, tempdir, g.branch)
+
+	ctx := context.Background()
+	git, err := OpenRepository(ctx, "simple", "default", &configapi.GitRepository{
+		Repo:   address,
+		Branch: g.branch,
+	}, tempdir, GitRepositoryOptions{})
+	if err != nil {
+		t.Fatalf("Failed to open Git repository loaded from %q: %v", tarfile, err)
+	}
+
+	revision, lock, err := git.GetPackage(ctx, "", "basens")
+	if err != nil {
+		t.Fatalf("GetPackage without a ref failed: %v", err)
+	}
+	if got, want := lock.Ref, g.branch; got != want {
+		t.Errorf("GetPackage without a ref: got ref %q, want %q", got, want)
+	}
+	if got, want := revision.Key().Revision, g.branch; got != want {
+		t.Errorf("GetPackage without a ref: got revision %q, want %q", got, want)
+	}
+}
+
 func (g GitSuite) TestListPackagesDrafts(t *testing.T) {
 	tempdir := t.TempDir()
 	tarfile := filepath.Join("testdata", "drafts-repository.tar")
@@ -669,6 +695,137 @@ func (g GitSuite) TestApproveDraft(t *testing.T) {
 	refMustExist(t, repo, finalReferenceName)
 }
 
+func (g GitSuite) TestApproveDraftWithCommitInfo(t *testing.T) {
+	tempdir := t.TempDir()
+	tarfile := filepath.Join("testdata", "drafts-repository.tar")
+	repo, address := ServeGitRepositoryWithBranch(t, tarfile, tempdir, g.branch)
+
+	const (
+		repositoryName                            = "approve"
+		namespace                                 = "default"
+		finalReferenceName plumbing.ReferenceName = "refs/tags/bucket/v1"
+		message                                   = "Approve bucket for the Q3 rollout"
+	)
+	author := &repository.UserInfo{Name: "Jane Doe", Email: "jane@example.com"}
+	ctx := repository.WithCommitInfo(context.Background(), repository.CommitInfo{
+		Message: message,
+		Author:  author,
+	})
+	git, err := OpenRepository(ctx, repositoryName, namespace, &configapi.GitRepository{
+		Repo:      address,
+		Branch:    g.branch,
+		Directory: "/",
+	}, tempdir, GitRepositoryOptions{})
+	if err != nil {
+		t.Fatalf("Failed to open Git repository loaded from %q: %v", tarfile, err)
+	}
+
+	revisions, err := git.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
+	if err != nil {
+		t.Fatalf("ListPackageRevisions failed: %v", err)
+	}
+
+	bucket := findPackage(t, revisions, repository.PackageRevisionKey{
+		Repository: repositoryName,
+		Package:    "bucket",
+		Revision:   "v1",
+	})
+
+	update, err := git.UpdatePackage(ctx, bucket)
+	if err != nil {
+		t.Fatalf("UpdatePackage failed: %v", err)
+	}
+	update.UpdateLifecycle(ctx, v1alpha1.PackageRevisionLifecyclePublished)
+	if _, err := update.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	commit := getCommitObject(t, repo, resolveReference(t, repo, finalReferenceName).Hash())
+	// The message is followed by the annotation recording the tasks of the package revision.
+	if got, want := commit.Message, message+"\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("Commit.Message: got %q, want prefix %q", got, want)
+	}
+	if got, want := commit.Author.Name, author.Name; got != want {
+		t.Errorf("Commit.Author.Name: got %q, want %q", got, want)
+	}
+	if got, want := commit.Author.Email, author.Email; got != want {
+		t.Errorf("Commit.Author.Email: got %q, want %q", got, want)
+	}
+}
+
+func (g GitSuite) TestApprovedTasks(t *testing.T) {
+	tempdir := t.TempDir()
+	tarfile := filepath.Join("testdata", "drafts-repository.tar")
+	_, address := ServeGitRepositoryWithBranch(t, tarfile, tempdir, g.branch)
+
+	const (
+		repositoryName = "approve"
+		namespace      = "default"
+	)
+	ctx := context.Background()
+	spec := &configapi.GitRepository{
+		Repo:      address,
+		Branch:    g.branch,
+		Directory: "/",
+	}
+	git, err := OpenRepository(ctx, repositoryName, namespace, spec, tempdir, GitRepositoryOptions{})
+	if err != nil {
+		t.Fatalf("Failed to open Git repository loaded from %q: %v", tarfile, err)
+	}
+
+	revisions, err := git.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
+	if err != nil {
+		t.Fatalf("ListPackageRevisions failed: %v", err)
+	}
+	key := repository.PackageRevisionKey{
+		Repository: repositoryName,
+		Package:    "bucket",
+		Revision:   "v1",
+	}
+	bucket := findPackage(t, revisions, key)
+	resources, err := bucket.GetResources(ctx)
+	if err != nil {
+		t.Fatalf("GetResources failed: %v", err)
+	}
+
+	update, err := git.UpdatePackage(ctx, bucket)
+	if err != nil {
+		t.Fatalf("UpdatePackage failed: %v", err)
+	}
+	task := v1alpha1.Task{
+		Type: v1alpha1.TaskTypeEval,
+		Eval: &v1alpha1.FunctionEvalTaskSpec{Image: "gcr.io/kpt-fn/set-labels:v0.1"},
+	}
+	if err := update.UpdateResources(ctx, resources, &task); err != nil {
+		t.Fatalf("UpdateResources failed: %v", err)
+	}
+	update.UpdateLifecycle(ctx, v1alpha1.PackageRevisionLifecyclePublished)
+	if _, err := update.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	want := append(append([]v1alpha1.Task{}, bucket.Tasks()...), task)
+
+	// The tasks are read back from a fresh clone of the repository.
+	reopened, err := OpenRepository(ctx, repositoryName, namespace, spec, t.TempDir(), GitRepositoryOptions{})
+	if err != nil {
+		t.Fatalf("Failed to reopen Git repository: %v", err)
+	}
+	revisions, err = reopened.ListPackageRevisions(ctx, repository.ListPackageRevisionFilter{})
+	if err != nil {
+		t.Fatalf("ListPackageRevisions failed: %v", err)
+	}
+	published := findPackage(t, revisions, key)
+	if got := published.Lifecycle(); got != v1alpha1.PackageRevisionLifecyclePublished {
+		t.Fatalf("Package revision lifecycle: got %s, want %s", got, v1alpha1.PackageRevisionLifecyclePublished)
+	}
+	if diff := cmp.Diff(want, published.Tasks()); diff != "" {
+		t.Errorf("Unexpected tasks of the published package revision (-want, +got): %s", diff)
+	}
+	if diff := cmp.Diff(want, published.GetPackageRevision().Spec.Tasks); diff != "" {
+		t.Errorf("Unexpected tasks in the package revision spec (-want, +got): %s", diff)
+	}
+}
+
 func (g GitSuite) TestDeletePackages(t *testing.T) {
 	tempdir := t.TempDir()
 	tarfile := filepath.Join("testdata", "drafts-repository.tar")
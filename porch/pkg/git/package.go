@@ -39,6 +39,9 @@ type gitPackageRevision struct {
 	tree     plumbing.Hash       // Cached tree of the package itself, some descendent of commit.Tree()
 	commit   plumbing.Hash       // Current version of the package (commit sha)
 	tasks    []v1alpha1.Task
+
+	// Memoized hash of the package resources.
+	contentHash repository.ContentHashCache
 }
 
 var _ repository.PackageRevision = &gitPackageRevision{}
@@ -98,6 +101,15 @@ func (p *gitPackageRevision) GetPackageRevision() *v1alpha1.PackageRevision {
 }
 
 func (p *gitPackageRevision) GetResources(ctx context.Context) (*v1alpha1.PackageRevisionResources, error) {
+	resources, err := p.loadContents()
+	if err != nil {
+		return nil, err
+	}
+	return p.resourcesObject(resources), nil
+}
+
+// loadContents reads the files of the package tree.
+func (p *gitPackageRevision) loadContents() (map[string]string, error) {
 	resources := map[string]string{}
 
 	tree, err := p.parent.repo.TreeObject(p.tree)
@@ -123,7 +135,11 @@ func (p *gitPackageRevision) GetResources(ctx context.Context) (*v1alpha1.Packag
 			//resources[path.Join(p.path, file.Name)] = content
 		}
 	}
+	return resources, nil
+}
 
+// resourcesObject returns the PackageRevisionResources of the package revision with the files.
+func (p *gitPackageRevision) resourcesObject(resources map[string]string) *v1alpha1.PackageRevisionResources {
 	key := p.Key()
 
 	return &v1alpha1.PackageRevisionResources{
@@ -148,7 +164,15 @@ func (p *gitPackageRevision) GetResources(ctx context.Context) (*v1alpha1.Packag
 
 			Resources: resources,
 		},
-	}, nil
+	}
+}
+
+func (p *gitPackageRevision) Tasks() []v1alpha1.Task {
+	return p.tasks
+}
+
+func (p *gitPackageRevision) ContentHash(ctx context.Context) (string, error) {
+	return p.contentHash.Get(ctx, p)
 }
 
 func (p *gitPackageRevision) GetUpstreamLock() (kptfile.Upstream, kptfile.UpstreamLock, error) {
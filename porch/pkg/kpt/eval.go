@@ -55,7 +55,13 @@ type runner struct {
 	processor framework.ResourceListProcessorFunc
 }
 
-var _ fn.FunctionRunner = &runner{}
+var _ fn.ConfigurableFunctionRunner = &runner{}
+
+// SetRunnerOptions implements fn.ConfigurableFunctionRunner. The functions run in-process without
+// network access, so they always run in the network sandbox.
+func (fr *runner) SetRunnerOptions(opts fn.RunnerOptions) error {
+	return nil
+}
 
 func (fr *runner) Run(r io.Reader, w io.Writer) error {
 	rw := &kio.ByteReadWriter{
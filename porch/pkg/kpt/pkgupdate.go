@@ -34,6 +34,11 @@ import (
 // PkgUpdateOpts are options for invoking kpt PkgUpdate
 type PkgUpdateOpts struct {
 	Strategy string
+
+	// OriginCommit is the upstream commit the package was originally cloned from,
+	// used as the common ancestor of the three-way merge. If empty, the commit
+	// recorded in the Kptfile upstream lock is used.
+	OriginCommit string
 }
 
 // PkgUpdate is a wrapper around `kpt pkg update`, running it against the package in packageDir
@@ -94,9 +99,15 @@ func PkgUpdate(ctx context.Context, ref string, packageDir string, opts PkgUpdat
 		updatedRepoSpec = updated
 
 		// var origin repoClone
-		if kf.UpstreamLock != nil {
-			gLock := kf.UpstreamLock.Git
-			originRepoSpec := &git.RepoSpec{OrgRepo: gLock.Repo, Path: gLock.Directory, Ref: gLock.Commit}
+		if kf.UpstreamLock != nil || opts.OriginCommit != "" {
+			originRepoSpec := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: opts.OriginCommit}
+			if kf.UpstreamLock != nil && kf.UpstreamLock.Git != nil {
+				gLock := kf.UpstreamLock.Git
+				originRepoSpec.OrgRepo, originRepoSpec.Path = gLock.Repo, gLock.Directory
+				if originRepoSpec.Ref == "" {
+					originRepoSpec.Ref = gLock.Commit
+				}
+			}
 			klog.Infof("Fetching origin from %s@%s\n", originRepoSpec.OrgRepo, originRepoSpec.Ref)
 			// pr.Printf("Fetching origin from %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
 			// if err := fetch.ClonerUsingGitExec(ctx, originRepoSpec); err != nil {
@@ -198,3 +209,21 @@ func PkgUpdate(ctx context.Context, ref string, packageDir string, opts PkgUpdat
 
 	return nil
 }
+
+// ReadUpstreamCommit returns the upstream commit recorded in the Kptfile upstream lock of the package in packageDir.
+func ReadUpstreamCommit(packageDir string) (string, error) {
+	f, err := os.Open(filepath.Join(packageDir, kptfilev1.KptFileName))
+	if err != nil {
+		return "", fmt.Errorf("error opening kptfile: %w", err)
+	}
+	defer f.Close()
+
+	kf, err := internalpkg.DecodeKptfile(f)
+	if err != nil {
+		return "", fmt.Errorf("error parsing kptfile: %w", err)
+	}
+	if kf.UpstreamLock == nil || kf.UpstreamLock.Git == nil {
+		return "", nil
+	}
+	return kf.UpstreamLock.Git.Commit, nil
+}
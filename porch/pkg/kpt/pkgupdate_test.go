@@ -0,0 +1,128 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kpt
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const upstreamKptfile = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: app
+`
+
+func configMap(data string) string {
+	return fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n%s", data)
+}
+
+// commitPackage writes the files into the app directory of the repository and commits them,
+// returning the commit hash.
+func commitPackage(t *testing.T, repo *gogit.Repository, dir string, files map[string]string) string {
+	t.Helper()
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree failed: %v", err)
+	}
+	for name, contents := range files {
+		p := filepath.Join(dir, "app", name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if _, err := wt.Add(filepath.Join("app", name)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	hash, err := wt.Commit("update app", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "Porch Test", Email: "test@kpt.dev", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	return hash.String()
+}
+
+func TestPkgUpdateOriginCommit(t *testing.T) {
+	t.Setenv(gitutil.RepoCacheDirEnv, t.TempDir())
+
+	upstreamDir := t.TempDir()
+	upstream, err := gogit.PlainInit(upstreamDir, false)
+	if err != nil {
+		t.Fatalf("PlainInit failed: %v", err)
+	}
+	origin := commitPackage(t, upstream, upstreamDir, map[string]string{
+		"Kptfile":        upstreamKptfile,
+		"configmap.yaml": configMap("  color: red\n  size: small\n"),
+	})
+	updated := commitPackage(t, upstream, upstreamDir, map[string]string{
+		"configmap.yaml": configMap("  color: blue\n  size: small\n"),
+	})
+
+	// The local package was cloned from the origin commit and changed locally, but its Kptfile
+	// has no upstream lock, so the merge base must come from the origin commit option.
+	packageDir := filepath.Join(t.TempDir(), "app")
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	kptfile := upstreamKptfile + fmt.Sprintf("upstream:\n  type: git\n  git:\n    repo: %s\n    directory: app\n    ref: main\n  updateStrategy: resource-merge\n", upstreamDir)
+	for name, contents := range map[string]string{
+		"Kptfile":        kptfile,
+		"configmap.yaml": configMap("  color: red\n  size: large\n"),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(packageDir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	if commit, err := ReadUpstreamCommit(packageDir); err != nil || commit != "" {
+		t.Fatalf("ReadUpstreamCommit before update: got %q, %v; want no commit", commit, err)
+	}
+
+	if err := PkgUpdate(context.Background(), updated, packageDir, PkgUpdateOpts{OriginCommit: origin}); err != nil {
+		t.Fatalf("PkgUpdate failed: %v", err)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(packageDir, "configmap.yaml"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	for _, want := range []string{"color: blue", "size: large"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("Merged configmap.yaml does not contain %q:\n%s", want, got)
+		}
+	}
+
+	commit, err := ReadUpstreamCommit(packageDir)
+	if err != nil {
+		t.Fatalf("ReadUpstreamCommit failed: %v", err)
+	}
+	if commit != updated {
+		t.Errorf("ReadUpstreamCommit: got %q, want the updated commit %q", commit, updated)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/util/render"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -38,15 +39,55 @@ type renderer struct {
 var _ fn.Renderer = &renderer{}
 
 func (r *renderer) Render(ctx context.Context, pkg filesys.FileSystem, opts fn.RenderOptions) error {
+	runtime := opts.Runtime
+	if opts.ChangedFiles != nil {
+		runtime = &incrementalRuntime{
+			FunctionRuntime: runtime,
+			changedFiles:    opts.ChangedFiles,
+		}
+	}
+
 	rr := render.Renderer{
-		PkgPath:    opts.PkgPath,
-		Runtime:    opts.Runtime,
-		FileSystem: pkg,
+		PkgPath:        opts.PkgPath,
+		Runtime:        runtime,
+		FileSystem:     pkg,
+		ResultsDirPath: opts.ResultsDirPath,
 	}
 
 	return rr.Execute(printer.WithContext(ctx, &packagePrinter{}))
 }
 
+// incrementalRuntime passes the changed files hint to runners which support incremental processing.
+type incrementalRuntime struct {
+	fn.FunctionRuntime
+	changedFiles []string
+}
+
+func (r *incrementalRuntime) GetRunner(ctx context.Context, funct *kptfilev1.Function) (fn.FunctionRunner, error) {
+	runner, err := r.FunctionRuntime.GetRunner(ctx, funct)
+	if err != nil {
+		return nil, err
+	}
+	if incremental, ok := runner.(fn.IncrementalFunctionRunner); ok {
+		return &incrementalRunner{
+			runner:       incremental,
+			changedFiles: r.changedFiles,
+		}, nil
+	}
+	return runner, nil
+}
+
+type incrementalRunner struct {
+	runner       fn.IncrementalFunctionRunner
+	changedFiles []string
+}
+
+var _ fn.FunctionRunner = &incrementalRunner{}
+
+func (r *incrementalRunner) Run(in io.Reader, out io.Writer) error {
+	return r.runner.RunIncremental(in, out, r.changedFiles)
+}
+
 type packagePrinter struct{}
 
 var _ printer.Printer = &packagePrinter{}
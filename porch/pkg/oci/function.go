@@ -31,6 +31,10 @@ const (
 	DescriptionKey      = ociImagePrefix + "description"
 	DocumentationURLKey = ociImagePrefix + "documentationurl"
 	keywordsKey         = ociImagePrefix + "keywords"
+	// InputTypesKey and OutputTypesKey list KRM types as comma-separated `<apiVersion>/<kind>` values,
+	// for example `rbac.authorization.k8s.io/v1/RoleBinding, v1/ConfigMap`.
+	InputTypesKey  = ociImagePrefix + "inputtypes"
+	OutputTypesKey = ociImagePrefix + "outputtypes"
 
 	fnConfigMetaPrefix = ociImagePrefix + "fnconfig."
 	// experimental: this field is very likely to be changed in the future.
@@ -46,11 +50,32 @@ func AnnotationToSlice(annotation string) []string {
 	return result
 }
 
+// AnnotationToTypeMetas parses a comma-separated list of `<apiVersion>/<kind>` values.
+// A value without a slash is interpreted as a kind applying to all API versions.
+func AnnotationToTypeMetas(annotation string) []metav1.TypeMeta {
+	var result []metav1.TypeMeta
+	for _, val := range AnnotationToSlice(annotation) {
+		if val == "" {
+			continue
+		}
+		var tm metav1.TypeMeta
+		if slash := strings.LastIndex(val, "/"); slash >= 0 {
+			tm.APIVersion, tm.Kind = val[:slash], val[slash+1:]
+		} else {
+			tm.Kind = val
+		}
+		result = append(result, tm)
+	}
+	return result
+}
+
 type functionMeta struct {
 	FunctionTypes    []string
 	Description      string
 	DocumentationUrl string
 	Keywords         []string
+	InputTypes       []metav1.TypeMeta
+	OutputTypes      []metav1.TypeMeta
 	// experimental: this field is very likely to be changed in the future.
 	FunctionConfigs []functionConfig
 }
@@ -79,7 +104,8 @@ func (f *ociFunction) Name() string {
 	// LINT.ThenChange(internal/fnruntime/container.go AddDefaultImagePathPrefix)
 }
 
-func (f *ociFunction) GetFunction() (*v1alpha1.Function, error) {
+// recognizedFunctionTypes returns the function types of the image metadata which are known.
+func (f *ociFunction) recognizedFunctionTypes() []v1alpha1.FunctionType {
 	var functionTypes []v1alpha1.FunctionType
 	for _, fnType := range f.meta.FunctionTypes {
 		switch {
@@ -91,6 +117,18 @@ func (f *ociFunction) GetFunction() (*v1alpha1.Function, error) {
 			// unrecognized custom FunctionType
 		}
 	}
+	return functionTypes
+}
+
+func (f *ociFunction) FunctionTypes() []v1alpha1.FunctionType {
+	if functionTypes := f.recognizedFunctionTypes(); len(functionTypes) > 0 {
+		return functionTypes
+	}
+	return []v1alpha1.FunctionType{v1alpha1.FunctionTypeUnspecified}
+}
+
+func (f *ociFunction) GetFunction() (*v1alpha1.Function, error) {
+	functionTypes := f.recognizedFunctionTypes()
 	var fnConfigs []v1alpha1.FunctionConfig
 	for _, metaFnConfig := range f.meta.FunctionConfigs {
 		fnConfigs = append(fnConfigs, v1alpha1.FunctionConfig{
@@ -118,6 +156,8 @@ func (f *ociFunction) GetFunction() (*v1alpha1.Function, error) {
 			DocumentationUrl: f.meta.DocumentationUrl,
 			Keywords:         f.meta.Keywords,
 			FunctionConfigs:  fnConfigs,
+			InputTypes:       f.meta.InputTypes,
+			OutputTypes:      f.meta.OutputTypes,
 		},
 		Status: v1alpha1.FunctionStatus{},
 	}, nil
@@ -0,0 +1,74 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAnnotationToTypeMetas(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		annotation string
+		want       []metav1.TypeMeta
+	}{
+		{
+			name:       "group, version and kind",
+			annotation: "apps/v1/Deployment",
+			want:       []metav1.TypeMeta{{APIVersion: "apps/v1", Kind: "Deployment"}},
+		},
+		{
+			name:       "version and kind",
+			annotation: "v1/ConfigMap",
+			want:       []metav1.TypeMeta{{APIVersion: "v1", Kind: "ConfigMap"}},
+		},
+		{
+			name:       "bare kind",
+			annotation: "Service",
+			want:       []metav1.TypeMeta{{Kind: "Service"}},
+		},
+		{
+			name:       "list",
+			annotation: "apps/v1/Deployment, v1/ConfigMap,Service",
+			want: []metav1.TypeMeta{
+				{APIVersion: "apps/v1", Kind: "Deployment"},
+				{APIVersion: "v1", Kind: "ConfigMap"},
+				{Kind: "Service"},
+			},
+		},
+		{
+			name:       "empty entries",
+			annotation: "v1/ConfigMap,, ,Service,",
+			want: []metav1.TypeMeta{
+				{APIVersion: "v1", Kind: "ConfigMap"},
+				{Kind: "Service"},
+			},
+		},
+		{
+			name:       "empty",
+			annotation: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := AnnotationToTypeMetas(tc.annotation)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("AnnotationToTypeMetas(%q): unexpected result (-want, +got): %s", tc.annotation, diff)
+			}
+		})
+	}
+}
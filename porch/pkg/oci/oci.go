@@ -191,9 +191,21 @@ func GetFunctionMeta(reference string, ctx context.Context) (*functionMeta, erro
 		DocumentationUrl: GetSingleFromAnnotation(DocumentationURLKey, manifest),
 		Keywords:         GetSliceFromAnnotation(keywordsKey, manifest),
 		FunctionConfigs:  GetDefaultFunctionConfig(manifest),
+		InputTypes:       GetTypeMetasFromAnnotation(InputTypesKey, manifest),
+		OutputTypes:      GetTypeMetasFromAnnotation(OutputTypesKey, manifest),
 	}, nil
 }
 
+// GetTypeMetasFromAnnotation returns the KRM types listed in the manifest annotation, or nil if the
+// manifest does not have the annotation.
+func GetTypeMetasFromAnnotation(key string, manifest *v1.Manifest) []metav1.TypeMeta {
+	val, ok := manifest.Annotations[key]
+	if !ok {
+		return nil
+	}
+	return AnnotationToTypeMetas(val)
+}
+
 func GetDefaultFunctionConfig(manifest *v1.Manifest) []functionConfig {
 	val, ok := manifest.Annotations[ConfigMapFnKey]
 	if !ok {
@@ -309,6 +321,9 @@ type ociPackageRevision struct {
 	parent *ociRepository
 
 	tasks []v1alpha1.Task
+
+	// Memoized hash of the package resources.
+	contentHash repository.ContentHashCache
 }
 
 var _ repository.PackageRevision = &ociPackageRevision{}
@@ -385,6 +400,14 @@ func (p *ociPackageRevision) GetPackageRevision() *v1alpha1.PackageRevision {
 	}
 }
 
+func (p *ociPackageRevision) Tasks() []v1alpha1.Task {
+	return p.tasks
+}
+
+func (p *ociPackageRevision) ContentHash(ctx context.Context) (string, error) {
+	return p.contentHash.Get(ctx, p)
+}
+
 func (p *ociPackageRevision) GetUpstreamLock() (kptfile.Upstream, kptfile.UpstreamLock, error) {
 	return kptfile.Upstream{}, kptfile.UpstreamLock{}, fmt.Errorf("UpstreamLock is not supported for OCI packages (%s)", p.KubeObjectName())
 }
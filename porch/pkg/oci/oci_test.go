@@ -0,0 +1,55 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetTypeMetasFromAnnotation(t *testing.T) {
+	manifest := &v1.Manifest{
+		Annotations: map[string]string{
+			InputTypesKey:  "apps/v1/Deployment,v1/ConfigMap",
+			OutputTypesKey: "",
+		},
+	}
+	for _, tc := range []struct {
+		key  string
+		want []metav1.TypeMeta
+	}{
+		{
+			key: InputTypesKey,
+			want: []metav1.TypeMeta{
+				{APIVersion: "apps/v1", Kind: "Deployment"},
+				{APIVersion: "v1", Kind: "ConfigMap"},
+			},
+		},
+		{
+			key: OutputTypesKey,
+		},
+		{
+			key: DescriptionKey,
+		},
+	} {
+		got := GetTypeMetasFromAnnotation(tc.key, manifest)
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("GetTypeMetasFromAnnotation(%q): unexpected result (-want, +got): %s", tc.key, diff)
+		}
+	}
+}
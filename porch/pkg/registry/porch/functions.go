@@ -22,6 +22,7 @@ import (
 	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
 	configapi "github.com/GoogleContainerTools/kpt/porch/api/porchconfig/v1alpha1"
 	"github.com/GoogleContainerTools/kpt/porch/pkg/engine"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -71,7 +72,7 @@ func (f *functions) List(ctx context.Context, options *metainternalversion.ListO
 
 	for i := range repositories.Items {
 		repo := &repositories.Items[i]
-		fns, err := f.cad.ListFunctions(ctx, repo)
+		fns, err := f.cad.ListFunctions(ctx, repo, repository.ListFunctionFilter{})
 		if err != nil {
 			return nil, fmt.Errorf("failed to list repository %s functions: %w", repositories.Items[i].Name, err)
 		}
@@ -101,8 +102,8 @@ func (f *functions) Get(ctx context.Context, name string, options *metav1.GetOpt
 		repositoryKey.Name = fn.repository
 	}
 
-	var repository configapi.Repository
-	if err := f.coreClient.Get(ctx, repositoryKey, &repository); err != nil {
+	var repositoryObj configapi.Repository
+	if err := f.coreClient.Get(ctx, repositoryKey, &repositoryObj); err != nil {
 		if apierrors.IsNotFound(err) {
 			return nil, apierrors.NewNotFound(v1alpha1.FunctionGVR.GroupResource(), name)
 		}
@@ -110,9 +111,9 @@ func (f *functions) Get(ctx context.Context, name string, options *metav1.GetOpt
 	}
 
 	// TODO: implement get to avoid listing
-	fns, err := f.cad.ListFunctions(ctx, &repository)
+	fns, err := f.cad.ListFunctions(ctx, &repositoryObj, repository.ListFunctionFilter{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list repository %s functions: %w", repository.Name, err)
+		return nil, fmt.Errorf("failed to list repository %s functions: %w", repositoryObj.Name, err)
 	}
 
 	for _, f := range fns {
@@ -233,36 +233,46 @@ func (s packageRevisionStrategy) ValidateUpdate(ctx context.Context, obj, old ru
 	oldRevision := old.(*api.PackageRevision)
 	newRevision := obj.(*api.PackageRevision)
 
-	switch lifecycle := oldRevision.Spec.Lifecycle; lifecycle {
-	case "", api.PackageRevisionLifecycleDraft, api.PackageRevisionLifecycleProposed:
-		// valid
+	// Empty lifecycle defaults to Draft.
+	from, to := oldRevision.Spec.Lifecycle, newRevision.Spec.Lifecycle
+	if from == "" {
+		from = api.PackageRevisionLifecycleDraft
+	}
+	if to == "" {
+		to = api.PackageRevisionLifecycleDraft
+	}
 
-	default:
-		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "lifecycle"), lifecycle, fmt.Sprintf("can only update package with lifecycle value one of %s",
+	lifecycles := repository.PackageRevisionLifecycles
+	switch {
+	case !lifecycles.IsValid(from) || from == api.PackageRevisionLifecyclePublished:
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "lifecycle"), oldRevision.Spec.Lifecycle, fmt.Sprintf("can only update package with lifecycle value one of %s",
 			strings.Join([]string{
 				string(api.PackageRevisionLifecycleDraft),
 				string(api.PackageRevisionLifecycleProposed),
 			}, ",")),
 		))
 
-	}
-
-	switch lifecycle := newRevision.Spec.Lifecycle; lifecycle {
-	case "", api.PackageRevisionLifecycleDraft, api.PackageRevisionLifecycleProposed:
-		// valid
-
-	default:
-		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "lifecycle"), lifecycle, fmt.Sprintf("value can be only updated to %s",
-			strings.Join([]string{
-				string(api.PackageRevisionLifecycleDraft),
-				string(api.PackageRevisionLifecycleProposed),
-			}, ",")),
+	// Publishing is only possible via approval.
+	case to == api.PackageRevisionLifecyclePublished || !lifecycles.CanTransition(from, to):
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "lifecycle"), newRevision.Spec.Lifecycle, fmt.Sprintf("value can be only updated to %s",
+			strings.Join(lifecycleTargets(from, api.PackageRevisionLifecyclePublished), ",")),
 		))
 	}
 
 	return allErrs
 }
 
+// lifecycleTargets returns the lifecycle values a package revision can transition to, except the excluded one.
+func lifecycleTargets(from, excluded api.PackageRevisionLifecycle) []string {
+	var targets []string
+	for _, to := range repository.PackageRevisionLifecycles.Targets(from) {
+		if to != excluded {
+			targets = append(targets, string(to))
+		}
+	}
+	return targets
+}
+
 func (s packageRevisionStrategy) Canonicalize(obj runtime.Object) {
 	pr := obj.(*api.PackageRevision)
 	if pr.Spec.Lifecycle == "" {
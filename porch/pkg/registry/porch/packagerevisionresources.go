@@ -159,6 +159,10 @@ func (r *packageRevisionResources) Update(ctx context.Context, name string, objI
 		return nil, false, apierrors.NewInternalError(fmt.Errorf("error getting repository %v: %w", repositoryID, err))
 	}
 
+	if options != nil && isDryRun(options.DryRun) {
+		return r.dryRunUpdate(ctx, oldPackage, oldObj, newObj)
+	}
+
 	rev, err := r.cad.UpdatePackageResources(ctx, &repositoryObj, oldPackage, oldObj, newObj)
 	if err != nil {
 		return nil, false, apierrors.NewInternalError(err)
@@ -170,3 +174,28 @@ func (r *packageRevisionResources) Update(ctx context.Context, name string, objI
 	}
 	return created, false, nil
 }
+
+func isDryRun(dryRun []string) bool {
+	for _, v := range dryRun {
+		if v == metav1.DryRunAll {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRunUpdate renders the updated resources without persisting them, and returns the rendered
+// resources. A failed render is reported as a bad request.
+func (r *packageRevisionResources) dryRunUpdate(ctx context.Context, oldPackage repository.PackageRevision, oldObj, newObj *api.PackageRevisionResources) (runtime.Object, bool, error) {
+	result, err := r.cad.DryRunUpdatePackageResources(ctx, oldPackage, oldObj, newObj)
+	if err != nil {
+		return nil, false, apierrors.NewInternalError(err)
+	}
+	if result.RenderErr != nil {
+		return nil, false, apierrors.NewBadRequest(result.RenderErr.Error())
+	}
+
+	rendered := newObj.DeepCopy()
+	rendered.Spec.Resources = result.Resources.Contents
+	return rendered, false, nil
+}
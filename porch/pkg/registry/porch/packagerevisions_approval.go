@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	api "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/GoogleContainerTools/kpt/porch/pkg/repository"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -73,18 +74,12 @@ func (s packageRevisionApprovalStrategy) ValidateUpdate(ctx context.Context, obj
 			fmt.Sprintf("cannot approve package with %s lifecycle value; only Proposed packages can be approved", lifecycle)))
 	}
 
-	switch lifecycle := newRevision.Spec.Lifecycle; lifecycle {
 	// TODO: signal rejection of the approval differently than by returning to draft?
-	case api.PackageRevisionLifecycleDraft, api.PackageRevisionLifecyclePublished:
-		// valid
-
-	default:
+	if lifecycle := newRevision.Spec.Lifecycle; lifecycle == api.PackageRevisionLifecycleProposed ||
+		!repository.PackageRevisionLifecycles.CanTransition(api.PackageRevisionLifecycleProposed, lifecycle) {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "lifecycle"), lifecycle, fmt.Sprintf("value for approval can be only one of %s",
-				strings.Join([]string{
-					string(api.PackageRevisionLifecycleDraft),
-					string(api.PackageRevisionLifecyclePublished),
-				}, ",")),
+				strings.Join(lifecycleTargets(api.PackageRevisionLifecycleProposed, api.PackageRevisionLifecycleProposed), ",")),
 			))
 	}
 	return allErrs
@@ -0,0 +1,63 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+)
+
+// LifecycleStateMachine defines the allowed transitions between package revision lifecycle values.
+type LifecycleStateMachine struct {
+	transitions map[v1alpha1.PackageRevisionLifecycle][]v1alpha1.PackageRevisionLifecycle
+}
+
+// PackageRevisionLifecycles is the package revision lifecycle state machine. A Draft can be proposed,
+// and a Proposed package revision can be published or returned to Draft. Published package
+// revisions are final. Every non-final lifecycle can also transition to itself.
+var PackageRevisionLifecycles = &LifecycleStateMachine{
+	transitions: map[v1alpha1.PackageRevisionLifecycle][]v1alpha1.PackageRevisionLifecycle{
+		v1alpha1.PackageRevisionLifecycleDraft: {
+			v1alpha1.PackageRevisionLifecycleDraft,
+			v1alpha1.PackageRevisionLifecycleProposed,
+		},
+		v1alpha1.PackageRevisionLifecycleProposed: {
+			v1alpha1.PackageRevisionLifecycleProposed,
+			v1alpha1.PackageRevisionLifecycleDraft,
+			v1alpha1.PackageRevisionLifecyclePublished,
+		},
+		v1alpha1.PackageRevisionLifecyclePublished: {},
+	},
+}
+
+// IsValid returns true if the lifecycle value is known to the state machine.
+func (m *LifecycleStateMachine) IsValid(lifecycle v1alpha1.PackageRevisionLifecycle) bool {
+	_, ok := m.transitions[lifecycle]
+	return ok
+}
+
+// CanTransition returns true if a package revision can transition from one lifecycle value to another.
+func (m *LifecycleStateMachine) CanTransition(from, to v1alpha1.PackageRevisionLifecycle) bool {
+	for _, allowed := range m.transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Targets returns the lifecycle values a package revision can transition to from the given lifecycle.
+func (m *LifecycleStateMachine) Targets(from v1alpha1.PackageRevisionLifecycle) []v1alpha1.PackageRevisionLifecycle {
+	return append([]v1alpha1.PackageRevisionLifecycle(nil), m.transitions[from]...)
+}
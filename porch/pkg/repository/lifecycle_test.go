@@ -0,0 +1,63 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+)
+
+func TestLifecycleTransitions(t *testing.T) {
+	const (
+		draft     = v1alpha1.PackageRevisionLifecycleDraft
+		proposed  = v1alpha1.PackageRevisionLifecycleProposed
+		published = v1alpha1.PackageRevisionLifecyclePublished
+	)
+
+	all := []v1alpha1.PackageRevisionLifecycle{"", "Wrong", draft, proposed, published}
+	allowed := map[v1alpha1.PackageRevisionLifecycle][]v1alpha1.PackageRevisionLifecycle{
+		draft:    {draft, proposed},
+		proposed: {proposed, draft, published},
+	}
+
+	for _, from := range all {
+		for _, to := range all {
+			want := false
+			for _, a := range allowed[from] {
+				if a == to {
+					want = true
+				}
+			}
+			if got := PackageRevisionLifecycles.CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%q, %q): got %t, want %t", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestLifecycleIsValid(t *testing.T) {
+	for lifecycle, want := range map[v1alpha1.PackageRevisionLifecycle]bool{
+		"":                                     false,
+		"Wrong":                                false,
+		v1alpha1.PackageRevisionLifecycleDraft: true,
+		v1alpha1.PackageRevisionLifecycleProposed:  true,
+		v1alpha1.PackageRevisionLifecyclePublished: true,
+	} {
+		if got := PackageRevisionLifecycles.IsValid(lifecycle); got != want {
+			t.Errorf("IsValid(%q): got %t, want %t", lifecycle, got, want)
+		}
+	}
+}
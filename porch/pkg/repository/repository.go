@@ -16,17 +16,92 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"sync"
 
 	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
 )
 
 // TODO: 	"sigs.k8s.io/kustomize/kyaml/filesys" FileSystem?
+//
+// PackageResources passed between mutations may share their Contents map with the resources the
+// mutation was given, so the map must be treated as read-only; changes are made to a copy.
 type PackageResources struct {
 	Contents map[string]string
 }
 
+// Sorted returns the contents of the package as files ordered by path.
+func (r PackageResources) Sorted() []ResourceFile {
+	return SortedResources(r.Contents)
+}
+
+// ResourceFile is a file of a package: its path relative to the package root and its contents.
+type ResourceFile struct {
+	Path    string
+	Content string
+}
+
+// SortedResources returns the resources, such as the PackageRevisionResources returned by
+// GetResources, as files ordered by path. Clients which compare or print resources use it
+// for a deterministic order, which iterating the map does not provide.
+func SortedResources(resources map[string]string) []ResourceFile {
+	files := make([]ResourceFile, 0, len(resources))
+	for path, content := range resources {
+		files = append(files, ResourceFile{Path: path, Content: content})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// ContentHash returns a hash over the sorted file paths and contents of the resources. Resources
+// with identical files have the same hash, regardless of the package revision they belong to.
+func ContentHash(resources map[string]string) string {
+	h := sha256.New()
+	var size [8]byte
+	write := func(s string) {
+		// Length prefixes keep the boundaries between paths and contents unambiguous.
+		binary.BigEndian.PutUint64(size[:], uint64(len(s)))
+		h.Write(size[:])
+		h.Write([]byte(s))
+	}
+	for _, f := range SortedResources(resources) {
+		write(f.Path)
+		write(f.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ContentHashCache memoizes the content hash of a package revision. Package revisions do not
+// change once loaded; updates produce new package revisions, which replace the old ones in the
+// cache along with their hashes. The zero value is ready to use.
+type ContentHashCache struct {
+	mutex sync.Mutex
+	hash  string
+}
+
+// Get returns the content hash of the package revision, computing it on first use.
+func (c *ContentHashCache) Get(ctx context.Context, pr PackageRevision) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.hash != "" {
+		return c.hash, nil
+	}
+	resources, err := pr.GetResources(ctx)
+	if err != nil {
+		return "", fmt.Errorf("cannot compute content hash of %q: %w", pr.KubeObjectName(), err)
+	}
+	c.hash = ContentHash(resources.Spec.Resources)
+	return c.hash, nil
+}
+
 type PackageRevisionKey struct {
 	Repository, Package, Revision string
 }
@@ -53,10 +128,18 @@ type PackageRevision interface {
 	// GetPackageRevision returns the PackageRevision ("DRY") API representation of this package-revision
 	GetPackageRevision() *v1alpha1.PackageRevision
 
+	// Tasks returns the tasks which produced the package-revision, in the order they were applied,
+	// including the patch tasks recorded by resource updates.
+	Tasks() []v1alpha1.Task
+
 	// GetResources returns the PackageRevisionResources ("WET") API representation of this package-revision
 	// TODO: return PackageResources or filesystem abstraction?
 	GetResources(ctx context.Context) (*v1alpha1.PackageRevisionResources, error)
 
+	// ContentHash returns a stable hash of the resources of the package-revision; see ContentHash.
+	// Package-revisions with identical resources have the same hash.
+	ContentHash(ctx context.Context) (string, error)
+
 	// GetUpstreamLock returns the kpt lock information.
 	GetUpstreamLock() (kptfile.Upstream, kptfile.UpstreamLock, error)
 }
@@ -73,6 +156,29 @@ type PackageDraft interface {
 type Function interface {
 	Name() string
 	GetFunction() (*v1alpha1.Function, error)
+	// FunctionTypes returns the types of the function; functions of no known type are
+	// v1alpha1.FunctionTypeUnspecified.
+	FunctionTypes() []v1alpha1.FunctionType
+}
+
+// ListFunctionFilter is a predicate for filtering Function objects;
+// only matching Function objects will be returned.
+type ListFunctionFilter struct {
+	// FunctionType matches functions of the type, such as mutators or validators.
+	FunctionType v1alpha1.FunctionType
+}
+
+// Matches returns true if the provided Function satisfies the conditions in the filter.
+func (f *ListFunctionFilter) Matches(fn Function) bool {
+	if f.FunctionType == "" {
+		return true
+	}
+	for _, t := range fn.FunctionTypes() {
+		if t == f.FunctionType {
+			return true
+		}
+	}
+	return false
 }
 
 // ListPackageRevisionFilter is a predicate for filtering PackageRevision objects;
@@ -86,8 +192,20 @@ type ListPackageRevisionFilter struct {
 
 	// Revision matches the revision of the package (spec.revision)
 	Revision string
+
+	// SortDirection is the order of the listed package revisions, by package and revision;
+	// defaults to ascending.
+	SortDirection SortDirection
 }
 
+// SortDirection is the order in which package revisions are listed.
+type SortDirection string
+
+const (
+	SortAscending  SortDirection = ""
+	SortDescending SortDirection = "descending"
+)
+
 // Matches returns true if the provided PackageRevision satisifies the conditions in the filter.
 func (f *ListPackageRevisionFilter) Matches(p PackageRevision) bool {
 	if f.Package != "" && f.Package != p.Key().Package {
@@ -117,6 +235,13 @@ type Repository interface {
 	UpdatePackage(ctx context.Context, old PackageRevision) (PackageDraft, error)
 }
 
+// ResourcesBatchReader is implemented by repositories which can read the resources of several of
+// their package revisions together, sharing the work of reading each.
+type ResourcesBatchReader interface {
+	// GetResourcesBatch returns the resources of the package revisions, keyed by KubeObjectName.
+	GetResourcesBatch(ctx context.Context, revisions []PackageRevision) (map[string]*v1alpha1.PackageRevisionResources, error)
+}
+
 type FunctionRepository interface {
 	// TODO: Should repository understand functions, or just packages (and function is just a package in an OCI repo?)
 	ListFunctions(ctx context.Context) ([]Function, error)
@@ -140,6 +265,29 @@ type UserInfo struct {
 	Email string
 }
 
+// CommitInfo describes the commits made by package operations, for auditing.
+type CommitInfo struct {
+	// Message is the commit message. If empty, the repository uses its default message.
+	Message string
+	// Author is the author of the commits. If nil, the author is the user on whose behalf the
+	// request is being processed, as reported by the UserInfoProvider.
+	Author *UserInfo
+}
+
+type commitInfoKey struct{}
+
+// WithCommitInfo returns a context which carries the commit info to the repository operations
+// made with it, including closing package drafts.
+func WithCommitInfo(ctx context.Context, info CommitInfo) context.Context {
+	return context.WithValue(ctx, commitInfoKey{}, info)
+}
+
+// CommitInfoFromContext returns the commit info carried by the context, if any.
+func CommitInfoFromContext(ctx context.Context) CommitInfo {
+	info, _ := ctx.Value(commitInfoKey{}).(CommitInfo)
+	return info
+}
+
 // UserInfoProvider providers name of the authenticated user on whose behalf the request
 // is being processed.
 type UserInfoProvider interface {
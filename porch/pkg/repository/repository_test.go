@@ -0,0 +1,133 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSortedResources(t *testing.T) {
+	resources := PackageResources{
+		Contents: map[string]string{
+			"service.yaml":        "service",
+			"Kptfile":             "kptfile",
+			"sub/Kptfile":         "sub kptfile",
+			"deployment.yaml":     "deployment",
+			"sub/deployment.yaml": "sub deployment",
+		},
+	}
+	want := []ResourceFile{
+		{Path: "Kptfile", Content: "kptfile"},
+		{Path: "deployment.yaml", Content: "deployment"},
+		{Path: "service.yaml", Content: "service"},
+		{Path: "sub/Kptfile", Content: "sub kptfile"},
+		{Path: "sub/deployment.yaml", Content: "sub deployment"},
+	}
+	if diff := cmp.Diff(want, resources.Sorted()); diff != "" {
+		t.Errorf("Unexpected files (-want, +got): %s", diff)
+	}
+	if got := SortedResources(nil); len(got) != 0 {
+		t.Errorf("SortedResources(nil) returned %d files; want 0", len(got))
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	resources := map[string]string{
+		"Kptfile":         "kptfile",
+		"deployment.yaml": "deployment",
+	}
+	hash := ContentHash(resources)
+	if got := ContentHash(map[string]string{"deployment.yaml": "deployment", "Kptfile": "kptfile"}); got != hash {
+		t.Errorf("ContentHash of identical resources differs: %q, %q", hash, got)
+	}
+	for name, other := range map[string]map[string]string{
+		"changed content":     {"Kptfile": "kptfile", "deployment.yaml": "deployment v2"},
+		"renamed file":        {"Kptfile": "kptfile", "deploy.yaml": "deployment"},
+		"moved boundary":      {"Kptfile": "kptfiled", "eployment.yaml": "deployment"},
+		"fewer files":         {"Kptfile": "kptfile"},
+		"path of empty file":  {"Kptfile": "kptfile", "deployment.yaml": "deployment", "empty": ""},
+		"content in the path": {"Kptfilekptfile": "", "deployment.yaml": "deployment"},
+	} {
+		if got := ContentHash(other); got == hash {
+			t.Errorf("ContentHash with %s is the same as the original", name)
+		}
+	}
+}
+
+type countingPackageRevision struct {
+	PackageRevision
+	resources map[string]string
+	loads     int
+}
+
+func (pr *countingPackageRevision) GetResources(context.Context) (*v1alpha1.PackageRevisionResources, error) {
+	pr.loads++
+	return &v1alpha1.PackageRevisionResources{
+		Spec: v1alpha1.PackageRevisionResourcesSpec{Resources: pr.resources},
+	}, nil
+}
+
+func TestContentHashCache(t *testing.T) {
+	pr := &countingPackageRevision{resources: map[string]string{"Kptfile": "kptfile"}}
+	var cache ContentHashCache
+	for i := 0; i < 3; i++ {
+		got, err := cache.Get(context.Background(), pr)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if want := ContentHash(pr.resources); got != want {
+			t.Errorf("Get returned %q; want %q", got, want)
+		}
+	}
+	if pr.loads != 1 {
+		t.Errorf("Resources were loaded %d times; want once", pr.loads)
+	}
+}
+
+type typedFunction struct {
+	Function
+	types []v1alpha1.FunctionType
+}
+
+func (f *typedFunction) FunctionTypes() []v1alpha1.FunctionType {
+	return f.types
+}
+
+func TestListFunctionFilter(t *testing.T) {
+	mutator := &typedFunction{types: []v1alpha1.FunctionType{v1alpha1.FunctionTypeMutator}}
+	both := &typedFunction{types: []v1alpha1.FunctionType{v1alpha1.FunctionTypeMutator, v1alpha1.FunctionTypeValidator}}
+	unspecified := &typedFunction{types: []v1alpha1.FunctionType{v1alpha1.FunctionTypeUnspecified}}
+
+	for _, tc := range []struct {
+		functionType v1alpha1.FunctionType
+		want         []bool
+	}{
+		{"", []bool{true, true, true}},
+		{v1alpha1.FunctionTypeMutator, []bool{true, true, false}},
+		{v1alpha1.FunctionTypeValidator, []bool{false, true, false}},
+		{v1alpha1.FunctionTypeUnspecified, []bool{false, false, true}},
+	} {
+		filter := ListFunctionFilter{FunctionType: tc.functionType}
+		for i, fn := range []Function{mutator, both, unspecified} {
+			if got := filter.Matches(fn); got != tc.want[i] {
+				t.Errorf("Filter %q matches function of types %v: got %t, want %t", tc.functionType, fn.FunctionTypes(), got, tc.want[i])
+			}
+		}
+	}
+}